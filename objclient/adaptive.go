@@ -0,0 +1,161 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions are the options of NewAdaptiveConcurrency, zero fields are
+// the defaults.
+type AdaptiveOptions struct {
+	// Min and Max are the bounds of the limit, default to 1 and 64. Initial
+	// is the limit at the start, defaults to 4.
+	Min     int
+	Max     int
+	Initial int
+	// LatencyTarget is the latency of healthy requests, the limit isn't
+	// raised by slower ones. The latency isn't checked if it's 0.
+	LatencyTarget time.Duration
+	// Decrease is the factor of the limit after requests are throttled,
+	// defaults to 0.5.
+	Decrease float64
+}
+
+// AdaptiveConcurrency limits the requests in flight of the parallel
+// transfers by AIMD: the limit is raised by 1 after a limit of requests
+// succeed in LatencyTarget while it's fully used, and decreased by the
+// factor of Decrease after a request is throttled by SlowDown, 503 or
+// similar errors of the backend. The requests throttled together, which are
+// started before the last decrease, decrease it once. It can be shared by
+// transfers of the same backend. A nil controller doesn't limit requests.
+type AdaptiveConcurrency struct {
+	opts AdaptiveOptions
+
+	mutex    sync.Mutex
+	limit    float64
+	inFlight int
+	// wake is closed when requests finish, and replaced by a new one.
+	wake        chan struct{}
+	lastBackoff time.Time
+}
+
+// NewAdaptiveConcurrency returns a controller of the options, which can be
+// nil.
+func NewAdaptiveConcurrency(opts *AdaptiveOptions) *AdaptiveConcurrency {
+	o := AdaptiveOptions{Min: 1, Max: 64, Initial: 4, Decrease: 0.5}
+	if opts != nil {
+		if opts.Min > 0 {
+			o.Min = opts.Min
+		}
+		if opts.Max > 0 {
+			o.Max = opts.Max
+		}
+		if opts.Initial > 0 {
+			o.Initial = opts.Initial
+		}
+		if opts.Decrease > 0 && opts.Decrease < 1 {
+			o.Decrease = opts.Decrease
+		}
+		o.LatencyTarget = opts.LatencyTarget
+	}
+	o.Max = max(o.Max, o.Min)
+	o.Initial = min(max(o.Initial, o.Min), o.Max)
+	return &AdaptiveConcurrency{opts: o, limit: float64(o.Initial), wake: make(chan struct{})}
+}
+
+// Limit returns the current limit of requests in flight.
+func (c *AdaptiveConcurrency) Limit() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return int(c.limit)
+}
+
+// Acquire waits until a request can be sent under the limit. The returned
+// release must be called with the error of the request after it finishes.
+func (c *AdaptiveConcurrency) Acquire(ctx context.Context) (release func(err error), err error) {
+	if c == nil {
+		return func(error) {}, nil
+	}
+	for {
+		c.mutex.Lock()
+		if c.inFlight < int(c.limit) {
+			c.inFlight++
+			c.mutex.Unlock()
+			start := time.Now()
+			var once sync.Once
+			return func(err error) {
+				once.Do(func() { c.release(start, err) })
+			}, nil
+		}
+		wake := c.wake
+		c.mutex.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *AdaptiveConcurrency) release(start time.Time, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	saturated := c.inFlight >= int(c.limit)
+	c.inFlight--
+	switch {
+	case isOverloaded(err):
+		c.backoffLocked(start)
+	case err == nil && saturated && (c.opts.LatencyTarget <= 0 || time.Since(start) <= c.opts.LatencyTarget):
+		c.limit = min(c.limit+1/c.limit, float64(c.opts.Max))
+	}
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// backoff decreases the limit for a throttled request started at start,
+// which is sent without Acquire.
+func (c *AdaptiveConcurrency) backoff(start time.Time) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.backoffLocked(start)
+}
+
+func (c *AdaptiveConcurrency) backoffLocked(start time.Time) {
+	if start.Before(c.lastBackoff) {
+		return
+	}
+	c.limit = max(c.limit*c.opts.Decrease, float64(c.opts.Min))
+	c.lastBackoff = time.Now()
+}
+
+// isOverloaded returns whether the request failed with err is rejected by
+// the overloaded backend, including the keys of a *RemoveError.
+func isOverloaded(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rerr *RemoveError
+	if errors.As(err, &rerr) {
+		for _, result := range rerr.Results {
+			if isOverloaded(result.Err) {
+				return true
+			}
+		}
+		return false
+	}
+	if IsThrottle(err) {
+		return true
+	}
+	_, status, ok := backendError(err)
+	return ok && status == http.StatusServiceUnavailable
+}
@@ -0,0 +1,95 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	ctx := context.Background()
+	c := NewAdaptiveConcurrency(&AdaptiveOptions{Min: 2, Max: 5, Initial: 2})
+	acquire := func() func(error) {
+		release, err := c.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+		return release
+	}
+
+	// The limit is raised by the healthy requests using it fully.
+	for i := 0; i < 30; i++ {
+		var releases []func(error)
+		for j := 0; j < c.Limit(); j++ {
+			releases = append(releases, acquire())
+		}
+		for _, release := range releases {
+			release(nil)
+		}
+	}
+	if c.Limit() != 5 {
+		t.Fatalf("invalid limit over max %v", c.Limit())
+	}
+
+	// The requests throttled together decrease it once.
+	slowDown := minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}
+	releases := []func(error){acquire(), acquire(), acquire()}
+	for _, release := range releases {
+		release(slowDown)
+	}
+	if c.Limit() != 2 {
+		t.Fatalf("invalid limit after throttles %v", c.Limit())
+	}
+	acquire()(&RemoveError{Results: []RemoveResult{{Key: "a", Err: slowDown}}})
+	if c.Limit() != 2 {
+		t.Fatalf("invalid limit under min %v", c.Limit())
+	}
+
+	// The requests wait under the limit.
+	first, second := acquire(), acquire()
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Acquire(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded: %v", err)
+	}
+	go first(errors.New("failed"))
+	acquire()(nil)
+	second(nil)
+}
+
+func TestAdaptiveConcurrencyLatency(t *testing.T) {
+	c := NewAdaptiveConcurrency(&AdaptiveOptions{Initial: 1, LatencyTarget: time.Millisecond})
+	release, err := c.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	release(nil)
+	if c.Limit() != 1 {
+		t.Fatalf("limit is raised by slow request: %v", c.Limit())
+	}
+}
+
+func TestReadParallelAdaptive(t *testing.T) {
+	mem := newMemClient()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	mem.put("a", string(data))
+	controller := NewAdaptiveConcurrency(&AdaptiveOptions{Initial: 1})
+
+	var buffer bytes.Buffer
+	o := &ParallelOptions{PartSize: 10, Concurrency: 8, Controller: controller}
+	if _, err := ReadParallel(context.Background(), mem, "a", &buffer, o); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if !bytes.Equal(buffer.Bytes(), data) {
+		t.Fatalf("invalid data")
+	}
+	if controller.Limit() <= 1 {
+		t.Fatalf("limit isn't raised: %v", controller.Limit())
+	}
+}
@@ -0,0 +1,260 @@
+package objclient
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExtractConcurrency = 4
+	defaultExtractBufferSize  = 8 << 20
+)
+
+// ErrArchiveTooLarge is returned by ExtractArchive when the entries exceed
+// MaxSize.
+var ErrArchiveTooLarge = errors.New("archive too large")
+
+// ModTimeMetadata is the metadata key of the RFC 3339 modification times
+// of the entries extracted by ExtractArchive.
+const ModTimeMetadata = "mtime"
+
+type ArchiveFormat int
+
+const (
+	ArchiveTar ArchiveFormat = iota
+	ArchiveTarGzip
+	// ArchiveZip is spooled to a temporary file, unless the reader is an
+	// io.ReaderAt and io.Seeker, e.g. *os.File.
+	ArchiveZip
+)
+
+// ExtractOptions are the options of ExtractArchive, zero fields are the
+// defaults.
+type ExtractOptions struct {
+	// Concurrency is the number of entries written at the same time,
+	// defaults to 4. The entries up to BufferSize are buffered for it, which
+	// defaults to 8MiB, and the larger ones are written from the archive
+	// directly.
+	Concurrency int
+	BufferSize  int64
+	// MaxSize limits the total size of the entries, the extraction fails
+	// with ErrArchiveTooLarge before writing the entry exceeding it. It's
+	// unlimited if 0.
+	MaxSize int64
+}
+
+// ExtractResult is the result of ExtractArchive.
+type ExtractResult struct {
+	// Objects and Bytes are the entries written.
+	Objects int
+	Bytes   int64
+	// Skipped is the number of entries which aren't regular files, e.g.
+	// directories and links.
+	Skipped int
+}
+
+// ExtractArchive writes the regular files of the archive read from r to
+// the keys of their paths under dstPrefix, and their modification times to
+// ModTimeMetadata. The paths are rejected with ErrInvalidKey if they can't
+// be used as keys by ValidateKey, e.g. with ".." segments. The options can
+// be nil. The entries written before a failure are kept.
+func ExtractArchive(ctx context.Context, client Client, r io.Reader, format ArchiveFormat, dstPrefix string, opts *ExtractOptions) (*ExtractResult, error) {
+	e := &extractor{client: client, prefix: dstPrefix, result: &ExtractResult{}}
+	e.concurrency, e.bufferSize = defaultExtractConcurrency, int64(defaultExtractBufferSize)
+	if opts != nil {
+		if opts.Concurrency > 0 {
+			e.concurrency = opts.Concurrency
+		}
+		if opts.BufferSize > 0 {
+			e.bufferSize = opts.BufferSize
+		}
+		e.maxSize = opts.MaxSize
+	}
+	e.sem = make(chan struct{}, e.concurrency)
+	e.ctx, e.cancel = context.WithCancelCause(ctx)
+	defer e.cancel(nil)
+
+	var err error
+	switch format {
+	case ArchiveTar:
+		err = e.extractTar(r)
+	case ArchiveTarGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(r); err == nil {
+			err = e.extractTar(zr)
+		}
+	case ArchiveZip:
+		err = e.extractZip(r)
+	default:
+		err = fmt.Errorf("unknown archive format %v", format)
+	}
+	if err != nil {
+		e.fail(err)
+	}
+	e.wg.Wait()
+
+	if err := context.Cause(e.ctx); err != nil {
+		return e.result, err
+	}
+	return e.result, nil
+}
+
+type extractor struct {
+	client      Client
+	prefix      string
+	concurrency int
+	bufferSize  int64
+	maxSize     int64
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	// size is the total size of the entries, which is only accessed by the
+	// goroutine reading the archive.
+	size int64
+
+	mutex  sync.Mutex
+	result *ExtractResult
+}
+
+// fail stops the extraction by the first error.
+func (e *extractor) fail(err error) {
+	e.cancel(err)
+}
+
+func (e *extractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			e.skip()
+			continue
+		}
+		if err := e.extract(header.Name, header.Size, header.ModTime, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *extractor) extractZip(r io.Reader) error {
+	ra, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	var size int64
+	if ok {
+		var err error
+		if size, err = ra.Seek(0, io.SeekEnd); err != nil {
+			return fmt.Errorf("failed to get size of zip: %w", err)
+		}
+	} else {
+		file, err := os.CreateTemp("", "objclient-zip-*")
+		if err != nil {
+			return fmt.Errorf("failed to create spool file: %w", err)
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		if size, err = copyBuffer(file, readerWithContext(e.ctx, r)); err != nil {
+			return fmt.Errorf("failed to spool zip: %w", err)
+		}
+		ra = file
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip: %w", err)
+	}
+	for _, file := range zr.File {
+		if !file.Mode().IsRegular() {
+			e.skip()
+			continue
+		}
+		fr, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %v of zip: %w", file.Name, err)
+		}
+		err = e.extract(file.Name, int64(file.UncompressedSize64), file.Modified, fr)
+		fr.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) skip() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.result.Skipped++
+}
+
+// extract writes the entry read from r. The entries up to the buffer size
+// are written in background, the larger ones before it returns.
+func (e *extractor) extract(name string, size int64, modTime time.Time, r io.Reader) error {
+	key := e.prefix + strings.TrimPrefix(name, "./")
+	if err := ValidateKey(key); err != nil {
+		return fmt.Errorf("invalid path %q of archive: %w", name, err)
+	}
+	e.size += size
+	if e.maxSize > 0 && e.size > e.maxSize {
+		return fmt.Errorf("%w: entries over %v bytes", ErrArchiveTooLarge, e.maxSize)
+	}
+	o := &WriteOptions{Size: size}
+	if !modTime.IsZero() {
+		o.Metadata = map[string]string{ModTimeMetadata: modTime.UTC().Format(time.RFC3339)}
+	}
+
+	select {
+	case e.sem <- struct{}{}:
+	case <-e.ctx.Done():
+		return context.Cause(e.ctx)
+	}
+	if size > e.bufferSize {
+		defer func() { <-e.sem }()
+		return e.write(key, readerWithContext(e.ctx, r), o)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		<-e.sem
+		return fmt.Errorf("failed to read %v of archive: %w", name, err)
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer func() { <-e.sem }()
+		if err := e.write(key, bytes.NewReader(data), o); err != nil {
+			e.fail(err)
+		}
+	}()
+	return nil
+}
+
+func (e *extractor) write(key string, r io.Reader, o *WriteOptions) error {
+	if err := e.client.Write(e.ctx, key, r, o); err != nil {
+		return fmt.Errorf("failed to write %v: %w", key, err)
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.result.Objects++
+	e.result.Bytes += o.Size
+	return nil
+}
@@ -0,0 +1,65 @@
+package objclient
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestExtractArchive(t *testing.T) {
+	ctx := context.Background()
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range []struct {
+		name, data string
+		flag       byte
+	}{
+		{"./a/", "", tar.TypeDir},
+		{"./a/small", "small", tar.TypeReg},
+		{"./a/large", "large entry", tar.TypeReg},
+		{"./a/link", "", tar.TypeSymlink},
+	} {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.flag, Size: int64(len(entry.data)), Mode: 0644, ModTime: modTime, Linkname: "small"}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write tar: %v", err)
+		}
+		tw.Write([]byte(entry.data))
+	}
+	tw.Close()
+	archive := buf.Bytes()
+
+	mem := newMemClient()
+	result, err := ExtractArchive(ctx, mem, bytes.NewReader(archive), ArchiveTar, "dst/", &ExtractOptions{BufferSize: 8})
+	if err != nil || *result != (ExtractResult{Objects: 2, Bytes: 16, Skipped: 2}) {
+		t.Fatalf("failed to extract tar: %+v, %v", result, err)
+	}
+	if obj := mem.objects["dst/a/large"]; string(obj.data) != "large entry" || obj.metadata[ModTimeMetadata] != "2024-01-02T03:04:05Z" {
+		t.Fatalf("invalid extracted object: %q, %v", obj.data, obj.metadata)
+	}
+
+	if _, err := ExtractArchive(ctx, newMemClient(), bytes.NewReader(archive), ArchiveTar, "dst/", &ExtractOptions{MaxSize: 10}); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("archive over max size is extracted: %v", err)
+	}
+
+	buf.Reset()
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "b/c", Modified: modTime, Method: zip.Deflate})
+	w.Write([]byte("zipped"))
+	zw.Create("../escape")
+	zw.Close()
+	// The zip isn't seekable, so it's spooled.
+	mem = newMemClient()
+	zr := io.MultiReader(bytes.NewReader(buf.Bytes()))
+	if _, err := ExtractArchive(ctx, mem, zr, ArchiveZip, "dst/", nil); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("path out of the prefix is extracted: %v", err)
+	}
+	if obj := mem.objects["dst/b/c"]; string(obj.data) != "zipped" {
+		t.Fatalf("invalid extracted object of zip: %q", obj.data)
+	}
+}
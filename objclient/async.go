@@ -0,0 +1,389 @@
+package objclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type AsyncWriterOptions struct {
+	// Workers is the number of concurrent uploads, defaults to 4.
+	Workers int
+	// MaxRetries of a failed upload, defaults to 5.
+	MaxRetries int
+	// RetryDelay is doubled after each retry, defaults to 1 second.
+	RetryDelay time.Duration
+	// OnFailure is called when an upload failed after all the retries.
+	// The spooled data is removed.
+	OnFailure func(key string, err error)
+}
+
+type asyncJob struct {
+	Key         string            `json:"key"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Expires     time.Time         `json:"expires,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Header      http.Header       `json:"header,omitempty"`
+
+	path string
+	// seq is the sequence of the write, and first is the one of the
+	// earliest pending write of the key replaced by it.
+	seq, first int64
+	// creds are the credentials of ContextWithCredentials of the write,
+	// which aren't spooled.
+	creds *Credentials
+}
+
+// AsyncWriter writes objects in the background. Writes are spooled to a
+// local dir and return immediately, then uploaded with retries. Spooled
+// writes which are not uploaded when the writer is closed are uploaded by
+// the next writer of the same dir.
+//
+// Writes to the same key are uploaded in order, and only the latest one of
+// pending writes is uploaded. Reads through the client don't see pending
+// writes. The writes are uploaded by the credentials of ContextWithCredentials
+// of them, but the credentials aren't spooled for the next writer.
+type AsyncWriter struct {
+	inner Client
+	dir   string
+	opts  AsyncWriterOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	seq    atomic.Int64
+	wg     sync.WaitGroup
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	queue    []string
+	pending  map[string]*asyncJob
+	inflight map[string]*asyncJob
+	closed   bool
+}
+
+func NewAsyncWriter(inner Client, dir string, opts *AsyncWriterOptions) (*AsyncWriter, error) {
+	var o AsyncWriterOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 5
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = time.Second
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %w", err)
+	}
+
+	w := &AsyncWriter{
+		inner:    inner,
+		dir:      dir,
+		opts:     o,
+		pending:  make(map[string]*asyncJob),
+		inflight: make(map[string]*asyncJob),
+	}
+	w.cond = sync.NewCond(&w.mutex)
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.seq.Store(time.Now().UnixNano())
+
+	if err := w.recover(); err != nil {
+		return nil, fmt.Errorf("failed to recover spooled writes: %w", err)
+	}
+
+	for i := 0; i < o.Workers; i++ {
+		w.wg.Add(1)
+		go w.worker()
+	}
+
+	return w, nil
+}
+
+// recover queues the writes spooled by the previous writer.
+func (w *AsyncWriter) recover() error {
+	files, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+
+	// Names are ordered by the sequence of writes.
+	var names []string
+	for _, file := range files {
+		name := file.Name()
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			names = append(names, strings.TrimSuffix(name, ".json"))
+		case strings.HasSuffix(name, ".tmp"):
+			os.Remove(filepath.Join(w.dir, name))
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(w.dir, name)
+		data, err := os.ReadFile(path + ".json")
+		if err != nil {
+			return err
+		}
+		var job asyncJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			return fmt.Errorf("invalid spooled write %v: %w", name, err)
+		}
+		job.path = path
+		job.seq, _ = strconv.ParseInt(name, 10, 64)
+		w.add(&job)
+	}
+
+	return nil
+}
+
+// Write spools the content of r, and returns before it's uploaded. Size,
+// Progress and the conditions of the options are ignored.
+func (w *AsyncWriter) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	job := &asyncJob{Key: key, seq: w.seq.Add(1), creds: tenantCredentials(ctx)}
+	if o != nil {
+		job.Metadata = o.Metadata
+		job.Expires = o.Expires
+		job.ContentType = o.ContentType
+		job.Header = o.Header
+	}
+
+	job.path = filepath.Join(w.dir, fmt.Sprintf("%020d", job.seq))
+	if err := w.spool(ctx, job, r); err != nil {
+		return fmt.Errorf("failed to spool %v: %w", key, err)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		// It will be uploaded by the next writer.
+		return nil
+	}
+	w.add(job)
+
+	return nil
+}
+
+func (w *AsyncWriter) spool(ctx context.Context, job *asyncJob, r io.Reader) error {
+	tmp, err := os.CreateTemp(w.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := copyBuffer(tmp, readerWithContext(ctx, r)); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), job.path); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(job.path+".json", data, 0o600); err != nil {
+		os.Remove(job.path)
+		return err
+	}
+
+	return nil
+}
+
+// add should be called with the mutex locked.
+func (w *AsyncWriter) add(job *asyncJob) {
+	job.first = job.seq
+	if old, ok := w.pending[job.Key]; ok {
+		// Not started yet, it's replaced by the latest one.
+		old.remove()
+		job.first = old.first
+	} else if w.inflight[job.Key] == nil {
+		w.queue = append(w.queue, job.Key)
+	}
+	w.pending[job.Key] = job
+	w.cond.Broadcast()
+}
+
+func (job *asyncJob) remove() {
+	os.Remove(job.path + ".json")
+	os.Remove(job.path)
+}
+
+func (w *AsyncWriter) worker() {
+	defer w.wg.Done()
+
+	for {
+		w.mutex.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if w.closed {
+			w.mutex.Unlock()
+			return
+		}
+		key := w.queue[0]
+		w.queue = w.queue[1:]
+		job := w.pending[key]
+		delete(w.pending, key)
+		w.inflight[key] = job
+		w.mutex.Unlock()
+
+		err := w.upload(job)
+		// If closed, the job is kept for the next writer.
+		kept := err != nil && w.ctx.Err() != nil
+		if !kept {
+			job.remove()
+			if err != nil && w.opts.OnFailure != nil {
+				w.opts.OnFailure(key, err)
+			}
+		}
+
+		w.mutex.Lock()
+		delete(w.inflight, key)
+		if _, ok := w.pending[key]; !ok && kept {
+			w.pending[key] = job
+		}
+		if _, ok := w.pending[key]; ok {
+			w.queue = append(w.queue, key)
+		}
+		w.cond.Broadcast()
+		w.mutex.Unlock()
+	}
+}
+
+func (w *AsyncWriter) upload(job *asyncJob) error {
+	delay := w.opts.RetryDelay
+
+	var err error
+	for i := 0; i <= w.opts.MaxRetries; i++ {
+		if i > 0 && !sleepContext(w.ctx, delay) {
+			return w.ctx.Err()
+		}
+		delay *= 2
+
+		err = w.uploadOnce(job)
+		if err == nil || w.ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (w *AsyncWriter) uploadOnce(job *asyncJob) error {
+	f, err := os.Open(job.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	opts := &WriteOptions{
+		Size:        stat.Size(),
+		Metadata:    job.Metadata,
+		Expires:     job.Expires,
+		ContentType: job.ContentType,
+		Header:      job.Header,
+	}
+	return w.inner.Write(credentialsContext(w.ctx, job.creds), job.Key, f, opts)
+}
+
+// Pending returns the number of writes not uploaded yet.
+func (w *AsyncWriter) Pending() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.pending) + len(w.inflight)
+}
+
+// Flush waits until all the writes made before are uploaded or failed. It
+// fails if the writer is closed with the writes pending, which are left for
+// the next writer.
+func (w *AsyncWriter) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		w.mutex.Lock()
+		w.cond.Broadcast()
+		w.mutex.Unlock()
+	})
+	defer stop()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	seq := w.seq.Load()
+	for w.unflushed(seq) {
+		if w.closed {
+			return errors.New("async writer is closed")
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.cond.Wait()
+	}
+	return nil
+}
+
+// unflushed returns whether any write not later than seq is pending, it
+// should be called with the mutex locked.
+func (w *AsyncWriter) unflushed(seq int64) bool {
+	for _, jobs := range []map[string]*asyncJob{w.pending, w.inflight} {
+		for _, job := range jobs {
+			if job.first <= seq {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close stops uploading, and waits for the workers to exit. Writes not
+// uploaded are left in the spool dir.
+func (w *AsyncWriter) Close() error {
+	w.mutex.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mutex.Unlock()
+
+	w.cancel()
+	w.wg.Wait()
+	return nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func readerWithContext(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx, r}
+}
+
+func (reader *contextReader) Read(data []byte) (int, error) {
+	if err := reader.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return reader.r.Read(data)
+}
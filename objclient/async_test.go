@@ -0,0 +1,202 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	dir := t.TempDir()
+	mem := newMemClient()
+
+	var (
+		mutex  sync.Mutex
+		failed []string
+	)
+	opts := &AsyncWriterOptions{
+		MaxRetries: 1,
+		RetryDelay: time.Millisecond,
+		OnFailure: func(key string, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			failed = append(failed, key)
+		},
+	}
+	w, err := NewAsyncWriter(mem, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"v1", "v2", "v3"} {
+		err := w.Write(ctx, "objclient/test", strings.NewReader(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, mem, "objclient/test"); data != "v3" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	mem.fail = func(op, key string) error {
+		if op == "Write" && key == "objclient/fail" {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+	if err := w.Write(ctx, "objclient/fail", strings.NewReader("demo"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != "objclient/fail" {
+		t.Fatalf("invalid failed keys: %v", failed)
+	}
+	w.Close()
+
+	// Writes not uploaded are recovered by the next writer.
+	mem.fail = func(op, key string) error {
+		return errors.New("write failed")
+	}
+	w, err = NewAsyncWriter(mem, dir, &AsyncWriterOptions{RetryDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(ctx, "objclient/recover", strings.NewReader("demo"), nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	w.Close()
+
+	mem.fail = nil
+	w, err = NewAsyncWriter(mem, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, mem, "objclient/recover"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	mem := newMemClient()
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	mem.fail = func(op, key string) error {
+		if op == "Write" {
+			started <- key
+			<-release
+		}
+		if op == "Write" && key == "objclient/second" {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+	w, err := NewAsyncWriter(mem, t.TempDir(), &AsyncWriterOptions{RetryDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Write(ctx, "objclient/first", strings.NewReader("1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Flush(ctx)
+	}()
+	// The writes after Flush is called aren't waited.
+	time.Sleep(10 * time.Millisecond)
+	if err := w.Write(ctx, "objclient/second", strings.NewReader("2"), nil); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	release <- struct{}{}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("flush waits for the later write")
+	}
+
+	// The writes left by Close fail Flush.
+	go func() {
+		done <- w.Flush(ctx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	w.Close()
+	if err := <-done; err == nil {
+		t.Fatalf("flush of closed writer succeeded")
+	}
+	if err := w.Flush(ctx); err == nil {
+		t.Fatalf("flush of closed writer succeeded")
+	}
+}
+
+// optionsClient records the options of the writes.
+type optionsClient struct {
+	*memClient
+	mutex sync.Mutex
+	opts  map[string]*WriteOptions
+}
+
+func (client *optionsClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *optionsClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	client.mutex.Lock()
+	client.opts[key] = o
+	client.mutex.Unlock()
+	return client.memClient.WriteWithResult(ctx, key, r, o)
+}
+
+func TestAsyncWriterSpoolOptions(t *testing.T) {
+	dir := t.TempDir()
+	mem := newMemClient()
+	mem.fail = func(op, key string) error {
+		return errors.New("write failed")
+	}
+	w, err := NewAsyncWriter(mem, dir, &AsyncWriterOptions{RetryDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &WriteOptions{ContentType: "text/csv", Header: http.Header{"Cache-Control": {"no-cache"}}}
+	if err := w.Write(ctx, "objclient/export.csv", strings.NewReader("a,b"), o); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	w.Close()
+
+	// The options are recovered from the spool by the next writer.
+	client := &optionsClient{memClient: newMemClient(), opts: make(map[string]*WriteOptions)}
+	w, err = NewAsyncWriter(client, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := client.opts["objclient/export.csv"]
+	if got == nil || got.ContentType != "text/csv" || got.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("invalid options of spooled write: %+v", got)
+	}
+}
@@ -0,0 +1,263 @@
+package objclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord is the record of a Write, Remove or Copy of AuditClient.
+// Each record has the hash of the previous one, so records removed or
+// changed later break the chain, see VerifyAuditLog.
+type AuditRecord struct {
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor"`
+	Op    string    `json:"op"`
+	Key   string    `json:"key"`
+	// Src is the source of Copy.
+	Src string `json:"src,omitempty"`
+	// Bytes is the size written by Write.
+	Bytes int64  `json:"bytes"`
+	ETag  string `json:"etag,omitempty"`
+	// Error is empty if the operation succeeded.
+	Error string `json:"error,omitempty"`
+	// PrevHash is the Hash of the previous record, empty for the first.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hex SHA-256 of the JSON of the record without it.
+	Hash string `json:"hash"`
+}
+
+func (record *AuditRecord) hash() (string, error) {
+	unsigned := *record
+	unsigned.Hash = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditSink stores the records of AuditClient in order. The records should
+// be appended to storage which can't be changed by the writers of objects.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, record *AuditRecord) error
+}
+
+type jsonAuditSink struct {
+	w io.Writer
+}
+
+// NewJSONAuditSink returns a sink writing the records to w as JSON lines,
+// which are read by VerifyAuditLog.
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{w: w}
+}
+
+func (sink *jsonAuditSink) WriteAudit(ctx context.Context, record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = sink.w.Write(append(data, '\n'))
+	return err
+}
+
+var ErrAuditChainBroken = errors.New("audit chain is broken")
+
+// VerifyAuditLog checks the chain of the JSON lines records of r, whose
+// first record follows prevHash. It returns the Hash of the last record,
+// and an error wrapping ErrAuditChainBroken if a record is changed,
+// removed or reordered.
+func VerifyAuditLog(r io.Reader, prevHash string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return "", fmt.Errorf("failed to parse audit record %v: %w", line, err)
+		}
+		if record.PrevHash != prevHash {
+			return "", fmt.Errorf("%w: record %v doesn't follow the previous one", ErrAuditChainBroken, line)
+		}
+		hash, err := record.hash()
+		if err != nil {
+			return "", err
+		}
+		if hash != record.Hash {
+			return "", fmt.Errorf("%w: record %v is changed", ErrAuditChainBroken, line)
+		}
+		prevHash = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return prevHash, nil
+}
+
+type AuditOptions struct {
+	// Actor returns who runs the operations of ctx. It defaults to the key
+	// ID of ContextWithCredentials if there are credentials of ctx.
+	Actor func(ctx context.Context) string
+	// PrevHash continues the chain of a previous run, i.e. the Hash of the
+	// last record of the sink.
+	PrevHash string
+}
+
+type auditClient struct {
+	inner Client
+	sink  AuditSink
+	actor func(ctx context.Context) string
+
+	// The records are hashed and written in order by mutex.
+	mutex    sync.Mutex
+	prevHash string
+}
+
+// NewAuditClient returns a client of inner recording each Write, Remove and
+// Copy to sink after it's run, whose failures are recorded too. If the
+// record fails to be written the operation returns the error, though it's
+// already done. The reads aren't recorded. The options can be nil.
+func NewAuditClient(inner Client, sink AuditSink, opts *AuditOptions) Client {
+	client := &auditClient{inner: inner, sink: sink, actor: auditActor}
+	if opts != nil {
+		if opts.Actor != nil {
+			client.actor = opts.Actor
+		}
+		client.prevHash = opts.PrevHash
+	}
+	if _, ok := AsPageLister(inner); ok {
+		return &auditPageClient{client}
+	}
+	return client
+}
+
+// auditPageClient is the auditClient of a PageLister, the listings
+// aren't recorded like the other reads.
+type auditPageClient struct {
+	*auditClient
+}
+
+func (client *auditPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func auditActor(ctx context.Context) string {
+	creds, _ := credentialsOf(ctx)
+	return creds.KeyID
+}
+
+// audit writes the records of the operation, and returns err or the error
+// of writing them.
+func (client *auditClient) audit(ctx context.Context, err error, records ...*AuditRecord) error {
+	now, actor := time.Now().UTC(), client.actor(ctx)
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, record := range records {
+		record.Time, record.Actor, record.PrevHash = now, actor, client.prevHash
+		hash, herr := record.hash()
+		if herr == nil {
+			record.Hash = hash
+			herr = client.sink.WriteAudit(ctx, record)
+		}
+		if herr != nil {
+			return errors.Join(err, fmt.Errorf("failed to write audit record of %v: %w", record.Key, herr))
+		}
+		client.prevHash = hash
+	}
+	return err
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (client *auditClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, key)
+}
+
+func (client *auditClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *auditClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *auditClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	// The reader is only wrapped if its size can't be detected, which the
+	// wrapper would hide.
+	size, ok := writeSize(r, o)
+	var counter *countReader
+	if !ok {
+		counter = &countReader{r: r}
+		r = counter
+	}
+	result, err := client.inner.WriteWithResult(ctx, key, r, o)
+	if counter != nil {
+		size = counter.n
+	}
+
+	record := &AuditRecord{Op: "Write", Key: key, Bytes: size, Error: errorString(err)}
+	if result != nil {
+		record.ETag = result.ETag
+	}
+	if err = client.audit(ctx, err, record); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (client *auditClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *auditClient) Remove(ctx context.Context, keys ...string) error {
+	err := client.inner.Remove(ctx, keys...)
+
+	// The errors of RemoveError are recorded by their keys.
+	failed := make(map[string]error)
+	var rerr *RemoveError
+	if errors.As(err, &rerr) {
+		for _, result := range rerr.Results {
+			failed[result.Key] = result.Err
+		}
+	}
+	records := make([]*AuditRecord, 0, len(keys))
+	for _, key := range keys {
+		keyErr := err
+		if rerr != nil {
+			keyErr = failed[key]
+		}
+		records = append(records, &AuditRecord{Op: "Remove", Key: key, Error: errorString(keyErr)})
+	}
+	return client.audit(ctx, err, records...)
+}
+
+func (client *auditClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *auditClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *auditClient) Copy(ctx context.Context, src, dst string) error {
+	err := client.inner.Copy(ctx, src, dst)
+	return client.audit(ctx, err, &AuditRecord{Op: "Copy", Key: dst, Src: src, Error: errorString(err)})
+}
+
+func (client *auditClient) Close() error {
+	return client.inner.Close()
+}
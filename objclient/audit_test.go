@@ -0,0 +1,79 @@
+package objclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAuditClient(t *testing.T) {
+	var buf bytes.Buffer
+	mem := newMemClient()
+	mem.put("a", "demo")
+	client := NewAuditClient(mem, NewJSONAuditSink(&buf), nil)
+
+	tenant := ContextWithCredentials(ctx, Credentials{KeyID: "alice", Key: "secret"})
+	if err := client.Write(tenant, "b", strings.NewReader("new data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Copy(ctx, "missing", "c"); err == nil {
+		t.Fatalf("copy of missing object succeeded")
+	}
+	mem.fail = func(op, key string) error {
+		if op == "Remove" && key == "b" {
+			return errors.New("denied")
+		}
+		return nil
+	}
+	if err := client.Remove(ctx, "a", "b"); err == nil {
+		t.Fatalf("failed removal isn't reported")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var records []AuditRecord
+	for _, line := range lines {
+		var record AuditRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 4 {
+		t.Fatalf("invalid records %q", buf.String())
+	}
+	if r := records[0]; r.Op != "Write" || r.Key != "b" || r.Actor != "alice" || r.Bytes != 8 || r.Error != "" || r.PrevHash != "" {
+		t.Fatalf("invalid write record %+v", r)
+	}
+	if r := records[1]; r.Op != "Copy" || r.Src != "missing" || r.Key != "c" || r.Error == "" {
+		t.Fatalf("invalid copy record %+v", r)
+	}
+	if records[2].Key != "a" || records[2].Error != "" || records[3].Key != "b" || records[3].Error == "" {
+		t.Fatalf("invalid remove records %+v", records[2:])
+	}
+
+	last, err := VerifyAuditLog(strings.NewReader(buf.String()), "")
+	if err != nil || last != records[3].Hash {
+		t.Fatalf("invalid verification %v: %v", last, err)
+	}
+
+	// The chain is continued by PrevHash.
+	client = NewAuditClient(mem, NewJSONAuditSink(&buf), &AuditOptions{PrevHash: last})
+	if err := client.Copy(ctx, "b", "d"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if _, err := VerifyAuditLog(strings.NewReader(buf.String()), ""); err != nil {
+		t.Fatalf("failed to verify continued chain: %v", err)
+	}
+
+	// Changed and removed records break the chain.
+	changed := strings.Replace(buf.String(), `"bytes":8`, `"bytes":9`, 1)
+	if _, err := VerifyAuditLog(strings.NewReader(changed), ""); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("changed record is verified: %v", err)
+	}
+	removed := strings.Join(append(lines[:1:1], lines[2:]...), "\n")
+	if _, err := VerifyAuditLog(strings.NewReader(removed), ""); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("removed record is verified: %v", err)
+	}
+}
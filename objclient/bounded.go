@@ -0,0 +1,166 @@
+package objclient
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+type boundedClient struct {
+	inner   Client
+	total   chan struct{}
+	classes map[OpClass]chan struct{}
+}
+
+// NewBoundedClient limits the operations of inner in flight to maxInFlight,
+// and the ones of each class to classLimits if it's set. Zero or negative
+// limits are unlimited. Operations wait for a slot until the context is
+// done, a Read holds its slot until the reader is closed.
+func NewBoundedClient(inner Client, maxInFlight int, classLimits map[OpClass]int) Client {
+	client := &boundedClient{inner: inner, classes: make(map[OpClass]chan struct{})}
+	if maxInFlight > 0 {
+		client.total = make(chan struct{}, maxInFlight)
+	}
+	for class, limit := range classLimits {
+		if limit > 0 {
+			client.classes[class] = make(chan struct{}, limit)
+		}
+	}
+	if _, ok := AsPageLister(inner); ok {
+		return &boundedPageClient{client}
+	}
+	return client
+}
+
+// boundedPageClient is the boundedClient of a PageLister, the slot of
+// OpList is held for the whole listing, like List.
+type boundedPageClient struct {
+	*boundedClient
+}
+
+func (client *boundedPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	release, err := client.acquire(ctx, OpList)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+// acquire waits for the slots of class, and returns the function to
+// release them.
+func (client *boundedClient) acquire(ctx context.Context, class OpClass) (func(), error) {
+	var acquired []chan struct{}
+	release := func() {
+		for _, tokens := range acquired {
+			<-tokens
+		}
+	}
+
+	for _, tokens := range []chan struct{}{client.classes[class], client.total} {
+		if tokens == nil {
+			continue
+		}
+		select {
+		case tokens <- struct{}{}:
+			acquired = append(acquired, tokens)
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+func (client *boundedClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *boundedClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	release, err := client.acquire(ctx, OpRead)
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &releaseReader{ReadCloser: r, release: release}, nil
+}
+
+// releaseReader releases the slots once it's closed.
+type releaseReader struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (reader *releaseReader) Close() error {
+	err := reader.ReadCloser.Close()
+	reader.once.Do(reader.release)
+	return err
+}
+
+func (client *boundedClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *boundedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	release, err := client.acquire(ctx, OpWrite)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *boundedClient) Exist(ctx context.Context, key string) (bool, error) {
+	release, err := client.acquire(ctx, OpRead)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *boundedClient) Remove(ctx context.Context, keys ...string) error {
+	release, err := client.acquire(ctx, OpDelete)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *boundedClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	release, err := client.acquire(ctx, OpList)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *boundedClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	release, err := client.acquire(ctx, OpRead)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return client.inner.Info(ctx, key)
+}
+
+func (client *boundedClient) Copy(ctx context.Context, src, dst string) error {
+	release, err := client.acquire(ctx, OpWrite)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *boundedClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,34 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBoundedClient(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "demo")
+	client := NewBoundedClient(mem, 2, map[OpClass]int{OpRead: 1})
+
+	r, err := client.Read(ctx, "objclient/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The read slot is held until the reader is closed.
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Info(timeout, "objclient/a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded: %v", err)
+	}
+	if _, err := client.List(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Close()
+	if _, err := client.Info(ctx, "objclient/a"); err != nil {
+		t.Fatal(err)
+	}
+}
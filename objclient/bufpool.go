@@ -0,0 +1,70 @@
+package objclient
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultBufferSize = 256 << 10
+
+var (
+	bufferSize atomic.Int64
+	bufferPool sync.Pool
+	// partPools are the pools of part buffers by size.
+	partPools sync.Map
+)
+
+func init() {
+	bufferSize.Store(defaultBufferSize)
+}
+
+// SetBufferSize sets the size of the pooled buffers of copies, defaults to
+// 256KiB. The buffers of the old size are dropped when they are returned.
+func SetBufferSize(size int) {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	bufferSize.Store(int64(size))
+}
+
+func getBuffer() *[]byte {
+	size := int(bufferSize.Load())
+	if buf, ok := bufferPool.Get().(*[]byte); ok && len(*buf) == size {
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+func putBuffer(buf *[]byte) {
+	if len(*buf) == int(bufferSize.Load()) {
+		bufferPool.Put(buf)
+	}
+}
+
+// copyBuffer is io.Copy with a pooled buffer.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// getPart returns a pooled buffer of size for parts of uploads and
+// downloads.
+func getPart(size int64) *[]byte {
+	if pool, ok := partPools.Load(size); ok {
+		if buf, ok := pool.(*sync.Pool).Get().(*[]byte); ok {
+			return buf
+		}
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+func putPart(buf *[]byte) {
+	size := int64(cap(*buf))
+	*buf = (*buf)[:size]
+	pool, _ := partPools.LoadOrStore(size, new(sync.Pool))
+	pool.(*sync.Pool).Put(buf)
+}
@@ -0,0 +1,35 @@
+package objclient
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBufferPool(t *testing.T) {
+	defer SetBufferSize(0)
+
+	SetBufferSize(1024)
+	if buf := getBuffer(); len(*buf) != 1024 {
+		t.Fatalf("invalid buffer size %v", len(*buf))
+	} else {
+		putBuffer(buf)
+	}
+	SetBufferSize(2048)
+	if buf := getBuffer(); len(*buf) != 2048 {
+		t.Fatalf("buffer of old size is reused: %v", len(*buf))
+	}
+
+	var dst bytes.Buffer
+	data := strings.Repeat("x", 10000)
+	if n, err := copyBuffer(&dst, strings.NewReader(data)); err != nil || n != 10000 || dst.String() != data {
+		t.Fatalf("invalid copy: %v, %v", n, err)
+	}
+
+	part := getPart(100)
+	*part = (*part)[:10]
+	putPart(part)
+	if part := getPart(100); len(*part) != 100 {
+		t.Fatalf("invalid part size %v", len(*part))
+	}
+}
@@ -0,0 +1,220 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes the faults injected into a class of operations. Rates
+// are probabilities in [0, 1].
+type Fault struct {
+	// Latency is added before each operation.
+	Latency time.Duration
+	// ErrorRate of operations failing with Err, which defaults to
+	// ErrInjected.
+	ErrorRate float64
+	Err       error
+	// TruncateRate of reads ending with io.ErrUnexpectedEOF after
+	// TruncateAfter bytes. It only applies to OpRead.
+	TruncateRate  float64
+	TruncateAfter int64
+	// StallRate of reads blocking after StallAfter bytes, until the context
+	// is done or the reader is closed. It only applies to OpRead.
+	StallRate  float64
+	StallAfter int64
+}
+
+type ChaosOptions struct {
+	// Seed of the random faults, the same seed injects the same faults for
+	// the same sequence of operations.
+	Seed   int64
+	Faults map[OpClass]Fault
+}
+
+type chaosClient struct {
+	inner  Client
+	faults map[OpClass]Fault
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewChaosClient injects faults into the operations of inner, for testing
+// the handling of failures.
+func NewChaosClient(inner Client, opts ChaosOptions) Client {
+	client := &chaosClient{
+		inner:  inner,
+		faults: opts.Faults,
+		rand:   rand.New(rand.NewSource(opts.Seed)),
+	}
+	if _, ok := AsPageLister(inner); ok {
+		return &chaosPageClient{client}
+	}
+	return client
+}
+
+// chaosPageClient is the chaosClient of a PageLister, the faults of OpList
+// are injected once for each listing, like List.
+type chaosPageClient struct {
+	*chaosClient
+}
+
+func (client *chaosPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	if err := client.inject(ctx, OpList); err != nil {
+		return err
+	}
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *chaosClient) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.rand.Float64() < rate
+}
+
+// inject waits for the latency, and returns the injected error if any.
+func (client *chaosClient) inject(ctx context.Context, class OpClass) error {
+	fault, ok := client.faults[class]
+	if !ok {
+		return nil
+	}
+	if fault.Latency > 0 && !sleepContext(ctx, fault.Latency) {
+		return ctx.Err()
+	}
+	if client.hit(fault.ErrorRate) {
+		if fault.Err != nil {
+			return fault.Err
+		}
+		return ErrInjected
+	}
+	return nil
+}
+
+func (client *chaosClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *chaosClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if err := client.inject(ctx, OpRead); err != nil {
+		return nil, err
+	}
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		return nil, err
+	}
+
+	fault := client.faults[OpRead]
+	reader := &chaosReader{ctx: ctx, r: r, remain: -1, stalls: -1, closed: make(chan struct{})}
+	if client.hit(fault.TruncateRate) {
+		reader.remain = fault.TruncateAfter
+	}
+	if client.hit(fault.StallRate) {
+		reader.stalls = fault.StallAfter
+	}
+	return reader, nil
+}
+
+// chaosReader truncates or stalls after the bytes of remain or stalls, if
+// they are not negative.
+type chaosReader struct {
+	ctx    context.Context
+	r      io.ReadCloser
+	remain int64
+	stalls int64
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (reader *chaosReader) Read(data []byte) (int, error) {
+	if reader.stalls == 0 {
+		select {
+		case <-reader.ctx.Done():
+			return 0, reader.ctx.Err()
+		case <-reader.closed:
+			return 0, errors.New("read on closed reader")
+		}
+	}
+	if reader.remain == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	for _, limit := range []int64{reader.remain, reader.stalls} {
+		if limit > 0 && int64(len(data)) > limit {
+			data = data[:limit]
+		}
+	}
+	n, err := reader.r.Read(data)
+	if reader.remain > 0 {
+		reader.remain -= int64(n)
+	}
+	if reader.stalls > 0 {
+		reader.stalls -= int64(n)
+	}
+	return n, err
+}
+
+func (reader *chaosReader) Close() error {
+	reader.once.Do(func() { close(reader.closed) })
+	return reader.r.Close()
+}
+
+func (client *chaosClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *chaosClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if err := client.inject(ctx, OpWrite); err != nil {
+		return nil, err
+	}
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *chaosClient) Exist(ctx context.Context, key string) (bool, error) {
+	if err := client.inject(ctx, OpRead); err != nil {
+		return false, err
+	}
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *chaosClient) Remove(ctx context.Context, keys ...string) error {
+	if err := client.inject(ctx, OpDelete); err != nil {
+		return err
+	}
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *chaosClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	if err := client.inject(ctx, OpList); err != nil {
+		return nil, err
+	}
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *chaosClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := client.inject(ctx, OpRead); err != nil {
+		return nil, err
+	}
+	return client.inner.Info(ctx, key)
+}
+
+func (client *chaosClient) Copy(ctx context.Context, src, dst string) error {
+	if err := client.inject(ctx, OpWrite); err != nil {
+		return err
+	}
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *chaosClient) Close() error {
+	return client.inner.Close()
+}
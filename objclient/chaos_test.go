@@ -0,0 +1,61 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestChaosClient(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "1234567890")
+
+	client := NewChaosClient(mem, ChaosOptions{Faults: map[OpClass]Fault{
+		OpList: {ErrorRate: 1},
+		OpRead: {TruncateRate: 1, TruncateAfter: 4},
+	}})
+	if _, err := client.List(ctx, ""); !errors.Is(err, ErrInjected) {
+		t.Fatalf("expect injected error: %v", err)
+	}
+	r, err := client.Read(ctx, "objclient/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != io.ErrUnexpectedEOF || string(data) != "1234" {
+		t.Fatalf("invalid truncated read: %q, %v", data, err)
+	}
+
+	client = NewChaosClient(mem, ChaosOptions{Faults: map[OpClass]Fault{
+		OpRead: {StallRate: 1, StallAfter: 2},
+	}})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	r, err = client.Read(ctx, "objclient/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(r)
+	r.Close()
+	if !errors.Is(err, context.DeadlineExceeded) || string(data) != "12" {
+		t.Fatalf("invalid stalled read: %q, %v", data, err)
+	}
+
+	// Faults are deterministic by the seed.
+	var results [2][]bool
+	for i := range results {
+		client := NewChaosClient(mem, ChaosOptions{Seed: 1, Faults: map[OpClass]Fault{OpRead: {ErrorRate: 0.5}}})
+		for j := 0; j < 16; j++ {
+			_, err := client.Exist(ctx, "objclient/a")
+			results[i] = append(results[i], err != nil)
+		}
+	}
+	for j := range results[0] {
+		if results[0][j] != results[1][j] {
+			t.Fatalf("faults are not deterministic")
+		}
+	}
+}
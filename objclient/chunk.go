@@ -0,0 +1,668 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	chunkManifestMetaKey = "objclient-chunk-manifest"
+	chunkSizeMetaKey     = "objclient-chunk-size"
+
+	defaultChunkSize   = 1 << 30
+	defaultChunkPrefix = ".chunks/"
+	// maxManifestSize limits the manifests read, which are about 100 bytes
+	// for each chunk.
+	maxManifestSize = 64 << 20
+)
+
+// ChunkOptions are the options of NewChunkedClient, zero fields are the
+// defaults.
+type ChunkOptions struct {
+	// ChunkSize is the size of the chunks of the objects larger than it,
+	// defaults to 1GiB.
+	ChunkSize int64
+	// Prefix is the prefix of the keys of chunks, defaults to ".chunks/".
+	// The keys under it are reserved.
+	Prefix string
+	// PartSize and Concurrency are the size of the ranges of chunks read
+	// at the same time by Read and their number, default to 16MiB and 4.
+	PartSize    int64
+	Concurrency int
+	// TempDir is where the chunks of objects of unknown sizes are spooled,
+	// the default temp dir is used if it's empty.
+	TempDir string
+}
+
+// chunkManifest is the content of the object of a chunked object.
+type chunkManifest struct {
+	Size   int64        `json:"size"`
+	Chunks []chunkEntry `json:"chunks"`
+}
+
+type chunkEntry struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	ETag string `json:"etag"`
+}
+
+// ChunkedClient splits the objects larger than the chunk size into chunk
+// objects under the chunk prefix, and writes the manifest of the chunks to
+// the key of the object, like the static large objects of Swift. The
+// objects not larger than it are written as is. The chunks are read
+// concurrently by Read.
+//
+// The sizes of the chunked objects are stored in the metadata of the
+// manifests, which List reads by Info for the objects having chunks. Writes
+// and removes remove the chunks of the objects replaced, the chunks of
+// concurrent writes of the same key may be left.
+type ChunkedClient struct {
+	inner Client
+	o     ChunkOptions
+}
+
+// NewChunkedClient returns a chunked client of inner. The options can be
+// nil.
+func NewChunkedClient(inner Client, opts *ChunkOptions) *ChunkedClient {
+	var o ChunkOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = defaultChunkSize
+	}
+	if o.Prefix == "" {
+		o.Prefix = defaultChunkPrefix
+	}
+	if o.PartSize <= 0 {
+		o.PartSize = defaultDownloadPartSize
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultDownloadConcurrency
+	}
+	return &ChunkedClient{inner: inner, o: o}
+}
+
+// manifest returns the manifest of the object of info, or nil if it isn't
+// chunked.
+func (client *ChunkedClient) manifest(ctx context.Context, key string, info *ObjectInfo) (*chunkManifest, error) {
+	if _, ok := metadataValue(info.Metadata, chunkManifestMetaKey); !ok {
+		return nil, nil
+	}
+	r, err := client.inner.ReadWithOptions(ctx, key, &ReadOptions{IfMatch: info.ETag})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var manifest chunkManifest
+	if err := json.NewDecoder(io.LimitReader(r, maxManifestSize)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %v: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// currentManifest returns the manifest of key, or nil if it isn't chunked
+// or doesn't exist.
+func (client *ChunkedClient) currentManifest(ctx context.Context, key string) (*chunkManifest, error) {
+	info, err := client.inner.Info(ctx, key)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client.manifest(ctx, key, info)
+}
+
+// removeChunks removes the chunks of manifests, the chunks failed to be
+// removed are only left.
+func (client *ChunkedClient) removeChunks(ctx context.Context, manifests ...*chunkManifest) {
+	var keys []string
+	for _, manifest := range manifests {
+		if manifest == nil {
+			continue
+		}
+		for _, chunk := range manifest.Chunks {
+			keys = append(keys, chunk.Key)
+		}
+	}
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), removeBatchSize)]
+		keys = keys[len(batch):]
+		client.inner.Remove(ctx, batch...)
+	}
+}
+
+func (client *ChunkedClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+// ReadWithOptions reads the chunks of chunked objects concurrently, their
+// ETag is the one of the manifest. Process, Progress and ReadAhead aren't
+// supported for them.
+func (client *ChunkedClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	info, err := client.inner.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := metadataValue(info.Metadata, chunkManifestMetaKey); !ok {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}
+	offset, length, err := readRange(o)
+	if err != nil {
+		return nil, err
+	}
+	if o != nil && o.Process != "" {
+		return nil, errors.New("process isn't supported by chunked objects")
+	}
+	if o != nil && o.IfMatch != "" && o.IfMatch != info.ETag {
+		return nil, fmt.Errorf("%w: %v", ErrPreconditionFailed, key)
+	}
+	manifest, err := client.manifest(ctx, key, info)
+	if err != nil {
+		return nil, err
+	}
+
+	end := manifest.Size
+	if length > 0 {
+		end = min(end, offset+length)
+	}
+	var ranges []objectRange
+	var start int64
+	for _, chunk := range manifest.Chunks {
+		for lo := max(offset, start); lo < min(end, start+chunk.Size); lo += client.o.PartSize {
+			hi := min(lo+client.o.PartSize, end, start+chunk.Size)
+			ranges = append(ranges, objectRange{key: chunk.Key, offset: lo - start, length: hi - lo, etag: chunk.ETag})
+		}
+		start += chunk.Size
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := readRanges(ctx, client.inner, ranges, client.o.PartSize, client.o.Concurrency, nil, pw)
+		pw.CloseWithError(err)
+	}()
+	return &cancelReader{ReadCloser: pr, cancel: cancel}, nil
+}
+
+func (client *ChunkedClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// WriteWithResult writes the object as is if it isn't larger than the
+// chunk size, or its chunks and manifest otherwise. The chunks of objects
+// of unknown sizes are spooled to the temp dir. The ETag of chunked objects
+// is the one of the manifest.
+func (client *ChunkedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	old, err := client.currentManifest(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	size, ok := writeSize(r, o)
+	var first *os.File
+	if !ok {
+		// The first chunk is spooled to find out whether it's the whole
+		// object.
+		var n int64
+		first, n, err = client.spool(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(first.Name())
+		defer first.Close()
+
+		var peek [1]byte
+		if m, _ := io.ReadFull(r, peek[:]); m > 0 {
+			r = io.MultiReader(bytes.NewReader(peek[:]), r)
+		} else {
+			size, ok = n, true
+			r = first
+		}
+	}
+	if ok && size <= client.o.ChunkSize {
+		var wo WriteOptions
+		if o != nil {
+			wo = *o
+		}
+		wo.Size = size
+		result, err := client.inner.WriteWithResult(ctx, key, r, &wo)
+		if err != nil {
+			return nil, err
+		}
+		client.removeChunks(ctx, old)
+		return result, nil
+	}
+
+	manifest, err := client.writeChunks(ctx, key, first, r, size, ok, o)
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.writeManifest(ctx, key, manifest, o)
+	if err != nil {
+		client.removeChunks(ctx, manifest)
+		return nil, err
+	}
+	client.removeChunks(ctx, old)
+	return result, nil
+}
+
+// spool copies up to a chunk of r to a temp file, and returns it at the
+// start with the bytes copied.
+func (client *ChunkedClient) spool(ctx context.Context, r io.Reader) (*os.File, int64, error) {
+	file, err := os.CreateTemp(client.o.TempDir, "objclient-chunk-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	n, err := copyBuffer(file, io.LimitReader(readerWithContext(ctx, r), client.o.ChunkSize))
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, fmt.Errorf("failed to spool chunk: %w", err)
+	}
+	return file, n, nil
+}
+
+// chunkID returns a unique ID of the chunks of a write.
+func chunkID() (string, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// writeChunks writes the chunks of first and r. If the size isn't known,
+// the chunks after the spooled first one are spooled too. The chunks
+// written are removed if it fails.
+func (client *ChunkedClient) writeChunks(ctx context.Context, key string, first *os.File, r io.Reader, size int64, sized bool, o *WriteOptions) (*chunkManifest, error) {
+	id, err := chunkID()
+	if err != nil {
+		return nil, err
+	}
+	var chunkOptions WriteOptions
+	if o != nil {
+		chunkOptions.Expires = o.Expires
+	}
+
+	manifest := &chunkManifest{}
+	for i := 0; ; i++ {
+		var (
+			chunk  io.Reader
+			length int64
+			spool  *os.File
+		)
+		switch {
+		case first != nil:
+			if _, err := first.Seek(0, io.SeekEnd); err != nil {
+				return nil, err
+			}
+			length, _ = first.Seek(0, io.SeekCurrent)
+			if _, err := first.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			chunk, first = first, nil
+		case sized:
+			length = min(client.o.ChunkSize, size-manifest.Size)
+			chunk = io.LimitReader(r, length)
+		default:
+			file, n, err := client.spool(ctx, r)
+			if err != nil {
+				client.removeChunks(ctx, manifest)
+				return nil, err
+			}
+			chunk, length, spool = file, n, file
+		}
+		if length == 0 {
+			closeSpool(spool)
+			return manifest, nil
+		}
+
+		chunkKey := fmt.Sprintf("%v%v/%v/%06d", client.o.Prefix, key, id, i)
+		chunkOptions.Size = length
+		result, err := client.inner.WriteWithResult(ctx, chunkKey, chunk, &chunkOptions)
+		closeSpool(spool)
+		if err != nil {
+			client.removeChunks(ctx, manifest, &chunkManifest{Chunks: []chunkEntry{{Key: chunkKey}}})
+			return nil, fmt.Errorf("failed to write chunk %v of %v: %w", i, key, err)
+		}
+		manifest.Chunks = append(manifest.Chunks, chunkEntry{Key: chunkKey, Size: length, ETag: result.ETag})
+		manifest.Size += length
+		if sized && manifest.Size >= size {
+			return manifest, nil
+		}
+	}
+}
+
+func closeSpool(file *os.File) {
+	if file != nil {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+func (client *ChunkedClient) writeManifest(ctx context.Context, key string, manifest *chunkManifest, o *WriteOptions) (*WriteResult, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	wo := &WriteOptions{Size: int64(len(data)), Metadata: make(map[string]string)}
+	if o != nil {
+		for k, v := range o.Metadata {
+			wo.Metadata[k] = v
+		}
+		wo.Expires = o.Expires
+		wo.IfMatch, wo.IfNoneMatch = o.IfMatch, o.IfNoneMatch
+	}
+	wo.Metadata[chunkManifestMetaKey] = "1"
+	wo.Metadata[chunkSizeMetaKey] = strconv.FormatInt(manifest.Size, 10)
+	return client.inner.WriteWithResult(ctx, key, bytes.NewReader(data), wo)
+}
+
+func (client *ChunkedClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+// Remove removes the chunks of the keys removed too.
+func (client *ChunkedClient) Remove(ctx context.Context, keys ...string) error {
+	infos, err := InfoMulti(ctx, client.inner, keys, 0)
+	var merr *MultiError
+	if errors.As(err, &merr) {
+		for _, err := range merr.Errors {
+			if !isNotFound(err) {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	var manifests []*chunkManifest
+	for key, info := range infos {
+		manifest, err := client.manifest(ctx, key, info)
+		if err != nil && !isNotFound(err) && !isPreconditionFailed(err) {
+			return err
+		}
+		if manifest != nil {
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	err = client.inner.Remove(ctx, keys...)
+	var rerr *RemoveError
+	if err != nil && !errors.As(err, &rerr) {
+		return err
+	}
+	if rerr != nil {
+		failed := make(map[string]bool)
+		for _, key := range rerr.Keys() {
+			failed[key] = true
+		}
+		kept := manifests[:0]
+		for _, manifest := range manifests {
+			if len(manifest.Chunks) > 0 && !failed[manifestKey(client.o.Prefix, manifest)] {
+				kept = append(kept, manifest)
+			}
+		}
+		manifests = kept
+	}
+	client.removeChunks(ctx, manifests...)
+	return err
+}
+
+// manifestKey returns the key of the object of the chunks of manifest.
+func manifestKey(prefix string, manifest *chunkManifest) string {
+	return chunkObjectKey(prefix, manifest.Chunks[0].Key)
+}
+
+// chunkObjectKey returns the key of the object of the chunk of key.
+func chunkObjectKey(prefix, key string) string {
+	key = strings.TrimPrefix(key, prefix)
+	for i := 0; i < 2 && strings.Contains(key, "/"); i++ {
+		key = key[:strings.LastIndex(key, "/")]
+	}
+	return key
+}
+
+// List skips the chunks, and returns the sizes of chunked objects. The chunks
+// of prefix are listed to find out the objects which may be chunked.
+func (client *ChunkedClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	items, err := client.inner.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	chunks := items
+	if !strings.HasPrefix(client.o.Prefix, prefix) {
+		if chunks, err = client.inner.List(ctx, client.o.Prefix+prefix); err != nil {
+			return nil, err
+		}
+	}
+	chunked := make(map[string]bool)
+	for _, item := range chunks {
+		if strings.HasPrefix(item.Key, client.o.Prefix) {
+			chunked[chunkObjectKey(client.o.Prefix, item.Key)] = true
+		}
+	}
+	return client.listed(ctx, items, chunked)
+}
+
+// ListPages implements PageLister like List. If inner is a PageLister, the
+// chunks of the objects of each page are listed for the page, otherwise the
+// items of List are returned in pages.
+func (client *ChunkedClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	lister, ok := AsPageLister(client.inner)
+	if !ok {
+		items, err := client.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+		listItemPages(items, startAfter, fn)
+		return nil
+	}
+
+	var lerr error
+	err := lister.ListPages(ctx, prefix, startAfter, func(items []ObjectItem) bool {
+		// The chunks of key are in [key+"/", key+"0"), the ranges of the
+		// page are listed at once.
+		var lo, hi string
+		for _, item := range items {
+			if strings.HasPrefix(item.Key, client.o.Prefix) {
+				continue
+			}
+			if lo == "" || item.Key+"/" < lo {
+				lo = item.Key + "/"
+			}
+			if item.Key+"0" > hi {
+				hi = item.Key + "0"
+			}
+		}
+		chunked := make(map[string]bool)
+		if lo != "" {
+			lerr = listPages(ctx, client.inner, client.o.Prefix+prefix, client.o.Prefix+lo, func(chunks []ObjectItem) bool {
+				for _, chunk := range chunks {
+					if chunk.Key >= client.o.Prefix+hi {
+						return false
+					}
+					chunked[chunkObjectKey(client.o.Prefix, chunk.Key)] = true
+				}
+				return true
+			})
+			if lerr != nil {
+				return false
+			}
+		}
+		filtered, err := client.listed(ctx, items, chunked)
+		if err != nil {
+			lerr = err
+			return false
+		}
+		if len(filtered) == 0 {
+			return true
+		}
+		return fn(filtered)
+	})
+	if err != nil {
+		return err
+	}
+	return lerr
+}
+
+// listsPages reports whether inner is a PageLister, for AsPageLister.
+func (client *ChunkedClient) listsPages() bool {
+	_, ok := AsPageLister(client.inner)
+	return ok
+}
+
+// listed skips the chunks of items, and returns the sizes of the objects
+// of chunked keys.
+func (client *ChunkedClient) listed(ctx context.Context, items []ObjectItem, chunked map[string]bool) ([]ObjectItem, error) {
+	filtered := items[:0]
+	var keys []string
+	for _, item := range items {
+		if strings.HasPrefix(item.Key, client.o.Prefix) {
+			continue
+		}
+		filtered = append(filtered, item)
+		if chunked[item.Key] {
+			keys = append(keys, item.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return filtered, nil
+	}
+
+	// The objects removed since the listing keep the sizes listed.
+	infos, err := InfoMulti(ctx, client.inner, keys, 0)
+	var merr *MultiError
+	if errors.As(err, &merr) {
+		for _, err := range merr.Errors {
+			if !isNotFound(err) {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	for i, item := range filtered {
+		info, ok := infos[item.Key]
+		if !ok {
+			continue
+		}
+		size, ok, err := chunkedSize(item.Key, info)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			filtered[i].Size = size
+		}
+	}
+	return filtered, nil
+}
+
+// chunkedSize returns the size of the object in the metadata of info, or
+// false if it isn't chunked.
+func chunkedSize(key string, info *ObjectInfo) (int64, bool, error) {
+	if _, ok := metadataValue(info.Metadata, chunkManifestMetaKey); !ok {
+		return 0, false, nil
+	}
+	v, _ := metadataValue(info.Metadata, chunkSizeMetaKey)
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid size of %v: %w", key, err)
+	}
+	return size, true, nil
+}
+
+// Info returns the size of chunked objects.
+func (client *ChunkedClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := client.inner.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	size, ok, err := chunkedSize(key, info)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return info, nil
+	}
+	info.Size = size
+	metadata := make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		if !strings.EqualFold(k, chunkManifestMetaKey) && !strings.EqualFold(k, chunkSizeMetaKey) {
+			metadata[k] = v
+		}
+	}
+	info.Metadata = metadata
+	return info, nil
+}
+
+// Copy copies the chunks of chunked objects to the chunks of dst.
+func (client *ChunkedClient) Copy(ctx context.Context, src, dst string) error {
+	old, err := client.currentManifest(ctx, dst)
+	if err != nil {
+		return err
+	}
+	info, err := client.inner.Info(ctx, src)
+	if err != nil {
+		return err
+	}
+	manifest, err := client.manifest(ctx, src, info)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		if err := client.inner.Copy(ctx, src, dst); err != nil {
+			return err
+		}
+		client.removeChunks(ctx, old)
+		return nil
+	}
+
+	id, err := chunkID()
+	if err != nil {
+		return err
+	}
+	copied := &chunkManifest{Size: manifest.Size}
+	for i, chunk := range manifest.Chunks {
+		chunkKey := fmt.Sprintf("%v%v/%v/%06d", client.o.Prefix, dst, id, i)
+		if err := client.inner.Copy(ctx, chunk.Key, chunkKey); err != nil {
+			client.removeChunks(ctx, copied, &chunkManifest{Chunks: []chunkEntry{{Key: chunkKey}}})
+			return fmt.Errorf("failed to copy chunk %v of %v: %w", i, src, err)
+		}
+		copied.Chunks = append(copied.Chunks, chunkEntry{Key: chunkKey, Size: chunk.Size, ETag: chunk.ETag})
+	}
+
+	metadata := make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		if !strings.EqualFold(k, chunkManifestMetaKey) && !strings.EqualFold(k, chunkSizeMetaKey) {
+			metadata[k] = v
+		}
+	}
+	if _, err := client.writeManifest(ctx, dst, copied, &WriteOptions{Metadata: metadata}); err != nil {
+		client.removeChunks(ctx, copied)
+		return err
+	}
+	client.removeChunks(ctx, old)
+	return nil
+}
+
+func (client *ChunkedClient) Close() error {
+	return client.inner.Close()
+}
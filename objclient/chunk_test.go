@@ -0,0 +1,124 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChunkedClient(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	client := NewChunkedClient(mem, &ChunkOptions{ChunkSize: 10, PartSize: 4, TempDir: t.TempDir()})
+	data := strings.Repeat("0123456789", 3) + "abc"
+
+	read := func(key string, o *ReadOptions) string {
+		r, err := client.ReadWithOptions(ctx, key, o)
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", key, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", key, err)
+		}
+		return string(data)
+	}
+	chunks := func() int {
+		n := 0
+		for key := range mem.objects {
+			if strings.HasPrefix(key, ".chunks/") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The objects of known and unknown sizes are chunked.
+	if err := client.Write(ctx, "a", strings.NewReader(data), &WriteOptions{Metadata: map[string]string{"owner": "me"}}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Write(ctx, "b", io.MultiReader(strings.NewReader(data)), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Write(ctx, "small", io.MultiReader(strings.NewReader("0123456789")), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if n := chunks(); n != 8 {
+		t.Fatalf("invalid chunks %v", n)
+	}
+	for _, key := range []string{"a", "b"} {
+		if got := read(key, nil); got != data {
+			t.Fatalf("invalid data of %v %q", key, got)
+		}
+	}
+	if got := read("a", &ReadOptions{Offset: 8, Length: 15}); got != data[8:23] {
+		t.Fatalf("invalid range %q", got)
+	}
+	if got := read("small", nil); got != "0123456789" {
+		t.Fatalf("invalid data %q", got)
+	}
+	info, err := client.Info(ctx, "a")
+	if err != nil || info.Size != int64(len(data)) || info.Metadata["owner"] != "me" || len(info.Metadata) != 1 {
+		t.Fatalf("invalid info %+v: %v", info, err)
+	}
+	if _, err := client.ReadWithOptions(ctx, "a", &ReadOptions{IfMatch: "other"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	items, err := client.List(ctx, "")
+	if err != nil || len(items) != 3 {
+		t.Fatalf("invalid items %+v: %v", items, err)
+	}
+	for _, item := range items {
+		if item.Key == "a" && item.Size != int64(len(data)) {
+			t.Fatalf("invalid size of chunked item %+v", item)
+		}
+	}
+
+	// The chunks are copied, and the chunks replaced are removed.
+	if err := client.Copy(ctx, "a", "c"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if got := read("c", nil); got != data {
+		t.Fatalf("invalid copied data %q", got)
+	}
+	if err := client.Write(ctx, "a", strings.NewReader("new"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if got := read("a", nil); got != "new" {
+		t.Fatalf("invalid data %q", got)
+	}
+	if n := chunks(); n != 8 {
+		t.Fatalf("invalid chunks after overwrite %v", n)
+	}
+	// The chunks of prefixes are listed to find out the chunked objects.
+	if err := client.Write(ctx, "dir/a", strings.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	items, err = client.List(ctx, "dir/")
+	if err != nil || len(items) != 1 || items[0].Size != int64(len(data)) {
+		t.Fatalf("invalid items of prefix %+v: %v", items, err)
+	}
+	if err := client.Remove(ctx, "a", "b", "c", "dir/a", "missing"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if n := chunks(); n != 0 {
+		t.Fatalf("invalid chunks after remove %v", n)
+	}
+
+	// The chunks written are removed if the write fails.
+	mem.fail = func(op, key string) error {
+		if op == "Write" && strings.HasSuffix(key, "000002") {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	if err := client.Write(ctx, "d", strings.NewReader(data), nil); err == nil {
+		t.Fatalf("failed write succeeded")
+	}
+	if n := chunks(); n != 0 {
+		t.Fatalf("invalid chunks after failed write %v", n)
+	}
+}
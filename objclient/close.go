@@ -0,0 +1,59 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// transfers cancels the transfers of S3 and OSS clients when they are
+// closed.
+type transfers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newTransfers() transfers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return transfers{ctx: ctx, cancel: cancel}
+}
+
+// context returns the context of a transfer, which is canceled by cancel or
+// closing the client.
+func (t transfers) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// close cancels the transfers in flight, and closes the idle connections of
+// transport.
+func (t transfers) close(transport http.RoundTripper) {
+	t.cancel()
+	if closer, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// closeAll closes all the clients, even if some failed.
+func closeAll(clients ...Client) error {
+	var errs []error
+	for _, client := range clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeReadOnly closes inner if it's a Client or an io.Closer.
+func closeReadOnly(inner ReadOnlyClient) error {
+	if closer, ok := inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
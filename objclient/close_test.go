@@ -0,0 +1,86 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloseWrappers(t *testing.T) {
+	a, b := newMemClient(), newMemClient()
+	client := NewTimeoutClient(NewMirrorClient(WithPrefix(a, "objclient/"), b, MirrorAsync, nil), &TimeoutPolicy{})
+	if err := client.Write(context.Background(), "key", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if !a.closed || !b.closed {
+		t.Fatalf("inner clients aren't closed: %v, %v", a.closed, b.closed)
+	}
+	// The async write is done before b is closed.
+	if _, ok := b.objects["key"]; !ok {
+		t.Fatalf("async write isn't waited by close")
+	}
+
+	// The replays are stopped.
+	primary, secondary := newMemClient(), newMemClient()
+	primary.fail = func(op, key string) error { return errors.New("unavailable") }
+	failover := NewFailoverClient(primary, secondary, &FailoverPolicy{ReplayWrites: true, ProbeInterval: time.Hour})
+	if err := failover.Write(context.Background(), "key", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := failover.Close(); err != nil || !primary.closed || !secondary.closed {
+		t.Fatalf("invalid close of failover client: %v", err)
+	}
+}
+
+func TestCloseTransfers(t *testing.T) {
+	// The server sends a part of the object, and blocks until the request
+	// is canceled.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("ETag", `"object"`)
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write([]byte("12"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	for _, backend := range []string{"s3", "oss"} {
+		var (
+			client Client
+			err    error
+		)
+		opts := []Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key")}
+		if backend == "s3" {
+			client, err = NewS3("bucket", append(opts, WithPathStyle(true))...)
+		} else {
+			client, err = NewOSS("bucket", opts...)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %v client: %v", backend, err)
+		}
+
+		r, err := client.Read(context.Background(), "key")
+		if err != nil {
+			t.Fatalf("failed to read by %v: %v", backend, err)
+		}
+		time.AfterFunc(50*time.Millisecond, func() { client.Close() })
+		data, err := io.ReadAll(r)
+		r.Close()
+		if !errors.Is(err, context.Canceled) || string(data) != "12" {
+			t.Fatalf("read of %v isn't canceled by close: %q, %v", backend, data, err)
+		}
+	}
+}
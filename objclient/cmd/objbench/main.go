@@ -0,0 +1,76 @@
+// Command objbench benchmarks the object storage of a DSN or config file.
+//
+//	objbench -dsn 's3://KEY_ID:KEY@/bucket?region=us-east-1' -concurrency 1,8,32
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/objbench"
+)
+
+func main() {
+	dsn := flag.String("dsn", "", "DSN of the storage")
+	config := flag.String("config", "", "config file with the storage section, instead of -dsn")
+	concurrency := flag.String("concurrency", "1,4,16", "comma separated numbers of concurrent workers")
+	ops := flag.String("ops", "put,get,list,delete", "comma separated operations")
+	size := flag.Int64("size", 1<<20, "object size in bytes")
+	objects := flag.Int("objects", 100, "number of objects at each concurrency")
+	lists := flag.Int("lists", 10, "number of LIST requests at each concurrency")
+	prefix := flag.String("prefix", "objbench/", "prefix of the objects, which should be empty")
+	flag.Parse()
+
+	if err := run(*dsn, *config, *concurrency, *ops, *size, *objects, *lists, *prefix); err != nil {
+		fmt.Fprintln(os.Stderr, "objbench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dsn, configPath, concurrency, ops string, size int64, objects, lists int, prefix string) error {
+	var client objclient.Client
+	var err error
+	switch {
+	case configPath != "":
+		client, err = objclient.LoadConfig(configPath)
+	case dsn != "":
+		client, err = objclient.NewClientFromDSN(dsn)
+	default:
+		return fmt.Errorf("-dsn or -config is required")
+	}
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := &objbench.Config{
+		ObjectSize: size,
+		Objects:    objects,
+		Lists:      lists,
+		Prefix:     prefix,
+	}
+	for _, s := range strings.Split(concurrency, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid concurrency: %v", s)
+		}
+		config.Concurrency = append(config.Concurrency, n)
+	}
+	if config.Ops, err = objbench.ParseOps(ops); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results, err := objbench.Run(ctx, client, config)
+	if werr := objbench.WriteReport(os.Stdout, results); err == nil {
+		err = werr
+	}
+	return err
+}
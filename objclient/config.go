@@ -0,0 +1,218 @@
+package objclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"unicode"
+)
+
+func validateBool(field, value string) error {
+	if value != "" && value != "true" && value != "false" {
+		return fmt.Errorf("invalid %v %q, it should be true or false", field, value)
+	}
+	return nil
+}
+
+// Validate checks the fields of config, the boolean fields should be empty,
+// "true" or "false".
+func (config S3Config) Validate() error {
+	if config.Bucket == "" {
+		return errors.New("missing Bucket")
+	}
+	bools := map[string]string{
+		"HTTPS":              config.HTTPS,
+		"PathStyleRequest":   config.PathStyleRequest,
+		"V4Signature":        config.V4Signature,
+		"Anonymous":          config.Anonymous,
+		"InsecureSkipVerify": config.InsecureSkipVerify,
+		"Accelerate":         config.Accelerate,
+		"DualStack":          config.DualStack,
+		"FIPS":               config.FIPS,
+		"StrictKeys":         config.StrictKeys,
+	}
+	for _, field := range []string{"HTTPS", "PathStyleRequest", "V4Signature", "Anonymous", "InsecureSkipVerify", "Accelerate", "DualStack", "FIPS", "StrictKeys"} {
+		if err := validateBool(field, bools[field]); err != nil {
+			return err
+		}
+	}
+	if config.FIPS == "true" {
+		if config.Endpoint != "" || config.EndpointTemplate != "" {
+			return errors.New("can't set Endpoint or EndpointTemplate for FIPS")
+		}
+		if config.Accelerate == "true" {
+			return errors.New("can't set Accelerate for FIPS")
+		}
+	}
+
+	if (config.KeyID == "") != (config.Key == "") {
+		return errors.New("both KeyID and Key should be set")
+	}
+	if config.Anonymous == "true" && config.KeyID != "" {
+		return errors.New("can't set KeyID and Key for Anonymous")
+	}
+	if config.Credentials != nil && config.KeyID != "" {
+		return errors.New("can't set KeyID and Key for Credentials")
+	}
+	if config.WebIdentityTokenFile != "" && config.KeyID != "" {
+		return errors.New("can't set KeyID and Key for WebIdentityTokenFile")
+	}
+	if config.Endpoint == "" && config.EndpointTemplate != "" {
+		if _, err := templateEndpoint(config.EndpointTemplate, config.Region); err != nil {
+			return err
+		}
+	}
+	if _, err := proxyFunc(config.ProxyURL); err != nil {
+		return err
+	}
+	if _, err := parseTimeouts(config.ConnectTimeout, config.RequestTimeout, config.ReadStallTimeout, 0); err != nil {
+		return err
+	}
+	if _, err := parseUpload(config.PartSize, config.UploadConcurrency); err != nil {
+		return err
+	}
+	if err := config.transportConfig().tune(new(http.Transport)); err != nil {
+		return err
+	}
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return errors.New("both ClientCertFile and ClientKeyFile should be set")
+	}
+	if _, err := parseHeaders(config.Headers, "x-amz-"); err != nil {
+		return err
+	}
+	if config.SSECKey != "" {
+		if len(config.SSECKey) != 32 {
+			return errors.New("length of SSECKey should be 32 bytes")
+		}
+		if config.V4Signature != "true" || config.HTTPS != "true" {
+			return errors.New("using SSECKey requires V4Signature and HTTPS")
+		}
+	}
+	return nil
+}
+
+func (config OSSConfig) Validate() error {
+	if config.Bucket == "" {
+		return errors.New("missing Bucket")
+	}
+	if config.Endpoint == "" && config.Region == "" && config.EndpointTemplate == "" {
+		return errors.New("missing Endpoint or Region")
+	}
+	bools := map[string]string{
+		"HTTPS":              config.HTTPS,
+		"InsecureSkipVerify": config.InsecureSkipVerify,
+		"Accelerate":         config.Accelerate,
+		"DualStack":          config.DualStack,
+		"StrictKeys":         config.StrictKeys,
+	}
+	for _, field := range []string{"HTTPS", "InsecureSkipVerify", "Accelerate", "DualStack", "StrictKeys"} {
+		if err := validateBool(field, bools[field]); err != nil {
+			return err
+		}
+	}
+	if (config.KeyID == "") != (config.Key == "") {
+		return errors.New("both KeyID and Key should be set")
+	}
+	if config.Credentials != nil && config.KeyID != "" {
+		return errors.New("can't set KeyID and Key for Credentials")
+	}
+	if config.RAMRole != "" && config.KeyID != "" {
+		return errors.New("can't set KeyID and Key for RAMRole")
+	}
+	if config.RoleARN != "" && config.KeyID == "" && config.RAMRole == "" {
+		return errors.New("missing KeyID and Key or RAMRole for RoleARN")
+	}
+	if config.Endpoint == "" && config.EndpointTemplate != "" {
+		if _, err := templateEndpoint(config.EndpointTemplate, config.Region); err != nil {
+			return err
+		}
+	}
+	if _, err := proxyFunc(config.ProxyURL); err != nil {
+		return err
+	}
+	if _, err := parseTimeouts(config.ConnectTimeout, config.RequestTimeout, config.ReadStallTimeout, 0); err != nil {
+		return err
+	}
+	if _, err := parseUpload(config.PartSize, config.UploadConcurrency); err != nil {
+		return err
+	}
+	if err := config.transportConfig().tune(new(http.Transport)); err != nil {
+		return err
+	}
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return errors.New("both ClientCertFile and ClientKeyFile should be set")
+	}
+	if _, err := parseHeaders(config.Headers, "x-oss-"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// envName returns the environment variable name of a field, which is the
+// upper snake case of it, e.g. PATH_STYLE_REQUEST for PathStyleRequest.
+func envName(field string) string {
+	runes := []rune(field)
+	var name []rune
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && next) {
+				name = append(name, '_')
+			}
+		}
+		name = append(name, unicode.ToUpper(r))
+	}
+	return string(name)
+}
+
+// ConfigFromEnv sets the string fields of the struct pointed by config
+// from the environment variables of prefix and the upper snake case of
+// field names, e.g. S3_KEY_ID for the KeyID field with prefix "S3_".
+// Fields are not changed if their variables are not set.
+func ConfigFromEnv(prefix string, config any) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config should be a pointer to struct: %T", config)
+	}
+	v = v.Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Type.Kind() != reflect.String {
+			continue
+		}
+		if value, ok := os.LookupEnv(prefix + envName(field.Name)); ok {
+			v.Field(i).SetString(value)
+		}
+	}
+	return nil
+}
+
+// S3ConfigFromEnv returns the S3Config of the environment variables of
+// prefix, see ConfigFromEnv for the names.
+func S3ConfigFromEnv(prefix string) (S3Config, error) {
+	var config S3Config
+	if err := ConfigFromEnv(prefix, &config); err != nil {
+		return config, err
+	}
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid config of %v* environment variables: %w", prefix, err)
+	}
+	return config, nil
+}
+
+// OSSConfigFromEnv returns the OSSConfig of the environment variables of
+// prefix, see ConfigFromEnv for the names.
+func OSSConfigFromEnv(prefix string) (OSSConfig, error) {
+	var config OSSConfig
+	if err := ConfigFromEnv(prefix, &config); err != nil {
+		return config, err
+	}
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid config of %v* environment variables: %w", prefix, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,41 @@
+package objclient
+
+import (
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	expects := map[string]string{
+		"Endpoint":         "ENDPOINT",
+		"HTTPS":            "HTTPS",
+		"KeyID":            "KEY_ID",
+		"PathStyleRequest": "PATH_STYLE_REQUEST",
+		"V4Signature":      "V4_SIGNATURE",
+		"SSECKey":          "SSEC_KEY",
+	}
+	for field, expect := range expects {
+		if name := envName(field); name != expect {
+			t.Fatalf("invalid env name of %v: %v", field, name)
+		}
+	}
+}
+
+func TestS3ConfigFromEnv(t *testing.T) {
+	t.Setenv("TEST_S3_BUCKET", "bucket")
+	t.Setenv("TEST_S3_KEY_ID", "id")
+	t.Setenv("TEST_S3_KEY", "key")
+	t.Setenv("TEST_S3_PATH_STYLE_REQUEST", "true")
+
+	config, err := S3ConfigFromEnv("TEST_S3_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Bucket != "bucket" || config.KeyID != "id" || config.Key != "key" || config.PathStyleRequest != "true" {
+		t.Fatalf("invalid config: %+v", config)
+	}
+
+	t.Setenv("TEST_S3_HTTPS", "yes")
+	if _, err := S3ConfigFromEnv("TEST_S3_"); err == nil {
+		t.Fatalf("expect invalid config")
+	}
+}
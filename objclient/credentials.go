@@ -0,0 +1,107 @@
+package objclient
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	defaultRoleSessionName = "objclient"
+)
+
+// defaultCredentialChain returns the credentials of the first provider
+// which has them, in the order of the AWS SDKs.
+func defaultCredentialChain(profile string) *credentials.Credentials {
+	return credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{Profile: profile},
+		// It includes web identity, ECS task roles and IMDSv2 of EC2
+		// instances.
+		&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+	})
+}
+
+// assumeRoleProvider assumes a role with the credentials of source, which
+// can be temporary credentials too.
+type assumeRoleProvider struct {
+	credentials.Expiry
+
+	source   *credentials.Credentials
+	endpoint string
+	opts     credentials.STSAssumeRoleOptions
+}
+
+// stsEndpoint returns the STS endpoint of config.
+func stsEndpoint(config S3Config, region string) string {
+	if config.STSEndpoint != "" {
+		return config.STSEndpoint
+	}
+	if region != "" {
+		return fmt.Sprintf("https://sts.%v.%v", region, awsDomain(region))
+	}
+	return credentials.DefaultSTSRoleEndpoint
+}
+
+func assumeRole(source *credentials.Credentials, config S3Config, region string) *credentials.Credentials {
+	sessionName := config.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+
+	return credentials.New(&assumeRoleProvider{
+		source:   source,
+		endpoint: stsEndpoint(config, region),
+		opts: credentials.STSAssumeRoleOptions{
+			Location:        region,
+			RoleARN:         config.RoleARN,
+			RoleSessionName: sessionName,
+			ExternalID:      config.RoleExternalID,
+		},
+	})
+}
+
+func (p *assumeRoleProvider) Retrieve() (credentials.Value, error) {
+	source, err := p.source.Get()
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("failed to get source credentials: %w", err)
+	}
+
+	opts := p.opts
+	opts.AccessKey = source.AccessKeyID
+	opts.SecretKey = source.SecretAccessKey
+	opts.SessionToken = source.SessionToken
+
+	role := &credentials.STSAssumeRole{
+		Client:      &http.Client{Transport: http.DefaultTransport},
+		STSEndpoint: p.endpoint,
+		Options:     opts,
+	}
+	value, err := role.Retrieve()
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("failed to assume role: %w", err)
+	}
+	// Refreshed after 80% of the lifetime.
+	p.SetExpiration(value.Expiration, credentials.DefaultExpiryWindow)
+
+	return value, nil
+}
+
+func webIdentity(config S3Config, region string) *credentials.Credentials {
+	path := config.WebIdentityTokenFile
+	return credentials.New(&credentials.STSWebIdentity{
+		Client:      &http.Client{Transport: http.DefaultTransport},
+		STSEndpoint: stsEndpoint(config, region),
+		RoleARN:     config.RoleARN,
+		GetWebIDTokenExpiry: func() (*credentials.WebIdentityToken, error) {
+			token, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read web identity token: %w", err)
+			}
+			return &credentials.WebIdentityToken{Token: strings.TrimSpace(string(token))}, nil
+		},
+	})
+}
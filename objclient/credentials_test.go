@@ -0,0 +1,111 @@
+package objclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func TestDefaultCredentialChain(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	path := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(path, []byte("[test]\naws_access_key_id = id\naws_secret_access_key = key\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+	value, err := defaultCredentialChain("test").Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "id" || value.SecretAccessKey != "key" {
+		t.Fatalf("invalid credentials from file: %+v", value)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-key")
+	value, err = defaultCredentialChain("test").Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "env-id" {
+		t.Fatalf("invalid credentials from env: %+v", value)
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRole" || r.Form.Get("RoleArn") != "arn:role" || r.Form.Get("ExternalId") != "ext" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		n := requests.Add(1)
+		expiration := time.Now().Add(100 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>
+<AccessKeyId>tmp-%d</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+<SessionToken>token</SessionToken><Expiration>%v</Expiration>
+</Credentials></AssumeRoleResult></AssumeRoleResponse>`, n, expiration)
+	}))
+	defer server.Close()
+
+	config := S3Config{RoleARN: "arn:role", RoleExternalID: "ext", STSEndpoint: server.URL}
+	creds := assumeRole(credentials.NewStaticV4("id", "key", ""), config, "us-east-1")
+
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "tmp-1" || value.SessionToken != "token" {
+		t.Fatalf("invalid credentials: %+v", value)
+	}
+
+	// Refreshed before it expires.
+	time.Sleep(90 * time.Millisecond)
+	value, err = creds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "tmp-2" {
+		t.Fatalf("credentials are not refreshed: %+v", value)
+	}
+}
+
+func TestWebIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("token-1\n"), 0o600)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("RoleArn") != "arn:role" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>for-%v</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+<SessionToken>token</SessionToken><Expiration>%v</Expiration>
+</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`, r.Form.Get("WebIdentityToken"), expiration)
+	}))
+	defer server.Close()
+
+	config := S3Config{RoleARN: "arn:role", STSEndpoint: server.URL, WebIdentityTokenFile: path}
+	value, err := webIdentity(config, "us-east-1").Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "for-token-1" {
+		t.Fatalf("invalid credentials: %+v", value)
+	}
+}
@@ -0,0 +1,295 @@
+package objclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	dedupHashMetaKey = "objclient-dedup-sha256"
+	dedupSizeMetaKey = "objclient-dedup-size"
+)
+
+// DedupClient stores the content of objects once by its SHA-256. The data
+// is stored under the blob prefix by hash, and logical keys are empty
+// reference objects with the hash in metadata. Objects without the hash
+// are read as is, so it can be used on existing data.
+//
+// The sizes of List results are of the references, use Info for the size
+// of content.
+type DedupClient struct {
+	inner      Client
+	blobPrefix string
+	tempDir    string
+}
+
+// NewDedupClient creates a client storing blobs under blobPrefix of inner,
+// keys under it are reserved. The content of writes is spooled to tempDir
+// for hashing, the default temp dir is used if it's empty.
+func NewDedupClient(inner Client, blobPrefix, tempDir string) *DedupClient {
+	return &DedupClient{inner: inner, blobPrefix: blobPrefix, tempDir: tempDir}
+}
+
+func (client *DedupClient) blobKey(hash string) string {
+	return client.blobPrefix + hash
+}
+
+func metadataValue(metadata map[string]string, key string) (string, bool) {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (client *DedupClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *DedupClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	info, err := client.inner.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	hash, ok := metadataValue(info.Metadata, dedupHashMetaKey)
+	if !ok {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}
+	return client.inner.ReadWithOptions(ctx, client.blobKey(hash), o)
+}
+
+func (client *DedupClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// WriteWithResult uploads the content only if there is no blob of the same
+// hash. The ETag of result is the hash, which IfMatch is checked against.
+// The options except Size and Progress are of the reference.
+func (client *DedupClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	var ref WriteOptions
+	if o != nil {
+		ref = *o
+	}
+	ref.Size, ref.Progress = 0, nil
+	if ref.IfMatch != "" {
+		// The reference is written only if it isn't changed since checked.
+		info, err := client.inner.Info(ctx, key)
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%w: %v", ErrPreconditionFailed, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		etag := info.ETag
+		if hash, ok := metadataValue(info.Metadata, dedupHashMetaKey); ok {
+			etag = hash
+		}
+		if !strings.EqualFold(etag, ref.IfMatch) {
+			return nil, fmt.Errorf("%w: %v", ErrPreconditionFailed, key)
+		}
+		ref.IfMatch = info.ETag
+	}
+
+	tmp, err := os.CreateTemp(client.tempDir, "objclient-dedup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := copyBuffer(io.MultiWriter(tmp, h), readerWithContext(ctx, r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool content: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	exist, err := client.inner.Exist(ctx, client.blobKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		var progress Progress
+		if o != nil {
+			progress = o.Progress
+		}
+		err := client.inner.Write(ctx, client.blobKey(hash), tmp, &WriteOptions{Size: size, Progress: progress})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write blob: %w", err)
+		}
+	}
+
+	ref.Metadata = make(map[string]string, len(ref.Metadata)+2)
+	if o != nil {
+		for k, v := range o.Metadata {
+			ref.Metadata[k] = v
+		}
+	}
+	ref.Metadata[dedupHashMetaKey] = hash
+	ref.Metadata[dedupSizeMetaKey] = strconv.FormatInt(size, 10)
+
+	result, err := client.inner.WriteWithResult(ctx, key, strings.NewReader(""), &ref)
+	if err != nil {
+		return nil, err
+	}
+	result.ETag = hash
+	return result, nil
+}
+
+func (client *DedupClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+// Remove removes the references only, blobs are removed by GC.
+func (client *DedupClient) Remove(ctx context.Context, keys ...string) error {
+	return client.inner.Remove(ctx, keys...)
+}
+
+// List skips the blobs.
+func (client *DedupClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	items, err := client.inner.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := items[:0]
+	for _, item := range items {
+		if !strings.HasPrefix(item.Key, client.blobPrefix) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// ListPages implements PageLister like List, the pages of only blobs are
+// skipped.
+func (client *DedupClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return listPages(ctx, client.inner, prefix, startAfter, func(items []ObjectItem) bool {
+		filtered := items[:0]
+		for _, item := range items {
+			if !strings.HasPrefix(item.Key, client.blobPrefix) {
+				filtered = append(filtered, item)
+			}
+		}
+		if len(filtered) == 0 {
+			return true
+		}
+		return fn(filtered)
+	})
+}
+
+// listsPages reports whether inner is a PageLister, for AsPageLister.
+func (client *DedupClient) listsPages() bool {
+	_, ok := AsPageLister(client.inner)
+	return ok
+}
+
+// Info returns the size of content, and the hash as ETag.
+func (client *DedupClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := client.inner.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	hash, ok := metadataValue(info.Metadata, dedupHashMetaKey)
+	if !ok {
+		return info, nil
+	}
+
+	if v, ok := metadataValue(info.Metadata, dedupSizeMetaKey); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size of %v: %w", key, err)
+		}
+		info.Size = size
+	}
+	info.ETag = hash
+
+	metadata := make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		if !strings.EqualFold(k, dedupHashMetaKey) && !strings.EqualFold(k, dedupSizeMetaKey) {
+			metadata[k] = v
+		}
+	}
+	info.Metadata = metadata
+
+	return info, nil
+}
+
+// Copy copies the reference only.
+func (client *DedupClient) Copy(ctx context.Context, src, dst string) error {
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *DedupClient) Close() error {
+	return client.inner.Close()
+}
+
+// GC removes the blobs not referenced by any key, and returns the number
+// of removed blobs. It should not run concurrently with writes, which may
+// reference a blob being removed.
+func (client *DedupClient) GC(ctx context.Context) (int, error) {
+	blobs, err := client.inner.List(ctx, client.blobPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	refs, err := client.List(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list references: %w", err)
+	}
+
+	keys := make([]string, len(refs))
+	for i, ref := range refs {
+		keys[i] = ref.Key
+	}
+	infos, err := InfoMulti(ctx, client.inner, keys, 0)
+	var merr *MultiError
+	if errors.As(err, &merr) {
+		// References removed after listing are ignored.
+		for _, err := range merr.Errors {
+			if !isNotFound(err) {
+				return 0, err
+			}
+		}
+	} else if err != nil {
+		return 0, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, info := range infos {
+		if hash, ok := metadataValue(info.Metadata, dedupHashMetaKey); ok {
+			referenced[client.blobKey(hash)] = true
+		}
+	}
+
+	var unreferenced []string
+	for _, blob := range blobs {
+		if !referenced[blob.Key] {
+			unreferenced = append(unreferenced, blob.Key)
+		}
+	}
+	if len(unreferenced) == 0 {
+		return 0, nil
+	}
+
+	err = client.inner.Remove(ctx, unreferenced...)
+	var rerr *RemoveError
+	if errors.As(err, &rerr) {
+		return len(unreferenced) - len(rerr.Results), err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(unreferenced), nil
+}
@@ -0,0 +1,72 @@
+package objclient
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDedupClient(t *testing.T) {
+	mem := newMemClient()
+	client := NewDedupClient(mem, "blobs/", t.TempDir())
+
+	for _, key := range []string{"objclient/a", "objclient/b"} {
+		err := client.Write(ctx, key, strings.NewReader("demo"), &WriteOptions{Metadata: map[string]string{"name": key}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	blobs, _ := mem.List(ctx, "blobs/")
+	if len(blobs) != 1 {
+		t.Fatalf("invalid number of blobs: %v", len(blobs))
+	}
+
+	if data := readString(t, client, "objclient/b"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	info, err := client.Info(ctx, "objclient/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 4 || len(info.Metadata) != 1 || info.Metadata["name"] != "objclient/a" {
+		t.Fatalf("invalid info: %+v", info)
+	}
+
+	items, err := client.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("invalid items: %v", items)
+	}
+
+	client.Write(ctx, "objclient/a", strings.NewReader("demo2"), nil)
+	if n, err := client.GC(ctx); err != nil || n != 0 {
+		t.Fatalf("invalid GC: %v, %v", n, err)
+	}
+	client.Remove(ctx, "objclient/b")
+	if n, err := client.GC(ctx); err != nil || n != 1 {
+		t.Fatalf("invalid GC: %v, %v", n, err)
+	}
+	if data := readString(t, client, "objclient/a"); data != "demo2" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	// The conditions are checked against the hashes.
+	info, err = client.Info(ctx, "objclient/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Write(ctx, "objclient/a", strings.NewReader("demo3"), &WriteOptions{IfMatch: "other"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	if err := client.Write(ctx, "objclient/a", strings.NewReader("demo3"), &WriteOptions{IfNoneMatch: "*"}); !isPreconditionFailed(err) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	if err := client.Write(ctx, "objclient/a", strings.NewReader("demo3"), &WriteOptions{IfMatch: info.ETag}); err != nil {
+		t.Fatalf("failed conditional write: %v", err)
+	}
+	if data := readString(t, client, "objclient/a"); data != "demo3" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+}
@@ -0,0 +1,154 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type DiffOp string
+
+const (
+	// DiffAdded is an object only in b.
+	DiffAdded DiffOp = "added"
+	// DiffRemoved is an object only in a.
+	DiffRemoved DiffOp = "removed"
+	// DiffChanged is an object of different sizes or ETags.
+	DiffChanged DiffOp = "changed"
+)
+
+// DiffEntry is a difference of the objects of two prefixes.
+type DiffEntry struct {
+	Op DiffOp
+	// Key is relative to the prefixes.
+	Key string
+	// A and B are the objects of both prefixes, nil if it's missing.
+	A, B *ObjectItem
+}
+
+// DiffSummary is the number of objects of each kind of Diff.
+type DiffSummary struct {
+	Same    int
+	Added   int
+	Removed int
+	Changed int
+}
+
+// Diff compares the objects of prefixA of a and prefixB of b by their keys
+// relative to the prefixes, and calls fn with the differences in the order
+// of keys until it returns false. The objects are changed if their sizes,
+// or ETags both listed and not of multipart uploads, are different. Both
+// are listed page by page if they are PageListers, so large prefixes are
+// diffed without holding the listings. The differences before a failure of
+// listing are reported, and the error is returned once it's found.
+func Diff(ctx context.Context, a ReadOnlyClient, prefixA string, b ReadOnlyClient, prefixB string, fn func(entry DiffEntry) bool) (*DiffSummary, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	itA := newItemIterator(ctx, a, prefixA, "")
+	itB := newItemIterator(ctx, b, prefixB, "")
+	summary := &DiffSummary{}
+	for {
+		itemA, okA := itA.peek()
+		if !okA && itA.listErr != nil {
+			return summary, fmt.Errorf("failed to list %v: %w", prefixA, itA.listErr)
+		}
+		itemB, okB := itB.peek()
+		if !okB && itB.listErr != nil {
+			return summary, fmt.Errorf("failed to list %v: %w", prefixB, itB.listErr)
+		}
+		if !okA && !okB {
+			return summary, nil
+		}
+
+		var entry DiffEntry
+		keyA := strings.TrimPrefix(itemA.Key, prefixA)
+		keyB := strings.TrimPrefix(itemB.Key, prefixB)
+		switch {
+		case okA && (!okB || keyA < keyB):
+			itA.next()
+			summary.Removed++
+			entry = DiffEntry{Op: DiffRemoved, Key: keyA, A: &itemA}
+		case okB && (!okA || keyB < keyA):
+			itB.next()
+			summary.Added++
+			entry = DiffEntry{Op: DiffAdded, Key: keyB, B: &itemB}
+		default:
+			itA.next()
+			itB.next()
+			if !itemChanged(itemA, itemB) {
+				summary.Same++
+				continue
+			}
+			summary.Changed++
+			entry = DiffEntry{Op: DiffChanged, Key: keyA, A: &itemA, B: &itemB}
+		}
+		if !fn(entry) {
+			return summary, nil
+		}
+	}
+}
+
+// itemChanged returns whether the objects of the same key are different.
+func itemChanged(a, b ObjectItem) bool {
+	if a.Size != b.Size {
+		return true
+	}
+	return ComparableETag(a.ETag) && ComparableETag(b.ETag) && !strings.EqualFold(a.ETag, b.ETag)
+}
+
+// ComparableETag returns whether etag tells the changes of objects across
+// backends. It's false for empty ETags and the ones of multipart uploads,
+// which depend on the part size.
+func ComparableETag(etag string) bool {
+	return etag != "" && !strings.Contains(etag, "-")
+}
+
+// itemIterator iterates the objects of a prefix after a key in the order of
+// keys, the pages are listed in background by listPages.
+type itemIterator struct {
+	pages chan []ObjectItem
+	page  []ObjectItem
+	done  bool
+	// listErr is set before pages is closed, so it's read after peek
+	// returns false.
+	listErr error
+}
+
+func newItemIterator(ctx context.Context, client ReadOnlyClient, prefix, startAfter string) *itemIterator {
+	it := &itemIterator{pages: make(chan []ObjectItem, 1)}
+	go func() {
+		defer close(it.pages)
+		it.listErr = listPages(ctx, client, prefix, startAfter, func(items []ObjectItem) bool {
+			// The pages may be reused by the lister.
+			page := append([]ObjectItem(nil), items...)
+			select {
+			case it.pages <- page:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return it
+}
+
+// peek returns the current item, or false at the end.
+func (it *itemIterator) peek() (ObjectItem, bool) {
+	for len(it.page) == 0 {
+		if it.done {
+			return ObjectItem{}, false
+		}
+		page, ok := <-it.pages
+		if !ok {
+			it.done = true
+			return ObjectItem{}, false
+		}
+		it.page = page
+	}
+	return it.page[0], true
+}
+
+func (it *itemIterator) next() {
+	it.page = it.page[1:]
+}
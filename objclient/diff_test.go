@@ -0,0 +1,57 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := &pageMemClient{memClient: newMemClient()}
+	b := newMemClient()
+	for i := 0; i < 250; i++ {
+		a.put(fmt.Sprintf("src/%03d", i), "a")
+		b.put(fmt.Sprintf("dst/%03d", i), "a")
+	}
+	a.put("src/150", "changed")
+	a.put("src/300", "removed")
+	b.put("dst/000-added", "added")
+	b.put("other", "other")
+	delete(b.objects, "dst/200")
+
+	var ops []string
+	summary, err := Diff(context.Background(), a, "src/", b, "dst/", func(entry DiffEntry) bool {
+		ops = append(ops, string(entry.Op)+" "+entry.Key)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	expect := []string{"added 000-added", "changed 150", "removed 200", "removed 300"}
+	if !reflect.DeepEqual(ops, expect) {
+		t.Fatalf("invalid differences %v", ops)
+	}
+	if *summary != (DiffSummary{Same: 248, Added: 1, Removed: 2, Changed: 1}) {
+		t.Fatalf("invalid summary %+v", *summary)
+	}
+
+	ops = nil
+	if _, err := Diff(context.Background(), a, "src/", b, "dst/", func(entry DiffEntry) bool {
+		ops = append(ops, entry.Key)
+		return false
+	}); err != nil || len(ops) != 1 {
+		t.Fatalf("diff isn't stopped: %v, %v", ops, err)
+	}
+
+	b.fail = func(op, key string) error {
+		if op == "List" {
+			return errors.New("list failed")
+		}
+		return nil
+	}
+	if _, err := Diff(context.Background(), a, "src/", b, "dst/", func(entry DiffEntry) bool { return true }); err == nil {
+		t.Fatalf("failed listing isn't reported")
+	}
+}
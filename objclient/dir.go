@@ -0,0 +1,95 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// The directories of consoles of S3 and OSS are the prefixes of keys
+// ending with "/", and empty directories are kept by zero-byte marker
+// objects whose keys are the prefixes, like the ones of objdav.
+
+// DirMarker returns the key of the marker of the directory prefix, which
+// ends with "/". It's "" for the root.
+func DirMarker(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// EnsurePrefix writes the markers of the directory prefix and its parents
+// missing, like os.MkdirAll.
+func EnsurePrefix(ctx context.Context, client Client, prefix string) error {
+	marker := DirMarker(prefix)
+	for i := 0; i < len(marker); {
+		n := strings.IndexByte(marker[i:], '/')
+		i += n + 1
+		key := marker[:i]
+		exist, err := client.Exist(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to check marker %v: %w", key, err)
+		}
+		if exist {
+			continue
+		}
+		if err := client.Write(ctx, key, bytes.NewReader(nil), nil); err != nil {
+			return fmt.Errorf("failed to write marker %v: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// PrefixEmpty returns whether there are no objects of the directory prefix
+// other than its marker. Only the pages until an object is found are
+// listed if the client is a PageLister.
+func PrefixEmpty(ctx context.Context, client ReadOnlyClient, prefix string) (bool, error) {
+	marker := DirMarker(prefix)
+	if lister, ok := AsPageLister(client); ok {
+		empty := true
+		err := lister.ListPages(ctx, marker, "", func(items []ObjectItem) bool {
+			for _, item := range items {
+				if item.Key != marker {
+					empty = false
+				}
+			}
+			return empty
+		})
+		return empty, err
+	}
+
+	items, err := client.List(ctx, marker)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range items {
+		if item.Key != marker {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RemoveEmptyPrefix removes the marker of the directory prefix if it's
+// empty, or returns ErrPrefixNotEmpty. It succeeds if the marker doesn't
+// exist. The objects written after the check aren't removed, and their
+// directory is still listed by the consoles without the marker.
+func RemoveEmptyPrefix(ctx context.Context, client Client, prefix string) error {
+	marker := DirMarker(prefix)
+	if marker == "" {
+		return fmt.Errorf("%w: the root can't be removed", ErrInvalidKey)
+	}
+	empty, err := PrefixEmpty(ctx, client, marker)
+	if err != nil {
+		return fmt.Errorf("failed to list %v: %w", marker, err)
+	}
+	if !empty {
+		return fmt.Errorf("%w: %v", ErrPrefixNotEmpty, marker)
+	}
+	if err := client.Remove(ctx, marker); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDirMarkers(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	mem.put("a/", "")
+	if err := EnsurePrefix(ctx, mem, "a/b/c"); err != nil {
+		t.Fatalf("failed to ensure prefix: %v", err)
+	}
+	for _, key := range []string{"a/", "a/b/", "a/b/c/"} {
+		if obj, ok := mem.objects[key]; !ok || len(obj.data) != 0 {
+			t.Fatalf("invalid marker %v", key)
+		}
+	}
+	if err := EnsurePrefix(ctx, mem, ""); err != nil || len(mem.objects) != 3 {
+		t.Fatalf("invalid root prefix: %v", err)
+	}
+
+	for _, client := range []Client{mem, &pageMemClient{memClient: mem}} {
+		if empty, err := PrefixEmpty(ctx, client, "a/b/c/"); err != nil || !empty {
+			t.Fatalf("invalid empty %v: %v", empty, err)
+		}
+		if empty, err := PrefixEmpty(ctx, client, "a/b"); err != nil || empty {
+			t.Fatalf("invalid empty %v: %v", empty, err)
+		}
+	}
+
+	if err := RemoveEmptyPrefix(ctx, mem, "a/b"); !errors.Is(err, ErrPrefixNotEmpty) {
+		t.Fatalf("expect not empty: %v", err)
+	}
+	if err := RemoveEmptyPrefix(ctx, mem, "a/b/c"); err != nil {
+		t.Fatalf("failed to remove prefix: %v", err)
+	}
+	if err := RemoveEmptyPrefix(ctx, mem, "a/b/c"); err != nil {
+		t.Fatalf("failed to remove missing prefix: %v", err)
+	}
+	if err := RemoveEmptyPrefix(ctx, mem, "a/b"); err != nil {
+		t.Fatalf("failed to remove prefix: %v", err)
+	}
+	if _, ok := mem.objects["a/b/"]; ok {
+		t.Fatalf("marker isn't removed")
+	}
+	if err := mem.Write(ctx, "a/file", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := RemoveEmptyPrefix(ctx, mem, "a"); !errors.Is(err, ErrPrefixNotEmpty) {
+		t.Fatalf("expect not empty: %v", err)
+	}
+}
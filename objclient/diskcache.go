@@ -0,0 +1,352 @@
+package objclient
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type diskCacheEntry struct {
+	Key  string `json:"key"`
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+}
+
+type diskCacheClient struct {
+	inner    Client
+	dir      string
+	maxBytes int64
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	// The most recently used entry is at the front.
+	lru  *list.List
+	size int64
+}
+
+// NewDiskCacheClient caches the content of objects read through inner in
+// dir, using at most maxBytes of disk space. Cached content is validated
+// by the ETag of the object before serving it, and the least recently used
+// objects are evicted first. Objects cached by a previous client of the same
+// dir are reused.
+func NewDiskCacheClient(inner Client, dir string, maxBytes int64) (Client, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	client := &diskCacheClient{
+		inner:    inner,
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	if err := client.load(); err != nil {
+		return nil, fmt.Errorf("failed to load cache dir: %w", err)
+	}
+
+	if _, ok := AsPageLister(inner); ok {
+		return &diskCachePageClient{client}, nil
+	}
+	return client, nil
+}
+
+// diskCachePageClient is the diskCacheClient of a PageLister.
+type diskCachePageClient struct {
+	*diskCacheClient
+}
+
+func (client *diskCachePageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *diskCacheClient) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(client.dir, hex.EncodeToString(sum[:]))
+}
+
+// load indexes the objects cached in the dir. Files of objects failed to
+// be cached are removed.
+func (client *diskCacheClient) load() error {
+	files, err := os.ReadDir(client.dir)
+	if err != nil {
+		return err
+	}
+
+	type loaded struct {
+		entry   *diskCacheEntry
+		modTime time.Time
+	}
+	var (
+		entries []loaded
+		valid   = make(map[string]bool)
+	)
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		var entry diskCacheEntry
+		data, err := os.ReadFile(filepath.Join(client.dir, name))
+		if err != nil || json.Unmarshal(data, &entry) != nil {
+			continue
+		}
+		path := client.path(entry.Key)
+		stat, err := os.Stat(path)
+		if err != nil || stat.Size() != entry.Size || filepath.Base(path)+".json" != name {
+			continue
+		}
+
+		entries = append(entries, loaded{&entry, stat.ModTime()})
+		valid[name] = true
+		valid[filepath.Base(path)] = true
+	}
+
+	for _, file := range files {
+		if !valid[file.Name()] && !file.IsDir() {
+			os.Remove(filepath.Join(client.dir, file.Name()))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	for _, e := range entries {
+		client.entries[e.entry.Key] = client.lru.PushFront(e.entry)
+		client.size += e.entry.Size
+	}
+	client.evict()
+
+	return nil
+}
+
+// open returns the cached file of key if it's of the etag.
+func (client *diskCacheClient) open(key, etag string) *os.File {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	elem, ok := client.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*diskCacheEntry)
+	if entry.ETag != etag {
+		client.remove(elem)
+		return nil
+	}
+
+	f, err := os.Open(client.path(key))
+	if err != nil {
+		client.remove(elem)
+		return nil
+	}
+	client.lru.MoveToFront(elem)
+
+	return f
+}
+
+func (client *diskCacheClient) add(entry *diskCacheEntry, tmp string) {
+	path := client.path(entry.Key)
+	data, _ := json.Marshal(entry)
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if elem, ok := client.entries[entry.Key]; ok {
+		client.remove(elem)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if err := os.WriteFile(path+".json", data, 0o600); err != nil {
+		os.Remove(path)
+		return
+	}
+
+	client.entries[entry.Key] = client.lru.PushFront(entry)
+	client.size += entry.Size
+	client.evict()
+}
+
+func (client *diskCacheClient) invalidate(key string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if elem, ok := client.entries[key]; ok {
+		client.remove(elem)
+	}
+}
+
+// remove should be called with the mutex locked.
+func (client *diskCacheClient) remove(elem *list.Element) {
+	entry := elem.Value.(*diskCacheEntry)
+	client.lru.Remove(elem)
+	delete(client.entries, entry.Key)
+	client.size -= entry.Size
+
+	path := client.path(entry.Key)
+	os.Remove(path + ".json")
+	os.Remove(path)
+}
+
+// evict should be called with the mutex locked.
+func (client *diskCacheClient) evict() {
+	for client.size > client.maxBytes && client.lru.Len() > 0 {
+		client.remove(client.lru.Back())
+	}
+}
+
+// cacheFiller caches the content read from r, when it's read to the end.
+type cacheFiller struct {
+	client *diskCacheClient
+	r      io.ReadCloser
+	entry  *diskCacheEntry
+	tmp    *os.File
+	n      int64
+	failed bool
+}
+
+func (filler *cacheFiller) Read(data []byte) (int, error) {
+	n, err := filler.r.Read(data)
+	if n > 0 && !filler.failed {
+		if _, werr := filler.tmp.Write(data[:n]); werr != nil {
+			filler.failed = true
+		}
+		filler.n += int64(n)
+	}
+	if err == io.EOF && !filler.failed && filler.tmp != nil {
+		filler.commit()
+	}
+	return n, err
+}
+
+func (filler *cacheFiller) commit() {
+	tmp := filler.tmp
+	filler.tmp = nil
+
+	err := tmp.Close()
+	if err != nil || filler.n != filler.entry.Size {
+		os.Remove(tmp.Name())
+		return
+	}
+	filler.client.add(filler.entry, tmp.Name())
+}
+
+func (filler *cacheFiller) Close() error {
+	if filler.tmp != nil {
+		filler.tmp.Close()
+		os.Remove(filler.tmp.Name())
+		filler.tmp = nil
+	}
+	return filler.r.Close()
+}
+
+func (client *diskCacheClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *diskCacheClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	// Processed and conditional reads aren't cached.
+	offset, length, ok := cachedRange(o)
+	if !ok {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}
+
+	info, err := client.inner.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// The ranges out of the object are left to inner to fail.
+	if offset == 0 || offset < info.Size {
+		if f := client.open(key, info.ETag); f != nil {
+			if offset == 0 && length == 0 {
+				return f, nil
+			}
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				f.Close()
+				return nil, err
+			}
+			if length == 0 {
+				length = info.Size - offset
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(f, length), f}, nil
+		}
+	}
+
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		return nil, err
+	}
+	// The content of ranges isn't cached, which isn't the whole object.
+	if info.ETag == "" || info.Size > client.maxBytes || offset > 0 || length > 0 {
+		return r, nil
+	}
+
+	tmp, err := os.CreateTemp(client.dir, "*.tmp")
+	if err != nil {
+		return r, nil
+	}
+	entry := &diskCacheEntry{Key: key, ETag: info.ETag, Size: info.Size}
+	return &cacheFiller{client: client, r: r, entry: entry, tmp: tmp}, nil
+}
+
+func (client *diskCacheClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *diskCacheClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	defer client.invalidate(key)
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *diskCacheClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *diskCacheClient) Remove(ctx context.Context, keys ...string) error {
+	defer func() {
+		for _, key := range keys {
+			client.invalidate(key)
+		}
+	}()
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *diskCacheClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *diskCacheClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *diskCacheClient) Copy(ctx context.Context, src, dst string) error {
+	defer client.invalidate(dst)
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *diskCacheClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,127 @@
+package objclient
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readString(t *testing.T, client Client, key string) string {
+	t.Helper()
+
+	r, err := client.Read(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func readRangeString(t *testing.T, client Client, key string, offset, length int64) string {
+	t.Helper()
+
+	r, err := client.ReadWithOptions(ctx, key, &ReadOptions{Offset: offset, Length: length})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDiskCacheClient(t *testing.T) {
+	dir := t.TempDir()
+	mem := newMemClient()
+	mem.put("objclient/a", "aaaa")
+	mem.put("objclient/b", "bbbb")
+
+	cache, err := NewDiskCacheClient(mem, dir, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, cache, "objclient/a"); data != "aaaa" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	// Cached content is served without reading from the backend.
+	mem.fail = func(op, key string) error {
+		if op == "Read" {
+			return errors.New("read from backend")
+		}
+		return nil
+	}
+	if data := readString(t, cache, "objclient/a"); data != "aaaa" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	// The cache is reused by new clients of the dir.
+	cache, err = NewDiskCacheClient(mem, dir, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, cache, "objclient/a"); data != "aaaa" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	// Changed objects are read again.
+	mem.fail = nil
+	if err := cache.Write(ctx, "objclient/a", strings.NewReader("cccc"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, cache, "objclient/a"); data != "cccc" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	// Reading b evicts a.
+	if data := readString(t, cache, "objclient/b"); data != "bbbb" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	c := cache.(*diskCacheClient)
+	if _, ok := c.entries["objclient/a"]; ok || c.size != 4 {
+		t.Fatalf("invalid cache entries: %v, size %v", c.entries, c.size)
+	}
+}
+
+func TestDiskCacheClientRange(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "0123456789")
+	cache, err := NewDiskCacheClient(mem, t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ranges read before the object is cached aren't cached.
+	if data := readRangeString(t, cache, "objclient/a", 2, 3); data != "234" {
+		t.Fatalf("invalid range: %q", data)
+	}
+	if c := cache.(*diskCacheClient); len(c.entries) != 0 {
+		t.Fatalf("range is cached: %v", c.entries)
+	}
+	if data := readString(t, cache, "objclient/a"); data != "0123456789" {
+		t.Fatalf("invalid data after range: %q", data)
+	}
+
+	// Ranges are served from the cached object.
+	mem.fail = func(op, key string) error {
+		if op == "Read" {
+			return errors.New("read from backend")
+		}
+		return nil
+	}
+	if data := readRangeString(t, cache, "objclient/a", 7, 0); data != "789" {
+		t.Fatalf("invalid range: %q", data)
+	}
+	if data := readRangeString(t, cache, "objclient/a", 1, 2); data != "12" {
+		t.Fatalf("invalid range: %q", data)
+	}
+}
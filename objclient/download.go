@@ -0,0 +1,287 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	defaultDownloadPartSize    = 16 << 20
+	defaultDownloadConcurrency = 4
+)
+
+// ParallelOptions are the options of ReadParallel and DownloadFile, zero
+// fields are the defaults.
+type ParallelOptions struct {
+	// PartSize is the size of ranges, defaults to 16MiB.
+	PartSize int64
+	// Concurrency is the number of ranges read at the same time, defaults
+	// to 4.
+	Concurrency int
+	// Progress is called while the object is written, whose total is the
+	// size of the object.
+	Progress Progress
+	// Controller adapts the number of ranges read at the same time, which
+	// is at most Concurrency, if it's set.
+	Controller *AdaptiveConcurrency
+}
+
+func (o *ParallelOptions) controller() *AdaptiveConcurrency {
+	if o == nil {
+		return nil
+	}
+	return o.Controller
+}
+
+func (o *ParallelOptions) values() (int64, int) {
+	partSize, concurrency := int64(defaultDownloadPartSize), defaultDownloadConcurrency
+	if o != nil && o.PartSize > 0 {
+		partSize = o.PartSize
+	}
+	if o != nil && o.Concurrency > 0 {
+		concurrency = o.Concurrency
+	}
+	return partSize, concurrency
+}
+
+// objectRange is a range of an object, which is read if the ETag of the
+// object is etag unless it's empty.
+type objectRange struct {
+	key            string
+	offset, length int64
+	etag           string
+}
+
+// readPart reads the range of the object to data of its length.
+func readPart(ctx context.Context, client ReadOnlyClient, part objectRange, data []byte) error {
+	r, err := client.ReadWithOptions(ctx, part.key, &ReadOptions{Offset: part.offset, Length: part.length, IfMatch: part.etag})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("failed to read range %v-%v of %v: %w", part.offset, part.offset+part.length-1, part.key, err)
+	}
+	return nil
+}
+
+// copyPart copies the range of the object to w.
+func copyPart(ctx context.Context, client ReadOnlyClient, key string, offset, length int64, w io.Writer) error {
+	r, err := client.ReadWithOptions(ctx, key, &ReadOptions{Offset: offset, Length: length})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	n, err := copyBuffer(w, io.LimitReader(r, length))
+	if err == nil && n < length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read range %v-%v of %v: %w", offset, offset+length-1, key, err)
+	}
+	return nil
+}
+
+// checkUnchanged returns an error if the object isn't the one of info any
+// more, whose ranges may be mixed with the new one.
+func checkUnchanged(ctx context.Context, client ReadOnlyClient, key string, info *ObjectInfo) error {
+	current, err := client.Info(ctx, key)
+	if err != nil {
+		return err
+	}
+	if current.ETag != info.ETag || current.Size != info.Size {
+		return fmt.Errorf("%w during the download: %v", ErrObjectChanged, key)
+	}
+	return nil
+}
+
+// ReadParallel writes the object of key to w. The ranges of the object are
+// read concurrently into buffers of PartSize, and written in order, and it
+// fails if the object is changed meanwhile. It returns the bytes written.
+func ReadParallel(ctx context.Context, client ReadOnlyClient, key string, w io.Writer, o *ParallelOptions) (int64, error) {
+	partSize, concurrency := o.values()
+	info, err := client.Info(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if info.Size == 0 {
+		return 0, nil
+	}
+
+	var ranges []objectRange
+	for offset := int64(0); offset < info.Size; offset += partSize {
+		ranges = append(ranges, objectRange{key: key, offset: offset, length: min(partSize, info.Size-offset)})
+	}
+	if o != nil && o.Progress != nil {
+		w = &progressWriter{w: w, counter: newProgressCounter(info.Size, o.Progress)}
+	}
+	written, err := readRanges(ctx, client, ranges, partSize, concurrency, o.controller(), w)
+	if err != nil {
+		return written, err
+	}
+	return written, checkUnchanged(ctx, client, key, info)
+}
+
+// readRanges writes the ranges to w in order, which are read concurrently
+// into buffers of partSize, the max length of them. It returns the bytes
+// written.
+func readRanges(ctx context.Context, client ReadOnlyClient, ranges []objectRange, partSize int64, concurrency int, controller *AdaptiveConcurrency, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type part struct {
+		buffer *[]byte
+		data   []byte
+		err    error
+		done   chan struct{}
+	}
+	parts := make(chan *part, concurrency)
+	buffers := make(chan *[]byte, concurrency)
+	pooled := make([]*[]byte, concurrency)
+	for i := range pooled {
+		pooled[i] = getPart(partSize)
+		buffers <- pooled[i]
+	}
+
+	// The buffers are returned to the pool after the reads of them are
+	// stopped.
+	var reading sync.WaitGroup
+	defer func() {
+		cancel()
+		reading.Wait()
+		for _, buffer := range pooled {
+			putPart(buffer)
+		}
+	}()
+
+	reading.Add(1)
+	go func() {
+		defer reading.Done()
+		defer close(parts)
+		for _, r := range ranges {
+			var buffer *[]byte
+			select {
+			case buffer = <-buffers:
+			case <-ctx.Done():
+				return
+			}
+			p := &part{buffer: buffer, data: (*buffer)[:r.length], done: make(chan struct{})}
+			parts <- p
+
+			reading.Add(1)
+			go func() {
+				defer reading.Done()
+				defer close(p.done)
+				release, err := controller.Acquire(ctx)
+				if err != nil {
+					p.err = err
+					return
+				}
+				p.err = readPart(ctx, client, r, p.data)
+				release(p.err)
+			}()
+		}
+	}()
+
+	// The parts are received in order, and the buffers are only reused
+	// after they are written.
+	var written int64
+	for p := range parts {
+		<-p.done
+		if p.err != nil {
+			return written, p.err
+		}
+		n, err := w.Write(p.data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		buffers <- p.buffer
+	}
+	return written, ctx.Err()
+}
+
+// DownloadFile downloads the object of key to path. The ranges of the object
+// are read concurrently and written to their offsets of a temporary file,
+// which is renamed to path if all of them succeed and the object isn't
+// changed meanwhile.
+func DownloadFile(ctx context.Context, client ReadOnlyClient, key, path string, o *ParallelOptions) error {
+	partSize, concurrency := o.values()
+	controller := o.controller()
+	info, err := client.Info(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		once    sync.Once
+		partErr error
+	)
+	var counter *progressCounter
+	if o != nil {
+		counter = newProgressCounter(info.Size, o.Progress)
+	}
+	slots := make(chan struct{}, concurrency)
+	for offset := int64(0); offset < info.Size; offset += partSize {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			release, err := controller.Acquire(ctx)
+			if err != nil {
+				return
+			}
+			w := &progressWriter{w: io.NewOffsetWriter(tmp, offset), counter: counter}
+			err = copyPart(ctx, client, key, offset, min(partSize, info.Size-offset), w)
+			release(err)
+			if err != nil {
+				once.Do(func() { partErr = err })
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if partErr != nil {
+		return partErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkUnchanged(ctx, client, key, info); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,99 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadParallel(t *testing.T) {
+	client := newMemClient()
+	data := strings.Repeat("0123456789", 1000)
+	client.put("key", data)
+	client.put("empty", "")
+
+	var reads atomic.Int32
+	client.fail = func(op, key string) error {
+		if op == "Read" {
+			reads.Add(1)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	var progress int64
+	n, err := ReadParallel(context.Background(), client, "key", &buf, &ParallelOptions{
+		PartSize:    999,
+		Concurrency: 3,
+		Progress:    func(transferred, total int64) { progress = transferred },
+	})
+	if err != nil || n != int64(len(data)) || buf.String() != data {
+		t.Fatalf("invalid parallel read: %v bytes, %v", n, err)
+	}
+	if progress != n {
+		t.Fatalf("invalid progress %v", progress)
+	}
+	if reads.Load() != 11 {
+		t.Fatalf("invalid ranged reads %v", reads.Load())
+	}
+
+	buf.Reset()
+	if n, err := ReadParallel(context.Background(), client, "empty", &buf, nil); err != nil || n != 0 {
+		t.Fatalf("invalid read of empty object: %v bytes, %v", n, err)
+	}
+
+	errFail := errors.New("failed")
+	client.fail = func(op, key string) error {
+		if op == "Read" && reads.Add(1) == 16 {
+			return errFail
+		}
+		return nil
+	}
+	if _, err := ReadParallel(context.Background(), client, "key", &buf, &ParallelOptions{PartSize: 999}); !errors.Is(err, errFail) {
+		t.Fatalf("failed range isn't reported: %v", err)
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	client := newMemClient()
+	data := strings.Repeat("0123456789", 1000)
+	client.put("key", data)
+
+	path := filepath.Join(t.TempDir(), "file")
+	var progress int64
+	err := DownloadFile(context.Background(), client, "key", path, &ParallelOptions{
+		PartSize:    999,
+		Concurrency: 3,
+		Progress:    func(transferred, total int64) { progress = transferred },
+	})
+	if err != nil || progress != int64(len(data)) {
+		t.Fatalf("failed to download file: %v, progress %v", err, progress)
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != data {
+		t.Fatalf("invalid downloaded file: %v", err)
+	}
+
+	// The object is changed after a range is read.
+	var changed atomic.Bool
+	client.fail = func(op, key string) error {
+		if op == "Read" && !changed.Swap(true) {
+			client.put("key", "changed")
+		}
+		return nil
+	}
+	os.Remove(path)
+	if err := DownloadFile(context.Background(), client, "key", path, &ParallelOptions{PartSize: 999}); err == nil {
+		t.Fatalf("changed object is downloaded")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file of changed object is created: %v", err)
+	}
+	if files, _ := filepath.Glob(path + "*"); len(files) != 0 {
+		t.Fatalf("temporary files are left: %v", files)
+	}
+}
@@ -0,0 +1,105 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+type dryRunClient struct {
+	inner  Client
+	logger *slog.Logger
+}
+
+// NewDryRunClient returns a client of inner whose Write, Remove and Copy
+// are logged to logger at slog.LevelInfo instead of run, for previews of
+// tools which change the objects. The reads pass through to inner. Writes
+// read r to the end for the size, and Copy fails like inner if the source
+// doesn't exist.
+func NewDryRunClient(inner Client, logger *slog.Logger) Client {
+	client := &dryRunClient{inner: inner, logger: logger}
+	if _, ok := AsPageLister(inner); ok {
+		return &dryRunPageClient{client}
+	}
+	return client
+}
+
+// dryRunPageClient is the dryRunClient of a PageLister.
+type dryRunPageClient struct {
+	*dryRunClient
+}
+
+func (client *dryRunPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *dryRunClient) log(ctx context.Context, op string, attrs ...slog.Attr) {
+	attrs = append(attrs, slog.String("op", op), slog.Bool("dry_run", true))
+	client.logger.LogAttrs(ctx, slog.LevelInfo, "objclient "+op, attrs...)
+}
+
+func (client *dryRunClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, key)
+}
+
+func (client *dryRunClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *dryRunClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *dryRunClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	// The data is drained, so the writers of pipes aren't blocked.
+	size, err := io.Copy(io.Discard, readerWithContext(ctx, r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data of %v: %w", key, err)
+	}
+
+	attrs := []slog.Attr{slog.String("key", key), slog.Int64("size", size)}
+	if o != nil {
+		if len(o.Metadata) > 0 {
+			attrs = append(attrs, slog.Any("metadata", o.Metadata))
+		}
+		if o.ContentType != "" {
+			attrs = append(attrs, slog.String("content_type", o.ContentType))
+		}
+	}
+	client.log(ctx, "Write", attrs...)
+	return &WriteResult{}, nil
+}
+
+func (client *dryRunClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *dryRunClient) Remove(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		client.log(ctx, "Remove", slog.String("key", key))
+	}
+	return nil
+}
+
+func (client *dryRunClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *dryRunClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *dryRunClient) Copy(ctx context.Context, src, dst string) error {
+	info, err := client.inner.Info(ctx, src)
+	if err != nil {
+		return err
+	}
+	client.log(ctx, "Copy", slog.String("src", src), slog.String("dst", dst), slog.Int64("size", info.Size))
+	return nil
+}
+
+func (client *dryRunClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,51 @@
+package objclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDryRunClient(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	mem := newMemClient()
+	mem.put("a", "demo")
+	client := NewDryRunClient(mem, logger)
+
+	// The changes are logged but not run.
+	if err := client.Write(ctx, "b", strings.NewReader("new data"), &WriteOptions{Metadata: map[string]string{"owner": "me"}}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Copy(ctx, "a", "c"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if err := client.Remove(ctx, "a"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if len(mem.objects) != 1 {
+		t.Fatalf("objects are changed by dry run %v", mem.objects)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "key=b size=8 metadata=map[owner:me]") ||
+		!strings.Contains(lines[1], "src=a dst=c size=4") || !strings.Contains(lines[2], "op=Remove") {
+		t.Fatalf("invalid logs %q", buf.String())
+	}
+
+	// The reads pass through, and Copy fails as inner.
+	r, err := client.Read(ctx, "a")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "demo" {
+		t.Fatalf("invalid data %q", data)
+	}
+	if err := client.Copy(ctx, "missing", "d"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect not found: %v", err)
+	}
+}
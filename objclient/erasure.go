@@ -0,0 +1,528 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The metadata of the shards of ErasureClient.
+const (
+	erasureSizeMetadata        = "ec-size"
+	erasureShardMetadata       = "ec-shard"
+	erasureDigestMetadata      = "ec-md5"
+	erasureShardDigestMetadata = "ec-shard-md5"
+)
+
+type ErasureOptions struct {
+	// WriteQuorum is the number of shards which must be written for writes
+	// and copies to succeed, defaults to one more than the data shards, so
+	// the objects written survive the loss of another backend.
+	WriteQuorum int
+}
+
+// ErasureClient splits objects into Reed-Solomon shards stored in the
+// backends at the same keys, any data shards of which can read the
+// objects. It's experimental.
+//
+// The objects are held in memory by Write and Read. The shards of the
+// failed writes are kept, the reads use the complete version modified
+// last. The data shards are the parts of the objects as is, so backends
+// should be encrypted for confidentiality. The ETags are MD5 digests of the
+// objects. The reads check the digest of each shard, and skip the corrupted
+// shards for the others.
+type ErasureClient struct {
+	backends    []Client
+	rs          *reedSolomon
+	writeQuorum int
+}
+
+// NewErasureClient returns a client of the shards in backends, which have
+// dataShards shards of data and the others of parity. The options can be
+// nil.
+func NewErasureClient(backends []Client, dataShards int, opts *ErasureOptions) (*ErasureClient, error) {
+	if dataShards <= 0 || dataShards > len(backends) || len(backends) > 256 {
+		return nil, fmt.Errorf("invalid %v data shards of %v backends", dataShards, len(backends))
+	}
+	rs, err := newReedSolomon(dataShards, len(backends))
+	if err != nil {
+		return nil, err
+	}
+	client := &ErasureClient{backends: backends, rs: rs, writeQuorum: min(dataShards+1, len(backends))}
+	if opts != nil && opts.WriteQuorum > 0 {
+		if opts.WriteQuorum < dataShards || opts.WriteQuorum > len(backends) {
+			return nil, fmt.Errorf("invalid write quorum %v", opts.WriteQuorum)
+		}
+		client.writeQuorum = opts.WriteQuorum
+	}
+	return client, nil
+}
+
+// eachBackend calls fn with the backends at the same time, and returns the
+// errors of them.
+func (client *ErasureClient) eachBackend(fn func(i int, backend Client) error) []error {
+	errs := make([]error, len(client.backends))
+	var wg sync.WaitGroup
+	for i, backend := range client.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, backend)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// shardInfo is the info of a shard of an object.
+type shardInfo struct {
+	index  int
+	info   *ObjectInfo
+	size   int64
+	digest string
+	// shardDigest is the MD5 of the shard, which is empty for the shards
+	// written before it's stored.
+	shardDigest string
+}
+
+func (client *ErasureClient) parseShard(i int, info *ObjectInfo) (*shardInfo, error) {
+	want := fmt.Sprintf("%v/%v/%v", i, client.rs.dataShards, client.rs.totalShards)
+	if shard := info.Metadata[erasureShardMetadata]; shard != want {
+		return nil, fmt.Errorf("invalid shard %q, expect %q", shard, want)
+	}
+	size, err := strconv.ParseInt(info.Metadata[erasureSizeMetadata], 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid size of shard %q", info.Metadata[erasureSizeMetadata])
+	}
+	if shardSize := (size + int64(client.rs.dataShards) - 1) / int64(client.rs.dataShards); info.Size != shardSize {
+		return nil, fmt.Errorf("invalid shard of %v bytes, expect %v", info.Size, shardSize)
+	}
+	return &shardInfo{
+		index:       i,
+		info:        info,
+		size:        size,
+		digest:      info.Metadata[erasureDigestMetadata],
+		shardDigest: info.Metadata[erasureShardDigestMetadata],
+	}, nil
+}
+
+// shards returns the shards of the complete version of key modified last.
+func (client *ErasureClient) shards(ctx context.Context, key string) ([]*shardInfo, error) {
+	infos := make([]*shardInfo, len(client.backends))
+	errs := client.eachBackend(func(i int, backend Client) error {
+		info, err := backend.Info(ctx, key)
+		if err != nil {
+			return err
+		}
+		infos[i], err = client.parseShard(i, info)
+		return err
+	})
+
+	versions := make(map[string][]*shardInfo)
+	for _, shard := range infos {
+		if shard != nil {
+			version := shard.digest + "/" + strconv.FormatInt(shard.size, 10)
+			versions[version] = append(versions[version], shard)
+		}
+	}
+	var (
+		latest   []*shardInfo
+		modified time.Time
+		found    int
+	)
+	for _, shards := range versions {
+		found = max(found, len(shards))
+		if len(shards) < client.rs.dataShards {
+			continue
+		}
+		var last time.Time
+		for _, shard := range shards {
+			if shard.info.LastModified.After(last) {
+				last = shard.info.LastModified
+			}
+		}
+		if latest == nil || last.After(modified) {
+			latest, modified = shards, last
+		}
+	}
+	if latest != nil {
+		return latest, nil
+	}
+
+	// The object may be complete if the failed backends had the shards.
+	var failed []error
+	for i, err := range errs {
+		if err != nil && !isNotFound(err) {
+			failed = append(failed, fmt.Errorf("backend %v: %w", i, err))
+		}
+	}
+	if len(failed) == 0 || found+len(failed) < client.rs.dataShards {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, key)
+	}
+	return nil, fmt.Errorf("failed to get shards of %v: %w", key, errors.Join(failed...))
+}
+
+func (client *ErasureClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+// ReadWithOptions reads the data shards, or the parity shards for the
+// shards failed to be read. Process, Progress and ReadAhead aren't
+// supported.
+func (client *ErasureClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	offset, length, err := readRange(o)
+	if err != nil {
+		return nil, err
+	}
+	if o != nil && o.Process != "" {
+		return nil, errors.New("process isn't supported by erasure clients")
+	}
+	shards, err := client.shards(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	info := shards[0]
+	if o != nil && o.IfMatch != "" && o.IfMatch != info.digest {
+		return nil, fmt.Errorf("%w: %v", ErrPreconditionFailed, key)
+	}
+
+	data := make([][]byte, len(client.backends))
+	var errs []error
+	read := 0
+	for len(shards) > 0 && read < client.rs.dataShards {
+		batch := shards[:min(client.rs.dataShards-read, len(shards))]
+		shards = shards[len(batch):]
+		batchErrs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for j, shard := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				batchErrs[j] = client.readShard(ctx, key, shard, data)
+			}()
+		}
+		wg.Wait()
+		for j, err := range batchErrs {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("backend %v: %w", batch[j].index, err))
+			} else {
+				read++
+			}
+		}
+	}
+	if read < client.rs.dataShards {
+		return nil, fmt.Errorf("failed to read shards of %v: %w", key, errors.Join(errs...))
+	}
+
+	object, err := client.rs.decode(data, info.size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode shards of %v: %w", key, err)
+	}
+	if sum := md5.Sum(object); hex.EncodeToString(sum[:]) != info.digest {
+		return nil, fmt.Errorf("invalid digest of the shards of %v", key)
+	}
+	object = object[min(offset, int64(len(object))):]
+	if length > 0 && length < int64(len(object)) {
+		object = object[:length]
+	}
+	return io.NopCloser(bytes.NewReader(object)), nil
+}
+
+func (client *ErasureClient) readShard(ctx context.Context, key string, shard *shardInfo, data [][]byte) error {
+	r, err := client.backends[shard.index].ReadWithOptions(ctx, key, &ReadOptions{IfMatch: shard.info.ETag})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	buf := make([]byte, shard.info.Size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	if sum := md5.Sum(buf); shard.shardDigest != "" && hex.EncodeToString(sum[:]) != shard.shardDigest {
+		return errors.New("invalid digest of shard")
+	}
+	data[shard.index] = buf
+	return nil
+}
+
+func (client *ErasureClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// WriteWithResult writes the shards of the object to the backends, and
+// fails if less than the write quorum of them are written. Progress isn't
+// supported.
+func (client *ErasureClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	metadata := make(map[string]string)
+	var expires time.Time
+	if o != nil {
+		for k, v := range o.Metadata {
+			switch k {
+			case erasureSizeMetadata, erasureShardMetadata, erasureDigestMetadata, erasureShardDigestMetadata:
+				return nil, fmt.Errorf("%w: key %q is reserved", ErrInvalidMetadata, k)
+			}
+			metadata[k] = v
+		}
+		expires = o.Expires
+	}
+	if size, ok := writeSize(r, o); ok {
+		r = io.LimitReader(r, size)
+	}
+	object, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", key, err)
+	}
+	sum := md5.Sum(object)
+	digest := hex.EncodeToString(sum[:])
+	metadata[erasureSizeMetadata] = strconv.Itoa(len(object))
+	metadata[erasureDigestMetadata] = digest
+
+	data := client.rs.encode(object)
+	results := make([]*WriteResult, len(client.backends))
+	errs := client.eachBackend(func(i int, backend Client) error {
+		shardMetadata := make(map[string]string, len(metadata)+2)
+		for k, v := range metadata {
+			shardMetadata[k] = v
+		}
+		shardMetadata[erasureShardMetadata] = fmt.Sprintf("%v/%v/%v", i, client.rs.dataShards, client.rs.totalShards)
+		shardSum := md5.Sum(data[i])
+		shardMetadata[erasureShardDigestMetadata] = hex.EncodeToString(shardSum[:])
+		var err error
+		results[i], err = backend.WriteWithResult(ctx, key, bytes.NewReader(data[i]),
+			&WriteOptions{Size: int64(len(data[i])), Metadata: shardMetadata, Expires: expires})
+		return err
+	})
+	if err := client.quorum("write", key, errs); err != nil {
+		return nil, err
+	}
+
+	result := &WriteResult{ETag: digest}
+	for _, r := range results {
+		if r != nil && r.LastModified.After(result.LastModified) {
+			result.LastModified = r.LastModified
+		}
+	}
+	return result, nil
+}
+
+// quorum returns an error if less than the write quorum of backends
+// succeeded.
+func (client *ErasureClient) quorum(op, key string, errs []error) error {
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("backend %v: %w", i, err))
+		}
+	}
+	if len(client.backends)-len(failed) < client.writeQuorum {
+		return fmt.Errorf("failed to %v shards of %v: %w", op, key, errors.Join(failed...))
+	}
+	return nil
+}
+
+func (client *ErasureClient) Exist(ctx context.Context, key string) (bool, error) {
+	if _, err := client.shards(ctx, key); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Remove removes the shards of keys from all the backends.
+func (client *ErasureClient) Remove(ctx context.Context, keys ...string) error {
+	errs := client.eachBackend(func(i int, backend Client) error {
+		if err := backend.Remove(ctx, keys...); err != nil {
+			return fmt.Errorf("failed to remove shards of backend %v: %w", i, err)
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// List lists the keys with the shards in the backends to read them, and
+// gets their sizes by Info.
+func (client *ErasureClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	lists := make([][]ObjectItem, len(client.backends))
+	errs := client.eachBackend(func(i int, backend Client) error {
+		var err error
+		lists[i], err = backend.List(ctx, prefix)
+		return err
+	})
+	var failed []error
+	counts := make(map[string]int)
+	for i, items := range lists {
+		if errs[i] != nil {
+			failed = append(failed, fmt.Errorf("backend %v: %w", i, errs[i]))
+			continue
+		}
+		for _, item := range items {
+			counts[item.Key]++
+		}
+	}
+	if len(client.backends)-len(failed) < client.rs.dataShards {
+		return nil, fmt.Errorf("failed to list %v: %w", prefix, errors.Join(failed...))
+	}
+
+	var keys []string
+	for key, count := range counts {
+		if count >= client.rs.dataShards || len(failed) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return client.listedItems(ctx, keys)
+}
+
+// listedItems returns the items of the objects of keys by Info, the ones
+// not found are skipped.
+func (client *ErasureClient) listedItems(ctx context.Context, keys []string) ([]ObjectItem, error) {
+	infos, err := InfoMulti(ctx, client, keys, 0)
+	var merr *MultiError
+	if errors.As(err, &merr) {
+		for key, err := range merr.Errors {
+			if !isNotFound(err) {
+				return nil, fmt.Errorf("failed to get info of %v: %w", key, err)
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	items := make([]ObjectItem, 0, len(infos))
+	for _, key := range keys {
+		if info, ok := infos[key]; ok {
+			items = append(items, ObjectItem{Key: key, Size: info.Size, LastModified: info.LastModified, ETag: info.ETag})
+		}
+	}
+	return items, nil
+}
+
+// ListPages implements PageLister like List by merging the pages of the
+// backends. The objects after a backend failed are listed if any of the
+// others has a shard of them.
+func (client *ErasureClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	its := make([]*itemIterator, len(client.backends))
+	for i, backend := range client.backends {
+		its[i] = newItemIterator(ctx, backend, prefix, startAfter)
+	}
+	failed := make(map[int]error)
+	var keys []string
+	for {
+		var key string
+		found := false
+		for i, it := range its {
+			item, ok := it.peek()
+			if !ok {
+				if it.listErr != nil && failed[i] == nil {
+					failed[i] = fmt.Errorf("backend %v: %w", i, it.listErr)
+				}
+				continue
+			}
+			if !found || item.Key < key {
+				key, found = item.Key, true
+			}
+		}
+		if len(client.backends)-len(failed) < client.rs.dataShards {
+			var errs []error
+			for _, err := range failed {
+				errs = append(errs, err)
+			}
+			return fmt.Errorf("failed to list %v: %w", prefix, errors.Join(errs...))
+		}
+		if !found {
+			break
+		}
+
+		count := 0
+		for _, it := range its {
+			if item, ok := it.peek(); ok && item.Key == key {
+				it.next()
+				count++
+			}
+		}
+		if count >= client.rs.dataShards || len(failed) > 0 {
+			keys = append(keys, key)
+		}
+		if len(keys) == listPageSize {
+			items, err := client.listedItems(ctx, keys)
+			if err != nil {
+				return err
+			}
+			if !fn(items) {
+				return nil
+			}
+			keys = keys[:0]
+		}
+	}
+	if len(keys) > 0 {
+		items, err := client.listedItems(ctx, keys)
+		if err != nil {
+			return err
+		}
+		fn(items)
+	}
+	return nil
+}
+
+// listsPages reports whether the backends are PageListers, for
+// AsPageLister.
+func (client *ErasureClient) listsPages() bool {
+	for _, backend := range client.backends {
+		if _, ok := AsPageLister(backend); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Info returns the info of the object, whose ETag is the MD5 digest of it.
+func (client *ErasureClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	shards, err := client.shards(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{Size: shards[0].size, ETag: shards[0].digest}
+	for _, shard := range shards {
+		if shard.info.LastModified.After(info.LastModified) {
+			info.LastModified = shard.info.LastModified
+		}
+	}
+	for k, v := range shards[0].info.Metadata {
+		switch k {
+		case erasureSizeMetadata, erasureShardMetadata, erasureDigestMetadata, erasureShardDigestMetadata:
+			continue
+		}
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[k] = v
+	}
+	return info, nil
+}
+
+// Copy copies the shards in the backends, and fails if less than the write
+// quorum of them are copied.
+func (client *ErasureClient) Copy(ctx context.Context, src, dst string) error {
+	errs := client.eachBackend(func(i int, backend Client) error {
+		return backend.Copy(ctx, src, dst)
+	})
+	return client.quorum("copy", dst, errs)
+}
+
+func (client *ErasureClient) Close() error {
+	return closeAll(client.backends...)
+}
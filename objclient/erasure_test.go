@@ -0,0 +1,140 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	rs, err := newReedSolomon(3, 6)
+	if err != nil {
+		t.Fatalf("failed to create code: %v", err)
+	}
+	for _, size := range []int{0, 1, 100, 1000} {
+		data := make([]byte, size)
+		rand.Read(data)
+		shards := rs.encode(data)
+
+		// Any 3 of the shards decode the data.
+		for mask := 0; mask < 1<<6; mask++ {
+			var selected [][]byte
+			count := 0
+			for i := range shards {
+				if mask&(1<<i) != 0 {
+					selected = append(selected, shards[i])
+					count++
+				} else {
+					selected = append(selected, nil)
+				}
+			}
+			if count != 3 {
+				continue
+			}
+			decoded, err := rs.decode(selected, int64(size))
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Fatalf("invalid decoding of shards %b of %v bytes: %v", mask, size, err)
+			}
+		}
+	}
+}
+
+func TestErasureClient(t *testing.T) {
+	ctx := context.Background()
+	mems := make([]*memClient, 5)
+	backends := make([]Client, len(mems))
+	for i := range mems {
+		mems[i] = newMemClient()
+		backends[i] = mems[i]
+	}
+	client, err := NewErasureClient(backends, 3, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	data := strings.Repeat("0123456789", 10)
+	if err := client.Write(ctx, "a", strings.NewReader(data), &WriteOptions{Metadata: map[string]string{"owner": "me"}}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Write(ctx, "b", strings.NewReader(""), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	read := func(key string, o *ReadOptions) string {
+		r, err := client.ReadWithOptions(ctx, key, o)
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", key, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", key, err)
+		}
+		return string(data)
+	}
+
+	// The objects are read without 2 of the backends.
+	down := func(op, key string) error { return errors.New("unavailable") }
+	mems[0].fail, mems[3].fail = down, down
+	if got := read("a", nil); got != data {
+		t.Fatalf("invalid data %q", got)
+	}
+	if got := read("a", &ReadOptions{Offset: 95, Length: 3}); got != "567" {
+		t.Fatalf("invalid range %q", got)
+	}
+	if got := read("b", nil); got != "" {
+		t.Fatalf("invalid data of empty object %q", got)
+	}
+	info, err := client.Info(ctx, "a")
+	if err != nil || info.Size != int64(len(data)) || info.Metadata["owner"] != "me" || len(info.Metadata) != 1 {
+		t.Fatalf("invalid info %+v: %v", info, err)
+	}
+	items, err := client.List(ctx, "")
+	if err != nil || len(items) != 2 || items[0].Key != "a" || items[0].Size != int64(len(data)) || items[1].Key != "b" {
+		t.Fatalf("invalid items %+v: %v", items, err)
+	}
+
+	// The writes fail without the quorum, but the complete version is
+	// still read.
+	mems[1].fail = down
+	if err := client.Write(ctx, "a", strings.NewReader("new"), nil); err == nil {
+		t.Fatalf("write without quorum succeeded")
+	}
+	mems[0].fail, mems[1].fail, mems[3].fail = nil, nil, nil
+	if got := read("a", nil); got != data {
+		t.Fatalf("invalid data after failed write %q", got)
+	}
+
+	// The corrupted shards are skipped, the reads fail without enough of
+	// the others.
+	if err := client.Write(ctx, "c", strings.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	corrupt := func(mem *memClient) {
+		obj := mem.objects["c"]
+		obj.data = append([]byte{obj.data[0] ^ 1}, obj.data[1:]...)
+		mem.objects["c"] = obj
+	}
+	corrupt(mems[0])
+	if got := read("c", nil); got != data {
+		t.Fatalf("invalid data of corrupted shard %q", got)
+	}
+	corrupt(mems[1])
+	corrupt(mems[2])
+	if _, err := client.Read(ctx, "c"); err == nil {
+		t.Fatalf("corrupted shards are read")
+	}
+
+	if err := client.Remove(ctx, "a", "b"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if exist, err := client.Exist(ctx, "a"); err != nil || exist {
+		t.Fatalf("invalid exist %v: %v", exist, err)
+	}
+	if _, err := client.Read(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect not found: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+// The errors of S3 and OSS clients wrap these sentinels by the kind of the
+// backend errors, which are still available with errors.As.
+var (
+	ErrNotFound           = errors.New("object not found")
+	ErrAccessDenied       = errors.New("access denied")
+	ErrBucketNotFound     = errors.New("bucket not found")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrQuotaExceeded is returned by the backends and quota clients.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrThrottled     = errors.New("request throttled")
+	ErrUnreachable   = errors.New("backend unreachable")
+	ErrInvalidKey    = errors.New("invalid key")
+	// ErrStalled is returned by transfers canceled for no progress in the
+	// ReadStallTimeout of configs.
+	ErrStalled = errors.New("transfer stalled")
+	// ErrObjectChanged is returned by reads of multiple requests if the
+	// object is overwritten between them.
+	ErrObjectChanged = errors.New("object changed")
+	// ErrNotVerified is returned by writes of verifying clients if the
+	// object isn't read back as written.
+	ErrNotVerified = errors.New("write not verified")
+	// ErrPrefixNotEmpty is returned by RemoveEmptyPrefix if there are
+	// objects of the prefix.
+	ErrPrefixNotEmpty = errors.New("prefix not empty")
+)
+
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// accessDeniedCodes are the error codes of both S3 and OSS for rejected
+// credentials.
+var accessDeniedCodes = map[string]bool{
+	"AccessDenied":          true,
+	"InvalidAccessKeyId":    true,
+	"SignatureDoesNotMatch": true,
+	"InvalidSecurityToken":  true,
+}
+
+// quotaExceededCodes are the error codes of S3, OSS and MinIO for the
+// exceeded capacity of buckets or accounts.
+var quotaExceededCodes = map[string]bool{
+	"QuotaExceeded":                  true,
+	"InsufficientStorage":            true,
+	"XMinioAdminBucketQuotaExceeded": true,
+	"XMinioStorageFull":              true,
+}
+
+// conflictCodes are the error codes of S3 and OSS for the conditional
+// requests failed.
+var conflictCodes = map[string]bool{
+	"PreconditionFailed":         true,
+	"ConditionalRequestConflict": true,
+	"FileAlreadyExists":          true,
+}
+
+// throttledCodes are the error codes of S3, OSS and MinIO for the requests
+// rejected by rate limits.
+var throttledCodes = map[string]bool{
+	"SlowDown":                         true,
+	"Throttling":                       true,
+	"ThrottlingException":              true,
+	"RequestThrottled":                 true,
+	"TooManyRequests":                  true,
+	"RequestLimitExceeded":             true,
+	"QpsLimitExceeded":                 true,
+	"DownloadTrafficRateLimitExceeded": true,
+	"UploadTrafficRateLimitExceeded":   true,
+	"XMinioServerNotInitialized":       true,
+}
+
+// errorKind returns the sentinel of the error code and HTTP status of a
+// backend error, or nil if it's none of them.
+func errorKind(code string, status int) error {
+	switch {
+	case code == "NoSuchBucket":
+		return ErrBucketNotFound
+	case code == "NoSuchKey" || status == http.StatusNotFound:
+		return ErrNotFound
+	case accessDeniedCodes[code] || status == http.StatusForbidden:
+		return ErrAccessDenied
+	case conflictCodes[code] || status == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case quotaExceededCodes[code] || status == http.StatusInsufficientStorage:
+		return ErrQuotaExceeded
+	case throttledCodes[code] || status == http.StatusTooManyRequests:
+		return ErrThrottled
+	}
+	return nil
+}
+
+// translateError wraps the backend error err with its sentinel. Errors of
+// no known kind are returned as is.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	code, status, ok := backendError(err)
+	if !ok {
+		return err
+	}
+	kind := errorKind(code, status)
+	if kind == nil || errors.Is(err, kind) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// isNotFound returns whether err is the not found error of a backend.
+func isNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var merr minio.ErrorResponse
+	if errors.As(err, &merr) {
+		return merr.StatusCode == http.StatusNotFound
+	}
+	var serr oss.ServiceError
+	return errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound
+}
+
+// isPreconditionFailed returns whether err is the failure of IfMatch.
+func isPreconditionFailed(err error) bool {
+	if errors.Is(err, ErrPreconditionFailed) {
+		return true
+	}
+	var merr minio.ErrorResponse
+	if errors.As(err, &merr) {
+		return merr.StatusCode == http.StatusPreconditionFailed
+	}
+	var serr oss.ServiceError
+	return errors.As(err, &serr) && serr.StatusCode == http.StatusPreconditionFailed
+}
+
+// retryableCodes are the error codes of S3 and OSS for transient failures
+// of the servers.
+var retryableCodes = map[string]bool{
+	"InternalError":      true,
+	"ServiceUnavailable": true,
+	"RequestTimeout":     true,
+	"OperationAborted":   true,
+}
+
+// timeoutCodes are the error codes of S3 and OSS for requests which timed
+// out on the servers.
+var timeoutCodes = map[string]bool{
+	"RequestTimeout": true,
+	"GatewayTimeout": true,
+}
+
+// backendError returns the error code and HTTP status of a backend error.
+func backendError(err error) (string, int, bool) {
+	var merr minio.ErrorResponse
+	if errors.As(err, &merr) {
+		return merr.Code, merr.StatusCode, true
+	}
+	var serr oss.ServiceError
+	if errors.As(err, &serr) {
+		return serr.Code, serr.StatusCode, true
+	}
+	return "", 0, false
+}
+
+// IsThrottle returns whether err is the rejection of a rate limit of the
+// backend, after which requests should be sent slower.
+func IsThrottle(err error) bool {
+	return errors.Is(translateError(err), ErrThrottled)
+}
+
+// IsTimeout returns whether err is a timeout of the client, the network or
+// the backend.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrStalled) {
+		return true
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	code, status, ok := backendError(err)
+	return ok && (timeoutCodes[code] || status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout)
+}
+
+// IsRetryable returns whether the operation failed with err may succeed if
+// it's retried: throttles, timeouts, network errors and server errors of
+// the backend. Errors of the request like not found and access denied, and
+// cancellation aren't retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsThrottle(err) || IsTimeout(err) || errors.Is(err, ErrUnreachable) {
+		return true
+	}
+	if code, status, ok := backendError(err); ok {
+		return retryableCodes[code] || status >= http.StatusInternalServerError
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
@@ -0,0 +1,137 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}, ErrNotFound},
+		{minio.ErrorResponse{StatusCode: http.StatusNotFound}, ErrNotFound},
+		{minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: http.StatusNotFound}, ErrBucketNotFound},
+		{minio.ErrorResponse{Code: "SignatureDoesNotMatch", StatusCode: http.StatusForbidden}, ErrAccessDenied},
+		{minio.ErrorResponse{Code: "PreconditionFailed", StatusCode: http.StatusPreconditionFailed}, ErrPreconditionFailed},
+		{minio.ErrorResponse{Code: "XMinioAdminBucketQuotaExceeded", StatusCode: http.StatusBadRequest}, ErrQuotaExceeded},
+		{minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}, ErrThrottled},
+		{oss.ServiceError{Code: "NoSuchKey", StatusCode: http.StatusNotFound}, ErrNotFound},
+		{oss.ServiceError{Code: "NoSuchBucket", StatusCode: http.StatusNotFound}, ErrBucketNotFound},
+		{oss.ServiceError{Code: "InvalidAccessKeyId", StatusCode: http.StatusForbidden}, ErrAccessDenied},
+		{oss.ServiceError{Code: "FileAlreadyExists", StatusCode: http.StatusConflict}, ErrPreconditionFailed},
+		{oss.ServiceError{Code: "QpsLimitExceeded", StatusCode: http.StatusServiceUnavailable}, ErrThrottled},
+		{fmt.Errorf("failed to read: %w", oss.ServiceError{StatusCode: http.StatusTooManyRequests}), ErrThrottled},
+	}
+	for _, test := range tests {
+		err := translateError(test.err)
+		if !errors.Is(err, test.kind) {
+			t.Fatalf("invalid kind of %v: %v", test.err, err)
+		}
+		var merr minio.ErrorResponse
+		var serr oss.ServiceError
+		if !errors.As(err, &merr) && !errors.As(err, &serr) {
+			t.Fatalf("backend error isn't wrapped: %v", err)
+		}
+	}
+
+	other := minio.ErrorResponse{Code: "InternalError", StatusCode: http.StatusInternalServerError}
+	if err := translateError(other); err != error(other) {
+		t.Fatalf("unknown error is translated: %v", err)
+	}
+	if err := translateError(nil); err != nil {
+		t.Fatalf("nil is translated: %v", err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	slowDown := minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}
+	tests := []struct {
+		err                          error
+		retryable, throttle, timeout bool
+	}{
+		{nil, false, false, false},
+		{context.Canceled, false, false, false},
+		{context.DeadlineExceeded, true, false, true},
+		{fmt.Errorf("%w: %w", ErrStalled, io.ErrUnexpectedEOF), true, false, true},
+		{&net.OpError{Op: "dial", Err: timeoutError{}}, true, false, true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true, false, false},
+		{io.ErrUnexpectedEOF, true, false, false},
+		{slowDown, true, true, false},
+		{translateError(slowDown), true, true, false},
+		{oss.ServiceError{Code: "QpsLimitExceeded", StatusCode: http.StatusServiceUnavailable}, true, true, false},
+		{minio.ErrorResponse{Code: "RequestTimeout", StatusCode: http.StatusBadRequest}, true, false, true},
+		{minio.ErrorResponse{Code: "InternalError", StatusCode: http.StatusInternalServerError}, true, false, false},
+		{oss.ServiceError{StatusCode: http.StatusBadGateway}, true, false, false},
+		{minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}, false, false, false},
+		{oss.ServiceError{Code: "AccessDenied", StatusCode: http.StatusForbidden}, false, false, false},
+		{fmt.Errorf("%w: prefix", ErrUnreachable), true, false, false},
+		{errors.New("invalid argument"), false, false, false},
+	}
+	for _, test := range tests {
+		if IsRetryable(test.err) != test.retryable || IsThrottle(test.err) != test.throttle || IsTimeout(test.err) != test.timeout {
+			t.Fatalf("invalid classification of %v: retryable %v, throttle %v, timeout %v",
+				test.err, IsRetryable(test.err), IsThrottle(test.err), IsTimeout(test.err))
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("X-Oss-Request-Id", "request")
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != http.MethodHead {
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+		}
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	for _, backend := range []string{"s3", "oss"} {
+		var (
+			client Client
+			err    error
+		)
+		opts := []Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key")}
+		if backend == "s3" {
+			client, err = NewS3("bucket", append(opts, WithPathStyle(true))...)
+		} else {
+			client, err = NewOSS("bucket", opts...)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %v client: %v", backend, err)
+		}
+
+		if _, err := client.Read(context.Background(), "key"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("invalid read error of %v: %v", backend, err)
+		}
+		if _, err := client.ReadWithOptions(context.Background(), "key", &ReadOptions{Offset: 10}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("invalid ranged read error of %v: %v", backend, err)
+		}
+		if _, err := client.Info(context.Background(), "key"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("invalid info error of %v: %v", backend, err)
+		}
+		if ok, err := client.Exist(context.Background(), "key"); ok || err != nil {
+			t.Fatalf("invalid existence of %v: %v, %v", backend, ok, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventBusClient delivers the events of the writes, removes and copies
+// through it to the subscribers in process, e.g. for indexing and cache
+// invalidation. The mutations not through it aren't delivered.
+type EventBusClient struct {
+	inner Client
+
+	mutex  sync.Mutex
+	subs   map[*subscription]bool
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewEventBusClient returns a client publishing the mutations of inner.
+func NewEventBusClient(inner Client) *EventBusClient {
+	return &EventBusClient{inner: inner, subs: make(map[*subscription]bool), closed: make(chan struct{})}
+}
+
+// subscription queues the events of a subscriber, so slow subscribers
+// neither block the operations nor lose events.
+type subscription struct {
+	prefix string
+	events []EventType
+
+	mutex  sync.Mutex
+	queue  []Event
+	signal chan struct{}
+}
+
+func (sub *subscription) push(event Event) {
+	sub.mutex.Lock()
+	sub.queue = append(sub.queue, event)
+	sub.mutex.Unlock()
+
+	select {
+	case sub.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (sub *subscription) pop() (Event, bool) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if len(sub.queue) == 0 {
+		return Event{}, false
+	}
+	event := sub.queue[0]
+	sub.queue[0] = Event{}
+	sub.queue = sub.queue[1:]
+	return event, true
+}
+
+// Subscribe delivers the events of objects under prefix in the order they
+// are published, until ctx is done or the client is closed, then the
+// returned channel is closed. The events are queued without limit until
+// they are received. Empty events means all event types.
+func (client *EventBusClient) Subscribe(ctx context.Context, prefix string, events ...EventType) (<-chan Event, error) {
+	sub := &subscription{prefix: prefix, events: events, signal: make(chan struct{}, 1)}
+	client.mutex.Lock()
+	select {
+	case <-client.closed:
+		client.mutex.Unlock()
+		return nil, errors.New("client is closed")
+	default:
+	}
+	client.subs[sub] = true
+	client.mutex.Unlock()
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		defer func() {
+			client.mutex.Lock()
+			delete(client.subs, sub)
+			client.mutex.Unlock()
+		}()
+
+		for {
+			event, ok := sub.pop()
+			if !ok {
+				select {
+				case <-sub.signal:
+					continue
+				case <-ctx.Done():
+					return
+				case <-client.closed:
+					return
+				}
+			}
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			case <-client.closed:
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (client *EventBusClient) publish(event Event) {
+	event.Time = time.Now()
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for sub := range client.subs {
+		if matchEvent(event, sub.prefix, sub.events) {
+			sub.push(event)
+		}
+	}
+}
+
+func (client *EventBusClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, key)
+}
+
+func (client *EventBusClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *EventBusClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// countReader counts the bytes read from r.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (reader *countReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.n += int64(n)
+	return n, err
+}
+
+func (client *EventBusClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	// The reader is only wrapped if its size can't be detected, which the
+	// wrapper would hide.
+	size, ok := writeSize(r, o)
+	var counter *countReader
+	if !ok {
+		counter = &countReader{r: r}
+		r = counter
+	}
+	result, err := client.inner.WriteWithResult(ctx, key, r, o)
+	if err != nil {
+		return nil, err
+	}
+	if counter != nil {
+		size = counter.n
+	}
+	client.publish(Event{Type: EventObjectCreated, Key: key, Size: size, ETag: result.ETag})
+	return result, nil
+}
+
+func (client *EventBusClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+// Remove delivers the events of the keys removed, even if the others
+// failed.
+func (client *EventBusClient) Remove(ctx context.Context, keys ...string) error {
+	err := client.inner.Remove(ctx, keys...)
+	failed := make(map[string]bool)
+	var rerr *RemoveError
+	switch {
+	case errors.As(err, &rerr):
+		for _, result := range rerr.Results {
+			failed[result.Key] = true
+		}
+	case err != nil:
+		return err
+	}
+	for _, key := range keys {
+		if !failed[key] {
+			client.publish(Event{Type: EventObjectRemoved, Key: key})
+		}
+	}
+	return err
+}
+
+func (client *EventBusClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+// ListPages implements PageLister by the pages of inner, or of its List.
+func (client *EventBusClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return listPages(ctx, client.inner, prefix, startAfter, fn)
+}
+
+// listsPages reports whether inner is a PageLister, for AsPageLister.
+func (client *EventBusClient) listsPages() bool {
+	_, ok := AsPageLister(client.inner)
+	return ok
+}
+
+func (client *EventBusClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *EventBusClient) Copy(ctx context.Context, src, dst string) error {
+	if err := client.inner.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	client.publish(Event{Type: EventObjectCopied, Key: dst, Source: src})
+	return nil
+}
+
+// Close ends the subscriptions and closes the inner client.
+func (client *EventBusClient) Close() error {
+	client.once.Do(func() {
+		client.mutex.Lock()
+		close(client.closed)
+		client.mutex.Unlock()
+	})
+	return client.inner.Close()
+}
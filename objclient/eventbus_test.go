@@ -0,0 +1,73 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, ch <-chan Event) Event {
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout receiving event")
+	}
+	return Event{}
+}
+
+func TestEventBusClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem := newMemClient()
+	client := NewEventBusClient(mem)
+	all, err := client.Subscribe(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	removed, err := client.Subscribe(ctx, "a/", EventObjectRemoved)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	// The size of readers without Len is counted.
+	if err := client.Write(ctx, "a/1", io.MultiReader(strings.NewReader("data")), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Copy(ctx, "a/1", "b/1"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	mem.fail = func(op, key string) error {
+		if op == "Remove" && key == "b/1" {
+			return errors.New("denied")
+		}
+		return nil
+	}
+	if err := client.Remove(ctx, "a/1", "b/1"); err == nil {
+		t.Fatalf("failed remove isn't reported")
+	}
+
+	if event := receiveEvent(t, all); event.Type != EventObjectCreated || event.Key != "a/1" || event.Size != 4 || event.ETag == "" {
+		t.Fatalf("invalid write event %+v", event)
+	}
+	if event := receiveEvent(t, all); event.Type != EventObjectCopied || event.Key != "b/1" || event.Source != "a/1" {
+		t.Fatalf("invalid copy event %+v", event)
+	}
+	if event := receiveEvent(t, all); event.Type != EventObjectRemoved || event.Key != "a/1" {
+		t.Fatalf("invalid remove event %+v", event)
+	}
+	if event := receiveEvent(t, removed); event.Type != EventObjectRemoved || event.Key != "a/1" {
+		t.Fatalf("invalid filtered event %+v", event)
+	}
+
+	client.Close()
+	if _, ok := <-all; ok {
+		t.Fatalf("events are delivered after closed")
+	}
+	if _, err := client.Subscribe(ctx, ""); err == nil {
+		t.Fatalf("closed client is subscribed")
+	}
+}
@@ -0,0 +1,391 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+type FailoverPolicy struct {
+	// ShouldFailover decides whether an error of the primary is served by
+	// the secondary. By default all errors but not found and cancellation
+	// fail over.
+	ShouldFailover func(err error) bool
+	// ReplayWrites makes Write, Copy and Remove fail over to the secondary
+	// too. They are replayed to the primary once it recovers, and reads of
+	// these keys are served by the secondary until then. The replays of keys
+	// changed on the primary later are dropped. Replays are kept in memory
+	// only.
+	ReplayWrites bool
+	// ProbeInterval is the interval of replaying to the primary, defaults
+	// to 30 seconds.
+	ProbeInterval time.Duration
+	// OnReplayError is called when replaying a key to the primary failed.
+	OnReplayError func(key string, err error)
+}
+
+func shouldFailover(err error) bool {
+	return !isNotFound(err) && !errors.Is(err, context.Canceled)
+}
+
+// failoverReplay is a change of a key to replay to the primary.
+type failoverReplay struct {
+	// remove is whether the key should be removed from the primary, or
+	// copied otherwise.
+	remove bool
+	// creds are the credentials of ContextWithCredentials of the change.
+	creds *Credentials
+}
+
+type failoverClient struct {
+	primary   Client
+	secondary Client
+	policy    FailoverPolicy
+
+	mutex     sync.Mutex
+	replays   map[string]*failoverReplay
+	replaying bool
+
+	// ctx is canceled by Close, which waits for the replays to stop.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	replayers sync.WaitGroup
+}
+
+// NewFailoverClient serves operations by primary, and fails over to
+// secondary when primary failed. The policy can be nil for the defaults.
+func NewFailoverClient(primary, secondary Client, policy *FailoverPolicy) Client {
+	var p FailoverPolicy
+	if policy != nil {
+		p = *policy
+	}
+	if p.ShouldFailover == nil {
+		p.ShouldFailover = shouldFailover
+	}
+	if p.ProbeInterval <= 0 {
+		p.ProbeInterval = 30 * time.Second
+	}
+
+	client := &failoverClient{
+		primary:   primary,
+		secondary: secondary,
+		policy:    p,
+		replays:   make(map[string]*failoverReplay),
+	}
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	if _, ok := AsPageLister(primary); ok {
+		return &failoverPageClient{client}
+	}
+	return client
+}
+
+// failoverPageClient is the failoverClient of a PageLister primary. If the
+// primary fails over in the middle, the listing continues on the secondary
+// after the last key listed.
+type failoverPageClient struct {
+	*failoverClient
+}
+
+func (client *failoverPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	last, stopped := startAfter, false
+	err := client.primary.(PageLister).ListPages(ctx, prefix, startAfter, func(items []ObjectItem) bool {
+		if len(items) > 0 {
+			last = items[len(items)-1].Key
+		}
+		stopped = !fn(items)
+		return !stopped
+	})
+	if err != nil && !stopped && client.policy.ShouldFailover(err) {
+		return listPages(ctx, client.secondary, prefix, last, fn)
+	}
+	return err
+}
+
+func (client *failoverClient) pendingReplay(key string) bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	_, ok := client.replays[key]
+	return ok
+}
+
+func (client *failoverClient) addReplay(ctx context.Context, key string, remove bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.replays[key] = &failoverReplay{remove: remove, creds: tenantCredentials(ctx)}
+	if !client.replaying {
+		client.replaying = true
+		client.replayers.Add(1)
+		go client.replay()
+	}
+}
+
+// dropReplay drops the replays of keys changed on the primary, which would
+// overwrite the changes by older ones.
+func (client *failoverClient) dropReplay(keys ...string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(client.replays, key)
+	}
+}
+
+// replay runs until all the replays are done, or the client is closed.
+func (client *failoverClient) replay() {
+	defer client.replayers.Done()
+
+	ticker := time.NewTicker(client.policy.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-client.ctx.Done():
+			return
+		}
+
+		client.mutex.Lock()
+		replays := make(map[string]*failoverReplay, len(client.replays))
+		for key, replay := range client.replays {
+			replays[key] = replay
+		}
+		client.mutex.Unlock()
+
+		for key, replay := range replays {
+			if client.ctx.Err() != nil {
+				return
+			}
+			// The replays dropped after copying them aren't made.
+			client.mutex.Lock()
+			current := client.replays[key] == replay
+			client.mutex.Unlock()
+			if !current {
+				continue
+			}
+			err := client.replayKey(key, replay)
+			if err != nil {
+				if client.policy.OnReplayError != nil {
+					client.policy.OnReplayError(key, err)
+				}
+				continue
+			}
+
+			client.mutex.Lock()
+			// The key may be changed again during replaying.
+			if r, ok := client.replays[key]; ok && r == replay {
+				delete(client.replays, key)
+			}
+			client.mutex.Unlock()
+		}
+
+		client.mutex.Lock()
+		if len(client.replays) == 0 {
+			client.replaying = false
+			client.mutex.Unlock()
+			return
+		}
+		client.mutex.Unlock()
+	}
+}
+
+func (client *failoverClient) replayKey(key string, replay *failoverReplay) error {
+	ctx, cancel := context.WithTimeout(credentialsContext(client.ctx, replay.creds), 10*time.Minute)
+	defer cancel()
+
+	if replay.remove {
+		return client.primary.Remove(ctx, key)
+	}
+	return copyObject(ctx, client.secondary, key, client.primary, key)
+}
+
+// copyObject copies an object between clients.
+func copyObject(ctx context.Context, src Client, srcKey string, dst Client, dstKey string) error {
+	info, err := src.Info(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	r, err := src.Read(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.Write(ctx, dstKey, r, &WriteOptions{Size: info.Size, Metadata: info.Metadata})
+}
+
+func (client *failoverClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *failoverClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if client.pendingReplay(key) {
+		return client.secondary.ReadWithOptions(ctx, key, o)
+	}
+	r, err := client.primary.ReadWithOptions(ctx, key, o)
+	if err != nil && client.policy.ShouldFailover(err) {
+		return client.secondary.ReadWithOptions(ctx, key, o)
+	}
+	return r, err
+}
+
+func (client *failoverClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *failoverClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	// The reader can only be written again if it can be rewound to where
+	// the write starts.
+	seeker, seekable := r.(io.Seeker)
+	var offset int64
+	if seekable && client.policy.ReplayWrites {
+		var err error
+		if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	result, err := client.primary.WriteWithResult(ctx, key, r, o)
+	if err == nil {
+		client.dropReplay(key)
+		return result, nil
+	}
+	if !client.policy.ReplayWrites || !client.policy.ShouldFailover(err) || !seekable {
+		return nil, err
+	}
+	if _, serr := seeker.Seek(offset, io.SeekStart); serr != nil {
+		return nil, err
+	}
+
+	result, err = client.secondary.WriteWithResult(ctx, key, r, o)
+	if err != nil {
+		return nil, err
+	}
+	client.addReplay(ctx, key, false)
+	return result, nil
+}
+
+func (client *failoverClient) Exist(ctx context.Context, key string) (bool, error) {
+	if client.pendingReplay(key) {
+		return client.secondary.Exist(ctx, key)
+	}
+	exist, err := client.primary.Exist(ctx, key)
+	if err != nil && client.policy.ShouldFailover(err) {
+		return client.secondary.Exist(ctx, key)
+	}
+	return exist, err
+}
+
+func (client *failoverClient) Remove(ctx context.Context, keys ...string) error {
+	err := client.primary.Remove(ctx, keys...)
+	if err == nil {
+		client.dropReplay(keys...)
+		return nil
+	}
+
+	failed := keys
+	// kept are the results of the keys failed without failover.
+	var kept []RemoveResult
+	var rerr *RemoveError
+	if errors.As(err, &rerr) {
+		// The keys removed from the primary aren't replayed.
+		errs := make(map[string]error, len(rerr.Results))
+		for _, result := range rerr.Results {
+			errs[result.Key] = result.Err
+		}
+		failed = nil
+		for _, key := range keys {
+			if kerr, ok := errs[key]; !ok {
+				client.dropReplay(key)
+			} else if client.policy.ShouldFailover(kerr) {
+				failed = append(failed, key)
+			} else {
+				kept = append(kept, RemoveResult{Key: key, Err: kerr})
+			}
+		}
+	}
+	if !client.policy.ReplayWrites || (rerr == nil && !client.policy.ShouldFailover(err)) {
+		return err
+	}
+
+	// The keys are removed from the secondary too, so they don't come back
+	// to the primary by replaying copies.
+	if len(failed) > 0 {
+		removed := make(map[string]bool, len(failed))
+		for _, key := range failed {
+			removed[key] = true
+		}
+		if err := client.secondary.Remove(ctx, failed...); err != nil {
+			var serr *RemoveError
+			if !errors.As(err, &serr) {
+				if rerr == nil {
+					return err
+				}
+				serr = &RemoveError{}
+				for _, key := range failed {
+					serr.Results = append(serr.Results, RemoveResult{Key: key, Err: err})
+				}
+			}
+			for _, result := range serr.Results {
+				removed[result.Key] = false
+			}
+			kept = append(kept, serr.Results...)
+		}
+		for _, key := range failed {
+			if removed[key] {
+				client.addReplay(ctx, key, true)
+			}
+		}
+	}
+	if len(kept) > 0 {
+		return &RemoveError{Results: kept}
+	}
+	return nil
+}
+
+func (client *failoverClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	items, err := client.primary.List(ctx, prefix)
+	if err != nil && client.policy.ShouldFailover(err) {
+		return client.secondary.List(ctx, prefix)
+	}
+	return items, err
+}
+
+func (client *failoverClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	if client.pendingReplay(key) {
+		return client.secondary.Info(ctx, key)
+	}
+	info, err := client.primary.Info(ctx, key)
+	if err != nil && client.policy.ShouldFailover(err) {
+		return client.secondary.Info(ctx, key)
+	}
+	return info, err
+}
+
+func (client *failoverClient) Copy(ctx context.Context, src, dst string) error {
+	err := client.primary.Copy(ctx, src, dst)
+	if err == nil {
+		client.dropReplay(dst)
+		return nil
+	}
+	if !client.policy.ReplayWrites || !client.policy.ShouldFailover(err) {
+		return err
+	}
+
+	if err := client.secondary.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	client.addReplay(ctx, dst, false)
+	return nil
+}
+
+// Close stops replaying, the replays not done yet are dropped.
+func (client *failoverClient) Close() error {
+	client.cancel()
+	client.replayers.Wait()
+	return closeAll(client.primary, client.secondary)
+}
@@ -0,0 +1,140 @@
+package objclient
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailoverClient(t *testing.T) {
+	primary := newMemClient()
+	secondary := newMemClient()
+	primary.put("objclient/test", "primary")
+	secondary.put("objclient/test", "secondary")
+
+	errDown := errors.New("primary down")
+	client := NewFailoverClient(primary, secondary, &FailoverPolicy{
+		ShouldFailover: func(err error) bool { return err == errDown },
+		ReplayWrites:   true,
+		ProbeInterval:  10 * time.Millisecond,
+	})
+
+	if data := readString(t, client, "objclient/test"); data != "primary" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	primary.fail = func(op, key string) error { return errDown }
+	if data := readString(t, client, "objclient/test"); data != "secondary" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	err := client.Write(ctx, "objclient/new", strings.NewReader("demo"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, secondary, "objclient/new"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	// The write is replayed after the primary recovers.
+	primary.fail = nil
+	for i := 0; i < 100 && client.(*failoverClient).pendingReplay("objclient/new"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data := readString(t, primary, "objclient/new"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+}
+
+func TestFailoverClientErrors(t *testing.T) {
+	primary := newMemClient()
+	secondary := newMemClient()
+	errDown := errors.New("primary down")
+	errDenied := errors.New("denied")
+	client := NewFailoverClient(primary, secondary, &FailoverPolicy{
+		ShouldFailover: func(err error) bool { return err == errDown },
+		ReplayWrites:   true,
+		ProbeInterval:  time.Hour,
+	})
+	defer client.Close()
+
+	// The reader is replayed from where the write starts.
+	primary.fail = func(op, key string) error { return errDown }
+	r := strings.NewReader("XXXXhello")
+	r.Seek(4, io.SeekStart)
+	if err := client.Write(ctx, "objclient/a", r, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, secondary, "objclient/a"); data != "hello" {
+		t.Fatalf("invalid replayed data %q", data)
+	}
+
+	// The removals failed without failover are reported.
+	primary.put("objclient/b", "b")
+	primary.put("objclient/c", "c")
+	primary.fail = func(op, key string) error {
+		if key == "objclient/b" {
+			return errDenied
+		}
+		return errDown
+	}
+	var rerr *RemoveError
+	err := client.Remove(ctx, "objclient/b", "objclient/c")
+	if !errors.As(err, &rerr) || len(rerr.Results) != 1 || rerr.Results[0].Key != "objclient/b" || rerr.Results[0].Err != errDenied {
+		t.Fatalf("invalid remove error %v", err)
+	}
+	primary.fail = func(op, key string) error { return errDenied }
+	if err := client.Remove(ctx, "objclient/b"); !errors.As(err, &rerr) || len(rerr.Results) != 1 {
+		t.Fatalf("invalid remove error %v", err)
+	}
+}
+
+func TestFailoverClientStaleReplay(t *testing.T) {
+	primary := newMemClient()
+	secondary := newMemClient()
+	errDown := errors.New("primary down")
+	var down atomic.Bool
+	primary.fail = func(op, key string) error {
+		if down.Load() {
+			return errDown
+		}
+		return nil
+	}
+	client := NewFailoverClient(primary, secondary, &FailoverPolicy{
+		ShouldFailover: func(err error) bool { return err == errDown },
+		ReplayWrites:   true,
+		ProbeInterval:  10 * time.Millisecond,
+	})
+	defer client.Close()
+
+	down.Store(true)
+	if err := client.Write(ctx, "objclient/a", strings.NewReader("old"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Write(ctx, "objclient/b", strings.NewReader("old"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The changes made on the primary after it recovers drop the replays.
+	down.Store(false)
+	if err := client.Write(ctx, "objclient/a", strings.NewReader("new"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Remove(ctx, "objclient/b"); err != nil {
+		t.Fatal(err)
+	}
+	failover := client.(*failoverClient)
+	if failover.pendingReplay("objclient/a") || failover.pendingReplay("objclient/b") {
+		t.Fatalf("replays of changed keys are pending")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if data := readString(t, primary, "objclient/a"); data != "new" {
+		t.Fatalf("write is overwritten by replay: %q", data)
+	}
+	if exist, err := primary.Exist(ctx, "objclient/b"); err != nil || exist {
+		t.Fatalf("removed key is replayed: %v, %v", exist, err)
+	}
+}
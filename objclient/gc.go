@@ -0,0 +1,152 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// GCOptions are the options of CollectGarbage.
+type GCOptions struct {
+	// GracePeriod keeps the unreferenced objects modified in it, which may
+	// be written but not referenced yet. It defaults to 24 hours.
+	GracePeriod time.Duration
+	// BatchSize is the number of keys of each Remove, defaults to 1000.
+	BatchSize int
+	// DryRun only reports the objects which would be removed.
+	DryRun bool
+	// Logger records the unreferenced objects collected, with their keys,
+	// sizes and modification times. The failed removals are logged at the
+	// error level. Nothing is logged if it's nil.
+	Logger *slog.Logger
+}
+
+// GCResult is the result of CollectGarbage.
+type GCResult struct {
+	// Listed is the number of objects of the prefix, Referenced is the
+	// number of them which are referenced.
+	Listed     int
+	Referenced int
+	// Removed and RemovedBytes are the unreferenced objects which are
+	// removed, or would be removed by dry runs.
+	Removed      int
+	RemovedBytes int64
+}
+
+// CollectGarbage removes the objects of prefix which aren't referenced and
+// older than the grace period. The referenced keys are marked by refs,
+// which calls mark with each of them. If refs returns an error nothing is
+// removed, so it must report the failures of enumerating references. The
+// options can be nil. The keys failed to be removed are reported by a
+// *RemoveError after the others are removed.
+func CollectGarbage(ctx context.Context, client Client, prefix string, refs func(mark func(key string)) error, opts *GCOptions) (*GCResult, error) {
+	o := GCOptions{GracePeriod: 24 * time.Hour, BatchSize: removeBatchSize}
+	if opts != nil {
+		if opts.GracePeriod > 0 {
+			o.GracePeriod = opts.GracePeriod
+		}
+		if opts.BatchSize > 0 {
+			o.BatchSize = opts.BatchSize
+		}
+		o.DryRun = opts.DryRun
+		o.Logger = opts.Logger
+	}
+
+	// Objects written after the start are kept by the grace period, even
+	// if they are referenced after marking.
+	deadline := time.Now().Add(-o.GracePeriod)
+	referenced := make(map[string]bool)
+	if err := refs(func(key string) { referenced[key] = true }); err != nil {
+		return nil, fmt.Errorf("failed to mark references: %w", err)
+	}
+	items, err := client.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %v: %w", prefix, err)
+	}
+
+	result := &GCResult{Listed: len(items)}
+	var garbage []ObjectItem
+	for _, item := range items {
+		switch {
+		case referenced[item.Key]:
+			result.Referenced++
+		case item.LastModified.Before(deadline):
+			garbage = append(garbage, item)
+		}
+	}
+
+	removed, removedBytes, err := removeItems(ctx, client, garbage, o.BatchSize, o.DryRun, func(item ObjectItem, err error) {
+		gcAudit(ctx, o, item, err)
+	})
+	result.Removed, result.RemovedBytes = removed, removedBytes
+	return result, err
+}
+
+// removeItems removes items in batches, or none of them if dryRun, and
+// calls audit with each of them and the error of removing it. It returns
+// the number and total size of items removed, or would be removed by dry
+// runs. The keys failed to be removed are reported by a *RemoveError after
+// the others are removed.
+func removeItems(ctx context.Context, client Client, items []ObjectItem, batchSize int, dryRun bool, audit func(item ObjectItem, err error)) (int, int64, error) {
+	var (
+		removed      int
+		removedBytes int64
+		failed       []RemoveResult
+	)
+	for len(items) > 0 {
+		batch := items[:min(len(items), batchSize)]
+		items = items[len(batch):]
+
+		errs := make(map[string]error)
+		if !dryRun {
+			keys := make([]string, len(batch))
+			for i, item := range batch {
+				keys[i] = item.Key
+			}
+			err := client.Remove(ctx, keys...)
+			var rerr *RemoveError
+			switch {
+			case errors.As(err, &rerr):
+				for _, r := range rerr.Results {
+					errs[r.Key] = r.Err
+				}
+				failed = append(failed, rerr.Results...)
+			case err != nil:
+				return removed, removedBytes, err
+			}
+		}
+		for _, item := range batch {
+			err := errs[item.Key]
+			if err == nil {
+				removed++
+				removedBytes += item.Size
+			}
+			audit(item, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return removed, removedBytes, &RemoveError{Results: failed}
+	}
+	return removed, removedBytes, nil
+}
+
+func gcAudit(ctx context.Context, o GCOptions, item ObjectItem, err error) {
+	if o.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("key", item.Key),
+		slog.Int64("size", item.Size),
+		slog.Time("modified", item.LastModified),
+		slog.Bool("dry_run", o.DryRun),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+		o.Logger.LogAttrs(ctx, slog.LevelError, "objclient gc remove", attrs...)
+		return
+	}
+	o.Logger.LogAttrs(ctx, slog.LevelInfo, "objclient gc remove", attrs...)
+}
@@ -0,0 +1,59 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	mem := newMemClient()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{"blocks/a", "blocks/b", "blocks/c", "other/d"} {
+		mem.objects[key] = memObject{data: []byte(key), modified: old}
+	}
+	// It's new and not referenced yet.
+	mem.put("blocks/e", "e")
+
+	refs := func(mark func(key string)) error {
+		mark("blocks/a")
+		return nil
+	}
+	var audit bytes.Buffer
+	opts := &GCOptions{BatchSize: 1, DryRun: true, Logger: slog.New(slog.NewTextHandler(&audit, nil))}
+	result, err := CollectGarbage(context.Background(), mem, "blocks/", refs, opts)
+	if err != nil || result.Listed != 4 || result.Referenced != 1 || result.Removed != 2 || result.RemovedBytes != 16 {
+		t.Fatalf("invalid dry run: %+v, %v", result, err)
+	}
+	if len(mem.objects) != 5 || strings.Count(audit.String(), "dry_run=true") != 2 {
+		t.Fatalf("objects are removed by dry run: %v", audit.String())
+	}
+
+	mem.fail = func(op, key string) error {
+		if op == "Remove" && key == "blocks/c" {
+			return errors.New("denied")
+		}
+		return nil
+	}
+	opts.DryRun = false
+	result, err = CollectGarbage(context.Background(), mem, "blocks/", refs, opts)
+	var rerr *RemoveError
+	if !errors.As(err, &rerr) || len(rerr.Results) != 1 || result.Removed != 1 {
+		t.Fatalf("failed removal isn't reported: %+v, %v", result, err)
+	}
+	if _, ok := mem.objects["blocks/b"]; ok {
+		t.Fatalf("garbage isn't removed")
+	}
+
+	// Nothing is removed if the references aren't all marked.
+	_, err = CollectGarbage(context.Background(), mem, "blocks/", func(mark func(key string)) error {
+		return errors.New("database is down")
+	}, nil)
+	if err == nil || len(mem.objects) != 4 {
+		t.Fatalf("objects are removed without references: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package objclient
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type HedgePolicy struct {
+	// Delay is the time waited for the response of a request before the
+	// duplicate is issued, e.g. about the p95 latency of the backend. It
+	// defaults to the delay of DefaultHedgePolicy.
+	Delay time.Duration
+	// OnHedge is called when a duplicate of the operation of key is issued,
+	// e.g. for metrics of the extra requests.
+	OnHedge func(op, key string)
+}
+
+// DefaultHedgePolicy is used by NewHedgedClient for nil policy.
+var DefaultHedgePolicy = HedgePolicy{Delay: 100 * time.Millisecond}
+
+type hedgedClient struct {
+	inner  Client
+	policy HedgePolicy
+}
+
+// NewHedgedClient issues a duplicate of Read, Exist and Info of inner if it
+// doesn't respond in the delay of policy, and uses the response arriving
+// first, the other one is canceled. For Read, it's the response until the
+// reader is returned. The requests failed before the delay aren't hedged,
+// and the error is returned if both fail. Other operations aren't hedged,
+// since they aren't idempotent or they are long listings.
+func NewHedgedClient(inner Client, policy *HedgePolicy) Client {
+	p := DefaultHedgePolicy
+	if policy != nil {
+		p = *policy
+	}
+	if p.Delay <= 0 {
+		p.Delay = DefaultHedgePolicy.Delay
+	}
+	client := &hedgedClient{inner: inner, policy: p}
+	if _, ok := AsPageLister(inner); ok {
+		return &hedgedPageClient{client}
+	}
+	return client
+}
+
+// hedgedPageClient is the hedgedClient of a PageLister, the listings
+// aren't hedged.
+type hedgedPageClient struct {
+	*hedgedClient
+}
+
+func (client *hedgedPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+type hedgeResult[T any] struct {
+	value T
+	err   error
+	i     int
+}
+
+// hedge calls call, and again after the delay unless it's responded. It
+// returns the first value succeeded with the cancel of its context, the
+// later values are passed to release.
+func hedge[T any](ctx context.Context, policy HedgePolicy, op, key string, call func(ctx context.Context) (T, error), release func(T)) (T, context.CancelFunc, error) {
+	results := make(chan hedgeResult[T], 2)
+	var cancels []context.CancelFunc
+	start := func() {
+		ctx, cancel := context.WithCancel(ctx)
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			value, err := call(ctx)
+			results <- hedgeResult[T]{value: value, err: err, i: i}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(policy.Delay)
+	defer timer.Stop()
+	pending := 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if policy.OnHedge != nil {
+				policy.OnHedge(op, key)
+			}
+			start()
+			pending++
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				cancels[result.i]()
+				if firstErr == nil {
+					firstErr = result.err
+				}
+				if pending == 0 {
+					var zero T
+					return zero, nil, firstErr
+				}
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != result.i {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go func() {
+					if late := <-results; late.err == nil {
+						release(late.value)
+					}
+				}()
+			}
+			return result.value, cancels[result.i], nil
+		}
+	}
+}
+
+func (client *hedgedClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *hedgedClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	r, cancel, err := hedge(ctx, client.policy, "Read", key, func(ctx context.Context) (io.ReadCloser, error) {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}, func(r io.ReadCloser) { r.Close() })
+	if err != nil {
+		return nil, err
+	}
+	return &cancelReader{ReadCloser: r, cancel: cancel}, nil
+}
+
+func (client *hedgedClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	return client.inner.Write(ctx, key, r, o)
+}
+
+func (client *hedgedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *hedgedClient) Exist(ctx context.Context, key string) (bool, error) {
+	exist, cancel, err := hedge(ctx, client.policy, "Exist", key, func(ctx context.Context) (bool, error) {
+		return client.inner.Exist(ctx, key)
+	}, func(bool) {})
+	if err != nil {
+		return false, err
+	}
+	cancel()
+	return exist, nil
+}
+
+func (client *hedgedClient) Remove(ctx context.Context, keys ...string) error {
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *hedgedClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *hedgedClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, cancel, err := hedge(ctx, client.policy, "Info", key, func(ctx context.Context) (*ObjectInfo, error) {
+		return client.inner.Info(ctx, key)
+	}, func(*ObjectInfo) {})
+	if err != nil {
+		return nil, err
+	}
+	cancel()
+	return info, nil
+}
+
+func (client *hedgedClient) Copy(ctx context.Context, src, dst string) error {
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *hedgedClient) Close() error {
+	return client.inner.Close()
+}
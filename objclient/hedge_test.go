@@ -0,0 +1,92 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedClient(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	mem.put("a", "data")
+
+	// The first request of each operation is slow.
+	var calls atomic.Int32
+	slow := make(chan struct{})
+	defer close(slow)
+	var mutex sync.Mutex
+	seen := make(map[string]bool)
+	mem.fail = func(op, key string) error {
+		calls.Add(1)
+		mutex.Lock()
+		first := !seen[op]
+		seen[op] = true
+		mutex.Unlock()
+		if first {
+			<-slow
+		}
+		return nil
+	}
+	var hedges atomic.Int32
+	client := NewHedgedClient(mem, &HedgePolicy{
+		Delay:   10 * time.Millisecond,
+		OnHedge: func(op, key string) { hedges.Add(1) },
+	})
+
+	r, err := client.Read(ctx, "a")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "data" {
+		t.Fatalf("invalid data %q: %v", data, err)
+	}
+	if exist, err := client.Exist(ctx, "a"); err != nil || !exist {
+		t.Fatalf("invalid exist %v: %v", exist, err)
+	}
+	if info, err := client.Info(ctx, "a"); err != nil || info.Size != 4 {
+		t.Fatalf("invalid info %+v: %v", info, err)
+	}
+	if hedges.Load() != 3 {
+		t.Fatalf("invalid number of hedges %v", hedges.Load())
+	}
+
+	// The fast requests and the ones failed fast aren't hedged.
+	calls.Store(0)
+	if _, err := client.Info(ctx, "a"); err != nil {
+		t.Fatalf("failed to get info: %v", err)
+	}
+	if _, err := client.Info(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect not found: %v", err)
+	}
+	if calls.Load() != 2 || hedges.Load() != 3 {
+		t.Fatalf("invalid number of requests %v, hedges %v", calls.Load(), hedges.Load())
+	}
+}
+
+func TestHedgedClientFailure(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	mem.put("a", "data")
+	mem.fail = func(op, key string) error {
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("unavailable")
+	}
+	var hedges atomic.Int32
+	client := NewHedgedClient(mem, &HedgePolicy{
+		Delay:   time.Millisecond,
+		OnHedge: func(op, key string) { hedges.Add(1) },
+	})
+	if _, err := client.Read(ctx, "a"); err == nil || err.Error() != "unavailable" {
+		t.Fatalf("expect error of both requests: %v", err)
+	}
+	if hedges.Load() != 1 {
+		t.Fatalf("invalid number of hedges %v", hedges.Load())
+	}
+}
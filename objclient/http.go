@@ -0,0 +1,286 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// objectNotFound checks whether err of key is caused by a missing object.
+func objectNotFound(ctx context.Context, client ReadOnlyClient, key string, err error) bool {
+	if isNotFound(err) {
+		return true
+	}
+	exist, eerr := client.Exist(ctx, key)
+	return eerr == nil && !exist
+}
+
+// objectReader reads an object from the offset, seeking reopens the object
+// from the new offset when it's read. The reads are of the ETag if it's
+// set, so the parts of different objects aren't mixed.
+type objectReader struct {
+	ctx    context.Context
+	client ReadOnlyClient
+	key    string
+	etag   string
+	size   int64
+	offset int64
+	r      io.ReadCloser
+}
+
+func (reader *objectReader) Read(data []byte) (int, error) {
+	if reader.offset >= reader.size {
+		return 0, io.EOF
+	}
+	if reader.r == nil {
+		r, err := reader.client.ReadWithOptions(reader.ctx, reader.key, &ReadOptions{Offset: reader.offset, IfMatch: reader.etag})
+		if err != nil {
+			return 0, err
+		}
+		reader.r = r
+	}
+
+	n, err := reader.r.Read(data)
+	reader.offset += int64(n)
+	return n, err
+}
+
+func (reader *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += reader.offset
+	case io.SeekEnd:
+		offset += reader.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to negative offset")
+	}
+
+	if offset != reader.offset && reader.r != nil {
+		reader.r.Close()
+		reader.r = nil
+	}
+	reader.offset = offset
+	return offset, nil
+}
+
+func (reader *objectReader) Close() error {
+	if reader.r == nil {
+		return nil
+	}
+	err := reader.r.Close()
+	reader.r = nil
+	return err
+}
+
+type httpHandler struct {
+	client ReadOnlyClient
+}
+
+// NewHTTPHandler serves the objects of client by GET and HEAD requests, and
+// supports range and conditional requests. The key is the URL path without
+// the leading "/", use http.StripPrefix to mount it under a path.
+func NewHTTPHandler(client ReadOnlyClient) http.Handler {
+	return &httpHandler{client: client}
+}
+
+func (handler *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if key == "" || strings.HasSuffix(key, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := handler.client.Info(ctx, key)
+	if err != nil {
+		if objectNotFound(ctx, handler.client, key, err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to get object info", http.StatusBadGateway)
+		return
+	}
+
+	if info.ETag != "" {
+		w.Header().Set("ETag", `"`+info.ETag+`"`)
+	}
+	if t := contentType(key, info); t != "" {
+		w.Header().Set("Content-Type", t)
+	}
+
+	reader := &objectReader{ctx: ctx, client: handler.client, key: key, etag: info.ETag, size: info.Size}
+	defer reader.Close()
+	http.ServeContent(w, r, key, info.LastModified, reader)
+}
+
+// contentType returns the Content-Type stored with the object, or the one
+// of the extension of key if it's unknown or the default of S3.
+func contentType(key string, info *ObjectInfo) string {
+	if info.ContentType != "" && info.ContentType != "application/octet-stream" {
+		return info.ContentType
+	}
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return info.ContentType
+}
+
+type fileSystem struct {
+	client ReadOnlyClient
+}
+
+// NewFileSystem returns a http.FileSystem of the objects of client, to be
+// served by http.FileServer. Directories are the prefixes of keys.
+func NewFileSystem(client ReadOnlyClient) http.FileSystem {
+	return &fileSystem{client: client}
+}
+
+func (fsys *fileSystem) Open(name string) (http.File, error) {
+	ctx := context.Background()
+	key := strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	if key != "" {
+		info, err := fsys.client.Info(ctx, key)
+		if err == nil {
+			return &objectFile{
+				objectReader: objectReader{ctx: ctx, client: fsys.client, key: key, etag: info.ETag, size: info.Size},
+				info:         &objectFileInfo{name: path.Base(key), size: info.Size, modTime: info.LastModified},
+			}, nil
+		}
+		if !objectNotFound(ctx, fsys.client, key, err) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	prefix := key
+	if prefix != "" {
+		prefix += "/"
+	}
+	items, err := fsys.client.List(ctx, prefix)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if len(items) == 0 && key != "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return newObjectDir(path.Base("/"+key), prefix, items), nil
+}
+
+type objectFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (info *objectFileInfo) Name() string       { return info.name }
+func (info *objectFileInfo) Size() int64        { return info.size }
+func (info *objectFileInfo) ModTime() time.Time { return info.modTime }
+func (info *objectFileInfo) IsDir() bool        { return info.dir }
+func (info *objectFileInfo) Sys() any           { return nil }
+
+func (info *objectFileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+type objectFile struct {
+	objectReader
+	info *objectFileInfo
+}
+
+func (file *objectFile) Stat() (fs.FileInfo, error) {
+	return file.info, nil
+}
+
+func (file *objectFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+type objectDir struct {
+	info    *objectFileInfo
+	entries []fs.FileInfo
+	offset  int
+}
+
+// newObjectDir returns the dir of the items listed by prefix, with the
+// entries of its files and sub dirs.
+func newObjectDir(name, prefix string, items []ObjectItem) *objectDir {
+	dirs := make(map[string]*objectFileInfo)
+	var entries []fs.FileInfo
+	for _, item := range items {
+		rel := strings.TrimPrefix(item.Key, prefix)
+		if rel == "" {
+			continue
+		}
+		if i := strings.Index(rel, "/"); i >= 0 {
+			sub, ok := dirs[rel[:i]]
+			if !ok {
+				sub = &objectFileInfo{name: rel[:i], dir: true}
+				dirs[rel[:i]] = sub
+				entries = append(entries, sub)
+			}
+			if item.LastModified.After(sub.modTime) {
+				sub.modTime = item.LastModified
+			}
+			continue
+		}
+		entries = append(entries, &objectFileInfo{name: rel, size: item.Size, modTime: item.LastModified})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return &objectDir{info: &objectFileInfo{name: name, dir: true}, entries: entries}
+}
+
+func (dir *objectDir) Read(data []byte) (int, error) {
+	return 0, errors.New("is a directory")
+}
+
+func (dir *objectDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		dir.offset = 0
+		return 0, nil
+	}
+	return 0, errors.New("is a directory")
+}
+
+func (dir *objectDir) Close() error {
+	return nil
+}
+
+func (dir *objectDir) Stat() (fs.FileInfo, error) {
+	return dir.info, nil
+}
+
+func (dir *objectDir) Readdir(count int) ([]fs.FileInfo, error) {
+	entries := dir.entries[dir.offset:]
+	if count <= 0 {
+		dir.offset = len(dir.entries)
+		return entries, nil
+	}
+	if len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if count < len(entries) {
+		entries = entries[:count]
+	}
+	dir.offset += len(entries)
+	return entries, nil
+}
@@ -0,0 +1,131 @@
+package objclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haiwen/goutils/objclient/s3test"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a.txt", "1234567890")
+	server := httptest.NewServer(NewHTTPHandler(mem))
+	defer server.Close()
+
+	get := func(key string, header map[string]string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/"+key, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, data := get("objclient/a.txt", nil)
+	if resp.StatusCode != http.StatusOK || data != "1234567890" {
+		t.Fatalf("invalid response: %v %q", resp.Status, data)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("invalid content type: %v", resp.Header.Get("Content-Type"))
+	}
+	etag := resp.Header.Get("ETag")
+
+	resp, data = get("objclient/a.txt", map[string]string{"Range": "bytes=2-4"})
+	if resp.StatusCode != http.StatusPartialContent || data != "345" {
+		t.Fatalf("invalid range response: %v %q", resp.Status, data)
+	}
+	resp, _ = get("objclient/a.txt", map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("invalid conditional response: %v", resp.Status)
+	}
+	resp, _ = get("objclient/b.txt", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid response of missing object: %v", resp.Status)
+	}
+
+	// The object overwritten after its info isn't mixed into the response.
+	mem.fail = func(op, key string) error {
+		if op == "Read" {
+			mem.put(key, "abcdefghij")
+		}
+		return nil
+	}
+	_, data = get("objclient/a.txt", map[string]string{"Range": "bytes=2-4"})
+	if data != "" {
+		t.Fatalf("invalid range response of overwritten object: %q", data)
+	}
+}
+
+func TestHTTPHandlerContentType(t *testing.T) {
+	server := s3test.NewTLSServer("bucket")
+	defer server.Close()
+	s3, err := NewS3Client(S3Config{
+		Endpoint: server.Endpoint(), Region: s3test.Region, HTTPS: "true", Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true", RootCAs: server.RootCAs(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	for key, contentType := range map[string]string{"a.txt": "application/x-demo", "b.txt": ""} {
+		if err := s3.Write(ctx, key, strings.NewReader("demo"), &WriteOptions{ContentType: contentType}); err != nil {
+			t.Fatalf("failed to write %v: %v", key, err)
+		}
+	}
+	handler := httptest.NewServer(NewHTTPHandler(s3))
+	defer handler.Close()
+
+	// The stored Content-Type is preferred to the one of the extension.
+	for key, expect := range map[string]string{"a.txt": "application/x-demo", "b.txt": "text/plain"} {
+		resp, err := http.Get(handler.URL + "/" + key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), expect) {
+			t.Fatalf("invalid content type of %v: %v", key, resp.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestFileSystem(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a.txt", "demo")
+	mem.put("objclient/dir/b.txt", "demo")
+	fsys := NewFileSystem(mem)
+
+	dir, err := fsys.Open("/objclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "a.txt" || !entries[1].IsDir() {
+		t.Fatalf("invalid entries: %v", entries)
+	}
+
+	file, err := fsys.Open("/objclient/dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.Seek(2, io.SeekStart)
+	data, _ := io.ReadAll(file)
+	if string(data) != "mo" {
+		t.Fatalf("invalid data: %q", data)
+	}
+
+	if _, err := fsys.Open("/objclient/c.txt"); err == nil {
+		t.Fatalf("expect not exist")
+	}
+}
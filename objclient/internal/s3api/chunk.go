@@ -0,0 +1,91 @@
+package s3api
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxChunkSize is the largest chunk of streaming bodies.
+const MaxChunkSize = 16 << 20
+
+// ChunkReader decodes the body of streaming uploads, the chunks of
+// "size;chunk-signature=...\r\ndata\r\n" ending with a chunk of size 0 and
+// the optional trailers, which are ignored. It fails with ErrIncompleteBody
+// if the body is malformed.
+type ChunkReader struct {
+	r      *bufio.Reader
+	verify func(signature string, data []byte) bool
+	buf    []byte
+	data   []byte
+	err    error
+}
+
+// NewChunkReader returns the reader of the chunks of r. If verify isn't
+// nil, it's called with the signature and data of each chunk in order, and
+// the reader fails with ErrSignatureMismatch if it returns false.
+func NewChunkReader(r io.Reader, verify func(signature string, data []byte) bool) *ChunkReader {
+	return &ChunkReader{r: bufio.NewReader(r), verify: verify}
+}
+
+func (reader *ChunkReader) Read(data []byte) (int, error) {
+	for len(reader.data) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		reader.err = reader.next()
+	}
+	n := copy(data, reader.data)
+	reader.data = reader.data[n:]
+	return n, nil
+}
+
+func (reader *ChunkReader) next() error {
+	line, err := reader.r.ReadSlice('\n')
+	if err != nil {
+		return ErrIncompleteBody
+	}
+	header, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return ErrIncompleteBody
+	}
+	hexSize, params, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(hexSize, 16, 64)
+	if err != nil || size < 0 || size > MaxChunkSize {
+		return ErrIncompleteBody
+	}
+
+	var buf []byte
+	if size > 0 {
+		if int64(cap(reader.buf)) < size+2 {
+			reader.buf = make([]byte, size+2)
+		}
+		buf = reader.buf[:size+2]
+		if _, err := io.ReadFull(reader.r, buf); err != nil || string(buf[size:]) != "\r\n" {
+			return ErrIncompleteBody
+		}
+		buf = buf[:size]
+	}
+	if reader.verify != nil {
+		signature, _ := strings.CutPrefix(params, "chunk-signature=")
+		if !reader.verify(signature, buf) {
+			return ErrSignatureMismatch
+		}
+	}
+	if size > 0 {
+		reader.data = buf
+		return nil
+	}
+
+	// The trailers end with an empty line, or the body.
+	for {
+		line, err := reader.r.ReadSlice('\n')
+		if err == io.EOF || string(line) == "\r\n" {
+			return io.EOF
+		}
+		if err != nil {
+			return ErrIncompleteBody
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package s3api
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxKeys is the default and largest page of listings.
+	DefaultMaxKeys  = 1000
+	listTimeFormat  = "2006-01-02T15:04:05.000Z"
+	prefixTokenKind = "p"
+	keyTokenKind    = "k"
+)
+
+// ListItem is an object of the listings.
+type ListItem struct {
+	Key          string
+	LastModified time.Time
+	ETag         string
+	Size         int64
+	// StorageClass defaults to "STANDARD".
+	StorageClass string
+}
+
+// Walker calls fn with the objects of prefix after startAfter in the order
+// of keys, until it returns false.
+type Walker func(prefix, startAfter string, fn func(item ListItem) bool) error
+
+// ListResult is the response of ListObjectsV2.
+type ListResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	EncodingType          string `xml:",omitempty"`
+	KeyCount              int
+	MaxKeys               int
+	IsTruncated           bool
+	Contents              []ListObject
+	CommonPrefixes        []CommonPrefix
+}
+
+type ListObject struct {
+	Key          string
+	LastModified string
+	ETag         string `xml:",omitempty"`
+	Size         int64
+	StorageClass string
+}
+
+type CommonPrefix struct {
+	Prefix string
+}
+
+// ListObjects serves ListObjectsV2 of bucket by the objects of walk. The
+// continuation tokens are the last key or common prefix returned, and the
+// listing is resumed after it. The errors of the parameters and walk are
+// returned without writing the response.
+func ListObjects(w http.ResponseWriter, r *http.Request, bucket string, walk Walker) error {
+	query := r.URL.Query()
+	result := &ListResult{
+		Name:              bucket,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: query.Get("continuation-token"),
+		MaxKeys:           DefaultMaxKeys,
+	}
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return ErrInvalidArgument
+		}
+		result.MaxKeys = min(n, DefaultMaxKeys)
+	}
+	switch v := query.Get("encoding-type"); v {
+	case "", "url":
+		result.EncodingType = v
+	default:
+		return ErrInvalidArgument
+	}
+
+	startAfter := result.StartAfter
+	var lastPrefix string
+	if result.ContinuationToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(result.ContinuationToken)
+		if err != nil || len(token) == 0 {
+			return ErrInvalidArgument
+		}
+		kind, last := string(token[:1]), string(token[1:])
+		if kind == prefixTokenKind {
+			lastPrefix = last
+		}
+		startAfter = max(startAfter, last)
+	}
+
+	// Clients list prefixes ending with "/", and the keys are filtered by
+	// the rest of the prefix.
+	prefix, delimiter := result.Prefix, result.Delimiter
+	listPrefix := prefix[:strings.LastIndex(prefix, "/")+1]
+	var next string
+	err := walk(listPrefix, startAfter, func(item ListItem) bool {
+		if !strings.HasPrefix(item.Key, prefix) {
+			// The keys are sorted, none of the later ones has prefix.
+			return item.Key < prefix
+		}
+		entry, kind := item.Key, keyTokenKind
+		if delimiter != "" {
+			if i := strings.Index(item.Key[len(prefix):], delimiter); i >= 0 {
+				entry, kind = item.Key[:len(prefix)+i+len(delimiter)], prefixTokenKind
+			}
+		}
+		if kind == prefixTokenKind && entry == lastPrefix {
+			return true
+		}
+		if result.KeyCount == result.MaxKeys {
+			result.IsTruncated = true
+			return false
+		}
+
+		result.KeyCount++
+		next = base64.RawURLEncoding.EncodeToString([]byte(kind + entry))
+		if kind == prefixTokenKind {
+			lastPrefix = entry
+			result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefix{Prefix: EncodeName(entry, result.EncodingType)})
+			return true
+		}
+		storageClass := item.StorageClass
+		if storageClass == "" {
+			storageClass = "STANDARD"
+		}
+		result.Contents = append(result.Contents, ListObject{
+			Key:          EncodeName(entry, result.EncodingType),
+			LastModified: FormatTime(item.LastModified),
+			ETag:         QuoteETag(item.ETag),
+			Size:         item.Size,
+			StorageClass: storageClass,
+		})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = next
+	}
+	for _, s := range []*string{&result.Prefix, &result.Delimiter, &result.StartAfter} {
+		*s = EncodeName(*s, result.EncodingType)
+	}
+	WriteXML(w, http.StatusOK, result)
+	return nil
+}
+
+// FormatTime returns t in the format of the XML responses.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(listTimeFormat)
+}
+
+// QuoteETag returns the quoted etag of responses, or "" if it's empty.
+func QuoteETag(etag string) string {
+	if etag == "" {
+		return ""
+	}
+	return `"` + etag + `"`
+}
@@ -0,0 +1,172 @@
+// Package s3api has the parts of the S3 HTTP API shared by the servers of
+// s3gateway and s3test: the errors, ranges, conditional headers, streaming
+// bodies and listings.
+//
+// It doesn't import objclient, so the test server of objclient can use it.
+package s3api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error response of S3.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func NewError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	ErrSignatureMismatch = &Error{http.StatusForbidden, "SignatureDoesNotMatch", "The signature doesn't match."}
+	ErrIncompleteBody    = &Error{http.StatusBadRequest, "IncompleteBody", "The body is incomplete or malformed."}
+	ErrInvalidArgument   = &Error{http.StatusBadRequest, "InvalidArgument", "The argument is invalid."}
+	ErrInvalidRange      = &Error{http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The range isn't satisfiable."}
+)
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string
+	Message  string
+	Resource string
+}
+
+// WriteError writes the response of err, HEAD responses have no body.
+func WriteError(w http.ResponseWriter, r *http.Request, err *Error) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(err.Status)
+		return
+	}
+	WriteXML(w, err.Status, &errorResponse{Code: err.Code, Message: err.Message, Resource: r.URL.Path})
+}
+
+func WriteXML(w http.ResponseWriter, status int, v any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	xml.NewEncoder(&buf).Encode(v)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+type LocationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Region  string   `xml:",chardata"`
+}
+
+// URIEncode escapes all the bytes except the unreserved characters of
+// RFC 3986, as S3 does.
+func URIEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+// EncodeName returns the key or prefix s of listings by the encoding-type
+// parameter.
+func EncodeName(s, encodingType string) string {
+	if encodingType != "url" {
+		return s
+	}
+	return strings.ReplaceAll(URIEncode(s), "%2F", "/")
+}
+
+// CheckPreconditions returns the status of the failed conditional headers of
+// r for the object of etag modified at modified, or 0 if none of them
+// fails.
+func CheckPreconditions(r *http.Request, etag string, modified time.Time) int {
+	modified = modified.Truncate(time.Second)
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !MatchETag(match, etag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && modified.After(t) {
+		return http.StatusPreconditionFailed
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if MatchETag(match, etag) {
+			return http.StatusNotModified
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(t) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+// MatchETag returns whether etag is in the list of ETags of the conditional
+// header.
+func MatchETag(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || etag != "" && strings.Trim(v, `"`) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRange returns the range of the Range header for an object of size,
+// and whether it's a partial range. Malformed and multiple ranges are
+// ignored like S3, then the whole object is returned.
+func ParseRange(s string, size int64) (int64, int64, bool, error) {
+	spec, ok := strings.CutPrefix(s, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size, false, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, size, false, nil
+	}
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, size, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, ErrInvalidRange
+		}
+		n = min(n, size)
+		return size - n, n, true, nil
+	}
+
+	offset, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, size, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < offset {
+			return 0, size, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if offset >= size {
+		return 0, 0, false, ErrInvalidRange
+	}
+	return offset, end - offset + 1, true, nil
+}
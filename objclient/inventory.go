@@ -0,0 +1,123 @@
+package objclient
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// InventoryFormat is the format of the reports of ExportInventory.
+type InventoryFormat int
+
+const (
+	// InventoryCSV has a header line of key, size, etag, storage_class and
+	// last_modified, then a line for each object.
+	InventoryCSV InventoryFormat = iota
+	// InventoryJSONL has a JSON object for each line, with the fields of
+	// the CSV header.
+	InventoryJSONL
+)
+
+// InventoryOptions are the options of ExportInventory.
+type InventoryOptions struct {
+	// Gzip compresses the report.
+	Gzip bool
+}
+
+type inventoryRecord struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag"`
+	StorageClass string `json:"storage_class"`
+	LastModified string `json:"last_modified"`
+}
+
+// ExportInventory writes the report of the objects of prefix to w in format,
+// the times are RFC 3339 in UTC. The pages are written as they are listed
+// if client is a PageLister, instead of listing all first. It returns the
+// number of objects written. The report is incomplete if it fails.
+func ExportInventory(ctx context.Context, client ReadOnlyClient, prefix string, w io.Writer, format InventoryFormat, o *InventoryOptions) (int64, error) {
+	var zw *gzip.Writer
+	if o != nil && o.Gzip {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+
+	var (
+		write func(record *inventoryRecord) error
+		flush func() error
+	)
+	switch format {
+	case InventoryCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"key", "size", "etag", "storage_class", "last_modified"}); err != nil {
+			return 0, fmt.Errorf("failed to write inventory: %w", err)
+		}
+		write = func(record *inventoryRecord) error {
+			return cw.Write([]string{record.Key, strconv.FormatInt(record.Size, 10), record.ETag, record.StorageClass, record.LastModified})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case InventoryJSONL:
+		encoder := json.NewEncoder(w)
+		write = func(record *inventoryRecord) error {
+			return encoder.Encode(record)
+		}
+		flush = func() error { return nil }
+	default:
+		return 0, fmt.Errorf("unknown inventory format %v", format)
+	}
+
+	var (
+		count int64
+		werr  error
+	)
+	send := func(items []ObjectItem) bool {
+		for _, item := range items {
+			record := &inventoryRecord{
+				Key:          item.Key,
+				Size:         item.Size,
+				ETag:         item.ETag,
+				StorageClass: item.StorageClass,
+			}
+			if !item.LastModified.IsZero() {
+				record.LastModified = item.LastModified.UTC().Format(time.RFC3339)
+			}
+			if werr = write(record); werr != nil {
+				return false
+			}
+			count++
+		}
+		return true
+	}
+
+	var err error
+	if lister, ok := AsPageLister(client); ok {
+		err = lister.ListPages(ctx, prefix, "", send)
+	} else {
+		var items []ObjectItem
+		if items, err = client.List(ctx, prefix); err == nil {
+			send(items)
+		}
+	}
+	if err != nil {
+		return count, fmt.Errorf("failed to list %v: %w", prefix, err)
+	}
+	if werr == nil {
+		werr = flush()
+	}
+	if werr == nil && zw != nil {
+		werr = zw.Close()
+	}
+	if werr != nil {
+		return count, fmt.Errorf("failed to write inventory: %w", werr)
+	}
+	return count, nil
+}
@@ -0,0 +1,70 @@
+package objclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/haiwen/goutils/objclient/s3test"
+)
+
+func TestExportInventory(t *testing.T) {
+	ctx := context.Background()
+	server := s3test.NewTLSServer("bucket")
+	defer server.Close()
+	client, err := NewS3Client(S3Config{
+		Endpoint: server.Endpoint(), Region: s3test.Region, HTTPS: "true", Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true", RootCAs: server.RootCAs(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		if err := client.Write(ctx, key, strings.NewReader(key), nil); err != nil {
+			t.Fatalf("failed to write %v: %v", key, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	count, err := ExportInventory(ctx, client, "a/", &buf, InventoryCSV, nil)
+	if err != nil || count != 2 {
+		t.Fatalf("failed to export inventory: %v, %v", count, err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("invalid CSV inventory: %v, %v", records, err)
+	}
+	if record := records[1]; record[0] != "a/1" || record[1] != "3" || record[2] != fmt.Sprintf("%x", md5.Sum([]byte("a/1"))) || record[3] != "STANDARD" || record[4] == "" {
+		t.Fatalf("invalid CSV record %v", record)
+	}
+
+	buf.Reset()
+	if _, err := ExportInventory(ctx, client, "", &buf, InventoryJSONL, &InventoryOptions{Gzip: true}); err != nil {
+		t.Fatalf("failed to export inventory: %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("invalid gzip inventory: %v", err)
+	}
+	decoder := json.NewDecoder(zr)
+	var keys []string
+	for decoder.More() {
+		var record inventoryRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("invalid JSONL inventory: %v", err)
+		}
+		if record.ETag == "" || strings.Contains(record.ETag, `"`) {
+			t.Fatalf("invalid ETag %q", record.ETag)
+		}
+		keys = append(keys, record.Key)
+	}
+	if strings.Join(keys, ",") != "a/1,a/2,b/1" {
+		t.Fatalf("invalid keys %v", keys)
+	}
+}
@@ -0,0 +1,57 @@
+package objclient
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxKeySize is the limit of keys in bytes of S3. OSS allows 1023 bytes.
+const maxKeySize = 1024
+
+// ValidateKey returns an error wrapping ErrInvalidKey if key can't be used
+// safely on both S3 and OSS: empty keys, keys longer than 1024 bytes,
+// invalid UTF-8 and control characters like "\r\n", which break signing of
+// some backends, keys beginning with "/" or "\", which OSS rejects, and
+// keys with "//" or "." and ".." segments, which are cleaned by some
+// servers and proxies.
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	if len(key) > maxKeySize {
+		return fmt.Errorf("%w: %v bytes is over %v bytes", ErrInvalidKey, len(key), maxKeySize)
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidKey, key)
+	}
+	if strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: %q contains control characters", ErrInvalidKey, key)
+	}
+	if key[0] == '/' || key[0] == '\\' {
+		return fmt.Errorf("%w: %q begins with %q", ErrInvalidKey, key, key[:1])
+	}
+	if strings.Contains(key, "//") {
+		return fmt.Errorf("%w: %q contains \"//\"", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q contains %q segments", ErrInvalidKey, key, segment)
+		}
+	}
+	return nil
+}
+
+// checkKeys validates keys if strict is set.
+func checkKeys(strict bool, keys ...string) error {
+	if !strict {
+		return nil
+	}
+	for _, key := range keys {
+		if err := ValidateKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	valid := []string{"a", "dir/file.txt", "dir/", "报告.txt", "a..b", strings.Repeat("a", 1024)}
+	for _, key := range valid {
+		if err := ValidateKey(key); err != nil {
+			t.Fatalf("valid key %q is rejected: %v", key, err)
+		}
+	}
+
+	invalid := []string{"", strings.Repeat("a", 1025), "a\r\nb", "a\x00", "\xff", "/a", `\a`, "a//b", "a/./b", "a/..", "."}
+	for _, key := range invalid {
+		if err := ValidateKey(key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("invalid key %q is accepted: %v", key, err)
+		}
+	}
+}
+
+func TestStrictKeys(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	for _, backend := range []string{"s3", "oss"} {
+		var (
+			client Client
+			err    error
+		)
+		opts := []Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key"), WithStrictKeys()}
+		if backend == "s3" {
+			client, err = NewS3("bucket", append(opts, WithPathStyle(true))...)
+		} else {
+			client, err = NewOSS("bucket", opts...)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %v client: %v", backend, err)
+		}
+
+		if _, err := client.Info(context.Background(), "a//b"); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("invalid key is accepted by %v: %v", backend, err)
+		}
+		if err := client.Write(context.Background(), "/a", strings.NewReader(""), nil); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("invalid key is accepted by %v: %v", backend, err)
+		}
+		if err := client.Remove(context.Background(), "a", "a\nb"); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("invalid key is accepted by %v: %v", backend, err)
+		}
+		if requests.Load() != 0 {
+			t.Fatalf("requests are sent by %v for invalid keys", backend)
+		}
+		if _, err := client.Info(context.Background(), "a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("valid key is rejected by %v: %v", backend, err)
+		}
+		requests.Store(0)
+	}
+}
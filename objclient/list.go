@@ -0,0 +1,98 @@
+package objclient
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ListOptions filters the objects of ListWithOptions, zero fields don't
+// filter.
+type ListOptions struct {
+	// StartAfter lists the keys after it, so listings can be resumed from
+	// the last key listed.
+	StartAfter string
+	// Suffix is the end of the keys.
+	Suffix string
+	// MinSize and MaxSize are the inclusive range of sizes, MaxSize 0 is
+	// unlimited.
+	MinSize int64
+	MaxSize int64
+	// ModifiedSince and ModifiedBefore are the range of LastModified,
+	// ModifiedSince is inclusive and ModifiedBefore isn't.
+	ModifiedSince  time.Time
+	ModifiedBefore time.Time
+	// Regexp matches the keys.
+	Regexp *regexp.Regexp
+}
+
+// Match returns whether item passes the filters.
+func (o *ListOptions) Match(item ObjectItem) bool {
+	if o == nil {
+		return true
+	}
+	if item.Key <= o.StartAfter {
+		return false
+	}
+	if !strings.HasSuffix(item.Key, o.Suffix) {
+		return false
+	}
+	if item.Size < o.MinSize || o.MaxSize > 0 && item.Size > o.MaxSize {
+		return false
+	}
+	if !o.ModifiedSince.IsZero() && item.LastModified.Before(o.ModifiedSince) {
+		return false
+	}
+	if !o.ModifiedBefore.IsZero() && !item.LastModified.Before(o.ModifiedBefore) {
+		return false
+	}
+	return o.Regexp == nil || o.Regexp.MatchString(item.Key)
+}
+
+// ListWithOptions lists the objects of prefix like List, and returns the
+// ones passing the filters of o, which can be nil. If client is a
+// PageLister, the pages are filtered while listing, so only the matched
+// objects are kept, and the listing starts from StartAfter.
+func ListWithOptions(ctx context.Context, client ReadOnlyClient, prefix string, o *ListOptions) ([]ObjectItem, error) {
+	lister, ok := AsPageLister(client)
+	if !ok {
+		items, err := client.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		return filterItems(items, o), nil
+	}
+
+	var startAfter string
+	if o != nil {
+		startAfter = o.StartAfter
+	}
+	var items []ObjectItem
+	err := lister.ListPages(ctx, prefix, startAfter, func(page []ObjectItem) bool {
+		for _, item := range page {
+			if o.Match(item) {
+				items = append(items, item)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// filterItems returns the items matched by o in the array of items.
+func filterItems(items []ObjectItem, o *ListOptions) []ObjectItem {
+	if o == nil {
+		return items
+	}
+	matched := items[:0]
+	for _, item := range items {
+		if o.Match(item) {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
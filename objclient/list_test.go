@@ -0,0 +1,57 @@
+package objclient
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestListWithOptions(t *testing.T) {
+	mem := newMemClient()
+	for key, data := range map[string]string{
+		"logs/2024-01-01.log":  "1",
+		"logs/2024-01-02.log":  "123",
+		"logs/2024-01-02.gz":   "12345",
+		"logs/2024-02-01.log":  "12345",
+		"other/2024-01-01.log": "1",
+	} {
+		mem.put(key, data)
+	}
+	now := time.Now()
+	mem.objects["logs/2024-01-01.log"] = memObject{data: []byte("1"), modified: now.Add(-2 * time.Hour)}
+
+	o := &ListOptions{
+		Suffix:        ".log",
+		MaxSize:       3,
+		ModifiedSince: now.Add(-time.Hour),
+		Regexp:        regexp.MustCompile(`/2024-01-`),
+	}
+	for _, client := range []ReadOnlyClient{mem, &pageMemClient{memClient: mem}} {
+		items, err := ListWithOptions(context.Background(), client, "logs/", o)
+		if err != nil {
+			t.Fatalf("failed to list: %v", err)
+		}
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, item.Key)
+		}
+		if !reflect.DeepEqual(keys, []string{"logs/2024-01-02.log"}) {
+			t.Fatalf("invalid filtered keys: %v", keys)
+		}
+	}
+
+	items, err := ListWithOptions(context.Background(), mem, "logs/", &ListOptions{MinSize: 5, ModifiedBefore: now.Add(time.Hour)})
+	if err != nil || len(items) != 2 {
+		t.Fatalf("invalid items of size range: %v, %v", items, err)
+	}
+
+	// The listing is resumed after the last key.
+	for _, client := range []ReadOnlyClient{mem, &pageMemClient{memClient: mem}} {
+		items, err := ListWithOptions(context.Background(), client, "logs/", &ListOptions{StartAfter: "logs/2024-01-02.gz"})
+		if err != nil || len(items) != 2 || items[0].Key != "logs/2024-01-02.log" {
+			t.Fatalf("invalid items after start: %v, %v", items, err)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package objclient
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	listPageSize = 1000
+	// listProbesPerWorker is the number of probes of boundaries for each
+	// worker of ListFast, more ranges than workers balance the uneven ones.
+	listProbesPerWorker = 8
+)
+
+// PageLister is implemented by clients which can list objects page by page
+// from a key.
+type PageLister interface {
+	// ListPages calls fn with the pages of objects of prefix, whose keys are
+	// after startAfter, in the order of keys until fn returns false.
+	ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error
+}
+
+// AsPageLister returns client as a PageLister if it lists the pages by its
+// backends. The clients combining others, e.g. ShardedClient, have ListPages
+// which falls back to List if their inner clients aren't PageListers, so
+// it's no better than List for them.
+func AsPageLister(client ReadOnlyClient) (PageLister, bool) {
+	lister, ok := client.(PageLister)
+	if !ok {
+		return nil, false
+	}
+	if paged, ok := client.(interface{ listsPages() bool }); ok && !paged.listsPages() {
+		return nil, false
+	}
+	return lister, true
+}
+
+// listPages lists the pages of client by ListPages, or the pages of the
+// items of List if it isn't a PageLister.
+func listPages(ctx context.Context, client ReadOnlyClient, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	if lister, ok := AsPageLister(client); ok {
+		return lister.ListPages(ctx, prefix, startAfter, fn)
+	}
+	items, err := client.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	listItemPages(items, startAfter, fn)
+	return nil
+}
+
+// listItemPages calls fn with the pages of the sorted items after
+// startAfter until it returns false.
+func listItemPages(items []ObjectItem, startAfter string, fn func(items []ObjectItem) bool) {
+	i := sort.Search(len(items), func(i int) bool { return items[i].Key > startAfter })
+	for items = items[i:]; len(items) > 0; {
+		page := items[:min(len(items), listPageSize)]
+		items = items[len(page):]
+		if !fn(page) {
+			return
+		}
+	}
+}
+
+func (client *S3Client) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	// The listing is stopped by canceling.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var opts minio.ListObjectsOptions
+	opts.Prefix = prefix
+	opts.Recursive = true
+	opts.StartAfter = startAfter
+	opts.MaxKeys = listPageSize
+
+	page := make([]ObjectItem, 0, listPageSize)
+	backend, err := client.listBackendOf(ctx)
+	if err != nil {
+		return err
+	}
+	for obj := range backend.ListObjects(ctx, client.bucket, opts) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		page = append(page, ObjectItem{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+			ETag:         obj.ETag,
+			StorageClass: obj.StorageClass,
+		})
+		if len(page) == listPageSize {
+			if !fn(page) {
+				return nil
+			}
+			page = make([]ObjectItem, 0, listPageSize)
+		}
+	}
+	if len(page) > 0 {
+		fn(page)
+	}
+	return nil
+}
+
+func (client *OSSClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	var opts []oss.Option
+	opts = append(opts, oss.WithContext(ctx))
+	opts = append(opts, oss.Prefix(prefix))
+	opts = append(opts, oss.MaxKeys(listPageSize))
+	if startAfter != "" {
+		opts = append(opts, oss.StartAfter(startAfter))
+	}
+
+	bucket, err := client.listBucketOf(ctx)
+	if err != nil {
+		return err
+	}
+	var token string
+	for {
+		// Canceling ctx stops the listing between pages.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		o := append(opts, oss.ContinuationToken(token))
+		list, err := bucket.ListObjectsV2(o...)
+		if err != nil {
+			return translateError(err)
+		}
+		page := make([]ObjectItem, 0, len(list.Objects))
+		for _, obj := range list.Objects {
+			page = append(page, ObjectItem{
+				Key:          obj.Key,
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+				ETag:         strings.Trim(obj.ETag, "\""),
+				StorageClass: obj.StorageClass,
+			})
+		}
+		if len(page) > 0 && !fn(page) {
+			return nil
+		}
+
+		if !list.IsTruncated {
+			return nil
+		}
+		token = list.NextContinuationToken
+	}
+}
+
+// listRange is the keys after startAfter, to until or the end if until is
+// empty.
+type listRange struct {
+	startAfter string
+	until      string
+	items      []ObjectItem
+}
+
+// ListFast lists the objects of prefix like List, but the keys are split
+// into ranges listed by workers concurrently if client is a PageLister. The
+// boundaries of ranges are the keys probed after guesses of the two bytes
+// following prefix, so keys of printable ASCII are split evenly.
+func ListFast(ctx context.Context, client ReadOnlyClient, prefix string, workers int) ([]ObjectItem, error) {
+	lister, ok := AsPageLister(client)
+	if !ok || workers <= 1 {
+		return client.List(ctx, prefix)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	boundaries, err := probeBoundaries(ctx, lister, prefix, workers)
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([]*listRange, 0, len(boundaries)+1)
+	startAfter := ""
+	for _, boundary := range boundaries {
+		ranges = append(ranges, &listRange{startAfter: startAfter, until: boundary})
+		startAfter = boundary
+	}
+	ranges = append(ranges, &listRange{startAfter: startAfter})
+
+	var (
+		wg      sync.WaitGroup
+		once    sync.Once
+		listErr error
+	)
+	jobs := make(chan *listRange)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				if err := r.list(ctx, lister, prefix); err != nil {
+					once.Do(func() { listErr = err })
+					cancel()
+				}
+			}
+		}()
+	}
+	for _, r := range ranges {
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+
+	if listErr != nil {
+		return nil, listErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The ranges are in order, so are their items.
+	var items []ObjectItem
+	for _, r := range ranges {
+		items = append(items, r.items...)
+	}
+	return items, nil
+}
+
+func (r *listRange) list(ctx context.Context, lister PageLister, prefix string) error {
+	return lister.ListPages(ctx, prefix, r.startAfter, func(items []ObjectItem) bool {
+		for i, item := range items {
+			if r.until != "" && item.Key > r.until {
+				r.items = append(r.items, items[:i]...)
+				return false
+			}
+		}
+		r.items = append(r.items, items...)
+		return true
+	})
+}
+
+// probeBoundaries returns the sorted keys which are the first ones after the
+// guesses of keys.
+func probeBoundaries(ctx context.Context, lister PageLister, prefix string, workers int) ([]string, error) {
+	const (
+		first = ' '
+		chars = '~' - ' ' + 1
+	)
+	count := workers * listProbesPerWorker
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		probeErr error
+		keys     = make(map[string]bool)
+	)
+	sem := make(chan struct{}, workers)
+	for i := 1; i < count; i++ {
+		// The guesses are spread evenly in the space of two characters.
+		n := i * chars * chars / count
+		guess := prefix + string(rune(first+n/chars)) + string(rune(first+n%chars))
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := lister.ListPages(ctx, prefix, guess, func(items []ObjectItem) bool {
+				mutex.Lock()
+				keys[items[0].Key] = true
+				mutex.Unlock()
+				return false
+			})
+			if err != nil {
+				mutex.Lock()
+				probeErr = err
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if probeErr != nil {
+		return nil, probeErr
+	}
+
+	boundaries := make([]string, 0, len(keys))
+	for key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			boundaries = append(boundaries, key)
+		}
+	}
+	sort.Strings(boundaries)
+	return boundaries, nil
+}
@@ -0,0 +1,266 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pageMemClient lists the objects of memClient in pages of 100.
+type pageMemClient struct {
+	*memClient
+	lists atomic.Int32
+}
+
+func (client *pageMemClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	client.lists.Add(1)
+	items, err := client.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for len(items) > 0 && items[0].Key <= startAfter {
+		items = items[1:]
+	}
+	for len(items) > 0 {
+		page := items[:min(len(items), 100)]
+		items = items[len(page):]
+		if !fn(page) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestListFast(t *testing.T) {
+	client := &pageMemClient{memClient: newMemClient()}
+	for i := 0; i < 4096; i++ {
+		client.put(fmt.Sprintf("blocks/%03x", i), "")
+	}
+	client.put("blocks", "")
+	client.put("other/key", "")
+
+	expect, err := client.List(context.Background(), "blocks/")
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	items, err := ListFast(context.Background(), client, "blocks/", 4)
+	if err != nil {
+		t.Fatalf("failed to list fast: %v", err)
+	}
+	if !reflect.DeepEqual(items, expect) {
+		t.Fatalf("invalid items: %v items, expect %v", len(items), len(expect))
+	}
+	// The probes and the ranges.
+	if client.lists.Load() <= 4*listProbesPerWorker {
+		t.Fatalf("keys are not split: %v lists", client.lists.Load())
+	}
+
+	if items, err := ListFast(context.Background(), client, "empty/", 4); err != nil || len(items) != 0 {
+		t.Fatalf("invalid items of empty prefix: %v, %v", items, err)
+	}
+
+	// Clients without ListPages list all.
+	items, err = ListFast(context.Background(), client.memClient, "blocks/", 4)
+	if err != nil || len(items) != len(expect) {
+		t.Fatalf("invalid items of memClient: %v, %v", len(items), err)
+	}
+}
+
+func TestWrapperListPages(t *testing.T) {
+	inner := &pageMemClient{memClient: newMemClient()}
+	for i := 0; i < 250; i++ {
+		inner.put(fmt.Sprintf("root/%03d", i), "")
+	}
+	diskCache, err := NewDiskCacheClient(inner, t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	region, err := NewMultiRegionClient(map[string]Client{"a": inner}, MultiRegionPolicy{Primary: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer region.Close()
+	quota := NewQuotaClient(inner, 1<<30, NewMemoryUsageStore(), nil)
+	defer quota.Close()
+	bus := NewEventBusClient(inner)
+	defer bus.Close()
+
+	// chain stacks the wrappers without their own listings.
+	var chain Client = inner
+	for _, wrap := range []func(inner Client) Client{
+		func(inner Client) Client { return NewRateLimitedClient(inner, 1000, 1000) },
+		func(inner Client) Client { return NewThrottledClient(inner, BandwidthLimits{}) },
+		func(inner Client) Client { return NewBoundedClient(inner, 4, nil) },
+		func(inner Client) Client { return NewChaosClient(inner, ChaosOptions{}) },
+		WithHooks(Hooks{}),
+		func(inner Client) Client { return NewHedgedClient(inner, nil) },
+		func(inner Client) Client { return NewVerifyingClient(inner, nil) },
+		func(inner Client) Client { return NewResumingClient(inner, nil) },
+		func(inner Client) Client { return NewAuditClient(inner, NewJSONAuditSink(io.Discard), nil) },
+		func(inner Client) Client { return NewDryRunClient(inner, slog.Default()) },
+		func(inner Client) Client { return NewMirrorClient(inner, newMemClient(), MirrorSync, nil) },
+		func(inner Client) Client { return NewFailoverClient(inner, newMemClient(), nil) },
+		func(inner Client) Client { return NewDedupClient(inner, ".blobs/", t.TempDir()) },
+		func(inner Client) Client { return NewTieredClient(inner, newMemClient(), "") },
+		func(inner Client) Client { return NewTimeoutClient(inner, nil) },
+	} {
+		chain = wrap(chain)
+	}
+
+	wrappers := map[string]Client{
+		"prefix":    WithPrefix(WithPrefix(inner, "ro"), "ot/"),
+		"sanitize":  WithPrefix(NewSanitizingClient(inner, DefaultKeyPolicy), "root/"),
+		"readonly":  WithPrefix(NewReadOnlyClient(inner), "root/"),
+		"timeout":   WithPrefix(NewTimeoutClient(inner, nil), "root/"),
+		"log":       WithPrefix(NewLoggingClient(inner, slog.New(slog.NewTextHandler(io.Discard, nil)), nil), "root/"),
+		"memcache":  WithPrefix(NewMemoryCacheClient(inner, MemoryCacheOptions{}), "root/"),
+		"diskcache": WithPrefix(diskCache, "root/"),
+		"region":    WithPrefix(region, "root/"),
+		"quota":     WithPrefix(quota, "root/"),
+		"eventbus":  WithPrefix(bus, "root/"),
+		"chain":     WithPrefix(chain, "root/"),
+	}
+	for name, client := range wrappers {
+		lister, ok := AsPageLister(client)
+		if !ok {
+			t.Fatalf("%v isn't a PageLister", name)
+		}
+		lists := inner.lists.Load()
+		var keys []string
+		err := lister.ListPages(context.Background(), "1", "100", func(items []ObjectItem) bool {
+			for _, item := range items {
+				keys = append(keys, item.Key)
+			}
+			return true
+		})
+		if err != nil || len(keys) != 99 || keys[0] != "101" || keys[98] != "199" {
+			t.Fatalf("invalid pages of %v: %v, %v", name, keys, err)
+		}
+		if inner.lists.Load() != lists+1 {
+			t.Fatalf("pages of %v aren't listed by inner", name)
+		}
+	}
+
+	// The wrappers of clients without ListPages aren't PageListers.
+	if _, ok := NewLoggingClient(inner.memClient, slog.Default(), nil).(PageLister); ok {
+		t.Fatalf("wrapper of memClient is a PageLister")
+	}
+	if _, ok := AsPageLister(NewTieredClient(inner.memClient, inner, "")); ok {
+		t.Fatalf("tiered client of memClient is a PageLister")
+	}
+}
+
+func TestCombinedListPages(t *testing.T) {
+	shards := []Client{&pageMemClient{memClient: newMemClient()}, &pageMemClient{memClient: newMemClient()}}
+	sharded, err := NewShardedClient(shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := []Client{&pageMemClient{memClient: newMemClient()}, &pageMemClient{memClient: newMemClient()}, &pageMemClient{memClient: newMemClient()}}
+	erasure, err := NewErasureClient(backends, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunked := NewChunkedClient(&pageMemClient{memClient: newMemClient()}, &ChunkOptions{ChunkSize: 4})
+	for name, client := range map[string]Client{"shard": sharded, "erasure": erasure, "chunk": chunked} {
+		for i := 0; i < 1500; i++ {
+			key, data := fmt.Sprintf("dir/%04d", i), ""
+			if i%50 == 0 {
+				// The chunked objects, whose chunks are after the next key.
+				data = "chunked"
+				if err := client.Write(ctx, key+".txt", strings.NewReader(""), nil); err != nil {
+					t.Fatalf("failed to write %v of %v: %v", key, name, err)
+				}
+			}
+			if err := client.Write(ctx, key, strings.NewReader(data), nil); err != nil {
+				t.Fatalf("failed to write %v of %v: %v", key, name, err)
+			}
+		}
+		expect, err := client.List(ctx, "dir/")
+		if err != nil || len(expect) != 1530 || expect[1071].Key != "dir/1050" || expect[1071].Size != 7 {
+			t.Fatalf("failed to list %v: %v, %v", name, len(expect), err)
+		}
+		// The first page ends with dir/0100.txt.
+		expect = expect[4:]
+
+		lister, ok := AsPageLister(client)
+		if !ok {
+			t.Fatalf("%v isn't a PageLister", name)
+		}
+		var items []ObjectItem
+		err = lister.ListPages(ctx, "dir/", "dir/0002", func(page []ObjectItem) bool {
+			items = append(items, page...)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("failed to list pages of %v: %v", name, err)
+		}
+		if len(items) != len(expect) {
+			t.Fatalf("invalid items of %v: %v, expect %v", name, len(items), len(expect))
+		}
+		for i, item := range items {
+			if item.Key != expect[i].Key || item.Size != expect[i].Size {
+				t.Fatalf("invalid item %v of %v: %v, expect %v", i, name, item, expect[i])
+			}
+		}
+	}
+
+	// They fall back to List if the inner clients aren't PageListers.
+	if _, ok := AsPageLister(NewChunkedClient(newMemClient(), nil)); ok {
+		t.Fatalf("chunked client of memClient is a PageLister")
+	}
+	sharded, err = NewShardedClient([]Client{shards[0], newMemClient()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := AsPageLister(sharded); ok {
+		t.Fatalf("sharded client of memClient is a PageLister")
+	}
+}
+
+func TestOSSListCancel(t *testing.T) {
+	// The server lists endless pages of an object.
+	var requests, cancelAt atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if n == cancelAt.Load() {
+			cancel()
+		}
+		fmt.Fprintf(w, `<ListBucketResult><Name>bucket</Name><IsTruncated>true</IsTruncated><NextContinuationToken>%v</NextContinuationToken>`+
+			`<Contents><Key>key%04d</Key><LastModified>2024-01-02T03:04:05.000Z</LastModified><Size>1</Size></Contents></ListBucketResult>`, n, n)
+	}))
+	defer server.Close()
+
+	client, err := NewOSS("bucket", WithEndpoint(strings.TrimPrefix(server.URL, "http://")), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	var pages int
+	err = client.ListPages(context.Background(), "", "", func(items []ObjectItem) bool {
+		pages++
+		return pages < 5
+	})
+	if err != nil || pages != 5 {
+		t.Fatalf("invalid pages %v: %v", pages, err)
+	}
+
+	requests.Store(0)
+	cancelAt.Store(3)
+	if _, err := client.List(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled listing isn't stopped: %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Fatalf("invalid requests after canceled: %v", requests.Load())
+	}
+}
@@ -0,0 +1,395 @@
+package objclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// StorageConfig describes a backend client and the wrappers of it. In
+// config files, it's the "storage" section, and names of fields and params
+// can be in snake case, e.g.
+//
+//	storage:
+//	  type: s3
+//	  s3:
+//	    bucket: seafile
+//	    region: eu-west-1
+//	    v4_signature: true
+//	  credentials:
+//	    env: S3_
+//	  wrappers:
+//	    - type: timeout
+//	    - type: memcache
+//	      params:
+//	        max_object_size: 65536
+type StorageConfig struct {
+	// DSN describes the backend in a string, see NewClientFromDSN. Type and
+	// the configs of backends are ignored if it's set.
+	DSN string
+	// Type is s3 or oss.
+	Type string
+	S3   S3Config
+	OSS  OSSConfig
+	// Credentials override the keys of backend config.
+	Credentials CredentialsRef
+	// Wrappers are applied in order, the first one is the outermost.
+	Wrappers []WrapperConfig
+}
+
+// CredentialsRef refers to the keys stored out of config files.
+type CredentialsRef struct {
+	// Env is the prefix of environment variables, the keys are read from
+	// <Env>KEY_ID and <Env>KEY.
+	Env string
+}
+
+type WrapperConfig struct {
+	Type   string
+	Params map[string]any
+}
+
+// WrapperFactory creates a wrapper of inner by params of the config.
+type WrapperFactory func(inner Client, params map[string]any) (Client, error)
+
+var (
+	wrappersMutex sync.RWMutex
+	wrappers      = map[string]WrapperFactory{
+		"prefix":    newPrefixWrapper,
+		"readonly":  newReadOnlyWrapper,
+		"sanitize":  newSanitizeWrapper,
+		"memcache":  newMemCacheWrapper,
+		"diskcache": newDiskCacheWrapper,
+		"ratelimit": newRateLimitWrapper,
+		"throttle":  newThrottleWrapper,
+		"bounded":   newBoundedWrapper,
+		"timeout":   newTimeoutWrapper,
+		"hedge":     newHedgeWrapper,
+		"log":       newLogWrapper,
+	}
+)
+
+// RegisterWrapper makes a wrapper available to config files by name. It
+// replaces the wrapper of the same name.
+func RegisterWrapper(name string, factory WrapperFactory) {
+	wrappersMutex.Lock()
+	defer wrappersMutex.Unlock()
+	wrappers[name] = factory
+}
+
+// LoadConfig creates the client described by the "storage" section of the
+// config file. The format is decided by the extension, which is one of
+// .json, .yaml, .yml and .toml.
+func LoadConfig(path string) (Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unknown config format: %v", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %v: %w", path, err)
+	}
+
+	section, ok := doc["storage"]
+	if !ok {
+		return nil, fmt.Errorf("no storage section in config %v", path)
+	}
+	var config StorageConfig
+	if err := decodeConfig(section, &config); err != nil {
+		return nil, fmt.Errorf("invalid storage config: %w", err)
+	}
+
+	return NewClientFromConfig(config)
+}
+
+// NewClientFromConfig creates the client described by config.
+func NewClientFromConfig(config StorageConfig) (Client, error) {
+	var keyID, key string
+	if config.Credentials.Env != "" {
+		keyID = os.Getenv(config.Credentials.Env + "KEY_ID")
+		key = os.Getenv(config.Credentials.Env + "KEY")
+		if keyID == "" || key == "" {
+			return nil, fmt.Errorf("%vKEY_ID or %vKEY is not set", config.Credentials.Env, config.Credentials.Env)
+		}
+	}
+
+	if config.DSN != "" {
+		scheme, _, _ := strings.Cut(config.DSN, "://")
+		var err error
+		switch scheme {
+		case "s3":
+			config.S3, err = ParseS3DSN(config.DSN)
+		case "oss":
+			config.OSS, err = ParseOSSDSN(config.DSN)
+		default:
+			err = fmt.Errorf("unknown dsn scheme: %q", scheme)
+		}
+		if err != nil {
+			return nil, err
+		}
+		config.Type = scheme
+	}
+
+	var (
+		client Client
+		err    error
+	)
+	switch config.Type {
+	case "s3":
+		if keyID != "" {
+			config.S3.KeyID, config.S3.Key = keyID, key
+		}
+		if err := config.S3.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid s3 config: %w", err)
+		}
+		client, err = NewS3Client(config.S3)
+	case "oss":
+		if keyID != "" {
+			config.OSS.KeyID, config.OSS.Key = keyID, key
+		}
+		if err := config.OSS.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid oss config: %w", err)
+		}
+		client, err = NewOSSClient(config.OSS)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %q", config.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wrappersMutex.RLock()
+	defer wrappersMutex.RUnlock()
+	for i := len(config.Wrappers) - 1; i >= 0; i-- {
+		wrapper := config.Wrappers[i]
+		factory, ok := wrappers[wrapper.Type]
+		if !ok {
+			client.Close()
+			return nil, fmt.Errorf("unknown wrapper: %q", wrapper.Type)
+		}
+		wrapped, err := factory(client, wrapper.Params)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to create wrapper %v: %w", wrapper.Type, err)
+		}
+		client = wrapped
+	}
+
+	return client, nil
+}
+
+// normalizeConfig removes "_" and "-" from map keys, so snake case names
+// match the fields. Scalars are converted to strings under the s3 and oss
+// sections, since fields of them are all strings.
+func normalizeConfig(v any, stringify bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			k = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(k))
+			m[k] = normalizeConfig(val, stringify || k == "s3" || k == "oss")
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, val := range v {
+			s[i] = normalizeConfig(val, stringify)
+		}
+		return s
+	case nil, string:
+		return v
+	}
+	if stringify {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+// decodeConfig decodes v parsed from config files into the struct pointed
+// by out.
+func decodeConfig(v any, out any) error {
+	data, err := json.Marshal(normalizeConfig(v, false))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(out)
+}
+
+// configDuration is a duration in config, e.g. "10s".
+type configDuration time.Duration
+
+func (d *configDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("duration should be a string, e.g. \"10s\"")
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = configDuration(duration)
+	return nil
+}
+
+func newPrefixWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct{ Prefix string }
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	return WithPrefix(inner, p.Prefix), nil
+}
+
+func newReadOnlyWrapper(inner Client, params map[string]any) (Client, error) {
+	return NewReadOnlyClient(inner), nil
+}
+
+func newSanitizeWrapper(inner Client, params map[string]any) (Client, error) {
+	if params == nil {
+		return NewSanitizingClient(inner, DefaultKeyPolicy), nil
+	}
+	var policy KeyPolicy
+	if err := decodeConfig(params, &policy); err != nil {
+		return nil, err
+	}
+	return NewSanitizingClient(inner, policy), nil
+}
+
+func newMemCacheWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct {
+		MaxObjectSize int64
+		MaxBytes      int64
+		MaxEntries    int
+		TTL           configDuration
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	return NewMemoryCacheClient(inner, MemoryCacheOptions{
+		MaxObjectSize: p.MaxObjectSize,
+		MaxBytes:      p.MaxBytes,
+		MaxEntries:    p.MaxEntries,
+		TTL:           time.Duration(p.TTL),
+	}), nil
+}
+
+func newDiskCacheWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct {
+		Dir      string
+		MaxBytes int64
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Dir == "" || p.MaxBytes <= 0 {
+		return nil, errors.New("dir and max_bytes are required")
+	}
+	return NewDiskCacheClient(inner, p.Dir, p.MaxBytes)
+}
+
+func newRateLimitWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct {
+		OpsPerSecond float64
+		Burst        int
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	if p.OpsPerSecond <= 0 {
+		return nil, errors.New("ops_per_second is required")
+	}
+	return NewRateLimitedClient(inner, p.OpsPerSecond, max(p.Burst, 1)), nil
+}
+
+func newThrottleWrapper(inner Client, params map[string]any) (Client, error) {
+	var limits BandwidthLimits
+	if err := decodeConfig(params, &limits); err != nil {
+		return nil, err
+	}
+	return NewThrottledClient(inner, limits), nil
+}
+
+func newBoundedWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct {
+		MaxInFlight int
+		Read        int
+		Write       int
+		List        int
+		Delete      int
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	return NewBoundedClient(inner, p.MaxInFlight, map[OpClass]int{
+		OpRead:   p.Read,
+		OpWrite:  p.Write,
+		OpList:   p.List,
+		OpDelete: p.Delete,
+	}), nil
+}
+
+func newTimeoutWrapper(inner Client, params map[string]any) (Client, error) {
+	if params == nil {
+		return NewTimeoutClient(inner, nil), nil
+	}
+	var p struct {
+		Read      configDuration
+		Write     configDuration
+		List      configDuration
+		Delete    configDuration
+		ReadStall configDuration
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	return NewTimeoutClient(inner, &TimeoutPolicy{
+		Timeouts: map[OpClass]time.Duration{
+			OpRead:   time.Duration(p.Read),
+			OpWrite:  time.Duration(p.Write),
+			OpList:   time.Duration(p.List),
+			OpDelete: time.Duration(p.Delete),
+		},
+		ReadStall: time.Duration(p.ReadStall),
+	}), nil
+}
+
+func newHedgeWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct {
+		Delay configDuration
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	return NewHedgedClient(inner, &HedgePolicy{Delay: time.Duration(p.Delay)}), nil
+}
+
+func newLogWrapper(inner Client, params map[string]any) (Client, error) {
+	var p struct {
+		SlowThreshold configDuration
+	}
+	if err := decodeConfig(params, &p); err != nil {
+		return nil, err
+	}
+	return NewLoggingClient(inner, slog.Default(), &LogOptions{SlowThreshold: time.Duration(p.SlowThreshold)}), nil
+}
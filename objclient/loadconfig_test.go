@@ -0,0 +1,125 @@
+package objclient
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TEST_STORAGE_KEY_ID", "id")
+	t.Setenv("TEST_STORAGE_KEY", "key")
+
+	configs := map[string]string{
+		"storage.json": `{"storage": {
+			"type": "s3",
+			"s3": {"bucket": "test", "region": "eu-west-1", "v4_signature": true, "https": true},
+			"credentials": {"env": "TEST_STORAGE_"},
+			"wrappers": [{"type": "readonly"}, {"type": "memcache", "params": {"max_object_size": 1024, "ttl": "10s"}}]
+		}}`,
+		"storage.yaml": `
+storage:
+  type: s3
+  s3:
+    bucket: test
+    region: eu-west-1
+    v4_signature: true
+    https: true
+  credentials:
+    env: TEST_STORAGE_
+  wrappers:
+    - type: readonly
+    - type: memcache
+      params:
+        max_object_size: 1024
+        ttl: 10s
+`,
+		"storage.toml": `
+[storage]
+type = "s3"
+credentials = { env = "TEST_STORAGE_" }
+
+[storage.s3]
+bucket = "test"
+region = "eu-west-1"
+v4_signature = true
+https = true
+
+[[storage.wrappers]]
+type = "readonly"
+
+[[storage.wrappers]]
+type = "memcache"
+params = { max_object_size = 1024, ttl = "10s" }
+`,
+	}
+
+	dir := t.TempDir()
+	for name, data := range configs {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		client, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("failed to load %v: %v", name, err)
+		}
+		// The wrappers of S3 list pages.
+		readonly, ok := client.(*readOnlyPageClient)
+		if !ok {
+			t.Fatalf("invalid client of %v: %T", name, client)
+		}
+		cache, ok := readonly.inner.(*memCachePageClient)
+		if !ok || cache.opts.MaxObjectSize != 1024 {
+			t.Fatalf("invalid wrapper of %v: %T", name, readonly.inner)
+		}
+		if s3, ok := cache.inner.(*S3Client); !ok || s3.bucket != "test" || !s3.https {
+			t.Fatalf("invalid backend of %v: %T", name, cache.inner)
+		}
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	os.WriteFile(path, []byte(`{"storage": {"type": "s3", "s3": {"bucket": "test", "https": "yes"}}}`), 0o600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatalf("expect invalid config")
+	}
+}
+
+type closeRecorder struct {
+	Client
+	closed bool
+}
+
+func (client *closeRecorder) Close() error {
+	client.closed = true
+	return client.Client.Close()
+}
+
+func TestNewClientFromConfigClose(t *testing.T) {
+	var recorder *closeRecorder
+	RegisterWrapper("test-record", func(inner Client, params map[string]any) (Client, error) {
+		recorder = &closeRecorder{Client: inner}
+		return recorder, nil
+	})
+	RegisterWrapper("test-fail", func(inner Client, params map[string]any) (Client, error) {
+		return nil, errors.New("invalid params")
+	})
+
+	// The client built is closed if the wrappers fail.
+	for _, name := range []string{"test-fail", "test-unknown"} {
+		recorder = nil
+		_, err := NewClientFromConfig(StorageConfig{
+			Type:     "s3",
+			S3:       S3Config{Bucket: "test", Region: "eu-west-1"},
+			Wrappers: []WrapperConfig{{Type: name}, {Type: "test-record"}},
+		})
+		if err == nil {
+			t.Fatalf("invalid wrapper %v is accepted", name)
+		}
+		if recorder == nil || !recorder.closed {
+			t.Fatalf("client isn't closed after wrapper %v fails", name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package objclient
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// LogOptions are the levels of NewLoggingClient, the nil ones are the
+// defaults. The levels can be a slog.Level, or a *slog.LevelVar changed
+// while logging.
+type LogOptions struct {
+	// Level of successful operations, defaults to slog.LevelDebug.
+	Level slog.Leveler
+	// ErrorLevel of failed operations, defaults to slog.LevelError.
+	ErrorLevel slog.Leveler
+	// Successful operations taking longer than SlowThreshold are logged at
+	// SlowLevel, which defaults to slog.LevelWarn. Zero disables it.
+	SlowThreshold time.Duration
+	SlowLevel     slog.Leveler
+}
+
+type loggingClient struct {
+	inner  Client
+	logger *slog.Logger
+	opts   LogOptions
+}
+
+// NewLoggingClient logs the operations of inner with their key, size,
+// duration and error. Nil opts uses the default levels.
+func NewLoggingClient(inner Client, logger *slog.Logger, opts *LogOptions) Client {
+	var o LogOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Level == nil {
+		o.Level = slog.LevelDebug
+	}
+	if o.ErrorLevel == nil {
+		o.ErrorLevel = slog.LevelError
+	}
+	if o.SlowLevel == nil {
+		o.SlowLevel = slog.LevelWarn
+	}
+
+	client := &loggingClient{inner: inner, logger: logger, opts: o}
+	if _, ok := AsPageLister(inner); ok {
+		return &loggingPageClient{client}
+	}
+	return client
+}
+
+// loggingPageClient is the loggingClient of a PageLister, which logs each
+// listing once with the count of the items of all the pages.
+type loggingPageClient struct {
+	*loggingClient
+}
+
+func (client *loggingPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	start := time.Now()
+	var count int
+	err := client.inner.(PageLister).ListPages(ctx, prefix, startAfter, func(items []ObjectItem) bool {
+		count += len(items)
+		return fn(items)
+	})
+	client.log(ctx, "ListPages", start, err, slog.String("prefix", prefix), slog.String("start_after", startAfter), slog.Int("count", count))
+	return err
+}
+
+func (client *loggingClient) log(ctx context.Context, op string, start time.Time, err error, attrs ...slog.Attr) {
+	duration := time.Since(start)
+
+	level := client.opts.Level.Level()
+	switch {
+	case err != nil:
+		level = client.opts.ErrorLevel.Level()
+		attrs = append(attrs, slog.Any("error", err))
+	case client.opts.SlowThreshold > 0 && duration > client.opts.SlowThreshold:
+		level = client.opts.SlowLevel.Level()
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	if !client.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append(attrs, slog.String("op", op), slog.Duration("duration", duration))
+	client.logger.LogAttrs(ctx, level, "objclient "+op, attrs...)
+}
+
+func (client *loggingClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *loggingClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	start := time.Now()
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	client.log(ctx, "Read", start, err, slog.String("key", key))
+	return r, err
+}
+
+func (client *loggingClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *loggingClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	var size int64
+	if o != nil {
+		size = o.Size
+	}
+
+	start := time.Now()
+	result, err := client.inner.WriteWithResult(ctx, key, r, o)
+	client.log(ctx, "Write", start, err, slog.String("key", key), slog.Int64("size", size))
+	return result, err
+}
+
+func (client *loggingClient) Exist(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	exist, err := client.inner.Exist(ctx, key)
+	client.log(ctx, "Exist", start, err, slog.String("key", key), slog.Bool("exist", exist))
+	return exist, err
+}
+
+func (client *loggingClient) Remove(ctx context.Context, keys ...string) error {
+	start := time.Now()
+	err := client.inner.Remove(ctx, keys...)
+	attrs := []slog.Attr{slog.Int("count", len(keys))}
+	if len(keys) == 1 {
+		attrs = append(attrs, slog.String("key", keys[0]))
+	}
+	client.log(ctx, "Remove", start, err, attrs...)
+	return err
+}
+
+func (client *loggingClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	start := time.Now()
+	items, err := client.inner.List(ctx, prefix)
+	client.log(ctx, "List", start, err, slog.String("prefix", prefix), slog.Int("count", len(items)))
+	return items, err
+}
+
+func (client *loggingClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	start := time.Now()
+	info, err := client.inner.Info(ctx, key)
+	attrs := []slog.Attr{slog.String("key", key)}
+	if info != nil {
+		attrs = append(attrs, slog.Int64("size", info.Size))
+	}
+	client.log(ctx, "Info", start, err, attrs...)
+	return info, err
+}
+
+func (client *loggingClient) Copy(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	err := client.inner.Copy(ctx, src, dst)
+	client.log(ctx, "Copy", start, err, slog.String("src", src), slog.String("dst", dst))
+	return err
+}
+
+func (client *loggingClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,64 @@
+package objclient
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggingClient(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	mem := newMemClient()
+	mem.put("objclient/test", "demo")
+	logged := NewLoggingClient(mem, logger, nil)
+
+	if _, err := logged.Info(ctx, "objclient/test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logged.Info(ctx, "objclient/none"); err == nil {
+		t.Fatal("expect info error")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("invalid logs: %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "level=DEBUG") || !strings.Contains(lines[0], "size=4") {
+		t.Fatalf("invalid log: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=ERROR") || !strings.Contains(lines[1], "key=objclient/none") {
+		t.Fatalf("invalid log: %q", lines[1])
+	}
+
+	buf.Reset()
+	mem.fail = func(op, key string) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	// The levels which aren't set are the defaults.
+	logged = NewLoggingClient(mem, logger, &LogOptions{SlowThreshold: time.Millisecond})
+	if _, err := logged.Exist(ctx, "objclient/test"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "level=WARN") {
+		t.Fatalf("invalid log: %q", buf.String())
+	}
+
+	buf.Reset()
+	mem.fail = nil
+	logged = NewLoggingClient(mem, logger, &LogOptions{Level: slog.LevelInfo})
+	if _, err := logged.Exist(ctx, "objclient/test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logged.Info(ctx, "objclient/none"); err == nil {
+		t.Fatalf("info of missing object succeeded")
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "level=INFO") || !strings.Contains(lines[1], "level=ERROR") {
+		t.Fatalf("invalid logs: %q", buf.String())
+	}
+}
@@ -0,0 +1,212 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type memObject struct {
+	data     []byte
+	metadata map[string]string
+	modified time.Time
+}
+
+// memClient is an in-memory client for tests. The fail hook, if set, is
+// called before each operation and the returned error is reported.
+type memClient struct {
+	mutex   sync.Mutex
+	objects map[string]memObject
+	fail    func(op, key string) error
+	closed  bool
+}
+
+func newMemClient() *memClient {
+	return &memClient{objects: make(map[string]memObject)}
+}
+
+func (client *memClient) check(op, key string) error {
+	if client.fail == nil {
+		return nil
+	}
+	return client.fail(op, key)
+}
+
+func (client *memClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *memClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if err := client.check("Read", key); err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	obj, ok := client.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if o != nil && o.IfMatch != "" {
+		if sum := md5.Sum(obj.data); hex.EncodeToString(sum[:]) != o.IfMatch {
+			return nil, minio.ErrorResponse{Code: "PreconditionFailed", StatusCode: http.StatusPreconditionFailed}
+		}
+	}
+	offset, length, err := readRange(o)
+	if err != nil {
+		return nil, err
+	}
+	data := obj.data[min(offset, int64(len(obj.data))):]
+	if length > 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (client *memClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *memClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if err := client.check("Write", key); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := memObject{data: data, metadata: make(map[string]string), modified: time.Now()}
+	if o != nil {
+		for k, v := range o.Metadata {
+			obj.metadata[strings.ToLower(k)] = v
+		}
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if old, ok := client.objects[key]; o != nil && (o.IfNoneMatch != "" || o.IfMatch != "") {
+		sum := md5.Sum(old.data)
+		if ok && o.IfNoneMatch != "" || o.IfMatch != "" && (!ok || hex.EncodeToString(sum[:]) != o.IfMatch) {
+			return nil, minio.ErrorResponse{Code: "PreconditionFailed", StatusCode: http.StatusPreconditionFailed}
+		}
+	}
+	client.objects[key] = obj
+
+	sum := md5.Sum(data)
+	return &WriteResult{ETag: hex.EncodeToString(sum[:]), LastModified: obj.modified}, nil
+}
+
+func (client *memClient) Exist(ctx context.Context, key string) (bool, error) {
+	if err := client.check("Exist", key); err != nil {
+		return false, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	_, ok := client.objects[key]
+	return ok, nil
+}
+
+func (client *memClient) Remove(ctx context.Context, keys ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	var results []RemoveResult
+	for _, key := range keys {
+		if err := client.check("Remove", key); err != nil {
+			results = append(results, RemoveResult{Key: key, Err: err})
+			continue
+		}
+		delete(client.objects, key)
+	}
+	if len(results) > 0 {
+		return &RemoveError{Results: results}
+	}
+	return nil
+}
+
+func (client *memClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	if err := client.check("List", prefix); err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	var items []ObjectItem
+	for key, obj := range client.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		items = append(items, ObjectItem{
+			Key:          key,
+			Size:         int64(len(obj.data)),
+			LastModified: obj.modified,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, nil
+}
+
+func (client *memClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := client.check("Info", key); err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	obj, ok := client.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sum := md5.Sum(obj.data)
+	info := &ObjectInfo{
+		Size:         int64(len(obj.data)),
+		LastModified: obj.modified,
+		Metadata:     make(map[string]string),
+		ETag:         hex.EncodeToString(sum[:]),
+	}
+	for k, v := range obj.metadata {
+		info.Metadata[k] = v
+	}
+	return info, nil
+}
+
+func (client *memClient) Copy(ctx context.Context, src, dst string) error {
+	if err := client.check("Copy", src); err != nil {
+		return err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	obj, ok := client.objects[src]
+	if !ok {
+		return ErrNotFound
+	}
+	obj.modified = time.Now()
+	client.objects[dst] = obj
+	return nil
+}
+
+func (client *memClient) Close() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.closed = true
+	return nil
+}
+
+func (client *memClient) put(key, data string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.objects[key] = memObject{data: []byte(data), metadata: make(map[string]string), modified: time.Now()}
+}
@@ -0,0 +1,327 @@
+package objclient
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type MemoryCacheOptions struct {
+	// Content of objects not larger than MaxObjectSize is cached, zero
+	// disables caching content.
+	MaxObjectSize int64
+	// MaxBytes limits the total size of cached content.
+	MaxBytes int64
+	// MaxEntries limits the number of cached keys, defaults to 10000.
+	MaxEntries int
+	// TTL of cached entries, defaults to 1 minute.
+	TTL time.Duration
+}
+
+type memCacheEntry struct {
+	key     string
+	expires time.Time
+	// exist is nil if it's unknown.
+	exist *bool
+	info  *ObjectInfo
+	data  []byte
+}
+
+// memCacheFill is the generation of a key being filled from inner, which is
+// bumped by invalidations, so the results read before them aren't cached.
+type memCacheFill struct {
+	n          int
+	generation uint64
+}
+
+type memCacheClient struct {
+	inner Client
+	opts  MemoryCacheOptions
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+	fills   map[string]*memCacheFill
+}
+
+// NewMemoryCacheClient caches small objects and the results of Info and
+// Exist in memory. Entries are invalidated by Write, Remove and Copy through
+// the returned client, changes made by others are seen after the TTL.
+func NewMemoryCacheClient(inner Client, opts MemoryCacheOptions) Client {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+
+	client := &memCacheClient{
+		inner:   inner,
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		fills:   make(map[string]*memCacheFill),
+	}
+	if _, ok := AsPageLister(inner); ok {
+		return &memCachePageClient{client}
+	}
+	return client
+}
+
+// memCachePageClient is the memCacheClient of a PageLister, the listings
+// aren't cached.
+type memCachePageClient struct {
+	*memCacheClient
+}
+
+func (client *memCachePageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func cloneInfo(info *ObjectInfo) *ObjectInfo {
+	clone := *info
+	clone.Metadata = make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		clone.Metadata[k] = v
+	}
+	return &clone
+}
+
+// get returns a copy of the valid entry of key.
+func (client *memCacheClient) get(key string) (memCacheEntry, bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	elem, ok := client.entries[key]
+	if !ok {
+		return memCacheEntry{}, false
+	}
+	entry := elem.Value.(*memCacheEntry)
+	if time.Now().After(entry.expires) {
+		client.remove(elem)
+		return memCacheEntry{}, false
+	}
+	client.lru.MoveToFront(elem)
+
+	return *entry, true
+}
+
+// begin starts filling key, and returns the generation of it for update.
+// end should be called after the fill.
+func (client *memCacheClient) begin(key string) uint64 {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	fill, ok := client.fills[key]
+	if !ok {
+		fill = &memCacheFill{}
+		client.fills[key] = fill
+	}
+	fill.n++
+	return fill.generation
+}
+
+func (client *memCacheClient) end(key string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	fill := client.fills[key]
+	if fill.n--; fill.n == 0 {
+		delete(client.fills, key)
+	}
+}
+
+// update changes the entry of key by fn, a new entry is created if there
+// isn't a valid one. It's skipped if key is invalidated since generation
+// is returned by begin.
+func (client *memCacheClient) update(key string, generation uint64, fn func(entry *memCacheEntry)) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.fills[key].generation != generation {
+		return
+	}
+
+	var entry *memCacheEntry
+	elem, ok := client.entries[key]
+	if ok && time.Now().Before(elem.Value.(*memCacheEntry).expires) {
+		entry = elem.Value.(*memCacheEntry)
+		client.size -= int64(len(entry.data))
+		client.lru.MoveToFront(elem)
+	} else {
+		if ok {
+			client.remove(elem)
+		}
+		entry = &memCacheEntry{key: key, expires: time.Now().Add(client.opts.TTL)}
+		client.entries[key] = client.lru.PushFront(entry)
+	}
+
+	fn(entry)
+	client.size += int64(len(entry.data))
+
+	for client.lru.Len() > client.opts.MaxEntries {
+		client.remove(client.lru.Back())
+	}
+	// Metadata of the entries is kept when content is over the limit.
+	for e := client.lru.Back(); e != nil && client.size > client.opts.MaxBytes; e = e.Prev() {
+		entry := e.Value.(*memCacheEntry)
+		client.size -= int64(len(entry.data))
+		entry.data = nil
+	}
+}
+
+func (client *memCacheClient) invalidate(key string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if fill, ok := client.fills[key]; ok {
+		fill.generation++
+	}
+	if elem, ok := client.entries[key]; ok {
+		client.remove(elem)
+	}
+}
+
+// remove should be called with the mutex locked.
+func (client *memCacheClient) remove(elem *list.Element) {
+	entry := elem.Value.(*memCacheEntry)
+	client.lru.Remove(elem)
+	delete(client.entries, entry.key)
+	client.size -= int64(len(entry.data))
+}
+
+func (client *memCacheClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+// cachedRange returns the range of o, and whether the read can be served
+// by the cached content of the object.
+func cachedRange(o *ReadOptions) (int64, int64, bool) {
+	if o == nil {
+		return 0, 0, true
+	}
+	if o.Process != "" || o.IfMatch != "" || len(o.Header) > 0 || len(o.ResponseHeader) > 0 || o.Offset < 0 || o.Length < 0 {
+		return 0, 0, false
+	}
+	return o.Offset, o.Length, true
+}
+
+func (client *memCacheClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	offset, length, ok := cachedRange(o)
+	if !ok {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}
+
+	// The ranges out of the object are left to inner to fail.
+	if entry, ok := client.get(key); ok && entry.data != nil && (offset == 0 || offset < int64(len(entry.data))) {
+		data := entry.data[offset:]
+		if length > 0 && length < int64(len(data)) {
+			data = data[:length]
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	// The content of ranges isn't cached, which isn't the whole object.
+	if client.opts.MaxObjectSize <= 0 || offset > 0 || length > 0 {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}
+	generation := client.begin(key)
+	defer client.end(key)
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read one more byte to know whether the object is small enough.
+	data, err := io.ReadAll(io.LimitReader(r, client.opts.MaxObjectSize+1))
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	if int64(len(data)) > client.opts.MaxObjectSize {
+		reader := io.MultiReader(bytes.NewReader(data), r)
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, r}, nil
+	}
+	r.Close()
+
+	client.update(key, generation, func(entry *memCacheEntry) {
+		entry.data = data
+	})
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (client *memCacheClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *memCacheClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	defer client.invalidate(key)
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *memCacheClient) Exist(ctx context.Context, key string) (bool, error) {
+	if entry, ok := client.get(key); ok && entry.exist != nil {
+		return *entry.exist, nil
+	}
+
+	generation := client.begin(key)
+	defer client.end(key)
+	exist, err := client.inner.Exist(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	client.update(key, generation, func(entry *memCacheEntry) {
+		entry.exist = &exist
+	})
+	return exist, nil
+}
+
+func (client *memCacheClient) Remove(ctx context.Context, keys ...string) error {
+	defer func() {
+		for _, key := range keys {
+			client.invalidate(key)
+		}
+	}()
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *memCacheClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *memCacheClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	if entry, ok := client.get(key); ok && entry.info != nil {
+		return cloneInfo(entry.info), nil
+	}
+
+	generation := client.begin(key)
+	defer client.end(key)
+	info, err := client.inner.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	exist := true
+	cached := cloneInfo(info)
+	client.update(key, generation, func(entry *memCacheEntry) {
+		entry.info = cached
+		entry.exist = &exist
+	})
+	return info, nil
+}
+
+func (client *memCacheClient) Copy(ctx context.Context, src, dst string) error {
+	defer client.invalidate(dst)
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *memCacheClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,119 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMemoryCacheClient(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/small", "demo")
+	mem.put("objclient/large", "large object")
+
+	cache := NewMemoryCacheClient(mem, MemoryCacheOptions{MaxObjectSize: 4, MaxBytes: 4})
+
+	if data := readString(t, cache, "objclient/small"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	if data := readString(t, cache, "objclient/large"); data != "large object" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	if _, err := cache.Info(ctx, "objclient/large"); err != nil {
+		t.Fatal(err)
+	}
+	if exist, err := cache.Exist(ctx, "objclient/none"); err != nil || exist {
+		t.Fatalf("expect object not exist: %v", err)
+	}
+
+	mem.fail = func(op, key string) error {
+		if op != "Write" {
+			return errors.New("backend called")
+		}
+		return nil
+	}
+	if data := readString(t, cache, "objclient/small"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	if info, err := cache.Info(ctx, "objclient/large"); err != nil || info.Size != 12 {
+		t.Fatalf("invalid info: %v, %v", info, err)
+	}
+	if exist, err := cache.Exist(ctx, "objclient/large"); err != nil || !exist {
+		t.Fatalf("expect object exists: %v", err)
+	}
+	if exist, err := cache.Exist(ctx, "objclient/none"); err != nil || exist {
+		t.Fatalf("expect object not exist: %v", err)
+	}
+
+	// Writes invalidate the cache.
+	if err := cache.Write(ctx, "objclient/none", strings.NewReader("new"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Exist(ctx, "objclient/none"); err == nil {
+		t.Fatal("expect exist from backend")
+	}
+}
+
+func TestMemoryCacheClientRange(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "0123456789")
+	cache := NewMemoryCacheClient(mem, MemoryCacheOptions{MaxObjectSize: 100, MaxBytes: 100})
+
+	// Ranges read before the object is cached aren't cached.
+	if data := readRangeString(t, cache, "objclient/a", 2, 3); data != "234" {
+		t.Fatalf("invalid range: %q", data)
+	}
+	if data := readString(t, cache, "objclient/a"); data != "0123456789" {
+		t.Fatalf("invalid data after range: %q", data)
+	}
+
+	// Ranges are served from the cached object.
+	mem.fail = func(op, key string) error {
+		if op == "Read" {
+			return errors.New("read from backend")
+		}
+		return nil
+	}
+	if data := readRangeString(t, cache, "objclient/a", 7, 0); data != "789" {
+		t.Fatalf("invalid range: %q", data)
+	}
+	if data := readRangeString(t, cache, "objclient/a", 1, 2); data != "12" {
+		t.Fatalf("invalid range: %q", data)
+	}
+}
+
+// infoHookClient calls after once, when the first Info of inner returns.
+type infoHookClient struct {
+	Client
+	after func()
+}
+
+func (client *infoHookClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := client.Client.Info(ctx, key)
+	if after := client.after; after != nil {
+		client.after = nil
+		after()
+	}
+	return info, err
+}
+
+func TestMemoryCacheClientInvalidateFill(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/key", "old")
+	inner := &infoHookClient{Client: mem}
+	cache := NewMemoryCacheClient(inner, MemoryCacheOptions{})
+
+	// The info read before a concurrent write isn't cached.
+	inner.after = func() {
+		if err := cache.Write(ctx, "objclient/key", strings.NewReader("newer"), nil); err != nil {
+			t.Errorf("failed to write: %v", err)
+		}
+	}
+	if info, err := cache.Info(ctx, "objclient/key"); err != nil || info.Size != 3 {
+		t.Fatalf("invalid info %+v: %v", info, err)
+	}
+	if info, err := cache.Info(ctx, "objclient/key"); err != nil || info.Size != 5 {
+		t.Fatalf("invalid info after write %+v: %v", info, err)
+	}
+}
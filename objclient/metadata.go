@@ -0,0 +1,103 @@
+package objclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMetadataSize is the limit of the keys and values of user metadata in
+// bytes, which is the one of S3. OSS allows 8KiB.
+const maxMetadataSize = 2 << 10
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+var metadataDecoder = new(mime.WordDecoder)
+
+// NormalizeMetadata returns the user metadata written by S3 and OSS clients
+// for metadata, so both backends store and return the same. Keys are lower
+// cased and can contain only ASCII letters, digits and "-". Values which
+// aren't printable ASCII are encoded as RFC 2047 words, and decoded by Info.
+// The keys and encoded values are at most 2KiB. It returns an error
+// wrapping ErrInvalidMetadata for the metadata which can't be stored.
+func NormalizeMetadata(metadata map[string]string) (map[string]string, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(metadata))
+	size := 0
+	for key, val := range metadata {
+		k := strings.ToLower(key)
+		if !validMetadataKey(k) {
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidMetadata, key)
+		}
+		if _, ok := normalized[k]; ok {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrInvalidMetadata, key)
+		}
+		v, err := encodeMetadataValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("%w: value of %q: %v", ErrInvalidMetadata, key, err)
+		}
+		normalized[k] = v
+		size += len(k) + len(v)
+	}
+	if size > maxMetadataSize {
+		return nil, fmt.Errorf("%w: %v bytes is over %v bytes", ErrInvalidMetadata, size, maxMetadataSize)
+	}
+	return normalized, nil
+}
+
+func validMetadataKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeMetadataValue(val string) (string, error) {
+	if !utf8.ValidString(val) {
+		return "", errors.New("not valid UTF-8")
+	}
+	printable := true
+	for i := 0; i < len(val); i++ {
+		if val[i] < ' ' || val[i] > '~' {
+			printable = false
+			break
+		}
+	}
+	// Values like encoded words are encoded too, so they are read back as
+	// they are.
+	if printable && !isEncodedWord(val) {
+		return val, nil
+	}
+	// It's a single word regardless of the length of 75 characters in RFC
+	// 2047, since metadata isn't folded like mail headers.
+	return "=?utf-8?b?" + base64.StdEncoding.EncodeToString([]byte(val)) + "?=", nil
+}
+
+func isEncodedWord(val string) bool {
+	return strings.HasPrefix(val, "=?") && strings.HasSuffix(val, "?=")
+}
+
+// decodeMetadata returns the metadata read from a backend, with lower cased
+// keys and decoded values.
+func decodeMetadata(metadata map[string]string) map[string]string {
+	decoded := make(map[string]string, len(metadata))
+	for key, val := range metadata {
+		if isEncodedWord(val) {
+			if v, err := metadataDecoder.DecodeHeader(val); err == nil {
+				val = v
+			}
+		}
+		decoded[strings.ToLower(key)] = val
+	}
+	return decoded
+}
@@ -0,0 +1,110 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeMetadata(t *testing.T) {
+	metadata := map[string]string{
+		"Name":    "report.txt",
+		"title":   "报告 2024",
+		"comment": "=?utf-8?q?not-encoded?=",
+		"tab":     "a\tb",
+	}
+	normalized, err := NormalizeMetadata(metadata)
+	if err != nil {
+		t.Fatalf("failed to normalize: %v", err)
+	}
+	if normalized["name"] != "report.txt" || normalized["title"] == metadata["title"] {
+		t.Fatalf("invalid normalized metadata: %v", normalized)
+	}
+	for _, val := range normalized {
+		for i := 0; i < len(val); i++ {
+			if val[i] < ' ' || val[i] > '~' {
+				t.Fatalf("value isn't printable ASCII: %q", val)
+			}
+		}
+	}
+	decoded := decodeMetadata(normalized)
+	for key, val := range metadata {
+		if decoded[strings.ToLower(key)] != val {
+			t.Fatalf("invalid decoded value of %v: %q", key, decoded[strings.ToLower(key)])
+		}
+	}
+
+	invalid := []map[string]string{
+		{"": "empty"},
+		{"under_score": "a"},
+		{"space key": "a"},
+		{"Name": "a", "name": "b"},
+		{"name": "\xff"},
+		{"name": strings.Repeat("a", 2<<10)},
+	}
+	for _, metadata := range invalid {
+		if _, err := NormalizeMetadata(metadata); !errors.Is(err, ErrInvalidMetadata) {
+			t.Fatalf("invalid metadata %v is accepted: %v", metadata, err)
+		}
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	// The server returns the metadata headers of the last upload.
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			header = r.Header.Clone()
+			w.Header().Set("ETag", `"object"`)
+		case http.MethodHead:
+			for key, vals := range header {
+				if strings.HasPrefix(key, "X-Amz-Meta-") || strings.HasPrefix(key, "X-Oss-Meta-") {
+					w.Header()[key] = vals
+				}
+			}
+			w.Header().Set("Content-Length", "0")
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Header().Set("ETag", `"object"`)
+		}
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	metadata := map[string]string{"Name": "报告.txt", "ctime": "1700000000"}
+	for _, backend := range []string{"s3", "oss"} {
+		var (
+			client Client
+			err    error
+		)
+		opts := []Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key")}
+		if backend == "s3" {
+			client, err = NewS3("bucket", append(opts, WithPathStyle(true))...)
+		} else {
+			client, err = NewOSS("bucket", opts...)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %v client: %v", backend, err)
+		}
+
+		err = client.Write(context.Background(), "key", strings.NewReader(""), &WriteOptions{Metadata: metadata})
+		if err != nil {
+			t.Fatalf("failed to write by %v: %v", backend, err)
+		}
+		info, err := client.Info(context.Background(), "key")
+		if err != nil {
+			t.Fatalf("failed to get info by %v: %v", backend, err)
+		}
+		if len(info.Metadata) != 2 || info.Metadata["name"] != "报告.txt" || info.Metadata["ctime"] != "1700000000" {
+			t.Fatalf("invalid metadata of %v: %v", backend, info.Metadata)
+		}
+
+		err = client.Write(context.Background(), "key", strings.NewReader(""), &WriteOptions{Metadata: map[string]string{"a_b": ""}})
+		if !errors.Is(err, ErrInvalidMetadata) {
+			t.Fatalf("invalid metadata is written by %v: %v", backend, err)
+		}
+	}
+}
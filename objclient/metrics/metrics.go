@@ -0,0 +1,323 @@
+// Package metrics instruments object clients with prometheus metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The "metrics" wrapper of config files exports to the default registerer,
+// it's available once the package is imported.
+func init() {
+	objclient.RegisterWrapper("metrics", func(inner objclient.Client, params map[string]any) (objclient.Client, error) {
+		return NewInstrumentedClient(inner, prometheus.DefaultRegisterer)
+	})
+}
+
+type collectors struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	bytes    *prometheus.CounterVec
+	inflight *prometheus.GaugeVec
+}
+
+func newCollectors(registerer prometheus.Registerer) (*collectors, error) {
+	c := &collectors{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "objclient_requests_total",
+			Help: "Number of object storage operations.",
+		}, []string{"backend", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "objclient_errors_total",
+			Help: "Number of failed object storage operations.",
+		}, []string{"backend", "operation", "class"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "objclient_request_duration_seconds",
+			Help:    "Duration of object storage operations, to the first byte for reads.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"backend", "operation"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "objclient_bytes_total",
+			Help: "Bytes transferred from (in) and to (out) object storage.",
+		}, []string{"backend", "direction"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "objclient_in_flight_requests",
+			Help: "Number of object storage operations in progress.",
+		}, []string{"backend", "operation"}),
+	}
+
+	var err error
+	c.requests, err = register(registerer, c.requests)
+	if err != nil {
+		return nil, err
+	}
+	c.errors, err = register(registerer, c.errors)
+	if err != nil {
+		return nil, err
+	}
+	c.duration, err = register(registerer, c.duration)
+	if err != nil {
+		return nil, err
+	}
+	c.bytes, err = register(registerer, c.bytes)
+	if err != nil {
+		return nil, err
+	}
+	c.inflight, err = register(registerer, c.inflight)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// register returns the registered collector if the same one was registered
+// by another client.
+func register[T prometheus.Collector](registerer prometheus.Registerer, c T) (T, error) {
+	err := registerer.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// ErrorClass returns the label value of the class of err.
+func ErrorClass(err error) string {
+	var nerr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, objclient.ErrAccessDenied):
+		return "access_denied"
+	case errors.Is(err, objclient.ErrBucketNotFound):
+		return "bucket_not_found"
+	case errors.Is(err, objclient.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, objclient.ErrPreconditionFailed):
+		return "precondition_failed"
+	case errors.Is(err, objclient.ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, objclient.ErrThrottled):
+		return "throttled"
+	case errors.As(err, &nerr):
+		return "network"
+	}
+	return "other"
+}
+
+type instrumentedClient struct {
+	inner   objclient.Client
+	backend string
+	c       *collectors
+}
+
+// NewInstrumentedClient exports the metrics of the operations of inner to
+// registerer. Clients of the same registerer share the metrics, which are
+// labeled by backend.
+func NewInstrumentedClient(inner objclient.Client, registerer prometheus.Registerer) (objclient.Client, error) {
+	c, err := newCollectors(registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &instrumentedClient{
+		inner:   inner,
+		backend: backendName(inner),
+		c:       c,
+	}
+	if _, ok := objclient.AsPageLister(inner); ok {
+		return &instrumentedPageClient{client}, nil
+	}
+	return client, nil
+}
+
+// instrumentedPageClient is the instrumentedClient of a PageLister, each
+// listing is observed once as the ListPages operation.
+type instrumentedPageClient struct {
+	*instrumentedClient
+}
+
+func (client *instrumentedPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []objclient.ObjectItem) bool) error {
+	done := client.observe("ListPages")
+	err := client.inner.(objclient.PageLister).ListPages(ctx, prefix, startAfter, fn)
+	done(err)
+	return err
+}
+
+func backendName(client objclient.Client) string {
+	switch client.(type) {
+	case *objclient.S3Client:
+		return "s3"
+	case *objclient.OSSClient:
+		return "oss"
+	}
+	return "other"
+}
+
+// observe starts observing an operation, the returned function should be
+// called with the result of it.
+func (client *instrumentedClient) observe(op string) func(err error) {
+	start := time.Now()
+	client.c.requests.WithLabelValues(client.backend, op).Inc()
+	inflight := client.c.inflight.WithLabelValues(client.backend, op)
+	inflight.Inc()
+
+	return func(err error) {
+		inflight.Dec()
+		client.c.duration.WithLabelValues(client.backend, op).Observe(time.Since(start).Seconds())
+		if err != nil {
+			client.c.errors.WithLabelValues(client.backend, op, ErrorClass(err)).Inc()
+		}
+	}
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter prometheus.Counter
+}
+
+func (reader *countingReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.counter.Add(float64(n))
+	return n, err
+}
+
+type countingReadCloser struct {
+	countingReader
+	c io.Closer
+}
+
+func (reader *countingReadCloser) Close() error {
+	return reader.c.Close()
+}
+
+func (client *instrumentedClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *instrumentedClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	done := client.observe("Read")
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := client.c.bytes.WithLabelValues(client.backend, "in")
+	return &countingReadCloser{countingReader{r, counter}, r}, nil
+}
+
+func (client *instrumentedClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *instrumentedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	done := client.observe("Write")
+	counter := client.c.bytes.WithLabelValues(client.backend, "out")
+	o = objclient.SizedWriteOptions(r, o)
+	result, err := client.inner.WriteWithResult(ctx, key, &countingReader{r, counter}, o)
+	done(err)
+	return result, err
+}
+
+func (client *instrumentedClient) Exist(ctx context.Context, key string) (bool, error) {
+	done := client.observe("Exist")
+	exist, err := client.inner.Exist(ctx, key)
+	done(err)
+	return exist, err
+}
+
+func (client *instrumentedClient) Remove(ctx context.Context, keys ...string) error {
+	done := client.observe("Remove")
+	err := client.inner.Remove(ctx, keys...)
+	done(err)
+	return err
+}
+
+func (client *instrumentedClient) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	done := client.observe("List")
+	items, err := client.inner.List(ctx, prefix)
+	done(err)
+	return items, err
+}
+
+func (client *instrumentedClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	done := client.observe("Info")
+	info, err := client.inner.Info(ctx, key)
+	done(err)
+	return info, err
+}
+
+func (client *instrumentedClient) Copy(ctx context.Context, src, dst string) error {
+	done := client.observe("Copy")
+	err := client.inner.Copy(ctx, src, dst)
+	done(err)
+	return err
+}
+
+func (client *instrumentedClient) Close() error {
+	return client.inner.Close()
+}
+
+// NewTTLObserver returns the OnSweep of objclient.TTLOptions, which exports
+// the results of sweeps to registerer, labeled by prefix.
+func NewTTLObserver(registerer prometheus.Registerer) (func(result *objclient.TTLResult, err error), error) {
+	scanned, err := register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "objclient_ttl_scanned_objects_total",
+		Help: "Number of objects scanned by TTL sweeps.",
+	}, []string{"prefix"}))
+	if err != nil {
+		return nil, err
+	}
+	removed, err := register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "objclient_ttl_removed_objects_total",
+		Help: "Number of expired objects removed, or would be removed by dry runs.",
+	}, []string{"prefix"}))
+	if err != nil {
+		return nil, err
+	}
+	removedBytes, err := register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "objclient_ttl_removed_bytes_total",
+		Help: "Bytes of expired objects removed, or would be removed by dry runs.",
+	}, []string{"prefix"}))
+	if err != nil {
+		return nil, err
+	}
+	failures, err := register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "objclient_ttl_sweep_failures_total",
+		Help: "Number of failed TTL sweeps.",
+	}, []string{"prefix"}))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := register(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "objclient_ttl_last_sweep_duration_seconds",
+		Help: "Duration of the last TTL sweep.",
+	}, []string{"prefix"}))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(result *objclient.TTLResult, err error) {
+		scanned.WithLabelValues(result.Prefix).Add(float64(result.Scanned))
+		removed.WithLabelValues(result.Prefix).Add(float64(result.Removed))
+		removedBytes.WithLabelValues(result.Prefix).Add(float64(result.RemovedBytes))
+		if err != nil {
+			failures.WithLabelValues(result.Prefix).Inc()
+		}
+		duration.WithLabelValues(result.Prefix).Set(result.Duration.Seconds())
+	}, nil
+}
@@ -0,0 +1,299 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/s3test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// memClient is an in-memory client for tests, which fails the operations
+// of the keys in failures.
+type memClient struct {
+	mutex    sync.Mutex
+	objects  map[string][]byte
+	failures map[string]error
+}
+
+func newMemClient() *memClient {
+	return &memClient{objects: make(map[string][]byte), failures: make(map[string]error)}
+}
+
+func (client *memClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *memClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.failures[key]; err != nil {
+		return nil, err
+	}
+	data, ok := client.objects[key]
+	if !ok {
+		return nil, objclient.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (client *memClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *memClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.failures[key]; err != nil {
+		return nil, err
+	}
+	client.objects[key] = data
+	return &objclient.WriteResult{}, nil
+}
+
+func (client *memClient) Exist(ctx context.Context, key string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, ok := client.objects[key]
+	return ok, nil
+}
+
+func (client *memClient) Remove(ctx context.Context, keys ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, key := range keys {
+		delete(client.objects, key)
+	}
+	return nil
+}
+
+func (client *memClient) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var items []objclient.ObjectItem
+	for key, data := range client.objects {
+		if strings.HasPrefix(key, prefix) {
+			items = append(items, objclient.ObjectItem{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, nil
+}
+
+func (client *memClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[key]
+	if !ok {
+		return nil, objclient.ErrNotFound
+	}
+	return &objclient.ObjectInfo{Size: int64(len(data))}, nil
+}
+
+func (client *memClient) Copy(ctx context.Context, src, dst string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[src]
+	if !ok {
+		return objclient.ErrNotFound
+	}
+	client.objects[dst] = data
+	return nil
+}
+
+func (client *memClient) Close() error {
+	return nil
+}
+
+// pageMemClient lists the objects in a page.
+type pageMemClient struct {
+	*memClient
+}
+
+func (client *pageMemClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []objclient.ObjectItem) bool) error {
+	items, err := client.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	var page []objclient.ObjectItem
+	for _, item := range items {
+		if item.Key > startAfter {
+			page = append(page, item)
+		}
+	}
+	fn(page)
+	return nil
+}
+
+func TestInstrumentedClient(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	mem := newMemClient()
+	mem.failures["denied"] = objclient.ErrAccessDenied
+	client, err := NewInstrumentedClient(mem, registry)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if err := client.Write(ctx, "a", strings.NewReader("hello"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	r, err := client.Read(ctx, "a")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	io.ReadAll(r)
+	r.Close()
+	if _, err := client.Read(ctx, "missing"); err == nil {
+		t.Fatalf("missing object is read")
+	}
+	if err := client.Write(ctx, "denied", strings.NewReader("x"), nil); err == nil {
+		t.Fatalf("denied write succeeded")
+	}
+
+	c, err := newCollectors(registry)
+	if err != nil {
+		t.Fatalf("failed to get collectors: %v", err)
+	}
+	for _, tc := range []struct {
+		collector prometheus.Collector
+		expect    float64
+	}{
+		{c.requests.WithLabelValues("other", "Write"), 2},
+		{c.requests.WithLabelValues("other", "Read"), 2},
+		{c.errors.WithLabelValues("other", "Read", "not_found"), 1},
+		{c.errors.WithLabelValues("other", "Write", "access_denied"), 1},
+		{c.bytes.WithLabelValues("other", "in"), 5},
+		{c.bytes.WithLabelValues("other", "out"), 6},
+		{c.inflight.WithLabelValues("other", "Read"), 0},
+	} {
+		if got := testutil.ToFloat64(tc.collector); got != tc.expect {
+			t.Fatalf("invalid value of %v: %v", tc.collector.(prometheus.Metric).Desc(), got)
+		}
+	}
+	if n := testutil.CollectAndCount(c.duration, "objclient_request_duration_seconds"); n != 2 {
+		t.Fatalf("invalid duration series %v", n)
+	}
+
+	// The clients of the same registerer share the metrics.
+	if _, err := NewInstrumentedClient(newMemClient(), registry); err != nil {
+		t.Fatalf("failed to create another client: %v", err)
+	}
+}
+
+func TestInstrumentedClientWriteSize(t *testing.T) {
+	server := s3test.NewTLSServer("bucket")
+	defer server.Close()
+	s3, err := objclient.NewS3Client(objclient.S3Config{
+		Endpoint: server.Endpoint(), Region: s3test.Region, HTTPS: "true", Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true", RootCAs: server.RootCAs(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client, err := NewInstrumentedClient(s3, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	// The size of the reader is detected through the counting one.
+	if err := client.Write(context.Background(), "a", bytes.NewReader([]byte("hello")), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+}
+
+func TestInstrumentedPageClient(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	mem := &pageMemClient{newMemClient()}
+	mem.objects["a"] = []byte("a")
+	client, err := NewInstrumentedClient(mem, registry)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	lister, ok := client.(objclient.PageLister)
+	if !ok {
+		t.Fatalf("invalid client %T", client)
+	}
+
+	// Each listing is observed once.
+	pages := 0
+	if err := lister.ListPages(ctx, "", "", func(items []objclient.ObjectItem) bool {
+		pages++
+		return true
+	}); err != nil || pages != 1 {
+		t.Fatalf("invalid pages %v: %v", pages, err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	var count uint64
+	for _, family := range families {
+		if family.GetName() != "objclient_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "operation" && label.GetValue() == "ListPages" {
+					count += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	if count != 1 {
+		t.Fatalf("invalid observations of ListPages %v", count)
+	}
+}
+
+func TestTTLObserver(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	observe, err := NewTTLObserver(registry)
+	if err != nil {
+		t.Fatalf("failed to create observer: %v", err)
+	}
+	observe(&objclient.TTLResult{Prefix: "tmp/", Scanned: 10, Removed: 3, RemovedBytes: 300, Duration: 2 * time.Second}, nil)
+	observe(&objclient.TTLResult{Prefix: "tmp/", Scanned: 5}, context.DeadlineExceeded)
+
+	expect := `
+# HELP objclient_ttl_removed_objects_total Number of expired objects removed, or would be removed by dry runs.
+# TYPE objclient_ttl_removed_objects_total counter
+objclient_ttl_removed_objects_total{prefix="tmp/"} 3
+# HELP objclient_ttl_scanned_objects_total Number of objects scanned by TTL sweeps.
+# TYPE objclient_ttl_scanned_objects_total counter
+objclient_ttl_scanned_objects_total{prefix="tmp/"} 15
+# HELP objclient_ttl_sweep_failures_total Number of failed TTL sweeps.
+# TYPE objclient_ttl_sweep_failures_total counter
+objclient_ttl_sweep_failures_total{prefix="tmp/"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expect),
+		"objclient_ttl_removed_objects_total", "objclient_ttl_scanned_objects_total", "objclient_ttl_sweep_failures_total"); err != nil {
+		t.Fatalf("invalid metrics: %v", err)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	for err, expect := range map[error]string{
+		context.Canceled:                "canceled",
+		context.DeadlineExceeded:        "timeout",
+		objclient.ErrNotFound:           "not_found",
+		objclient.ErrPreconditionFailed: "precondition_failed",
+		io.ErrUnexpectedEOF:             "other",
+	} {
+		if got := ErrorClass(err); got != expect {
+			t.Fatalf("invalid class of %v: %v", err, got)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package objclient
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Middleware wraps a client, e.g.
+//
+//	func(c Client) Client { return NewRateLimitedClient(c, 100, 10) }
+type Middleware func(Client) Client
+
+// Chain wraps inner by the middlewares, the first one is the outermost, so
+// operations go through the middlewares in the order they are declared.
+func Chain(inner Client, middlewares ...Middleware) Client {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		inner = middlewares[i](inner)
+	}
+	return inner
+}
+
+// Operation describes an operation passed to Hooks.
+type Operation struct {
+	// Name is the method name, ReadWithOptions and WriteWithResult are
+	// named Read and Write.
+	Name  string
+	Class OpClass
+	// Key is the key of operation, the prefix of List, the src of Copy, or
+	// the first key of Remove.
+	Key string
+	// Keys are all the keys of Remove, or the src and dst of Copy.
+	Keys []string
+	// Size is the Size option of Write.
+	Size int64
+
+	Start time.Time
+	// Duration and Err are set after the operation.
+	Duration time.Duration
+	Err      error
+}
+
+type Hooks struct {
+	// Before is called before each operation. If it returns an error, the
+	// operation fails with it and isn't sent to the inner client.
+	Before func(ctx context.Context, op *Operation) error
+	// After is called after each operation, including the ones rejected by
+	// Before. For Read it's called when the reader is returned.
+	After func(ctx context.Context, op *Operation)
+}
+
+type hookClient struct {
+	inner Client
+	hooks Hooks
+}
+
+// WithHooks returns a middleware calling hooks around the operations.
+func WithHooks(hooks Hooks) Middleware {
+	return func(inner Client) Client {
+		client := &hookClient{inner: inner, hooks: hooks}
+		if _, ok := AsPageLister(inner); ok {
+			return &hookPageClient{client}
+		}
+		return client
+	}
+}
+
+// hookPageClient is the hookClient of a PageLister, the hooks are called
+// once around each listing.
+type hookPageClient struct {
+	*hookClient
+}
+
+func (client *hookPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.run(ctx, &Operation{Name: "ListPages", Class: OpList, Key: prefix}, func() error {
+		return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+	})
+}
+
+func (client *hookClient) run(ctx context.Context, op *Operation, fn func() error) error {
+	op.Start = time.Now()
+	if op.Key == "" && len(op.Keys) > 0 {
+		op.Key = op.Keys[0]
+	}
+
+	var err error
+	if client.hooks.Before != nil {
+		err = client.hooks.Before(ctx, op)
+	}
+	if err == nil {
+		err = fn()
+	}
+
+	op.Duration = time.Since(op.Start)
+	op.Err = err
+	if client.hooks.After != nil {
+		client.hooks.After(ctx, op)
+	}
+	return err
+}
+
+func (client *hookClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *hookClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	err := client.run(ctx, &Operation{Name: "Read", Class: OpRead, Key: key}, func() error {
+		var err error
+		r, err = client.inner.ReadWithOptions(ctx, key, o)
+		return err
+	})
+	return r, err
+}
+
+func (client *hookClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *hookClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	op := &Operation{Name: "Write", Class: OpWrite, Key: key}
+	if o != nil {
+		op.Size = o.Size
+	}
+
+	var result *WriteResult
+	err := client.run(ctx, op, func() error {
+		var err error
+		result, err = client.inner.WriteWithResult(ctx, key, r, o)
+		return err
+	})
+	return result, err
+}
+
+func (client *hookClient) Exist(ctx context.Context, key string) (bool, error) {
+	var exist bool
+	err := client.run(ctx, &Operation{Name: "Exist", Class: OpRead, Key: key}, func() error {
+		var err error
+		exist, err = client.inner.Exist(ctx, key)
+		return err
+	})
+	return exist, err
+}
+
+func (client *hookClient) Remove(ctx context.Context, keys ...string) error {
+	return client.run(ctx, &Operation{Name: "Remove", Class: OpDelete, Keys: keys}, func() error {
+		return client.inner.Remove(ctx, keys...)
+	})
+}
+
+func (client *hookClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	var items []ObjectItem
+	err := client.run(ctx, &Operation{Name: "List", Class: OpList, Key: prefix}, func() error {
+		var err error
+		items, err = client.inner.List(ctx, prefix)
+		return err
+	})
+	return items, err
+}
+
+func (client *hookClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	var info *ObjectInfo
+	err := client.run(ctx, &Operation{Name: "Info", Class: OpRead, Key: key}, func() error {
+		var err error
+		info, err = client.inner.Info(ctx, key)
+		return err
+	})
+	return info, err
+}
+
+func (client *hookClient) Copy(ctx context.Context, src, dst string) error {
+	return client.run(ctx, &Operation{Name: "Copy", Class: OpWrite, Keys: []string{src, dst}}, func() error {
+		return client.inner.Copy(ctx, src, dst)
+	})
+}
+
+func (client *hookClient) Close() error {
+	return client.inner.Close()
+}
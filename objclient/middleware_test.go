@@ -0,0 +1,44 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	hook := func(name string) Middleware {
+		return WithHooks(Hooks{
+			Before: func(ctx context.Context, op *Operation) error {
+				calls = append(calls, name+" before "+op.Name)
+				if op.Key == "denied" {
+					return ErrAccessDenied
+				}
+				return nil
+			},
+			After: func(ctx context.Context, op *Operation) {
+				calls = append(calls, name+" after "+op.Name)
+			},
+		})
+	}
+
+	mem := newMemClient()
+	client := Chain(mem, hook("a"), hook("b"))
+	if err := client.Write(ctx, "objclient/a", strings.NewReader("demo"), nil); err != nil {
+		t.Fatal(err)
+	}
+	expect := "a before Write,b before Write,b after Write,a after Write"
+	if strings.Join(calls, ",") != expect {
+		t.Fatalf("invalid calls: %v", calls)
+	}
+
+	calls = nil
+	if _, err := client.Info(ctx, "denied"); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expect access denied: %v", err)
+	}
+	if strings.Join(calls, ",") != "a before Info,a after Info" {
+		t.Fatalf("invalid calls: %v", calls)
+	}
+}
@@ -0,0 +1,254 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+type MirrorMode int
+
+const (
+	// MirrorSync writes to both clients before returning, and fails if
+	// any of them failed.
+	MirrorSync MirrorMode = iota
+	// MirrorAsync writes to the secondary in background, only failures of
+	// the primary are returned.
+	MirrorAsync
+)
+
+// Divergence reports an operation which succeeded on the primary but
+// failed on the secondary, or the other way around in MirrorSync mode.
+type Divergence struct {
+	Op  string
+	Key string
+	Err error
+}
+
+type MirrorOptions struct {
+	OnDivergence func(d Divergence)
+	// AsyncLimit is the max number of background operations in MirrorAsync
+	// mode, defaults to 16. Operations block when it's reached.
+	AsyncLimit int
+}
+
+type mirrorClient struct {
+	a, b   Client
+	mode   MirrorMode
+	opts   MirrorOptions
+	tokens chan struct{}
+}
+
+// NewMirrorClient replicates writes and removes to both a and b, reads are
+// served by a. The options can be nil.
+func NewMirrorClient(a, b Client, mode MirrorMode, opts *MirrorOptions) Client {
+	var o MirrorOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.AsyncLimit <= 0 {
+		o.AsyncLimit = 16
+	}
+
+	client := &mirrorClient{
+		a:      a,
+		b:      b,
+		mode:   mode,
+		opts:   o,
+		tokens: make(chan struct{}, o.AsyncLimit),
+	}
+	if _, ok := AsPageLister(a); ok {
+		return &mirrorPageClient{client}
+	}
+	return client
+}
+
+// mirrorPageClient is the mirrorClient of a PageLister a.
+type mirrorPageClient struct {
+	*mirrorClient
+}
+
+func (client *mirrorPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.a.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *mirrorClient) diverge(op, key string, err error) {
+	if client.opts.OnDivergence != nil {
+		client.opts.OnDivergence(Divergence{Op: op, Key: key, Err: err})
+	}
+}
+
+// async runs fn on the secondary in background, by the credentials of ctx.
+func (client *mirrorClient) async(ctx context.Context, op, key string, fn func(ctx context.Context) error) {
+	creds := tenantCredentials(ctx)
+	client.tokens <- struct{}{}
+	go func() {
+		defer func() { <-client.tokens }()
+
+		ctx, cancel := context.WithTimeout(credentialsContext(context.Background(), creds), 10*time.Minute)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			client.diverge(op, key, err)
+		}
+	}()
+}
+
+// sync runs the operation on both clients concurrently.
+func (client *mirrorClient) sync(op, key string, fa, fb func() error) error {
+	errb := make(chan error, 1)
+	go func() { errb <- fb() }()
+	erra := fa()
+	err := <-errb
+
+	switch {
+	case erra != nil && err != nil:
+		return erra
+	case erra != nil:
+		client.diverge(op, key, erra)
+		return erra
+	case err != nil:
+		client.diverge(op, key, err)
+		return err
+	}
+	return nil
+}
+
+func (client *mirrorClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.a.Read(ctx, key)
+}
+
+func (client *mirrorClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.a.ReadWithOptions(ctx, key, o)
+}
+
+func (client *mirrorClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// discardOnError stops writing to w after it failed, so the reader teed to
+// it can still be consumed.
+type discardOnError struct {
+	w   io.Writer
+	err error
+}
+
+func (w *discardOnError) Write(data []byte) (int, error) {
+	if w.err == nil {
+		_, w.err = w.w.Write(data)
+	}
+	return len(data), nil
+}
+
+func (client *mirrorClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if client.mode == MirrorAsync {
+		result, err := client.a.WriteWithResult(ctx, key, r, o)
+		if err != nil {
+			return nil, err
+		}
+		client.async(ctx, "Write", key, func(ctx context.Context) error {
+			return copyObject(ctx, client.a, key, client.b, key)
+		})
+		return result, nil
+	}
+
+	// The content is streamed to b while it's written to a.
+	o = SizedWriteOptions(r, o)
+	pr, pw := io.Pipe()
+	tee := io.TeeReader(r, &discardOnError{w: pw})
+
+	var result *WriteResult
+	err := client.sync("Write", key, func() error {
+		var err error
+		result, err = client.a.WriteWithResult(ctx, key, tee, o)
+		pw.CloseWithError(err)
+		return err
+	}, func() error {
+		err := client.b.Write(ctx, key, pr, secondaryWriteOptions(o))
+		if err == nil {
+			// Make sure the content isn't truncated.
+			_, err = copyBuffer(io.Discard, pr)
+		}
+		pr.CloseWithError(errors.New("mirror write finished"))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// secondaryWriteOptions returns the options of the writes mirrored to b.
+// The conditions are of the ETags of a, which may not match the ones of b,
+// and Progress is reported by the write to a.
+func secondaryWriteOptions(o *WriteOptions) *WriteOptions {
+	if o == nil {
+		return nil
+	}
+	wo := *o
+	wo.IfMatch, wo.IfNoneMatch, wo.Progress = "", "", nil
+	return &wo
+}
+
+func (client *mirrorClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.a.Exist(ctx, key)
+}
+
+func (client *mirrorClient) Remove(ctx context.Context, keys ...string) error {
+	var key string
+	if len(keys) == 1 {
+		key = keys[0]
+	}
+
+	if client.mode == MirrorAsync {
+		if err := client.a.Remove(ctx, keys...); err != nil {
+			return err
+		}
+		client.async(ctx, "Remove", key, func(ctx context.Context) error {
+			return client.b.Remove(ctx, keys...)
+		})
+		return nil
+	}
+
+	return client.sync("Remove", key, func() error {
+		return client.a.Remove(ctx, keys...)
+	}, func() error {
+		return client.b.Remove(ctx, keys...)
+	})
+}
+
+func (client *mirrorClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.a.List(ctx, prefix)
+}
+
+func (client *mirrorClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.a.Info(ctx, key)
+}
+
+func (client *mirrorClient) Copy(ctx context.Context, src, dst string) error {
+	if client.mode == MirrorAsync {
+		if err := client.a.Copy(ctx, src, dst); err != nil {
+			return err
+		}
+		client.async(ctx, "Copy", dst, func(ctx context.Context) error {
+			return client.b.Copy(ctx, src, dst)
+		})
+		return nil
+	}
+
+	return client.sync("Copy", dst, func() error {
+		return client.a.Copy(ctx, src, dst)
+	}, func() error {
+		return client.b.Copy(ctx, src, dst)
+	})
+}
+
+// Close waits for the background operations, then closes both clients.
+func (client *mirrorClient) Close() error {
+	for i := 0; i < cap(client.tokens); i++ {
+		client.tokens <- struct{}{}
+	}
+	return closeAll(client.a, client.b)
+}
@@ -0,0 +1,76 @@
+package objclient
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMirrorClient(t *testing.T) {
+	a := newMemClient()
+	b := newMemClient()
+
+	var (
+		mutex       sync.Mutex
+		divergences []Divergence
+	)
+	opts := &MirrorOptions{OnDivergence: func(d Divergence) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		divergences = append(divergences, d)
+	}}
+
+	client := NewMirrorClient(a, b, MirrorSync, opts)
+	err := client.Write(ctx, "objclient/test", strings.NewReader("demo"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Client{a, b} {
+		if data := readString(t, c, "objclient/test"); data != "demo" {
+			t.Fatalf("invalid data from Read(): %q", data)
+		}
+	}
+
+	b.fail = func(op, key string) error { return errors.New("b failed") }
+	err = client.Write(ctx, "objclient/test", strings.NewReader("demo2"), nil)
+	if err == nil {
+		t.Fatal("expect write error")
+	}
+	if len(divergences) != 1 || divergences[0].Key != "objclient/test" {
+		t.Fatalf("invalid divergences: %v", divergences)
+	}
+
+	b.fail = nil
+
+	// The conditions of a aren't checked by b, whose object differs.
+	b.put("objclient/cond", "other")
+	a.put("objclient/cond", "old")
+	info, err := a.Info(ctx, "objclient/cond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Write(ctx, "objclient/cond", strings.NewReader("new"), &WriteOptions{IfMatch: info.ETag})
+	if err != nil {
+		t.Fatalf("failed conditional write: %v", err)
+	}
+	if data := readString(t, b, "objclient/cond"); data != "new" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	client = NewMirrorClient(a, b, MirrorAsync, opts)
+	err = client.Write(ctx, "objclient/async", strings.NewReader("demo"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if exist, _ := b.Exist(ctx, "objclient/async"); exist {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data := readString(t, b, "objclient/async"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+}
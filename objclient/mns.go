@@ -0,0 +1,230 @@
+package objclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type MNSConfig struct {
+	// Endpoint looks like https://123456789012.mns.cn-hangzhou.aliyuncs.com.
+	Endpoint string
+	Queue    string
+	KeyID    string
+	Key      string
+}
+
+type mnsNotifications struct {
+	endpoint *url.URL
+	queue    string
+	keyID    string
+	key      string
+	client   *http.Client
+}
+
+// NewMNSNotifications returns notifications consuming OSS events delivered
+// to a MNS queue. The event rule of the bucket is configured in MNS.
+// Received messages are deleted from the queue.
+func NewMNSNotifications(config MNSConfig) (Notifications, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid mns endpoint: %q", config.Endpoint)
+	}
+	if config.Queue == "" {
+		return nil, fmt.Errorf("mns queue is required")
+	}
+
+	n := &mnsNotifications{
+		endpoint: endpoint,
+		queue:    config.Queue,
+		keyID:    config.KeyID,
+		key:      config.Key,
+		client:   &http.Client{Timeout: time.Minute},
+	}
+	return n, nil
+}
+
+func (n *mnsNotifications) Subscribe(ctx context.Context, prefix string, events ...EventType) (<-chan Event, error) {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			msg, err := n.receive(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if !sendEvent(ctx, ch, Event{Err: err}) || !sleepContext(ctx, pollErrorDelay) {
+					return
+				}
+				continue
+			}
+			if msg == nil {
+				continue
+			}
+
+			records, err := parseOSSEvents(msg.Body)
+			if err != nil {
+				err = fmt.Errorf("invalid message %v: %w", msg.MessageID, err)
+				if !sendEvent(ctx, ch, Event{Err: err}) {
+					return
+				}
+			}
+			for _, event := range records {
+				if !matchEvent(event, prefix, events) {
+					continue
+				}
+				if !sendEvent(ctx, ch, event) {
+					return
+				}
+			}
+
+			if err := n.delete(ctx, msg.ReceiptHandle); err != nil {
+				if !sendEvent(ctx, ch, Event{Err: err}) {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+type mnsMessage struct {
+	MessageID     string `xml:"MessageId"`
+	ReceiptHandle string `xml:"ReceiptHandle"`
+	Body          string `xml:"MessageBody"`
+}
+
+// receive returns nil if there was no message during the wait time.
+func (n *mnsNotifications) receive(ctx context.Context) (*mnsMessage, error) {
+	resource := "/queues/" + url.PathEscape(n.queue) + "/messages?waitseconds=30"
+	status, body, err := n.do(ctx, http.MethodGet, resource)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound && mnsErrorCode(body) == "MessageNotExist" {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, mnsError("receive message", status, body)
+	}
+
+	var msg mnsMessage
+	if err := xml.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid mns response: %w", err)
+	}
+	return &msg, nil
+}
+
+func (n *mnsNotifications) delete(ctx context.Context, handle string) error {
+	resource := "/queues/" + url.PathEscape(n.queue) + "/messages?ReceiptHandle=" + url.QueryEscape(handle)
+	status, body, err := n.do(ctx, http.MethodDelete, resource)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusNoContent && status != http.StatusOK {
+		return mnsError("delete message", status, body)
+	}
+	return nil
+}
+
+func (n *mnsNotifications) do(ctx context.Context, method, resource string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, n.endpoint.Scheme+"://"+n.endpoint.Host+resource, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", date)
+	req.Header.Set("X-Mns-Version", "2015-06-06")
+
+	stringToSign := method + "\n\n\n" + date + "\nx-mns-version:2015-06-06\n" + resource
+	h := hmac.New(sha1.New, []byte(n.key))
+	h.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	req.Header.Set("Authorization", "MNS "+n.keyID+":"+signature)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+func mnsErrorCode(body []byte) string {
+	var e struct {
+		Code string `xml:"Code"`
+	}
+	xml.Unmarshal(body, &e)
+	return e.Code
+}
+
+func mnsError(op string, status int, body []byte) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.Unmarshal(body, &e)
+	return fmt.Errorf("mns %v failed: %v %v: %v", op, status, e.Code, e.Message)
+}
+
+// parseOSSEvents parses the body of an OSS event message. The body is base64
+// encoded when delivered through MNS topics.
+func parseOSSEvents(body string) ([]Event, error) {
+	data := []byte(body)
+	if !strings.HasPrefix(strings.TrimSpace(body), "{") {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+
+	var msg struct {
+		Events []struct {
+			EventName string `json:"eventName"`
+			EventTime string `json:"eventTime"`
+			OSS       struct {
+				Object struct {
+					Key  string `json:"key"`
+					Size int64  `json:"size"`
+					ETag string `json:"eTag"`
+				} `json:"object"`
+			} `json:"oss"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, e := range msg.Events {
+		var event Event
+		event.Type = eventTypeFromName(e.EventName)
+		if event.Type == "" {
+			continue
+		}
+		event.Key = e.OSS.Object.Key
+		event.Size = e.OSS.Object.Size
+		event.ETag = e.OSS.Object.ETag
+		event.Time, _ = time.Parse(time.RFC3339, e.EventTime)
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,81 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultInfoConcurrency = 16
+)
+
+// MultiError reports the keys that failed in an operation on multiple keys.
+type MultiError struct {
+	Errors map[string]error
+}
+
+func (e *MultiError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 1 {
+		return fmt.Sprintf("%v: %v", keys[0], e.Errors[keys[0]])
+	}
+	return fmt.Sprintf("%v keys failed, %v: %v", len(keys), keys[0], e.Errors[keys[0]])
+}
+
+// InfoMulti gets the info of keys with at most concurrency requests in
+// flight, a default is used if concurrency <= 0. The returned map holds
+// the keys that succeeded even if some failed, which are reported by a
+// *MultiError.
+func InfoMulti(ctx context.Context, client Client, keys []string, concurrency int) (map[string]*ObjectInfo, error) {
+	if concurrency <= 0 {
+		concurrency = defaultInfoConcurrency
+	}
+
+	var (
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+		infos  = make(map[string]*ObjectInfo, len(keys))
+		errs   = make(map[string]error)
+		tokens = make(chan struct{}, concurrency)
+	)
+
+	for _, key := range keys {
+		select {
+		case tokens <- struct{}{}:
+		case <-ctx.Done():
+			mutex.Lock()
+			errs[key] = ctx.Err()
+			mutex.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-tokens }()
+
+			info, err := client.Info(ctx, key)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs[key] = err
+			} else {
+				infos[key] = info
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return infos, &MultiError{Errors: errs}
+	}
+	return infos, nil
+}
@@ -0,0 +1,29 @@
+package objclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInfoMulti(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "a")
+	mem.put("objclient/b", "bb")
+
+	infos, err := InfoMulti(ctx, mem, []string{"objclient/a", "objclient/b", "objclient/c"}, 2)
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expect multi error: %v", err)
+	}
+	if len(merr.Errors) != 1 || merr.Errors["objclient/c"] == nil {
+		t.Fatalf("invalid errors: %v", merr.Errors)
+	}
+	if len(infos) != 2 || infos["objclient/b"].Size != 2 {
+		t.Fatalf("invalid infos: %v", infos)
+	}
+
+	_, err = InfoMulti(ctx, mem, []string{"objclient/a"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,177 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+const (
+	defaultPartSize          = 16 << 20
+	minPartSize              = 5 << 20
+	maxPartCount             = 10000
+	defaultUploadConcurrency = 4
+)
+
+// uploadConfig is the parsed multipart fields of configs.
+type uploadConfig struct {
+	partSize    int64
+	concurrency int
+	controller  *AdaptiveConcurrency
+}
+
+// parseSize parses bytes with the optional suffix of KiB, MiB or GiB.
+func parseSize(s string) (int64, error) {
+	n, unit := s, int64(1)
+	for suffix, size := range map[string]int64{"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30} {
+		if strings.HasSuffix(s, suffix) {
+			n, unit = strings.TrimSpace(strings.TrimSuffix(s, suffix)), size
+			break
+		}
+	}
+	size, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * unit, nil
+}
+
+func parseUpload(partSize, concurrency string) (uploadConfig, error) {
+	upload := uploadConfig{partSize: defaultPartSize, concurrency: defaultUploadConcurrency}
+	if partSize != "" {
+		size, err := parseSize(partSize)
+		if err != nil || size < minPartSize {
+			return upload, fmt.Errorf("invalid PartSize %q, it should be at least 5MiB", partSize)
+		}
+		upload.partSize = size
+	}
+	if concurrency != "" {
+		n, err := strconv.Atoi(concurrency)
+		if err != nil || n <= 0 {
+			return upload, fmt.Errorf("invalid UploadConcurrency %q", concurrency)
+		}
+		upload.concurrency = n
+	}
+	return upload, nil
+}
+
+// partSizeOf returns the part size for objects of size, which is enlarged to
+// keep the parts in the limit of count.
+func (upload uploadConfig) partSizeOf(size int64) int64 {
+	partSize := upload.partSize
+	if size > partSize*maxPartCount {
+		partSize = (size + maxPartCount - 1) / maxPartCount
+		// Round up to MiB like the SDKs.
+		partSize = (partSize + 1<<20 - 1) &^ (1<<20 - 1)
+	}
+	return partSize
+}
+
+// uploadParts uploads r of size in parts with the options of the object,
+// upload.concurrency parts are buffered and uploaded at the same time.
+func (client *OSSClient) uploadParts(ctx context.Context, key string, r io.Reader, size int64, header *http.Header, opts []oss.Option) (oss.CompleteMultipartUploadResult, error) {
+	var result oss.CompleteMultipartUploadResult
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return result, err
+	}
+	imur, err := bucket.InitiateMultipartUpload(key, append(opts, oss.WithContext(ctx))...)
+	if err != nil {
+		return result, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partSize := client.upload.partSizeOf(size)
+	buffers := make(chan *[]byte, client.upload.concurrency)
+	for i := 0; i < client.upload.concurrency; i++ {
+		buffers <- nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		parts   []oss.UploadPart
+		partErr error
+	)
+	fail := func(err error) {
+		mutex.Lock()
+		if partErr == nil {
+			partErr = err
+		}
+		mutex.Unlock()
+		cancel()
+	}
+
+upload:
+	for number := 1; int64(number-1)*partSize < size; number++ {
+		var buffer *[]byte
+		select {
+		case buffer = <-buffers:
+		case <-ctx.Done():
+			break upload
+		}
+		if buffer == nil {
+			buffer = getPart(partSize)
+		}
+
+		data := (*buffer)[:min(partSize, size-int64(number-1)*partSize)]
+		if _, err := io.ReadFull(r, data); err != nil {
+			buffers <- buffer
+			fail(fmt.Errorf("failed to read part %v: %w", number, err))
+			break
+		}
+
+		wg.Add(1)
+		go func(number int) {
+			defer wg.Done()
+			defer func() { buffers <- buffer }()
+
+			release, err := client.upload.controller.Acquire(ctx)
+			if err != nil {
+				fail(err)
+				return
+			}
+			part, err := bucket.UploadPart(imur, bytes.NewReader(data), int64(len(data)), number, oss.WithContext(ctx))
+			release(err)
+			if err != nil {
+				fail(fmt.Errorf("failed to upload part %v: %w", number, err))
+				return
+			}
+			mutex.Lock()
+			parts = append(parts, part)
+			mutex.Unlock()
+		}(number)
+	}
+	wg.Wait()
+	close(buffers)
+	for buffer := range buffers {
+		if buffer != nil {
+			putPart(buffer)
+		}
+	}
+
+	if partErr == nil && ctx.Err() != nil {
+		partErr = ctx.Err()
+	}
+	if partErr == nil {
+		result, partErr = bucket.CompleteMultipartUpload(imur, parts, oss.WithContext(ctx), oss.GetResponseHeader(header))
+	}
+	if partErr != nil {
+		// The parts are not charged after the upload is aborted.
+		abortCtx, abortCancel := context.WithTimeout(context.Background(), client.timeout)
+		defer abortCancel()
+		bucket.AbortMultipartUpload(imur, oss.WithContext(abortCtx))
+		return result, partErr
+	}
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// multipartServer implements the multipart upload API shared by S3 and OSS.
+type multipartServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	parts     map[int][]byte
+	object    []byte
+	uploading int
+	maxUpload int
+}
+
+func newMultipartServer() *multipartServer {
+	server := &multipartServer{parts: make(map[int][]byte)}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (server *multipartServer) serve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload</UploadId></InitiateMultipartUploadResult>`))
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		server.mutex.Lock()
+		server.uploading++
+		server.maxUpload = max(server.maxUpload, server.uploading)
+		server.mutex.Unlock()
+
+		data, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data = decodeSignedChunks(data)
+		}
+		time.Sleep(100 * time.Millisecond)
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+
+		server.mutex.Lock()
+		server.uploading--
+		server.parts[number] = data
+		server.mutex.Unlock()
+		w.Header().Set("ETag", fmt.Sprintf(`"part%v"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		server.mutex.Lock()
+		var numbers []int
+		for number := range server.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		for _, number := range numbers {
+			server.object = append(server.object, server.parts[number]...)
+		}
+		server.mutex.Unlock()
+		w.Write([]byte(`<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"object"</ETag></CompleteMultipartUploadResult>`))
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data = decodeSignedChunks(data)
+		}
+		server.mutex.Lock()
+		server.object = data
+		server.mutex.Unlock()
+		w.Header().Set("ETag", `"object"`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// decodeSignedChunks returns the payload of the chunks of S3 streaming
+// signature, "size;chunk-signature=...\r\ndata\r\n".
+func decodeSignedChunks(data []byte) []byte {
+	var payload []byte
+	for {
+		line, rest, ok := bytes.Cut(data, []byte("\r\n"))
+		size, _, _ := bytes.Cut(line, []byte(";"))
+		n, err := strconv.ParseInt(string(size), 16, 64)
+		if !ok || err != nil || n == 0 || int64(len(rest)) < n {
+			return payload
+		}
+		payload = append(payload, rest[:n]...)
+		data = bytes.TrimPrefix(rest[n:], []byte("\r\n"))
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	data := make([]byte, 12<<20)
+	rand.Read(data)
+
+	for _, backend := range []string{"s3", "oss"} {
+		server := newMultipartServer()
+		endpoint := strings.TrimPrefix(server.URL, "http://")
+
+		var (
+			client Client
+			err    error
+		)
+		opts := []Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key"), WithMultipart(5<<20, 3)}
+		if backend == "s3" {
+			client, err = NewS3("bucket", append(opts, WithPathStyle(true))...)
+		} else {
+			client, err = NewOSS("bucket", opts...)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %v client: %v", backend, err)
+		}
+
+		// The reader isn't seekable, like the one of network.
+		r := io.MultiReader(bytes.NewReader(data))
+		var progress int64
+		err = client.Write(context.Background(), "key", r, &WriteOptions{
+			Size: int64(len(data)),
+			Progress: func(transferred, total int64) {
+				if total != int64(len(data)) {
+					t.Errorf("invalid total %v", total)
+				}
+				progress = transferred
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to write by %v: %v", backend, err)
+		}
+		if progress != int64(len(data)) {
+			t.Fatalf("invalid progress of %v: %v", backend, progress)
+		}
+		if len(server.parts) != 3 || server.maxUpload < 2 {
+			t.Fatalf("invalid parts of %v: %v parts, %v concurrent", backend, len(server.parts), server.maxUpload)
+		}
+		if !bytes.Equal(server.object, data) {
+			t.Fatalf("invalid object of %v", backend)
+		}
+		server.Close()
+	}
+}
+
+func TestPartSize(t *testing.T) {
+	if _, err := parseUpload("1MiB", ""); err == nil {
+		t.Fatalf("part size less than 5MiB is accepted")
+	}
+	if _, err := parseUpload("", "0"); err == nil {
+		t.Fatalf("zero concurrency is accepted")
+	}
+
+	upload, err := parseUpload("64MiB", "8")
+	if err != nil || upload.partSize != 64<<20 || upload.concurrency != 8 {
+		t.Fatalf("invalid upload config: %+v, %v", upload, err)
+	}
+	if size := upload.partSizeOf(1 << 40); size*maxPartCount < 1<<40 || size%(1<<20) != 0 {
+		t.Fatalf("invalid part size %v of 1TiB", size)
+	}
+}
+
+func TestWriteSize(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "file")
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer file.Close()
+	file.WriteString("0123456789")
+	file.Seek(4, io.SeekStart)
+
+	for _, test := range []struct {
+		r    io.Reader
+		o    *WriteOptions
+		size int64
+		ok   bool
+	}{
+		{strings.NewReader("data"), nil, 4, true},
+		{bytes.NewReader([]byte("data")), &WriteOptions{}, 4, true},
+		{bytes.NewBufferString("data"), nil, 4, true},
+		{file, nil, 6, true},
+		{io.MultiReader(strings.NewReader("data")), nil, 0, false},
+		{io.MultiReader(strings.NewReader("data")), &WriteOptions{Size: 4}, 4, true},
+	} {
+		if size, ok := writeSize(test.r, test.o); size != test.size || ok != test.ok {
+			t.Fatalf("invalid size of %T: %v, %v", test.r, size, ok)
+		}
+	}
+	if offset, _ := file.Seek(0, io.SeekCurrent); offset != 4 {
+		t.Fatalf("offset of file is changed to %v", offset)
+	}
+
+	server := newMultipartServer()
+	defer server.Close()
+	s3, err := NewS3("bucket", WithEndpoint(strings.TrimPrefix(server.URL, "http://")), WithRegion("us-east-1"),
+		WithHTTPS(false), WithPathStyle(true), WithKeys("id", "key"))
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	if err := s3.Write(context.Background(), "key", file, nil); err != nil {
+		t.Fatalf("failed to write file without size: %v", err)
+	}
+	if string(server.object) != "456789" {
+		t.Fatalf("invalid object %q", server.object)
+	}
+}
@@ -0,0 +1,221 @@
+package objclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+type EventType string
+
+const (
+	EventObjectCreated EventType = "ObjectCreated"
+	EventObjectRemoved EventType = "ObjectRemoved"
+	// EventObjectCopied is only delivered by EventBusClient, copies are
+	// ObjectCreated events of the backends.
+	EventObjectCopied EventType = "ObjectCopied"
+	// EventObjectUpdated is only delivered by Watch for the objects
+	// overwritten, they are ObjectCreated events of the backends.
+	EventObjectUpdated EventType = "ObjectUpdated"
+)
+
+type Event struct {
+	Type EventType
+	Key  string
+	Size int64
+	ETag string
+	Time time.Time
+	// Source is the source key of EventObjectCopied.
+	Source string
+
+	// Err is set when receiving events failed. The subscription keeps
+	// retrying until the context is done.
+	Err error
+}
+
+type Notifications interface {
+	// Subscribe delivers the events of objects under prefix until ctx is
+	// done, then the returned channel is closed. Empty events means all
+	// supported event types.
+	Subscribe(ctx context.Context, prefix string, events ...EventType) (<-chan Event, error)
+}
+
+// Subscribe listens for bucket events using the MinIO specific
+// ListenBucketNotification API. Use NewSQSNotifications for AWS S3.
+func (client *S3Client) Subscribe(ctx context.Context, prefix string, events ...EventType) (<-chan Event, error) {
+	if len(events) == 0 {
+		events = []EventType{EventObjectCreated, EventObjectRemoved}
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, "s3:"+string(e)+":*")
+	}
+
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := backend.ListenBucketNotification(ctx, client.bucket, prefix, "", names)
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for info := range infos {
+			if info.Err != nil {
+				if !sendEvent(ctx, ch, Event{Err: info.Err}) {
+					return
+				}
+				continue
+			}
+			for _, record := range info.Records {
+				event, ok := parseS3Record(record)
+				if !ok || !matchEvent(event, prefix, events) {
+					continue
+				}
+				if !sendEvent(ctx, ch, event) {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// ConfigureNotifications makes the bucket publish events of objects under
+// prefix to the queue identified by queueARN, e.g. an SQS queue on AWS.
+func (client *S3Client) ConfigureNotifications(ctx context.Context, queueARN, prefix string, events ...EventType) error {
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	arn, err := notification.NewArnFromString(queueARN)
+	if err != nil {
+		return fmt.Errorf("invalid queue arn: %w", err)
+	}
+
+	if len(events) == 0 {
+		events = []EventType{EventObjectCreated, EventObjectRemoved}
+	}
+
+	config := notification.NewConfig(arn)
+	for _, e := range events {
+		config.AddEvents(notification.EventType("s3:" + string(e) + ":*"))
+	}
+	if prefix != "" {
+		config.AddFilterPrefix(prefix)
+	}
+
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := backend.GetBucketNotification(ctx, client.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get bucket notification: %w", err)
+	}
+	current.RemoveQueueByArn(arn)
+	current.AddQueue(config)
+
+	return backend.SetBucketNotification(ctx, client.bucket, current)
+}
+
+// parseS3Records parses the body of an S3 event message. The body may also
+// be wrapped in a SNS notification.
+func parseS3Records(body []byte) ([]Event, error) {
+	var msg struct {
+		Type    string
+		Message string
+		Records []notification.Event
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Type == "Notification" && msg.Message != "" {
+		return parseS3Records([]byte(msg.Message))
+	}
+
+	var events []Event
+	for _, record := range msg.Records {
+		if event, ok := parseS3Record(record); ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func parseS3Record(record notification.Event) (Event, bool) {
+	var event Event
+
+	event.Type = eventTypeFromName(record.EventName)
+	if event.Type == "" {
+		return event, false
+	}
+
+	// Keys are url encoded in S3 event messages.
+	key, err := url.QueryUnescape(record.S3.Object.Key)
+	if err != nil {
+		key = record.S3.Object.Key
+	}
+	event.Key = key
+	event.Size = record.S3.Object.Size
+	event.ETag = record.S3.Object.ETag
+	event.Time, _ = time.Parse(time.RFC3339, record.EventTime)
+
+	return event, true
+}
+
+func eventTypeFromName(name string) EventType {
+	name = strings.TrimPrefix(name, "s3:")
+	switch {
+	case strings.HasPrefix(name, string(EventObjectCreated)+":"):
+		return EventObjectCreated
+	case strings.HasPrefix(name, string(EventObjectRemoved)+":"):
+		return EventObjectRemoved
+	}
+	return ""
+}
+
+func matchEvent(event Event, prefix string, events []EventType) bool {
+	if !strings.HasPrefix(event.Key, prefix) {
+		return false
+	}
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func sendEvent(ctx context.Context, ch chan<- Event, event Event) bool {
+	select {
+	case ch <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// pollErrorDelay is the time to wait before polling a queue again after
+// an error.
+const pollErrorDelay = 5 * time.Second
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package objclient
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseS3Records(t *testing.T) {
+	body := `{"Records":[
+		{"eventName":"ObjectCreated:Put","eventTime":"2024-01-02T03:04:05.000Z",
+		 "s3":{"object":{"key":"objclient/a+b%21","size":4,"eTag":"etag"}}},
+		{"eventName":"s3:ObjectRemoved:Delete","s3":{"object":{"key":"objclient/c"}}},
+		{"eventName":"s3:ObjectAccessed:Get","s3":{"object":{"key":"objclient/d"}}}
+	]}`
+
+	events, err := parseS3Records([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("invalid events: %v", events)
+	}
+	if events[0].Type != EventObjectCreated || events[0].Key != "objclient/a b!" ||
+		events[0].Size != 4 || events[0].Time.IsZero() {
+		t.Fatalf("invalid event: %+v", events[0])
+	}
+	if events[1].Type != EventObjectRemoved || events[1].Key != "objclient/c" {
+		t.Fatalf("invalid event: %+v", events[1])
+	}
+
+	// Events published through SNS.
+	sns := `{"Type":"Notification","Message":"{\"Records\":[{\"eventName\":\"ObjectCreated:Copy\",\"s3\":{\"object\":{\"key\":\"objclient/e\"}}}]}"}`
+	events, err = parseS3Records([]byte(sns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Key != "objclient/e" {
+		t.Fatalf("invalid events: %v", events)
+	}
+}
+
+func TestParseOSSEvents(t *testing.T) {
+	body := `{"events":[{"eventName":"ObjectCreated:PutObject","eventTime":"2024-01-02T03:04:05.000Z",
+		"oss":{"object":{"key":"objclient/a","size":4,"eTag":"etag"}}}]}`
+
+	for _, b := range []string{body, base64.StdEncoding.EncodeToString([]byte(body))} {
+		events, err := parseOSSEvents(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("invalid events: %v", events)
+		}
+		if events[0].Type != EventObjectCreated || events[0].Key != "objclient/a" || events[0].Size != 4 {
+			t.Fatalf("invalid event: %+v", events[0])
+		}
+	}
+
+	if !matchEvent(Event{Type: EventObjectCreated, Key: "objclient/a"}, "objclient/", nil) {
+		t.Fatal("expect event matches")
+	}
+	if matchEvent(Event{Type: EventObjectCreated, Key: "objclient/a"}, "objclient/", []EventType{EventObjectRemoved}) {
+		t.Fatal("expect event not matches")
+	}
+}
@@ -0,0 +1,329 @@
+// Package objbench measures the latency and throughput of object clients,
+// so backends can be compared by the same workload.
+package objbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+type Op string
+
+const (
+	OpPut    Op = "PUT"
+	OpGet    Op = "GET"
+	OpList   Op = "LIST"
+	OpDelete Op = "DELETE"
+)
+
+// Ops are all operations in the order they are run.
+var Ops = []Op{OpPut, OpGet, OpList, OpDelete}
+
+// ParseOps parses the comma separated operations, e.g. "put,get".
+func ParseOps(s string) ([]Op, error) {
+	var ops []Op
+	for _, name := range strings.Split(s, ",") {
+		op := Op(strings.ToUpper(strings.TrimSpace(name)))
+		if !slices.Contains(Ops, op) {
+			return nil, fmt.Errorf("unknown operation: %v", name)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+type Config struct {
+	// Concurrency are the numbers of concurrent workers the operations are
+	// run with in turn, defaults to 1.
+	Concurrency []int
+	// Ops are the measured operations, defaults to all of them. Objects
+	// are written before reads and removed after all even if PUT and
+	// DELETE aren't measured.
+	Ops []Op
+	// ObjectSize is the size of each object, defaults to 1MiB.
+	ObjectSize int64
+	// Objects is the number of objects of each concurrency, defaults to 100.
+	Objects int
+	// Lists is the number of LIST requests of each concurrency, defaults
+	// to 10.
+	Lists int
+	// Prefix is the prefix of the objects, defaults to "objbench/". It
+	// should be empty in the bucket.
+	Prefix string
+}
+
+func (config *Config) withDefaults() Config {
+	c := Config{
+		Concurrency: []int{1},
+		Ops:         Ops,
+		ObjectSize:  1 << 20,
+		Objects:     100,
+		Lists:       10,
+		Prefix:      "objbench/",
+	}
+	if config == nil {
+		return c
+	}
+	if len(config.Concurrency) > 0 {
+		c.Concurrency = config.Concurrency
+	}
+	if len(config.Ops) > 0 {
+		c.Ops = config.Ops
+	}
+	if config.ObjectSize > 0 {
+		c.ObjectSize = config.ObjectSize
+	}
+	if config.Objects > 0 {
+		c.Objects = config.Objects
+	}
+	if config.Lists > 0 {
+		c.Lists = config.Lists
+	}
+	if config.Prefix != "" {
+		c.Prefix = config.Prefix
+	}
+	return c
+}
+
+// Result is the measurement of an operation at a concurrency. Latencies of
+// GET are until the whole object is read.
+type Result struct {
+	Op          Op
+	Concurrency int
+	Requests    int
+	Errors      int
+	// Bytes is the data transferred by PUT and GET requests.
+	Bytes int64
+	// Elapsed is the wall time of all requests.
+	Elapsed time.Duration
+	Mean    time.Duration
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// OpsPerSecond is the rate of the succeeded requests.
+func (result *Result) OpsPerSecond() float64 {
+	if result.Elapsed <= 0 {
+		return 0
+	}
+	return float64(result.Requests-result.Errors) / result.Elapsed.Seconds()
+}
+
+// Throughput is the bytes transferred per second.
+func (result *Result) Throughput() float64 {
+	if result.Elapsed <= 0 {
+		return 0
+	}
+	return float64(result.Bytes) / result.Elapsed.Seconds()
+}
+
+// Run runs the operations of config against client at each concurrency,
+// and returns the results in the order of running. Failed requests are
+// counted by the results, but if all requests of an operation fail, the
+// first error is returned with the results so far after the objects are
+// removed.
+func Run(ctx context.Context, client objclient.Client, config *Config) ([]Result, error) {
+	c := config.withDefaults()
+	var results []Result
+	for _, concurrency := range c.Concurrency {
+		bench := &bench{client: client, config: &c, concurrency: max(concurrency, 1)}
+		err := bench.run(ctx, &results)
+		if cerr := bench.cleanup(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+type bench struct {
+	client      objclient.Client
+	config      *Config
+	concurrency int
+	prefix      string
+}
+
+func (bench *bench) key(i int) string {
+	return fmt.Sprintf("%s%06d", bench.prefix, i)
+}
+
+func (bench *bench) run(ctx context.Context, results *[]Result) error {
+	bench.prefix = fmt.Sprintf("%s%d/", bench.config.Prefix, bench.concurrency)
+	measured := func(op Op) bool { return slices.Contains(bench.config.Ops, op) }
+
+	steps := []struct {
+		op    Op
+		count int
+		fn    func(ctx context.Context, i int, data []byte) (int64, error)
+	}{
+		{OpPut, bench.config.Objects, bench.put},
+		{OpGet, bench.config.Objects, bench.get},
+		{OpList, bench.config.Lists, bench.list},
+		{OpDelete, bench.config.Objects, bench.remove},
+	}
+	for _, step := range steps {
+		// Objects are written for reads anyway, and removed by cleanup.
+		if !measured(step.op) && step.op != OpPut {
+			continue
+		}
+		result, err := bench.measure(ctx, step.count, step.fn)
+		if err != nil {
+			return fmt.Errorf("failed to %v: %w", step.op, err)
+		}
+		if measured(step.op) {
+			result.Op = step.op
+			*results = append(*results, result)
+		}
+	}
+	return nil
+}
+
+// measure calls fn count times by the workers, with i from 0 to count-1.
+func (bench *bench) measure(ctx context.Context, count int, fn func(ctx context.Context, i int, data []byte) (int64, error)) (Result, error) {
+	var (
+		wg          sync.WaitGroup
+		next        atomic.Int64
+		transferred atomic.Int64
+		errs        atomic.Int64
+		firstErr    error
+		errOnce     sync.Once
+		latencies   = make([]time.Duration, count)
+	)
+	start := time.Now()
+	for w := 0; w < bench.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, bench.config.ObjectSize)
+			rand.Read(data)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= count || ctx.Err() != nil {
+					return
+				}
+				begin := time.Now()
+				n, err := fn(ctx, i, data)
+				latencies[i] = time.Since(begin)
+				transferred.Add(n)
+				if err != nil {
+					errs.Add(1)
+					errOnce.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Concurrency: bench.concurrency,
+		Requests:    count,
+		Errors:      int(errs.Load()),
+		Bytes:       transferred.Load(),
+		Elapsed:     elapsed,
+	}
+	setLatencies(&result, latencies)
+	if result.Errors == count && count > 0 {
+		return result, firstErr
+	}
+	return result, nil
+}
+
+func setLatencies(result *Result, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	result.Mean = sum / time.Duration(len(latencies))
+	result.P50 = percentile(latencies, 50)
+	result.P90 = percentile(latencies, 90)
+	result.P99 = percentile(latencies, 99)
+	result.Max = latencies[len(latencies)-1]
+}
+
+// percentile returns the nearest rank percentile of the sorted latencies.
+func percentile(latencies []time.Duration, p int) time.Duration {
+	rank := (len(latencies)*p + 99) / 100
+	return latencies[max(rank-1, 0)]
+}
+
+func (bench *bench) put(ctx context.Context, i int, data []byte) (int64, error) {
+	// Each object has different content, in case the backend deduplicates.
+	copy(data, binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano())^uint64(i)))
+	err := bench.client.Write(ctx, bench.key(i), bytes.NewReader(data), nil)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (bench *bench) get(ctx context.Context, i int, data []byte) (int64, error) {
+	r, err := bench.client.Read(ctx, bench.key(i))
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.CopyBuffer(io.Discard, r, data)
+}
+
+func (bench *bench) list(ctx context.Context, i int, data []byte) (int64, error) {
+	_, err := bench.client.List(ctx, bench.prefix)
+	return 0, err
+}
+
+func (bench *bench) remove(ctx context.Context, i int, data []byte) (int64, error) {
+	return 0, bench.client.Remove(ctx, bench.key(i))
+}
+
+// cleanup removes the objects left by the run, even if it's canceled.
+func (bench *bench) cleanup() error {
+	_, err := objclient.RemovePrefix(context.Background(), bench.client, bench.prefix, nil)
+	return err
+}
+
+// WriteReport writes the results to w as a table.
+func WriteReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OP\tCONC\tREQS\tERRS\tOPS/S\tMB/S\tMEAN\tP50\tP90\tP99\tMAX\t")
+	for _, result := range results {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%.1f\t%.2f\t%v\t%v\t%v\t%v\t%v\t\n",
+			result.Op, result.Concurrency, result.Requests, result.Errors,
+			result.OpsPerSecond(), result.Throughput()/(1<<20),
+			round(result.Mean), round(result.P50), round(result.P90),
+			round(result.P99), round(result.Max))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,147 @@
+package objbench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+// mapClient is an in-memory client of the benchmarks.
+type mapClient struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (client *mapClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *mapClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (client *mapClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *mapClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.objects[key] = data
+	return &objclient.WriteResult{}, nil
+}
+
+func (client *mapClient) Exist(ctx context.Context, key string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, ok := client.objects[key]
+	return ok, nil
+}
+
+func (client *mapClient) Remove(ctx context.Context, keys ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, key := range keys {
+		delete(client.objects, key)
+	}
+	return nil
+}
+
+func (client *mapClient) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var items []objclient.ObjectItem
+	for key, data := range client.objects {
+		if strings.HasPrefix(key, prefix) {
+			items = append(items, objclient.ObjectItem{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, nil
+}
+
+func (client *mapClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return &objclient.ObjectInfo{Size: int64(len(data))}, nil
+}
+
+func (client *mapClient) Copy(ctx context.Context, src, dst string) error {
+	return errors.New("not supported")
+}
+
+func (client *mapClient) Close() error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	client := &mapClient{objects: make(map[string][]byte)}
+	results, err := Run(context.Background(), client, &Config{
+		Concurrency: []int{1, 4},
+		Ops:         []Op{OpGet, OpList},
+		ObjectSize:  1000,
+		Objects:     20,
+		Lists:       5,
+	})
+	if err != nil || len(results) != 4 {
+		t.Fatalf("failed to run: %v results, %v", len(results), err)
+	}
+	for i, result := range results {
+		op := []Op{OpGet, OpList}[i%2]
+		if result.Op != op || result.Concurrency != []int{1, 4}[i/2] || result.Errors != 0 {
+			t.Fatalf("invalid result %+v", result)
+		}
+		if op == OpGet && (result.Requests != 20 || result.Bytes != 20000) {
+			t.Fatalf("invalid result of get %+v", result)
+		}
+		if result.P50 > result.P90 || result.P90 > result.P99 || result.P99 > result.Max {
+			t.Fatalf("invalid percentiles %+v", result)
+		}
+	}
+	if len(client.objects) != 0 {
+		t.Fatalf("objects are left: %v", len(client.objects))
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, results); err != nil || strings.Count(report.String(), "\n") != 5 {
+		t.Fatalf("invalid report: %v\n%v", err, report.String())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 200; i++ {
+		latencies = append(latencies, time.Duration(i))
+	}
+	for p, want := range map[int]time.Duration{50: 100, 90: 180, 99: 198} {
+		if got := percentile(latencies, p); got != want {
+			t.Fatalf("invalid p%v %v", p, got)
+		}
+	}
+	if got := percentile(latencies[:1], 99); got != 1 {
+		t.Fatalf("invalid percentile of one latency %v", got)
+	}
+}
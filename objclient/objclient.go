@@ -2,48 +2,279 @@ package objclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 )
 
 const (
-	defaultTimeout = 30 * time.Second
+	defaultTimeout      = 30 * time.Second
+	defaultStallTimeout = 30 * time.Second
 )
 
-type Client interface {
-	// The caller should close the returned reader when done.
+// ExpiresTagKey is the tag of objects written with the Expires option. The
+// value is the number of days until the object expires, so a lifecycle rule
+// per value (e.g. expire after 1 day for tag value "1") removes the object.
+const ExpiresTagKey = "objclient-expires-days"
+
+// expiresDays returns the tag value of ExpiresTagKey, at least 1 day.
+func expiresDays(expires time.Time) string {
+	days := int64(math.Ceil(time.Until(expires).Hours() / 24))
+	return strconv.FormatInt(max(days, 1), 10)
+}
+
+// ReadOnlyClient is the subset of Client which doesn't modify objects.
+type ReadOnlyClient interface {
+	// The caller should close the returned reader when done. Read and Info
+	// return an error wrapping ErrNotFound if the key doesn't exist.
 	Read(ctx context.Context, key string) (io.ReadCloser, error)
-	// The WriteOptions can be empty for OSS clients. But caller must set the
-	// Size option for S3 clients.
-	Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error
+	// The ReadOptions can be nil, then it's the same as Read.
+	ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error)
 	Exist(ctx context.Context, key string) (bool, error)
-	Remove(ctx context.Context, keys ...string) error
-
 	// Empty prefix will list every objects in the bucket. Otherwise, the
 	// prefix should end with a "/".
 	List(ctx context.Context, prefix string) ([]ObjectItem, error)
 	Info(ctx context.Context, key string) (*ObjectInfo, error)
+}
+
+type Client interface {
+	ReadOnlyClient
+
+	// The WriteOptions can be empty for OSS clients. But caller must set the
+	// Size option for S3 clients, unless the size of r can be detected, see
+	// WriteOptions.
+	Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error
+	// WriteWithResult is the same as Write, but returns the ETag and version
+	// of the stored object.
+	WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error)
+	// If some keys failed to be removed, the returned error is a
+	// *RemoveError reporting each of them.
+	Remove(ctx context.Context, keys ...string) error
 	Copy(ctx context.Context, src, dst string) error
+	// Close releases the connections and background goroutines of the
+	// client, and cancels its transfers in flight. Wrappers close their
+	// inner clients too. The client can't be used after Close.
+	Close() error
+}
+
+type ReadOptions struct {
+	// Process is the OSS data processing parameters, e.g.
+	// "image/resize,w_100". It's not supported by S3 clients.
+	Process string
+	// Offset and Length select a range of the object. Length 0 reads to the
+	// end of the object.
+	Offset int64
+	Length int64
+	// Progress is called while the object is read from S3 and OSS clients.
+	Progress Progress
+	// ReadAhead is the bytes which S3 and OSS clients read ahead of the
+	// caller in the background, for consumers which read slowly at times.
+	ReadAhead int64
+	// IfMatch fails the read of S3 and OSS clients if the ETag of the
+	// object isn't it. For emulated symlinks of S3, it's the ETag of the
+	// symlink instead of the target.
+	IfMatch string
+	// Header is the extra headers of the requests of S3 and OSS clients,
+	// e.g. X-Oss-Traffic-Limit. The headers of presigned URLs are signed,
+	// and must be sent by the requests of them.
+	Header http.Header
+	// ResponseHeader overrides the headers of the responses of S3 and OSS
+	// clients and presigned URLs by the response-* parameters, e.g.
+	// Content-Disposition for the names of downloads. Only Cache-Control,
+	// Content-Disposition, Content-Encoding, Content-Language,
+	// Content-Type and Expires can be overridden.
+	ResponseHeader http.Header
+}
+
+// overridableHeaders are the response headers of ResponseHeader.
+var overridableHeaders = map[string]bool{
+	"Cache-Control":       true,
+	"Content-Disposition": true,
+	"Content-Encoding":    true,
+	"Content-Language":    true,
+	"Content-Type":        true,
+	"Expires":             true,
+}
+
+// responseParams returns the query parameters of the response header
+// overrides of o.
+func responseParams(o *ReadOptions) (url.Values, error) {
+	params := url.Values{}
+	if o == nil {
+		return params, nil
+	}
+	for k, values := range o.ResponseHeader {
+		k = http.CanonicalHeaderKey(k)
+		if !overridableHeaders[k] {
+			return nil, fmt.Errorf("response header %v can't be overridden", k)
+		}
+		if len(values) > 0 {
+			params.Set("response-"+strings.ToLower(k), values[0])
+		}
+	}
+	return params, nil
+}
+
+// wrapReader applies the options of S3 and OSS readers to r of size total.
+func wrapReader(r io.ReadCloser, total int64, o *ReadOptions) io.ReadCloser {
+	if o == nil {
+		return r
+	}
+	return readCloserWithProgress(newReadAheadReader(r, o.ReadAhead), total, o.Progress)
+}
+
+// readRange returns the range of options, or an error if it's invalid.
+func readRange(o *ReadOptions) (int64, int64, error) {
+	if o == nil {
+		return 0, 0, nil
+	}
+	if o.Offset < 0 || o.Length < 0 {
+		return 0, 0, fmt.Errorf("invalid range: offset %v, length %v", o.Offset, o.Length)
+	}
+	return o.Offset, o.Length, nil
 }
 
 type WriteOptions struct {
-	// Size is required for S3 clients.
+	// Size is required for S3 clients, unless it's detected from r which is
+	// io.Seeker or has the Len method, e.g. *os.File, *bytes.Reader and
+	// *strings.Reader. The remaining bytes from the current offset are
+	// written. Size -1 is an unknown size, which S3 clients upload in parts
+	// of the part size.
 	Size int64
-	// Metadata is optional. Keys should be lower case.
+	// Metadata is optional. S3 and OSS clients store it as normalized by
+	// NormalizeMetadata, and Info returns lower cased keys.
 	Metadata map[string]string
+	// Expires is optional. It sets the Expires header, and tags the object
+	// with ExpiresTagKey so lifecycle rules can remove it after expired.
+	Expires time.Time
+	// Progress is called while the reader is read by S3 and OSS clients,
+	// the total is Size. Parts may be buffered before they are uploaded.
+	Progress Progress
+	// ContentType is optional, it's the Content-Type of S3 and OSS
+	// objects.
+	ContentType string
+	// IfMatch fails the write of S3 and OSS clients with an error wrapping
+	// ErrPreconditionFailed if the ETag of the object isn't it, and
+	// IfNoneMatch "*" fails it if the object exists. The conditional
+	// writes aren't multipart uploads, so they are at most 5GB.
+	IfMatch     string
+	IfNoneMatch string
+	// Header is the extra headers of the requests of S3 and OSS clients.
+	// S3 clients only send the standard headers of objects, e.g.
+	// Cache-Control and Content-Disposition, and the X-Amz-Acl, X-Amz-Grant-*
+	// and X-Amz-Storage-Class ones.
+	Header http.Header
+}
+
+// conditional returns whether the write of o is conditional.
+func (o *WriteOptions) conditional() bool {
+	return o != nil && (o.IfMatch != "" || o.IfNoneMatch != "")
+}
+
+// SizedWriteOptions returns a copy of o with the size detected from r, for
+// the wrappers which replace r by a reader hiding its size. o is returned if
+// the size isn't detected.
+func SizedWriteOptions(r io.Reader, o *WriteOptions) *WriteOptions {
+	size, ok := writeSize(r, o)
+	if !ok {
+		return o
+	}
+	var wo WriteOptions
+	if o != nil {
+		wo = *o
+	}
+	wo.Size = size
+	return &wo
+}
+
+// writeSize returns Size of o, or the size detected from r if it's zero.
+// Negative sizes are unknown.
+func writeSize(r io.Reader, o *WriteOptions) (int64, bool) {
+	if o != nil && o.Size < 0 {
+		return 0, false
+	}
+	if o != nil && o.Size > 0 {
+		return o.Size, true
+	}
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case io.Seeker:
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - offset, true
+	}
+	return 0, false
+}
+
+type WriteResult struct {
+	ETag string
+	// VersionID is empty if versioning isn't enabled for the bucket.
+	VersionID string
+	// LastModified is zero if the backend doesn't report it.
+	LastModified time.Time
 }
 
 type ObjectItem struct {
 	Key          string
 	Size         int64
 	LastModified time.Time
+	// ETag and StorageClass are empty if the backend doesn't list them.
+	ETag         string
+	StorageClass string
 }
 
 type ObjectInfo struct {
 	Size         int64
 	LastModified time.Time
 	Metadata     map[string]string
+	ETag         string
+	// ContentType is the Content-Type stored with S3 and OSS objects, it's
+	// empty if the client doesn't keep it.
+	ContentType string
+}
+
+type RemoveResult struct {
+	Key string
+	Err error
+}
+
+// RemoveError reports the keys that Remove failed to remove.
+type RemoveError struct {
+	Results []RemoveResult
+}
+
+func (e *RemoveError) Error() string {
+	if len(e.Results) == 1 {
+		return fmt.Sprintf("failed to remove %v: %v", e.Results[0].Key, e.Results[0].Err)
+	}
+	return fmt.Sprintf("failed to remove %v keys, %v: %v",
+		len(e.Results), e.Results[0].Key, e.Results[0].Err)
+}
+
+// Keys returns the keys failed to be removed, which could be retried.
+func (e *RemoveError) Keys() []string {
+	keys := make([]string, 0, len(e.Results))
+	for _, result := range e.Results {
+		keys = append(keys, result.Key)
+	}
+	return keys
 }
 
 func stringToBool(s string, defaults bool) bool {
@@ -54,23 +285,67 @@ func stringToBool(s string, defaults bool) bool {
 	}
 }
 
+// StallEvent is a transfer canceled for no data in the window.
+type StallEvent struct {
+	// Op is "Read" or "Write".
+	Op     string
+	Key    string
+	Window time.Duration
+}
+
+var stallHook atomic.Pointer[func(StallEvent)]
+
+// SetStallHook sets the function called when a transfer of S3 and OSS
+// clients, or a reader of timeout clients, is canceled for stalling. Nil
+// hook removes it.
+func SetStallHook(hook func(StallEvent)) {
+	if hook == nil {
+		stallHook.Store(nil)
+		return
+	}
+	stallHook.Store(&hook)
+}
+
 // TimeoutReader will call the cancel function if Read() was blocked for about
-// 30 seconds.
+// 30 seconds. Errors of reads wrap ErrStalled after that.
 type TimeoutReader struct {
-	r      io.Reader
-	c      io.Closer
-	cancel context.CancelFunc
-	readed atomic.Int64
-	closed atomic.Bool
+	r       io.Reader
+	c       io.Closer
+	cancel  context.CancelFunc
+	window  time.Duration
+	op      string
+	key     string
+	readed  atomic.Int64
+	closed  atomic.Bool
+	done    chan struct{}
+	stalled atomic.Bool
+	// lastRead is the unix nanoseconds of the last read of data, eof is
+	// whether r is read to the end.
+	lastRead atomic.Int64
+	eof      atomic.Bool
+	// notified is whether the stall is reported to the hook, which may be
+	// detected by the deadlines of connections too.
+	notified atomic.Bool
 }
 
 // newTimeoutReader returns a new timeout reader.
 // Caller should close it after reading.
 func newTimeoutReader(r io.Reader, c io.Closer, cancel context.CancelFunc) *TimeoutReader {
+	return newStallReader(r, c, cancel, defaultStallTimeout, "Read", "")
+}
+
+// newStallReader returns a timeout reader which calls cancel if Read() was
+// blocked for about window. The op of key is reported to the stall hook.
+func newStallReader(r io.Reader, c io.Closer, cancel context.CancelFunc, window time.Duration, op, key string) *TimeoutReader {
 	reader := new(TimeoutReader)
 	reader.r = r
 	reader.c = c
 	reader.cancel = cancel
+	reader.window = window
+	reader.op = op
+	reader.key = key
+	reader.done = make(chan struct{})
+	reader.lastRead.Store(time.Now().UnixNano())
 	go reader.timer()
 	return reader
 }
@@ -78,11 +353,52 @@ func newTimeoutReader(r io.Reader, c io.Closer, cancel context.CancelFunc) *Time
 func (reader *TimeoutReader) Read(data []byte) (int, error) {
 	n, err := reader.r.Read(data)
 	reader.readed.Add(int64(n))
+	if n > 0 {
+		reader.lastRead.Store(time.Now().UnixNano())
+	}
+	if err == io.EOF {
+		reader.eof.Store(true)
+	} else if err != nil {
+		err = reader.wrapError(err)
+	}
 	return n, err
 }
 
+// wrapError wraps err of the transfer with ErrStalled if it's stalled.
+func (reader *TimeoutReader) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrStalled) {
+		// The transport of uploads may fail before the timer for no reads,
+		// e.g. by the deadline of the connection, and report only that the
+		// connection is closed. The deadline is set a little after the last
+		// read, which is buffered by the transport.
+		idle := time.Since(time.Unix(0, reader.lastRead.Load()))
+		if !reader.stalled.Load() && (reader.eof.Load() || !isNetworkError(err) || idle < reader.window/2) {
+			return err
+		}
+		err = fmt.Errorf("%w: %w", ErrStalled, err)
+	}
+	reader.notify()
+	return err
+}
+
+// notify reports the stall to the hook once.
+func (reader *TimeoutReader) notify() {
+	if reader.notified.Swap(true) {
+		return
+	}
+	if hook := stallHook.Load(); hook != nil {
+		(*hook)(StallEvent{Op: reader.op, Key: reader.key, Window: reader.window})
+	}
+}
+
 func (reader *TimeoutReader) Close() error {
-	reader.closed.Store(true)
+	if !reader.closed.Swap(true) {
+		// The timer is stopped at once.
+		close(reader.done)
+	}
 	reader.cancel()
 	if reader.c != nil {
 		reader.c.Close()
@@ -91,19 +407,21 @@ func (reader *TimeoutReader) Close() error {
 }
 
 func (reader *TimeoutReader) timer() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(reader.window)
 	defer ticker.Stop()
 
 	for {
-		<-ticker.C
-
-		if reader.closed.Load() {
+		select {
+		case <-ticker.C:
+		case <-reader.done:
 			return
 		}
 
 		readed := reader.readed.Swap(0)
 		if readed == 0 {
+			reader.stalled.Store(true)
 			reader.cancel()
+			reader.notify()
 			return
 		}
 	}
@@ -1,14 +1,18 @@
 package objclient
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/haiwen/goutils/objclient/s3test"
 )
 
 var (
@@ -16,15 +20,37 @@ var (
 	client Client
 )
 
+// s3TestConfig returns the config of the bucket of the environment, or of
+// an in-memory server if s3_bucket isn't set.
+func s3TestConfig(t *testing.T) S3Config {
+	if os.Getenv("s3_bucket") != "" {
+		return S3Config{
+			Region:      os.Getenv("s3_region"),
+			HTTPS:       "true",
+			Bucket:      os.Getenv("s3_bucket"),
+			KeyID:       os.Getenv("s3_key_id"),
+			Key:         os.Getenv("s3_key"),
+			V4Signature: "true",
+		}
+	}
+
+	server := s3test.NewTLSServer("objclient-test")
+	t.Cleanup(server.Close)
+	return S3Config{
+		Endpoint:         server.Endpoint(),
+		Region:           s3test.Region,
+		HTTPS:            "true",
+		Bucket:           "objclient-test",
+		PathStyleRequest: "true",
+		KeyID:            "id",
+		Key:              "key",
+		V4Signature:      "true",
+		RootCAs:          server.RootCAs(),
+	}
+}
+
 func TestS3Client(t *testing.T) {
-	cli, err := NewS3Client(S3Config{
-		Region:      os.Getenv("s3_region"),
-		HTTPS:       "true",
-		Bucket:      os.Getenv("s3_bucket"),
-		KeyID:       os.Getenv("s3_key_id"),
-		Key:         os.Getenv("s3_key"),
-		V4Signature: "true",
-	})
+	cli, err := NewS3Client(s3TestConfig(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -34,10 +60,15 @@ func TestS3Client(t *testing.T) {
 	t.Run("Clean", testRemove)
 
 	t.Run("ReadWrite", testReadWrite)
+	t.Run("ReadRange", testReadRange)
+	t.Run("WriteResult", testWriteResult)
+	t.Run("ConditionalWrite", testConditionalWrite)
+	t.Run("Ping", testPing)
 	t.Run("Exist", testExist)
 	t.Run("Info", testInfo)
 	t.Run("Copy", testCopy)
 	t.Run("List", testList)
+	t.Run("Symlink", testSymlink)
 	t.Run("Remove", testRemove)
 }
 
@@ -48,15 +79,9 @@ func TestS3SSECClient(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cli, err := NewS3Client(S3Config{
-		Region:      os.Getenv("s3_region"),
-		HTTPS:       "true",
-		Bucket:      os.Getenv("s3_bucket"),
-		KeyID:       os.Getenv("s3_key_id"),
-		Key:         os.Getenv("s3_key"),
-		V4Signature: "true",
-		SSECKey:     string(key),
-	})
+	config := s3TestConfig(t)
+	config.SSECKey = string(key)
+	cli, err := NewS3Client(config)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -66,14 +91,22 @@ func TestS3SSECClient(t *testing.T) {
 	t.Run("Clean", testRemove)
 
 	t.Run("ReadWrite", testReadWrite)
+	t.Run("ReadRange", testReadRange)
+	t.Run("WriteResult", testWriteResult)
+	t.Run("ConditionalWrite", testConditionalWrite)
+	t.Run("Ping", testPing)
 	t.Run("Exist", testExist)
 	t.Run("Info", testInfo)
 	t.Run("Copy", testCopy)
 	t.Run("List", testList)
+	t.Run("Symlink", testSymlink)
 	t.Run("Remove", testRemove)
 }
 
 func TestOSSClient(t *testing.T) {
+	if os.Getenv("oss_bucket") == "" {
+		t.Skip("oss_bucket is not set")
+	}
 	cli, err := NewOSSClient(OSSConfig{
 		Endpoint: "oss-" + os.Getenv("oss_region") + ".aliyuncs.com",
 		Region:   os.Getenv("oss_region"),
@@ -91,10 +124,15 @@ func TestOSSClient(t *testing.T) {
 	t.Run("Remove", testRemove)
 
 	t.Run("ReadWrite", testReadWrite)
+	t.Run("ReadRange", testReadRange)
+	t.Run("WriteResult", testWriteResult)
+	t.Run("ConditionalWrite", testConditionalWrite)
+	t.Run("Ping", testPing)
 	t.Run("Exist", testExist)
 	t.Run("Info", testInfo)
 	t.Run("Copy", testCopy)
 	t.Run("List", testList)
+	t.Run("Symlink", testSymlink)
 	t.Run("Remove", testRemove)
 }
 
@@ -122,6 +160,71 @@ func testReadWrite(t *testing.T) {
 	}
 }
 
+func testReadRange(t *testing.T) {
+	for _, o := range []ReadOptions{{Offset: 1, Length: 2}, {Offset: 2}} {
+		r, err := client.ReadWithOptions(ctx, "objclient/test", &o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "demo"[o.Offset:o.Offset+2] {
+			t.Fatalf("invalid data from ReadWithOptions(): %q", data)
+		}
+	}
+}
+
+func testWriteResult(t *testing.T) {
+	body := strings.NewReader("demo")
+
+	result, err := client.WriteWithResult(ctx, "objclient/test", body,
+		&WriteOptions{Size: body.Size()},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ETag == "" {
+		t.Fatalf("invalid result: %+v", result)
+	}
+}
+
+func testConditionalWrite(t *testing.T) {
+	write := func(data string, o *WriteOptions) (*WriteResult, error) {
+		o.Size = int64(len(data))
+		return client.WriteWithResult(ctx, "objclient/conditional", strings.NewReader(data), o)
+	}
+	result, err := write("first", &WriteOptions{IfNoneMatch: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := write("second", &WriteOptions{IfNoneMatch: "*"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	if _, err := write("second", &WriteOptions{IfMatch: result.ETag}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := write("third", &WriteOptions{IfMatch: result.ETag}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	if err := client.Remove(ctx, "objclient/conditional"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testPing(t *testing.T) {
+	pinger, ok := client.(Pinger)
+	if !ok {
+		t.Skip("ping is not supported")
+	}
+
+	if err := pinger.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func testExist(t *testing.T) {
 	exist, err := client.Exist(ctx, "objclient/test")
 	if err != nil {
@@ -214,6 +317,44 @@ func testList(t *testing.T) {
 	}
 }
 
+func testSymlink(t *testing.T) {
+	symlinker, ok := client.(Symlinker)
+	if !ok {
+		t.Skip("symlinks are not supported")
+	}
+
+	err := symlinker.PutSymlink(ctx, "objclient/link", "objclient/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := symlinker.GetSymlink(ctx, "objclient/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "objclient/test" {
+		t.Fatalf("invalid symlink target: %q", target)
+	}
+
+	r, err := client.Read(ctx, "objclient/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	_, err = symlinker.GetSymlink(ctx, "objclient/test")
+	if err != ErrNotSymlink {
+		t.Fatalf("expect not symlink error: %v", err)
+	}
+}
+
 func testRemove(t *testing.T) {
 	items, err := client.List(ctx, "objclient/")
 	if err != nil {
@@ -227,3 +368,32 @@ func testRemove(t *testing.T) {
 		}
 	}
 }
+
+// TestWrapperWriteSize writes readers of detectable sizes without the Size
+// option through the wrappers replacing them, which S3 clients require.
+func TestWrapperWriteSize(t *testing.T) {
+	server := s3test.NewTLSServer("bucket")
+	defer server.Close()
+	s3, err := NewS3Client(S3Config{
+		Endpoint: server.Endpoint(), Region: s3test.Region, HTTPS: "true", Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true", RootCAs: server.RootCAs(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	wrappers := map[string]Client{
+		"throttle": NewThrottledClient(s3, BandwidthLimits{MaxUploadBytesPerSec: 1 << 20}),
+		"mirror":   NewMirrorClient(s3, newMemClient(), MirrorSync, nil),
+		"quota":    NewQuotaClient(s3, 1<<20, NewMemoryUsageStore(), nil),
+	}
+	for name, wrapper := range wrappers {
+		key := "size/" + name
+		if err := wrapper.Write(ctx, key, bytes.NewReader([]byte("hello")), nil); err != nil {
+			t.Fatalf("failed to write through %v: %v", name, err)
+		}
+		if data := readString(t, s3, key); data != "hello" {
+			t.Fatalf("invalid data through %v: %q", name, data)
+		}
+	}
+}
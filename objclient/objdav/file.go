@@ -0,0 +1,270 @@
+package objdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+var (
+	errNotDirectory = errors.New("not a directory")
+	errIsDirectory  = errors.New("is a directory")
+	errReadOnly     = errors.New("file is opened for reading")
+	errWriteOnly    = errors.New("file is opened for writing")
+)
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+// newFileInfo returns the info of an object. The time is truncated to
+// seconds, since listings of S3 have milliseconds but the Last-Modified of
+// objects doesn't, and the ETags of WebDAV are computed from it.
+func newFileInfo(name string, size int64, modTime time.Time) *fileInfo {
+	return &fileInfo{name: name, size: size, modTime: modTime.Truncate(time.Second)}
+}
+
+func (info *fileInfo) Name() string       { return info.name }
+func (info *fileInfo) Size() int64        { return info.size }
+func (info *fileInfo) ModTime() time.Time { return info.modTime }
+func (info *fileInfo) IsDir() bool        { return info.dir }
+func (info *fileInfo) Sys() any           { return nil }
+
+func (info *fileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType returns the type by the extension, so PROPFIND doesn't read
+// the objects to detect their types.
+func (info *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if t := mime.TypeByExtension(path.Ext(info.name)); t != "" {
+		return t, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// objectReader reads an object from the offset, seeking reopens the object
+// from the new offset when it's read.
+type objectReader struct {
+	ctx    context.Context
+	client objclient.Client
+	key    string
+	size   int64
+	offset int64
+	r      io.ReadCloser
+}
+
+func (reader *objectReader) Read(data []byte) (int, error) {
+	if reader.offset >= reader.size {
+		return 0, io.EOF
+	}
+	if reader.r == nil {
+		r, err := reader.client.ReadWithOptions(reader.ctx, reader.key, &objclient.ReadOptions{Offset: reader.offset})
+		if err != nil {
+			return 0, err
+		}
+		reader.r = r
+	}
+
+	n, err := reader.r.Read(data)
+	reader.offset += int64(n)
+	return n, err
+}
+
+func (reader *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += reader.offset
+	case io.SeekEnd:
+		offset += reader.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to negative offset")
+	}
+
+	if offset != reader.offset && reader.r != nil {
+		reader.r.Close()
+		reader.r = nil
+	}
+	reader.offset = offset
+	return offset, nil
+}
+
+func (reader *objectReader) Close() error {
+	if reader.r == nil {
+		return nil
+	}
+	err := reader.r.Close()
+	reader.r = nil
+	return err
+}
+
+type readFile struct {
+	objectReader
+	info fs.FileInfo
+}
+
+func (file *readFile) Stat() (fs.FileInfo, error) {
+	return file.info, nil
+}
+
+func (file *readFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, errNotDirectory
+}
+
+func (file *readFile) Write(data []byte) (int, error) {
+	return 0, errReadOnly
+}
+
+// writeFile writes the object by a pipe, it's written when the file is
+// closed.
+type writeFile struct {
+	name  string
+	pw    *io.PipeWriter
+	size  int64
+	state *requestState
+	done  chan error
+	err   error
+}
+
+func newWriteFile(ctx context.Context, client objclient.Client, key string) *writeFile {
+	pr, pw := io.Pipe()
+	file := &writeFile{name: path.Base(key), pw: pw, state: stateOf(ctx), done: make(chan error, 1)}
+	go func() {
+		err := client.Write(ctx, key, pr, &objclient.WriteOptions{Size: file.state.writeSize()})
+		// Writes fail instead of blocking if the client returned early.
+		pr.CloseWithError(err)
+		file.done <- err
+	}()
+	return file
+}
+
+func (file *writeFile) Write(data []byte) (int, error) {
+	n, err := file.pw.Write(data)
+	file.size += int64(n)
+	return n, err
+}
+
+// Close aborts the write if the request body failed to be read.
+func (file *writeFile) Close() error {
+	if file.done == nil {
+		return file.err
+	}
+	if err := file.state.readErr(); err != nil {
+		file.pw.CloseWithError(err)
+	} else {
+		file.pw.Close()
+	}
+	file.err = <-file.done
+	file.done = nil
+	return file.err
+}
+
+func (file *writeFile) Stat() (fs.FileInfo, error) {
+	return newFileInfo(file.name, file.size, time.Now()), nil
+}
+
+func (file *writeFile) Read(data []byte) (int, error) {
+	return 0, errWriteOnly
+}
+
+func (file *writeFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, errWriteOnly
+}
+
+func (file *writeFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, errNotDirectory
+}
+
+type dirFile struct {
+	info    *fileInfo
+	entries []fs.FileInfo
+	offset  int
+}
+
+// newDirFile returns the dir of the items listed by prefix, with the
+// entries of its files and sub dirs. Markers of directories aren't entries,
+// and a sub dir is hidden by the file of the same name.
+func newDirFile(info *fileInfo, prefix string, items []objclient.ObjectItem) *dirFile {
+	names := make(map[string]*fileInfo)
+	var entries []fs.FileInfo
+	for _, item := range items {
+		rel := strings.TrimPrefix(item.Key, prefix)
+		if rel == "" {
+			continue
+		}
+		name, _, isDir := strings.Cut(rel, "/")
+		entry, ok := names[name]
+		if !ok {
+			if isDir {
+				entry = &fileInfo{name: name, dir: true}
+			} else {
+				entry = newFileInfo(name, item.Size, item.LastModified)
+			}
+			names[name] = entry
+			entries = append(entries, entry)
+		} else if !isDir && entry.dir {
+			*entry = *newFileInfo(name, item.Size, item.LastModified)
+		}
+		if entry.dir && item.LastModified.After(entry.modTime) {
+			entry.modTime = item.LastModified.Truncate(time.Second)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return &dirFile{info: info, entries: entries}
+}
+
+func (dir *dirFile) Read(data []byte) (int, error) {
+	return 0, errIsDirectory
+}
+
+func (dir *dirFile) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		dir.offset = 0
+		return 0, nil
+	}
+	return 0, errIsDirectory
+}
+
+func (dir *dirFile) Write(data []byte) (int, error) {
+	return 0, errIsDirectory
+}
+
+func (dir *dirFile) Close() error {
+	return nil
+}
+
+func (dir *dirFile) Stat() (fs.FileInfo, error) {
+	return dir.info, nil
+}
+
+func (dir *dirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	entries := dir.entries[dir.offset:]
+	if count <= 0 {
+		dir.offset = len(dir.entries)
+		return entries, nil
+	}
+	if len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if count < len(entries) {
+		entries = entries[:count]
+	}
+	dir.offset += len(entries)
+	return entries, nil
+}
@@ -0,0 +1,368 @@
+// Package objdav serves the objects of any objclient.Client by WebDAV, so
+// desktop clients can mount a bucket, or a prefix of it by
+// objclient.WithPrefix, as a drive. Directories are the prefixes of keys.
+// MKCOL writes an empty marker object whose key is the directory with a
+// trailing "/", like the consoles of S3 and OSS, so empty directories are
+// kept.
+package objdav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/haiwen/goutils/objclient"
+	"golang.org/x/net/webdav"
+)
+
+const removeBatchSize = 1000
+
+type Options struct {
+	// Prefix is the URL path prefix stripped from the paths of requests.
+	Prefix string
+	// LockSystem holds the locks of LOCK requests, defaults to the
+	// in-memory one of webdav.NewMemLS.
+	LockSystem webdav.LockSystem
+	// Logger logs the failed requests if it's set.
+	Logger *slog.Logger
+}
+
+type handler struct {
+	dav *webdav.Handler
+}
+
+// NewHandler serves the objects of client by WebDAV. GET, HEAD, PUT,
+// DELETE, MKCOL, COPY, MOVE, PROPFIND and LOCK are supported, dead
+// properties of PROPPATCH aren't stored. MOVE and COPY of directories copy
+// the objects one by one, they aren't atomic.
+func NewHandler(client objclient.Client, opts Options) http.Handler {
+	dav := &webdav.Handler{
+		Prefix:     opts.Prefix,
+		FileSystem: &fileSystem{client: client},
+		LockSystem: opts.LockSystem,
+	}
+	if dav.LockSystem == nil {
+		dav.LockSystem = webdav.NewMemLS()
+	}
+	if logger := opts.Logger; logger != nil {
+		dav.Logger = func(r *http.Request, err error) {
+			if err != nil && !os.IsNotExist(err) {
+				logger.Error("webdav request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+			}
+		}
+	}
+	return &handler{dav: dav}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	state := &requestState{infos: make(map[string]*fileInfo), size: -1}
+	if r.Method == http.MethodPut && r.ContentLength > 0 {
+		state.size = r.ContentLength
+	}
+	if r.Body != nil {
+		r.Body = &bodyReader{ReadCloser: r.Body, state: state}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), requestStateKey{}, state))
+	handler.dav.ServeHTTP(w, r)
+}
+
+type requestStateKey struct{}
+
+// requestState is the state of a request shared by the calls of the file
+// system. The infos of the entries listed by Readdir are kept, so PROPFIND
+// doesn't get the info of every entry again. They are dropped by changes.
+type requestState struct {
+	mutex   sync.Mutex
+	infos   map[string]*fileInfo
+	bodyErr error
+	// size is the size of the body of PUT, or -1 if it's unknown.
+	size int64
+}
+
+func stateOf(ctx context.Context) *requestState {
+	state, _ := ctx.Value(requestStateKey{}).(*requestState)
+	return state
+}
+
+func (state *requestState) info(key string) (*fileInfo, bool) {
+	if state == nil {
+		return nil, false
+	}
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	info, ok := state.infos[key]
+	return info, ok
+}
+
+func (state *requestState) addInfo(key string, info *fileInfo) {
+	if state == nil {
+		return
+	}
+	state.mutex.Lock()
+	state.infos[key] = info
+	state.mutex.Unlock()
+}
+
+func (state *requestState) reset() {
+	if state == nil {
+		return
+	}
+	state.mutex.Lock()
+	clear(state.infos)
+	state.mutex.Unlock()
+}
+
+// writeSize returns the size of files written by the request.
+func (state *requestState) writeSize() int64 {
+	if state == nil {
+		return -1
+	}
+	return state.size
+}
+
+func (state *requestState) readErr() error {
+	if state == nil {
+		return nil
+	}
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	return state.bodyErr
+}
+
+// bodyReader records the failure of reading the request body, so uploads
+// which are cut off aren't written as truncated objects.
+type bodyReader struct {
+	io.ReadCloser
+	state *requestState
+}
+
+func (body *bodyReader) Read(data []byte) (int, error) {
+	n, err := body.ReadCloser.Read(data)
+	if err != nil && err != io.EOF {
+		body.state.mutex.Lock()
+		body.state.bodyErr = err
+		body.state.mutex.Unlock()
+	}
+	return n, err
+}
+
+type fileSystem struct {
+	client objclient.Client
+}
+
+// toKey returns the key of the WebDAV path name, the root is "".
+func toKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// dirPrefix returns the prefix of the keys in the directory of key.
+func dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (fsys *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	key := toKey(name)
+	if key == "" {
+		return &fileInfo{name: "/", dir: true}, nil
+	}
+	if info, ok := stateOf(ctx).info(key); ok {
+		return info, nil
+	}
+
+	info, err := fsys.client.Info(ctx, key)
+	if err == nil {
+		return newFileInfo(path.Base(key), info.Size, info.LastModified), nil
+	}
+	if !errors.Is(err, objclient.ErrNotFound) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+	exist, err := fsys.prefixExists(ctx, key+"/")
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if !exist {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return &fileInfo{name: path.Base(key), dir: true}, nil
+}
+
+// prefixExists returns whether there are objects of prefix. Only the first
+// page is listed if the client is a PageLister.
+func (fsys *fileSystem) prefixExists(ctx context.Context, prefix string) (bool, error) {
+	if lister, ok := objclient.AsPageLister(fsys.client); ok {
+		exist := false
+		err := lister.ListPages(ctx, prefix, "", func(items []objclient.ObjectItem) bool {
+			exist = len(items) > 0
+			return !exist
+		})
+		return exist, err
+	}
+	items, err := fsys.client.List(ctx, prefix)
+	return len(items) > 0, err
+}
+
+// checkParent checks that the parent of key is a directory, since WebDAV
+// doesn't create the missing parents of new files.
+func (fsys *fileSystem) checkParent(ctx context.Context, op, key string) error {
+	parent := path.Dir("/" + key)
+	info, err := fsys.Stat(ctx, parent)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: op, Path: parent, Err: errNotDirectory}
+	}
+	return nil
+}
+
+func (fsys *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	key := toKey(name)
+	_, err := fsys.Stat(ctx, name)
+	if err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if err := fsys.checkParent(ctx, "mkdir", key); err != nil {
+		return err
+	}
+
+	stateOf(ctx).reset()
+	if err := fsys.client.Write(ctx, key+"/", bytes.NewReader(nil), nil); err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (fsys *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	key := toKey(name)
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fsys.open(ctx, name, key)
+	}
+
+	info, err := fsys.Stat(ctx, name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil && info.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errIsDirectory}
+	}
+	if err := fsys.checkParent(ctx, "open", key); err != nil {
+		return nil, err
+	}
+
+	stateOf(ctx).reset()
+	return newWriteFile(ctx, fsys.client, key), nil
+}
+
+func (fsys *fileSystem) open(ctx context.Context, name, key string) (webdav.File, error) {
+	info, err := fsys.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &readFile{objectReader: objectReader{ctx: ctx, client: fsys.client, key: key, size: info.Size()}, info: info}, nil
+	}
+
+	prefix := dirPrefix(key)
+	items, err := fsys.client.List(ctx, prefix)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	dir := newDirFile(info.(*fileInfo), prefix, items)
+	for _, entry := range dir.entries {
+		stateOf(ctx).addInfo(prefix+entry.Name(), entry.(*fileInfo))
+	}
+	return dir, nil
+}
+
+// RemoveAll removes the object of name, or the objects of the directory.
+// Like os.RemoveAll, it succeeds if name doesn't exist.
+func (fsys *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	key := toKey(name)
+	if key == "" {
+		return &os.PathError{Op: "removeall", Path: name, Err: errors.New("the root can't be removed")}
+	}
+	stateOf(ctx).reset()
+	info, err := fsys.Stat(ctx, name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		_, err = objclient.RemovePrefix(ctx, fsys.client, key+"/", nil)
+	} else {
+		err = fsys.client.Remove(ctx, key)
+	}
+	if err != nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Rename copies the object of oldName, or the objects of the directory, and
+// removes the copied objects.
+func (fsys *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldKey, newKey := toKey(oldName), toKey(newName)
+	if oldKey == "" || newKey == "" {
+		return &os.PathError{Op: "rename", Path: oldName, Err: errors.New("the root can't be renamed")}
+	}
+	if newKey == oldKey || strings.HasPrefix(newKey, oldKey+"/") {
+		return &os.PathError{Op: "rename", Path: oldName, Err: errors.New("can't be moved into itself")}
+	}
+	info, err := fsys.Stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	if err := fsys.checkParent(ctx, "rename", newKey); err != nil {
+		return err
+	}
+	stateOf(ctx).reset()
+
+	if !info.IsDir() {
+		if err := fsys.client.Copy(ctx, oldKey, newKey); err != nil {
+			return &os.PathError{Op: "rename", Path: oldName, Err: err}
+		}
+		if err := fsys.client.Remove(ctx, oldKey); err != nil {
+			return &os.PathError{Op: "rename", Path: oldName, Err: err}
+		}
+		return nil
+	}
+
+	// Only the copied objects are removed, not the ones written under the
+	// directory meanwhile.
+	items, err := fsys.client.List(ctx, oldKey+"/")
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: oldName, Err: err}
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		dst := newKey + "/" + strings.TrimPrefix(item.Key, oldKey+"/")
+		if err := fsys.client.Copy(ctx, item.Key, dst); err != nil {
+			return &os.PathError{Op: "rename", Path: oldName, Err: err}
+		}
+		keys = append(keys, item.Key)
+	}
+	for len(keys) > 0 {
+		n := min(len(keys), removeBatchSize)
+		if err := fsys.client.Remove(ctx, keys[:n]...); err != nil {
+			return &os.PathError{Op: "rename", Path: oldName, Err: err}
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
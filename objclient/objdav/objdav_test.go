@@ -0,0 +1,277 @@
+package objdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/s3test"
+)
+
+// memClient is an in-memory client for tests, which keeps the markers of
+// directories unlike DirClient.
+type memClient struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (client *memClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *memClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", objclient.ErrNotFound, key)
+	}
+	if o != nil {
+		data = data[min(o.Offset, int64(len(data))):]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (client *memClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *memClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.objects[key] = data
+	return &objclient.WriteResult{}, nil
+}
+
+func (client *memClient) Exist(ctx context.Context, key string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, ok := client.objects[key]
+	return ok, nil
+}
+
+func (client *memClient) Remove(ctx context.Context, keys ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, key := range keys {
+		delete(client.objects, key)
+	}
+	return nil
+}
+
+func (client *memClient) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var items []objclient.ObjectItem
+	for key, data := range client.objects {
+		if strings.HasPrefix(key, prefix) {
+			items = append(items, objclient.ObjectItem{Key: key, Size: int64(len(data)), LastModified: time.Unix(1, 0)})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, nil
+}
+
+func (client *memClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", objclient.ErrNotFound, key)
+	}
+	return &objclient.ObjectInfo{Size: int64(len(data)), LastModified: time.Unix(1, 0)}, nil
+}
+
+func (client *memClient) Copy(ctx context.Context, src, dst string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	data, ok := client.objects[src]
+	if !ok {
+		return fmt.Errorf("%w: %v", objclient.ErrNotFound, src)
+	}
+	client.objects[dst] = data
+	return nil
+}
+
+func (client *memClient) Close() error {
+	return nil
+}
+
+func (client *memClient) keys() []string {
+	items, _ := client.List(context.Background(), "")
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
+
+func request(t *testing.T, server *httptest.Server, method, path string, body io.Reader, header map[string]string) (int, string) {
+	req, err := http.NewRequest(method, server.URL+path, body)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to %v %v: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response of %v %v: %v", method, path, err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// propfind returns the hrefs of the responses.
+func propfind(t *testing.T, server *httptest.Server, path string) []string {
+	status, body := request(t, server, "PROPFIND", path, nil, map[string]string{"Depth": "1"})
+	if status != http.StatusMultiStatus {
+		t.Fatalf("invalid status of PROPFIND %v: %v %v", path, status, body)
+	}
+	var result struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}
+	if err := xml.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("invalid PROPFIND response %v: %v", body, err)
+	}
+	var hrefs []string
+	for _, response := range result.Responses {
+		hrefs = append(hrefs, response.Href)
+	}
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+func TestHandler(t *testing.T) {
+	client := &memClient{objects: make(map[string][]byte)}
+	server := httptest.NewServer(NewHandler(client, Options{Prefix: "/dav"}))
+	defer server.Close()
+
+	if status, _ := request(t, server, "MKCOL", "/dav/docs", nil, nil); status != http.StatusCreated {
+		t.Fatalf("invalid status of MKCOL: %v", status)
+	}
+	if status, _ := request(t, server, "MKCOL", "/dav/docs", nil, nil); status != http.StatusMethodNotAllowed {
+		t.Fatalf("existing directory is created: %v", status)
+	}
+	if status, _ := request(t, server, "PUT", "/dav/docs/a.txt", strings.NewReader("hello"), nil); status != http.StatusCreated {
+		t.Fatalf("invalid status of PUT: %v", status)
+	}
+	if status, _ := request(t, server, "PUT", "/dav/missing/a.txt", strings.NewReader("a"), nil); status != http.StatusConflict {
+		t.Fatalf("file of missing directory is written: %v", status)
+	}
+	if keys := client.keys(); strings.Join(keys, ",") != "docs/,docs/a.txt" {
+		t.Fatalf("invalid keys %v", keys)
+	}
+
+	if status, body := request(t, server, "GET", "/dav/docs/a.txt", nil, map[string]string{"Range": "bytes=1-"}); status != http.StatusPartialContent || body != "ello" {
+		t.Fatalf("invalid GET: %v %q", status, body)
+	}
+	if status, _ := request(t, server, "GET", "/dav/docs/b.txt", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("invalid status of missing file: %v", status)
+	}
+
+	client.objects["docs/sub/b.txt"] = []byte("b")
+	if hrefs := propfind(t, server, "/dav/docs/"); strings.Join(hrefs, ",") != "/dav/docs/,/dav/docs/a.txt,/dav/docs/sub/" {
+		t.Fatalf("invalid PROPFIND hrefs %v", hrefs)
+	}
+	if hrefs := propfind(t, server, "/dav/"); strings.Join(hrefs, ",") != "/dav/,/dav/docs/" {
+		t.Fatalf("invalid PROPFIND hrefs of root %v", hrefs)
+	}
+
+	if status, _ := request(t, server, "MOVE", "/dav/docs", nil, map[string]string{"Destination": server.URL + "/dav/moved"}); status != http.StatusCreated {
+		t.Fatalf("invalid status of MOVE: %v", status)
+	}
+	if keys := client.keys(); strings.Join(keys, ",") != "moved/,moved/a.txt,moved/sub/b.txt" {
+		t.Fatalf("invalid keys after MOVE %v", keys)
+	}
+	if status, _ := request(t, server, "DELETE", "/dav/moved/sub", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("invalid status of DELETE: %v", status)
+	}
+	if status, _ := request(t, server, "DELETE", "/dav/moved/sub", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("invalid status of DELETE of missing directory: %v", status)
+	}
+	if keys := client.keys(); strings.Join(keys, ",") != "moved/,moved/a.txt" {
+		t.Fatalf("invalid keys after DELETE %v", keys)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(data []byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestHandlerTruncatedUpload(t *testing.T) {
+	client := &memClient{objects: make(map[string][]byte)}
+	handler := NewHandler(client, Options{})
+
+	body := io.MultiReader(strings.NewReader("partial"), failingReader{})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("PUT", "/a", body))
+	if w.Code == http.StatusCreated {
+		t.Fatalf("truncated upload succeeded")
+	}
+	if keys := client.keys(); len(keys) != 0 {
+		t.Fatalf("truncated upload is written: %v", keys)
+	}
+}
+
+// onlyReader hides the Len method, so the body is sent chunked.
+type onlyReader struct {
+	io.Reader
+}
+
+func TestHandlerS3(t *testing.T) {
+	backend := s3test.NewServer("bucket")
+	defer backend.Close()
+	client, err := objclient.NewS3Client(objclient.S3Config{
+		Endpoint: backend.Endpoint(), Region: s3test.Region, Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+	server := httptest.NewServer(NewHandler(client, Options{}))
+	defer server.Close()
+
+	// The files of known and unknown sizes are written.
+	if status, body := request(t, server, "PUT", "/a.txt", strings.NewReader("hello"), nil); status != http.StatusCreated {
+		t.Fatalf("invalid status of PUT: %v %v", status, body)
+	}
+	if status, body := request(t, server, "PUT", "/b.txt", onlyReader{strings.NewReader("chunked")}, nil); status != http.StatusCreated {
+		t.Fatalf("invalid status of chunked PUT: %v %v", status, body)
+	}
+	if status, body := request(t, server, "PUT", "/empty.txt", strings.NewReader(""), nil); status != http.StatusCreated {
+		t.Fatalf("invalid status of empty PUT: %v %v", status, body)
+	}
+	for path, data := range map[string]string{"/a.txt": "hello", "/b.txt": "chunked", "/empty.txt": ""} {
+		if status, body := request(t, server, "GET", path, nil, nil); status != http.StatusOK || body != data {
+			t.Fatalf("invalid GET of %v: %v %q", path, status, body)
+		}
+	}
+	if status, _ := request(t, server, "COPY", "/a.txt", nil, map[string]string{"Destination": server.URL + "/c.txt"}); status != http.StatusCreated {
+		t.Fatalf("invalid status of COPY: %v", status)
+	}
+}
@@ -0,0 +1,162 @@
+package objlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type ElectorOptions struct {
+	// TTL is the TTL of the lease of the leader, defaults to 30s.
+	TTL time.Duration
+	// RenewInterval is the interval of the renewals of the lease, defaults
+	// to a third of the TTL. It must be less than the TTL.
+	RenewInterval time.Duration
+	// Margin is how long before the expiry of the lease the leadership is
+	// given up if it isn't renewed, for the drift of the clocks. It defaults
+	// to a tenth of the TTL, or a half of the time left after the renew
+	// interval if it's less.
+	Margin time.Duration
+	// OnElected is called when it's elected with a context canceled when
+	// the leadership is lost or the elector is closed. It shouldn't block,
+	// the work of the leader runs in its own goroutines.
+	OnElected func(ctx context.Context)
+	// OnLost is called with the error after the leadership is lost, since
+	// the lease is taken over or it's expired without renewals.
+	OnLost func(err error)
+}
+
+// LeaderElector campaigns for the lock of a name in background until it's
+// closed, so one of the electors of the name is the leader at a time. The
+// leader renews the lease by the renew interval, and gives up the
+// leadership the margin before the lease expires without renewals.
+type LeaderElector struct {
+	locker *Locker
+	name   string
+	o      ElectorOptions
+	leader atomic.Bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewLeaderElector starts campaigning for the lock of name by locker. The
+// options can be nil.
+func NewLeaderElector(locker *Locker, name string, opts *ElectorOptions) (*LeaderElector, error) {
+	var o ElectorOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.TTL <= 0 {
+		o.TTL = 30 * time.Second
+	}
+	if o.RenewInterval <= 0 {
+		o.RenewInterval = o.TTL / 3
+	}
+	if o.RenewInterval >= o.TTL {
+		return nil, fmt.Errorf("renew interval %v must be less than TTL %v", o.RenewInterval, o.TTL)
+	}
+	if o.Margin <= 0 {
+		o.Margin = min(o.TTL/10, (o.TTL-o.RenewInterval)/2)
+	}
+	if o.RenewInterval+o.Margin >= o.TTL {
+		return nil, fmt.Errorf("renew interval %v and margin %v must be less than TTL %v", o.RenewInterval, o.Margin, o.TTL)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	elector := &LeaderElector{locker: locker, name: name, o: o, cancel: cancel, done: make(chan struct{})}
+	go elector.run(ctx)
+	return elector, nil
+}
+
+// IsLeader returns whether it holds the leadership.
+func (elector *LeaderElector) IsLeader() bool {
+	return elector.leader.Load()
+}
+
+func (elector *LeaderElector) run(ctx context.Context) {
+	defer close(elector.done)
+	for {
+		lease, err := elector.locker.Acquire(ctx, elector.name, elector.o.TTL)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// The errors of backends are retried like the locks held.
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(elector.locker.o.RetryInterval):
+			}
+			continue
+		}
+
+		leaderCtx, cancelLeader := context.WithCancel(ctx)
+		elector.leader.Store(true)
+		if elector.o.OnElected != nil {
+			elector.o.OnElected(leaderCtx)
+		}
+		err = elector.lead(ctx, lease)
+		elector.leader.Store(false)
+		cancelLeader()
+		if err == nil {
+			// The elector is closed, and the lease is released for the
+			// others.
+			ctx, cancel := context.WithTimeout(context.Background(), elector.o.RenewInterval)
+			lease.Release(ctx)
+			cancel()
+			return
+		}
+		if elector.o.OnLost != nil {
+			elector.o.OnLost(err)
+		}
+	}
+}
+
+// lead renews lease until ctx is done, or returns the error the leadership
+// is lost by. It's lost the margin before the lease expires, even if it's
+// between the renewals, so the leaders don't overlap.
+func (elector *LeaderElector) lead(ctx context.Context, lease *Lease) error {
+	ticker := time.NewTicker(elector.o.RenewInterval)
+	defer ticker.Stop()
+	var renewErr error
+	for {
+		deadline := lease.Expires().Add(-elector.o.Margin)
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+			if renewErr == nil {
+				return fmt.Errorf("%w: %v expired", ErrLeaseLost, elector.name)
+			}
+			return fmt.Errorf("%w: %v expired: %w", ErrLeaseLost, elector.name, renewErr)
+		case <-ticker.C:
+			timer.Stop()
+		}
+
+		renewCtx, cancel := context.WithDeadline(ctx, deadline)
+		err := lease.Renew(renewCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrLeaseLost) {
+			return err
+		}
+		if err != nil && !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %v expired: %w", ErrLeaseLost, elector.name, err)
+		}
+		renewErr = err
+	}
+}
+
+// Close stops campaigning, and releases the lease if it's the leader.
+func (elector *LeaderElector) Close() error {
+	elector.cancel()
+	<-elector.done
+	return nil
+}
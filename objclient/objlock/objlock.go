@@ -0,0 +1,209 @@
+// Package objlock provides coarse locks of the jobs sharing a bucket, e.g.
+// GC and migrations. A lock is an object written by conditional writes,
+// whose metadata is the lease of the holder until it expires. The holder
+// renews the lease before it expires, and the others take over the expired
+// leases. The client must support conditional writes, like the S3 and OSS
+// clients and the wrappers passing WriteOptions through, and the clocks of
+// the jobs shouldn't be skewed much compared with the TTLs.
+package objlock
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+const (
+	ownerMetaKey   = "lock-owner"
+	expiresMetaKey = "lock-expires"
+)
+
+var (
+	// ErrLocked is returned by TryAcquire if the lease of the lock hasn't
+	// expired.
+	ErrLocked = errors.New("lock held")
+	// ErrLeaseLost is returned by Renew and Release if the lock is taken
+	// over by another holder after the lease expired.
+	ErrLeaseLost = errors.New("lease lost")
+)
+
+type Options struct {
+	// Prefix is the prefix of the keys of locks, defaults to "locks/".
+	Prefix string
+	// Owner identifies the holder in the leases, defaults to the hostname
+	// and the pid.
+	Owner string
+	// RetryInterval is the interval Acquire retries the locks held,
+	// defaults to 1s.
+	RetryInterval time.Duration
+}
+
+type Locker struct {
+	client objclient.Client
+	o      Options
+}
+
+// New returns a locker of the locks of client. The options can be nil.
+func New(client objclient.Client, opts *Options) *Locker {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Prefix == "" {
+		o.Prefix = "locks/"
+	}
+	if o.Owner == "" {
+		hostname, _ := os.Hostname()
+		o.Owner = fmt.Sprintf("%v:%v", hostname, os.Getpid())
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = time.Second
+	}
+	return &Locker{client: client, o: o}
+}
+
+// Lease is the lease of a lock acquired.
+type Lease struct {
+	locker *Locker
+	name   string
+	ttl    time.Duration
+
+	mutex   sync.Mutex
+	etag    string
+	expires time.Time
+}
+
+// write writes the lease of name until expires with the conditions of o,
+// and returns the ETag of it.
+func (locker *Locker) write(ctx context.Context, name string, expires time.Time, o *objclient.WriteOptions) (string, error) {
+	// The nonce makes the ETags of leases different.
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(map[string]string{
+		"owner":   locker.o.Owner,
+		"expires": expires.Format(time.RFC3339Nano),
+		"nonce":   hex.EncodeToString(nonce[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+	o.Size = int64(len(data))
+	o.Metadata = map[string]string{
+		ownerMetaKey:   locker.o.Owner,
+		expiresMetaKey: expires.Format(time.RFC3339Nano),
+	}
+	result, err := locker.client.WriteWithResult(ctx, locker.o.Prefix+name, bytes.NewReader(data), o)
+	if err != nil {
+		return "", err
+	}
+	return result.ETag, nil
+}
+
+// TryAcquire acquires the lock of name for ttl, or returns an error
+// wrapping ErrLocked if it's held by an unexpired lease.
+func (locker *Locker) TryAcquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if err := objclient.ValidateKey(locker.o.Prefix + name); err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(ttl)
+	etag, err := locker.write(ctx, name, expires, &objclient.WriteOptions{IfNoneMatch: "*"})
+	if err == nil {
+		return &Lease{locker: locker, name: name, ttl: ttl, etag: etag, expires: expires}, nil
+	}
+	if !errors.Is(err, objclient.ErrPreconditionFailed) {
+		return nil, fmt.Errorf("failed to write lock %v: %w", name, err)
+	}
+
+	info, err := locker.client.Info(ctx, locker.o.Prefix+name)
+	if errors.Is(err, objclient.ErrNotFound) {
+		// The lock is removed by others, it's retried later.
+		return nil, fmt.Errorf("%w: %v", ErrLocked, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock %v: %w", name, err)
+	}
+	held, err := time.Parse(time.RFC3339Nano, info.Metadata[expiresMetaKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid lease of lock %v: %w", name, err)
+	}
+	if time.Now().Before(held) {
+		return nil, fmt.Errorf("%w: %v by %v until %v", ErrLocked, name, info.Metadata[ownerMetaKey], held)
+	}
+
+	// The expired lease is taken over, unless another holder does first.
+	expires = time.Now().Add(ttl)
+	etag, err = locker.write(ctx, name, expires, &objclient.WriteOptions{IfMatch: info.ETag})
+	if errors.Is(err, objclient.ErrPreconditionFailed) {
+		return nil, fmt.Errorf("%w: %v", ErrLocked, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write lock %v: %w", name, err)
+	}
+	return &Lease{locker: locker, name: name, ttl: ttl, etag: etag, expires: expires}, nil
+}
+
+// Acquire acquires the lock of name for ttl, and retries it by the retry
+// interval until ctx is done if it's held.
+func (locker *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	for {
+		lease, err := locker.TryAcquire(ctx, name, ttl)
+		if !errors.Is(err, ErrLocked) {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(locker.o.RetryInterval):
+		}
+	}
+}
+
+func (lease *Lease) Name() string {
+	return lease.name
+}
+
+// Expires returns when the lease expires, by the local clock.
+func (lease *Lease) Expires() time.Time {
+	lease.mutex.Lock()
+	defer lease.mutex.Unlock()
+	return lease.expires
+}
+
+// update replaces the lease with the one until expires, if it isn't taken
+// over.
+func (lease *Lease) update(ctx context.Context, expires time.Time) error {
+	lease.mutex.Lock()
+	defer lease.mutex.Unlock()
+	etag, err := lease.locker.write(ctx, lease.name, expires, &objclient.WriteOptions{IfMatch: lease.etag})
+	if errors.Is(err, objclient.ErrPreconditionFailed) || errors.Is(err, objclient.ErrNotFound) {
+		return fmt.Errorf("%w: %v", ErrLeaseLost, lease.name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write lock %v: %w", lease.name, err)
+	}
+	lease.etag, lease.expires = etag, expires
+	return nil
+}
+
+// Renew extends the lease by the TTL from now. It succeeds after the lease
+// expired if the lock isn't taken over yet.
+func (lease *Lease) Renew(ctx context.Context) error {
+	return lease.update(ctx, time.Now().Add(lease.ttl))
+}
+
+// Release expires the lease, so the lock can be acquired by others. The
+// object of the lock is kept for them.
+func (lease *Lease) Release(ctx context.Context) error {
+	return lease.update(ctx, time.Time{})
+}
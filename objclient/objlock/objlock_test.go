@@ -0,0 +1,324 @@
+package objlock
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+type memObject struct {
+	data     []byte
+	metadata map[string]string
+}
+
+// memClient is an in-memory client for tests, which supports the
+// conditional writes.
+type memClient struct {
+	mutex   sync.Mutex
+	objects map[string]memObject
+	// down fails the writes if it's set.
+	down bool
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (client *memClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *memClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	obj, ok := client.objects[key]
+	if !ok {
+		return nil, objclient.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (client *memClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *memClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.down {
+		return nil, errors.New("unavailable")
+	}
+	old, ok := client.objects[key]
+	if o != nil && (ok && o.IfNoneMatch != "" || o.IfMatch != "" && (!ok || etagOf(old.data) != o.IfMatch)) {
+		return nil, fmt.Errorf("%w: %v", objclient.ErrPreconditionFailed, key)
+	}
+	obj := memObject{data: data, metadata: make(map[string]string)}
+	if o != nil {
+		for k, v := range o.Metadata {
+			obj.metadata[k] = v
+		}
+	}
+	client.objects[key] = obj
+	return &objclient.WriteResult{ETag: etagOf(data)}, nil
+}
+
+func (client *memClient) Exist(ctx context.Context, key string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, ok := client.objects[key]
+	return ok, nil
+}
+
+func (client *memClient) Remove(ctx context.Context, keys ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, key := range keys {
+		delete(client.objects, key)
+	}
+	return nil
+}
+
+func (client *memClient) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var items []objclient.ObjectItem
+	for key, obj := range client.objects {
+		if strings.HasPrefix(key, prefix) {
+			items = append(items, objclient.ObjectItem{Key: key, Size: int64(len(obj.data))})
+		}
+	}
+	return items, nil
+}
+
+func (client *memClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	obj, ok := client.objects[key]
+	if !ok {
+		return nil, objclient.ErrNotFound
+	}
+	return &objclient.ObjectInfo{Size: int64(len(obj.data)), ETag: etagOf(obj.data), Metadata: obj.metadata}, nil
+}
+
+func (client *memClient) Copy(ctx context.Context, src, dst string) error {
+	return errors.New("copy isn't supported")
+}
+
+func (client *memClient) Close() error {
+	return nil
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	mem := &memClient{objects: make(map[string]memObject)}
+	gc := New(mem, &Options{Owner: "gc", RetryInterval: 5 * time.Millisecond})
+	migrate := New(mem, &Options{Owner: "migrate", RetryInterval: 5 * time.Millisecond})
+
+	lease, err := gc.TryAcquire(ctx, "bucket", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	if _, err := migrate.TryAcquire(ctx, "bucket", time.Hour); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expect locked: %v", err)
+	}
+	if _, err := migrate.TryAcquire(ctx, "other", time.Hour); err != nil {
+		t.Fatalf("failed to acquire other lock: %v", err)
+	}
+	if err := lease.Renew(ctx); err != nil {
+		t.Fatalf("failed to renew: %v", err)
+	}
+
+	// The released lock is acquired by the waiting one.
+	acquired := make(chan *Lease, 1)
+	go func() {
+		lease, err := migrate.Acquire(ctx, "bucket", time.Hour)
+		if err != nil {
+			t.Errorf("failed to acquire: %v", err)
+		}
+		acquired <- lease
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := lease.Release(ctx); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	taken := <-acquired
+	if taken == nil || mem.objects["locks/bucket"].metadata[ownerMetaKey] != "migrate" {
+		t.Fatalf("invalid lease %+v", mem.objects["locks/bucket"])
+	}
+	if err := lease.Renew(ctx); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("expect lease lost: %v", err)
+	}
+
+	// The expired lease is taken over.
+	if err := taken.Release(ctx); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	expired, err := migrate.TryAcquire(ctx, "bucket", time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := gc.TryAcquire(ctx, "bucket", time.Hour); err != nil {
+		t.Fatalf("failed to take over expired lease: %v", err)
+	}
+	if err := expired.Release(ctx); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("expect lease lost: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := migrate.Acquire(ctx, "bucket", time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded: %v", err)
+	}
+}
+
+func TestLeaderElector(t *testing.T) {
+	mem := &memClient{objects: make(map[string]memObject)}
+	var (
+		mutex   sync.Mutex
+		elected []string
+		lost    []error
+	)
+	newElector := func(owner string) *LeaderElector {
+		locker := New(mem, &Options{Owner: owner, RetryInterval: 5 * time.Millisecond})
+		elector, err := NewLeaderElector(locker, "replication", &ElectorOptions{
+			TTL:           200 * time.Millisecond,
+			RenewInterval: 10 * time.Millisecond,
+			OnElected: func(ctx context.Context) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				elected = append(elected, owner)
+			},
+			OnLost: func(err error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				lost = append(lost, err)
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create elector: %v", err)
+		}
+		return elector
+	}
+	waitElected := func(n int) []string {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mutex.Lock()
+			got := append([]string(nil), elected...)
+			mutex.Unlock()
+			if len(got) >= n {
+				return got
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("invalid elections %v", got)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	a, b := newElector("a"), newElector("b")
+	defer b.Close()
+	first := waitElected(1)[0]
+	leader, other := a, b
+	if first == "b" {
+		leader, other = b, a
+	}
+	// The lease is renewed, so the leader is kept after the TTL.
+	time.Sleep(300 * time.Millisecond)
+	if got := waitElected(1); len(got) != 1 || !leader.IsLeader() || other.IsLeader() {
+		t.Fatalf("invalid elections %v", got)
+	}
+
+	// The lease taken over is lost.
+	mem.mutex.Lock()
+	mem.objects["locks/replication"] = memObject{data: []byte("stolen"), metadata: map[string]string{
+		expiresMetaKey: time.Now().Add(50 * time.Millisecond).Format(time.RFC3339Nano),
+	}}
+	mem.mutex.Unlock()
+	if got := waitElected(2); got[1] == "" {
+		t.Fatalf("invalid elections %v", got)
+	}
+	mutex.Lock()
+	if len(lost) != 1 || !errors.Is(lost[0], ErrLeaseLost) {
+		t.Fatalf("invalid losses %v", lost)
+	}
+	mutex.Unlock()
+
+	// The closed leader releases the lease for the others.
+	current := a
+	if b.IsLeader() {
+		current = b
+	}
+	current.Close()
+	if got := waitElected(3); got[2] == got[1] {
+		t.Fatalf("invalid elections %v", got)
+	}
+	a.Close()
+}
+
+func TestLeaderElectorExpiry(t *testing.T) {
+	mem := &memClient{objects: make(map[string]memObject)}
+	elected := make(chan context.Context, 1)
+	lost := make(chan error, 1)
+	elector, err := NewLeaderElector(New(mem, nil), "job", &ElectorOptions{
+		TTL:           300 * time.Millisecond,
+		RenewInterval: 100 * time.Millisecond,
+		Margin:        50 * time.Millisecond,
+		OnElected:     func(ctx context.Context) { elected <- ctx },
+		OnLost:        func(err error) { lost <- err },
+	})
+	if err != nil {
+		t.Fatalf("failed to create elector: %v", err)
+	}
+	defer elector.Close()
+	ctx := <-elected
+
+	// The leadership is given up before the lease expires without renewals.
+	mem.mutex.Lock()
+	mem.down = true
+	expires, err := time.Parse(time.RFC3339Nano, mem.objects["locks/job"].metadata[expiresMetaKey])
+	mem.mutex.Unlock()
+	if err != nil {
+		t.Fatalf("invalid expiry: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("leadership isn't lost")
+	}
+	if !time.Now().Before(expires) {
+		t.Fatalf("leadership is lost after the expiry %v", expires)
+	}
+	if err := <-lost; !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("invalid loss %v", err)
+	}
+	mem.mutex.Lock()
+	mem.down = false
+	mem.mutex.Unlock()
+}
+
+func TestLeaderElectorOptions(t *testing.T) {
+	locker := New(&memClient{objects: make(map[string]memObject)}, nil)
+	if _, err := NewLeaderElector(locker, "job", &ElectorOptions{TTL: time.Second, RenewInterval: time.Second}); err == nil {
+		t.Fatalf("invalid renew interval is accepted")
+	}
+	if _, err := NewLeaderElector(locker, "job", &ElectorOptions{TTL: time.Second, RenewInterval: 500 * time.Millisecond, Margin: 500 * time.Millisecond}); err == nil {
+		t.Fatalf("invalid margin is accepted")
+	}
+}
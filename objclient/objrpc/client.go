@@ -0,0 +1,319 @@
+package objrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/url"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+type ClientOptions struct {
+	// Token is sent as the bearer token of the authorization metadata.
+	Token string
+	// TLSConfig is the config of https addresses, nil uses the system
+	// roots.
+	TLSConfig *tls.Config
+	// DialOptions are passed to grpc.NewClient after the ones of the
+	// options above.
+	DialOptions []grpc.DialOption
+}
+
+// Client is the objclient.Client of the service served by NewServer. The
+// Progress options aren't supported.
+type Client struct {
+	conn    *grpc.ClientConn
+	service ObjectServiceClient
+}
+
+// tokenCredentials sends the bearer token of the calls.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (creds tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + creds.token}, nil
+}
+
+func (creds tokenCredentials) RequireTransportSecurity() bool {
+	return creds.secure
+}
+
+// NewClient returns the client of the service at addr, which is
+// "https://host:port", or "http://host:port" for cleartext HTTP/2. Nil opts
+// uses the default options.
+func NewClient(addr string, opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid address %v", addr)
+	}
+
+	var options []grpc.DialOption
+	switch u.Scheme {
+	case "https":
+		options = append(options, grpc.WithTransportCredentials(credentials.NewTLS(opts.TLSConfig)))
+	case "http":
+		options = append(options, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	default:
+		return nil, fmt.Errorf("invalid address %v", addr)
+	}
+	if opts.Token != "" {
+		options = append(options, grpc.WithPerRPCCredentials(tokenCredentials{token: opts.Token, secure: u.Scheme == "https"}))
+	}
+	conn, err := grpc.NewClient(u.Host, append(options, opts.DialOptions...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client of %v: %w", addr, err)
+	}
+	return &Client{conn: conn, service: NewObjectServiceClient(conn)}, nil
+}
+
+// chunkReader reads the data of the messages of Read responses. The call
+// is canceled by Close.
+type chunkReader struct {
+	stream grpc.ServerStreamingClient[Chunk]
+	cancel context.CancelFunc
+	data   []byte
+	err    error
+}
+
+func (reader *chunkReader) next() error {
+	c, err := reader.stream.Recv()
+	reader.data, reader.err = c.GetData(), fromStatus(err)
+	return reader.err
+}
+
+func (reader *chunkReader) Read(data []byte) (int, error) {
+	for len(reader.data) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		reader.next()
+	}
+	n := copy(data, reader.data)
+	reader.data = reader.data[n:]
+	return n, nil
+}
+
+func (reader *chunkReader) Close() error {
+	reader.cancel()
+	return nil
+}
+
+func (client *Client) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *Client) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	req := &ReadRequest{Key: key}
+	if o != nil {
+		req.Offset, req.Length, req.Process, req.ReadAhead, req.IfMatch = o.Offset, o.Length, o.Process, o.ReadAhead, o.IfMatch
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.service.Read(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, fromStatus(err)
+	}
+
+	// The first message is read, so the errors of opening the object are
+	// returned by Read.
+	reader := &chunkReader{stream: stream, cancel: cancel}
+	if err := reader.next(); err != nil && err != io.EOF {
+		cancel()
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (client *Client) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *Client) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	if o == nil {
+		o = &objclient.WriteOptions{}
+	}
+	first := &WriteRequest{Key: key, Size: -1, Metadata: o.Metadata, Expires: unixNano(o.Expires)}
+	if size, ok := writeSize(r, o); ok {
+		first.Size = size
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.service.Write(ctx)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if err := writeMessages(stream, first, r); err != nil {
+		return nil, fmt.Errorf("failed to write %v: %w", key, err)
+	}
+	result, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &objclient.WriteResult{ETag: result.Etag, VersionID: result.VersionId, LastModified: fromUnixNano(result.LastModified)}, nil
+}
+
+// writeMessages sends the first message and the data of r in chunks. If
+// the server fails the call, it returns the status.
+func writeMessages(stream grpc.ClientStreamingClient[WriteRequest, WriteResult], first *WriteRequest, r io.Reader) error {
+	send := func(req *WriteRequest) error {
+		err := stream.Send(req)
+		if err == io.EOF {
+			// The status of the stream is received by RecvMsg.
+			err = stream.RecvMsg(&WriteResult{})
+			if err == nil {
+				err = status.Error(codes.Internal, "call ends before the data is sent")
+			}
+		}
+		return fromStatus(err)
+	}
+	if err := send(first); err != nil {
+		return err
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if werr := send(&WriteRequest{Data: buf[:n]}); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeSize returns Size of o, or the size detected from r like S3
+// clients.
+func writeSize(r io.Reader, o *objclient.WriteOptions) (int64, bool) {
+	if o.Size != 0 {
+		return o.Size, true
+	}
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case io.Seeker:
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - offset, true
+	}
+	return 0, false
+}
+
+func (client *Client) Exist(ctx context.Context, key string) (bool, error) {
+	resp, err := client.service.Exist(ctx, &KeyRequest{Key: key})
+	if err != nil {
+		return false, fromStatus(err)
+	}
+	return resp.Exist, nil
+}
+
+func (client *Client) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	resp, err := client.service.Info(ctx, &KeyRequest{Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return &objclient.ObjectInfo{Size: resp.Size, LastModified: fromUnixNano(resp.LastModified), Metadata: resp.Metadata, ETag: resp.Etag}, nil
+}
+
+func (client *Client) Remove(ctx context.Context, keys ...string) error {
+	resp, err := client.service.Remove(ctx, &RemoveRequest{Keys: keys})
+	if err != nil {
+		return fromStatus(err)
+	}
+	if len(resp.Failures) == 0 {
+		return nil
+	}
+	results := make([]objclient.RemoveResult, len(resp.Failures))
+	for i, failure := range resp.Failures {
+		results[i] = objclient.RemoveResult{
+			Key: failure.Key,
+			Err: &statusError{status: status.New(codes.Unknown, failure.Message), kind: failure.Kind},
+		}
+	}
+	return &objclient.RemoveError{Results: results}
+}
+
+func (client *Client) Copy(ctx context.Context, src, dst string) error {
+	_, err := client.service.Copy(ctx, &CopyRequest{Src: src, Dst: dst})
+	return fromStatus(err)
+}
+
+func (client *Client) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	var items []objclient.ObjectItem
+	err := client.ListPages(ctx, prefix, "", func(page []objclient.ObjectItem) bool {
+		items = append(items, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ListPages implements objclient.PageLister by the pages streamed by the
+// server.
+func (client *Client) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []objclient.ObjectItem) bool) error {
+	// The rest of the stream is canceled if fn stops.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.service.List(ctx, &ListRequest{Prefix: prefix, StartAfter: startAfter})
+	if err != nil {
+		return fromStatus(err)
+	}
+
+	for {
+		page, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fromStatus(err)
+		}
+		items := make([]objclient.ObjectItem, len(page.Items))
+		for i, item := range page.Items {
+			items[i] = objclient.ObjectItem{
+				Key:          item.Key,
+				Size:         item.Size,
+				LastModified: fromUnixNano(item.LastModified),
+				ETag:         item.Etag,
+				StorageClass: item.StorageClass,
+			}
+		}
+		if !fn(items) {
+			return nil
+		}
+	}
+}
+
+// Close cancels the calls in flight, and closes the connections.
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
@@ -0,0 +1,366 @@
+// The service of objrpc, which mirrors objclient.Client. The Go code is
+// generated by protoc-gen-go and protoc-gen-go-grpc, see generate.go.
+//
+// The statuses of failed calls have an ErrorInfo detail of the domain
+// "objclient" if they're objclient errors, whose reason is the kind of the
+// error, e.g. "not-found" or "precondition-failed".
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: objclient.proto
+
+package objrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ObjectService_Read_FullMethodName   = "/objclient.v1.ObjectService/Read"
+	ObjectService_Write_FullMethodName  = "/objclient.v1.ObjectService/Write"
+	ObjectService_Exist_FullMethodName  = "/objclient.v1.ObjectService/Exist"
+	ObjectService_Info_FullMethodName   = "/objclient.v1.ObjectService/Info"
+	ObjectService_List_FullMethodName   = "/objclient.v1.ObjectService/List"
+	ObjectService_Remove_FullMethodName = "/objclient.v1.ObjectService/Remove"
+	ObjectService_Copy_FullMethodName   = "/objclient.v1.ObjectService/Copy"
+)
+
+// ObjectServiceClient is the client API for ObjectService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ObjectServiceClient interface {
+	// Read streams the data of the object, it fails with NOT_FOUND before
+	// any chunk if the key doesn't exist.
+	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error)
+	// The first message of Write has the key and options, the following
+	// ones have the data.
+	Write(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[WriteRequest, WriteResult], error)
+	Exist(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*ExistResponse, error)
+	Info(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*ObjectInfo, error)
+	// List streams the objects in pages, in the order of keys.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListResponse], error)
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+	Copy(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type objectServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewObjectServiceClient(cc grpc.ClientConnInterface) ObjectServiceClient {
+	return &objectServiceClient{cc}
+}
+
+func (c *objectServiceClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ObjectService_ServiceDesc.Streams[0], ObjectService_Read_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ReadRequest, Chunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ObjectService_ReadClient = grpc.ServerStreamingClient[Chunk]
+
+func (c *objectServiceClient) Write(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[WriteRequest, WriteResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ObjectService_ServiceDesc.Streams[1], ObjectService_Write_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WriteRequest, WriteResult]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ObjectService_WriteClient = grpc.ClientStreamingClient[WriteRequest, WriteResult]
+
+func (c *objectServiceClient) Exist(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*ExistResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExistResponse)
+	err := c.cc.Invoke(ctx, ObjectService_Exist_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *objectServiceClient) Info(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*ObjectInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ObjectInfo)
+	err := c.cc.Invoke(ctx, ObjectService_Info_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *objectServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ObjectService_ServiceDesc.Streams[2], ObjectService_List_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListRequest, ListResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ObjectService_ListClient = grpc.ServerStreamingClient[ListResponse]
+
+func (c *objectServiceClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, ObjectService_Remove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *objectServiceClient) Copy(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, ObjectService_Copy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ObjectServiceServer is the server API for ObjectService service.
+// All implementations must embed UnimplementedObjectServiceServer
+// for forward compatibility.
+type ObjectServiceServer interface {
+	// Read streams the data of the object, it fails with NOT_FOUND before
+	// any chunk if the key doesn't exist.
+	Read(*ReadRequest, grpc.ServerStreamingServer[Chunk]) error
+	// The first message of Write has the key and options, the following
+	// ones have the data.
+	Write(grpc.ClientStreamingServer[WriteRequest, WriteResult]) error
+	Exist(context.Context, *KeyRequest) (*ExistResponse, error)
+	Info(context.Context, *KeyRequest) (*ObjectInfo, error)
+	// List streams the objects in pages, in the order of keys.
+	List(*ListRequest, grpc.ServerStreamingServer[ListResponse]) error
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	Copy(context.Context, *CopyRequest) (*Empty, error)
+	mustEmbedUnimplementedObjectServiceServer()
+}
+
+// UnimplementedObjectServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedObjectServiceServer struct{}
+
+func (UnimplementedObjectServiceServer) Read(*ReadRequest, grpc.ServerStreamingServer[Chunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Read not implemented")
+}
+func (UnimplementedObjectServiceServer) Write(grpc.ClientStreamingServer[WriteRequest, WriteResult]) error {
+	return status.Errorf(codes.Unimplemented, "method Write not implemented")
+}
+func (UnimplementedObjectServiceServer) Exist(context.Context, *KeyRequest) (*ExistResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Exist not implemented")
+}
+func (UnimplementedObjectServiceServer) Info(context.Context, *KeyRequest) (*ObjectInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedObjectServiceServer) List(*ListRequest, grpc.ServerStreamingServer[ListResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedObjectServiceServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedObjectServiceServer) Copy(context.Context, *CopyRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Copy not implemented")
+}
+func (UnimplementedObjectServiceServer) mustEmbedUnimplementedObjectServiceServer() {}
+func (UnimplementedObjectServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeObjectServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ObjectServiceServer will
+// result in compilation errors.
+type UnsafeObjectServiceServer interface {
+	mustEmbedUnimplementedObjectServiceServer()
+}
+
+func RegisterObjectServiceServer(s grpc.ServiceRegistrar, srv ObjectServiceServer) {
+	// If the following call pancis, it indicates UnimplementedObjectServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ObjectService_ServiceDesc, srv)
+}
+
+func _ObjectService_Read_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ObjectServiceServer).Read(m, &grpc.GenericServerStream[ReadRequest, Chunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ObjectService_ReadServer = grpc.ServerStreamingServer[Chunk]
+
+func _ObjectService_Write_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ObjectServiceServer).Write(&grpc.GenericServerStream[WriteRequest, WriteResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ObjectService_WriteServer = grpc.ClientStreamingServer[WriteRequest, WriteResult]
+
+func _ObjectService_Exist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ObjectServiceServer).Exist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ObjectService_Exist_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ObjectServiceServer).Exist(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ObjectService_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ObjectServiceServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ObjectService_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ObjectServiceServer).Info(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ObjectService_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ObjectServiceServer).List(m, &grpc.GenericServerStream[ListRequest, ListResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ObjectService_ListServer = grpc.ServerStreamingServer[ListResponse]
+
+func _ObjectService_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ObjectServiceServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ObjectService_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ObjectServiceServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ObjectService_Copy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CopyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ObjectServiceServer).Copy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ObjectService_Copy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ObjectServiceServer).Copy(ctx, req.(*CopyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ObjectService_ServiceDesc is the grpc.ServiceDesc for ObjectService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ObjectService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "objclient.v1.ObjectService",
+	HandlerType: (*ObjectServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Exist",
+			Handler:    _ObjectService_Exist_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _ObjectService_Info_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _ObjectService_Remove_Handler,
+		},
+		{
+			MethodName: "Copy",
+			Handler:    _ObjectService_Copy_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Read",
+			Handler:       _ObjectService_Read_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Write",
+			Handler:       _ObjectService_Write_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "List",
+			Handler:       _ObjectService_List_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "objclient.proto",
+}
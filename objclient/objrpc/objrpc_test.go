@@ -0,0 +1,138 @@
+package objrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/objsync"
+)
+
+func TestRemoteClient(t *testing.T) {
+	ctx := context.Background()
+	dir := objsync.NewDirClient(t.TempDir())
+	// The gRPC server is served as the http.Handler of TLS.
+	server := httptest.NewUnstartedServer(NewServer(dir, &ServerOptions{Tokens: []string{"token"}}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	client, err := NewClient(server.URL, &ClientOptions{Token: "token", TLSConfig: tlsConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := client.Write(ctx, "a/b", bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	// The size of the reader isn't known.
+	if err := client.Write(ctx, "a/c", io.MultiReader(strings.NewReader("c")), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Write(ctx, "a/empty", strings.NewReader(""), nil); err != nil {
+		t.Fatalf("failed to write empty object: %v", err)
+	}
+
+	r, err := client.Read(ctx, "a/b")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	read, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("invalid data read: %v bytes, %v", len(read), err)
+	}
+	r, err = client.ReadWithOptions(ctx, "a/b", &objclient.ReadOptions{Offset: 5, Length: 3})
+	if err != nil {
+		t.Fatalf("failed to read range: %v", err)
+	}
+	read, _ = io.ReadAll(r)
+	r.Close()
+	if string(read) != "567" {
+		t.Fatalf("invalid range read %q", read)
+	}
+	if _, err := client.Read(ctx, "missing"); !errors.Is(err, objclient.ErrNotFound) {
+		t.Fatalf("missing object is read: %v", err)
+	}
+
+	if info, err := client.Info(ctx, "a/c"); err != nil || info.Size != 1 {
+		t.Fatalf("invalid info: %+v, %v", info, err)
+	}
+	if exist, err := client.Exist(ctx, "a/empty"); err != nil || !exist {
+		t.Fatalf("invalid exist: %v, %v", exist, err)
+	}
+	if err := client.Copy(ctx, "a/c", "d"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	items, err := objclient.ListWithOptions(ctx, client, "", &objclient.ListOptions{StartAfter: "a/b"})
+	if err != nil || len(items) != 3 || items[0].Key != "a/c" || items[1].Key != "a/empty" || items[2].Key != "d" {
+		t.Fatalf("invalid list: %+v, %v", items, err)
+	}
+
+	err = client.Remove(ctx, "a/b", "missing", "/invalid")
+	var rerr *objclient.RemoveError
+	if !errors.As(err, &rerr) || len(rerr.Results) != 1 || rerr.Results[0].Key != "/invalid" ||
+		!errors.Is(rerr.Results[0].Err, objclient.ErrInvalidKey) {
+		t.Fatalf("invalid remove error %v", err)
+	}
+	if exist, _ := dir.Exist(ctx, "a/b"); exist {
+		t.Fatalf("removed object exists")
+	}
+
+	denied, err := NewClient(server.URL, &ClientOptions{Token: "wrong", TLSConfig: tlsConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer denied.Close()
+	if _, err := denied.Info(ctx, "a/c"); !errors.Is(err, objclient.ErrAccessDenied) {
+		t.Fatalf("request of wrong token isn't denied: %v", err)
+	}
+	if err := denied.Write(ctx, "e", strings.NewReader("e"), nil); !errors.Is(err, objclient.ErrAccessDenied) {
+		t.Fatalf("write of wrong token isn't denied: %v", err)
+	}
+}
+
+func TestRemoteClientCleartext(t *testing.T) {
+	ctx := context.Background()
+	dir := objsync.NewDirClient(t.TempDir())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(dir, nil)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client, err := NewClient("http://"+listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The object isn't buffered by the flow control windows.
+	data := make([]byte, 16<<20)
+	if err := client.Write(ctx, "a", bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	r, err := client.Read(ctx, "a")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	defer r.Close()
+
+	client.Close()
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatalf("read isn't canceled by Close")
+	}
+	if _, err := client.Info(ctx, "a"); err == nil {
+		t.Fatalf("closed client is used")
+	}
+}
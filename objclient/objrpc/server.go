@@ -0,0 +1,265 @@
+package objrpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+type ServerOptions struct {
+	// Tokens are the accepted bearer tokens of the authorization metadata.
+	// Requests aren't authenticated if it's empty.
+	Tokens []string
+	// Logger logs the failures of unknown kinds if it's set.
+	Logger *slog.Logger
+	// GRPCOptions are passed to grpc.NewServer, e.g. grpc.Creds for TLS.
+	GRPCOptions []grpc.ServerOption
+}
+
+type server struct {
+	UnimplementedObjectServiceServer
+	client objclient.Client
+	opts   ServerOptions
+}
+
+// NewServer returns the gRPC server of client serving the ObjectService of
+// objclient.proto, e.g. by Serve of a listener. The server is an
+// http.Handler too, which can be served by http.Server with TLS. The
+// objects of edge nodes can be limited by serving NewReadOnlyClient or
+// WithPrefix of the client. Nil opts doesn't authenticate requests.
+func NewServer(client objclient.Client, opts *ServerOptions) *grpc.Server {
+	s := &server{client: client}
+	if opts != nil {
+		s.opts = *opts
+	}
+	options := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	}, s.opts.GRPCOptions...)
+	gs := grpc.NewServer(options...)
+	RegisterObjectServiceServer(gs, s)
+	return gs
+}
+
+func (s *server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	return resp, s.status(info.FullMethod, err)
+}
+
+func (s *server) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(stream.Context()); err != nil {
+		return err
+	}
+	return s.status(info.FullMethod, handler(srv, stream))
+}
+
+func (s *server) authenticate(ctx context.Context) error {
+	if len(s.opts.Tokens) == 0 {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	for _, t := range s.opts.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return nil
+		}
+	}
+	return newStatus(codes.Unauthenticated, "access-denied", "invalid token").Err()
+}
+
+// status returns the status error of the error of a call.
+func (s *server) status(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	st := toStatus(err)
+	if st.Code() == codes.Unknown && s.opts.Logger != nil {
+		s.opts.Logger.Error("objrpc call failed", "method", method, "err", err)
+	}
+	return st.Err()
+}
+
+func (s *server) Exist(ctx context.Context, req *KeyRequest) (*ExistResponse, error) {
+	exist, err := s.client.Exist(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &ExistResponse{Exist: exist}, nil
+}
+
+func (s *server) Info(ctx context.Context, req *KeyRequest) (*ObjectInfo, error) {
+	info, err := s.client.Info(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Size: info.Size, LastModified: unixNano(info.LastModified), Metadata: info.Metadata, Etag: info.ETag}, nil
+}
+
+func (s *server) Copy(ctx context.Context, req *CopyRequest) (*Empty, error) {
+	if err := s.client.Copy(ctx, req.Src, req.Dst); err != nil {
+		return nil, err
+	}
+	return &Empty{}, nil
+}
+
+func (s *server) Read(req *ReadRequest, stream grpc.ServerStreamingServer[Chunk]) error {
+	r, err := s.client.ReadWithOptions(stream.Context(), req.Key, &objclient.ReadOptions{
+		Offset:    req.Offset,
+		Length:    req.Length,
+		Process:   req.Process,
+		ReadAhead: req.ReadAhead,
+		IfMatch:   req.IfMatch,
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := stream.Send(&Chunk{Data: buf[:n]}); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeBody reads the data of the write messages after the first one.
+type writeBody struct {
+	stream grpc.ClientStreamingServer[WriteRequest, WriteResult]
+	data   []byte
+	err    error
+}
+
+func (body *writeBody) Read(data []byte) (int, error) {
+	for len(body.data) == 0 {
+		if body.err != nil {
+			return 0, body.err
+		}
+		req, err := body.stream.Recv()
+		body.data, body.err = req.GetData(), err
+	}
+	n := copy(data, body.data)
+	body.data = body.data[n:]
+	return n, nil
+}
+
+// emptyBody is the body of size 0, whose size is detected by the Len method
+// like bytes.Reader.
+type emptyBody struct {
+	*writeBody
+}
+
+func (body emptyBody) Len() int {
+	return 0
+}
+
+func (s *server) Write(stream grpc.ClientStreamingServer[WriteRequest, WriteResult]) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "missing request message")
+	}
+	if err != nil {
+		return err
+	}
+	o := &objclient.WriteOptions{Metadata: req.Metadata, Expires: fromUnixNano(req.Expires)}
+	var r io.Reader = &writeBody{stream: stream, data: req.Data}
+	switch {
+	case req.Size == 0:
+		r = emptyBody{r.(*writeBody)}
+	default:
+		// Negative sizes are unknown.
+		o.Size = req.Size
+	}
+	result, err := s.client.WriteWithResult(stream.Context(), req.Key, r, o)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&WriteResult{Etag: result.ETag, VersionId: result.VersionID, LastModified: unixNano(result.LastModified)})
+}
+
+func (s *server) List(req *ListRequest, stream grpc.ServerStreamingServer[ListResponse]) error {
+	ctx := stream.Context()
+	send := func(items []objclient.ObjectItem) error {
+		resp := &ListResponse{Items: make([]*ObjectItem, 0, len(items))}
+		for _, item := range items {
+			if item.Key > req.StartAfter {
+				resp.Items = append(resp.Items, &ObjectItem{
+					Key:          item.Key,
+					Size:         item.Size,
+					LastModified: unixNano(item.LastModified),
+					Etag:         item.ETag,
+					StorageClass: item.StorageClass,
+				})
+			}
+		}
+		if len(resp.Items) == 0 {
+			return nil
+		}
+		return stream.Send(resp)
+	}
+
+	if lister, ok := objclient.AsPageLister(s.client); ok {
+		var werr error
+		err := lister.ListPages(ctx, req.Prefix, req.StartAfter, func(items []objclient.ObjectItem) bool {
+			werr = send(items)
+			return werr == nil
+		})
+		return errors.Join(err, werr)
+	}
+
+	items, err := objclient.ListWithOptions(ctx, s.client, req.Prefix, &objclient.ListOptions{StartAfter: req.StartAfter})
+	if err != nil {
+		return err
+	}
+	for len(items) > 0 {
+		n := min(len(items), listPageSize)
+		if err := send(items[:n]); err != nil {
+			return err
+		}
+		items = items[n:]
+	}
+	return nil
+}
+
+// Remove returns the failures of a RemoveError in the response, instead of
+// the status.
+func (s *server) Remove(ctx context.Context, req *RemoveRequest) (*RemoveResponse, error) {
+	err := s.client.Remove(ctx, req.Keys...)
+	var rerr *objclient.RemoveError
+	if err != nil && !errors.As(err, &rerr) {
+		return nil, err
+	}
+	resp := &RemoveResponse{}
+	if rerr != nil {
+		for _, result := range rerr.Results {
+			kind, _ := errorKind(result.Err)
+			resp.Failures = append(resp.Failures, &RemoveFailure{Key: result.Key, Kind: kind, Message: result.Err.Error()})
+		}
+	}
+	return resp, nil
+}
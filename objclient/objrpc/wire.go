@@ -0,0 +1,140 @@
+package objrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+const (
+	chunkSize    = 256 << 10
+	listPageSize = 1000
+
+	// errorDomain is the domain of the ErrorInfo details of objclient
+	// errors, whose reasons are the kinds.
+	errorDomain = "objclient"
+)
+
+var errorKinds = []struct {
+	kind string
+	err  error
+	code codes.Code
+}{
+	{"not-found", objclient.ErrNotFound, codes.NotFound},
+	{"bucket-not-found", objclient.ErrBucketNotFound, codes.NotFound},
+	{"access-denied", objclient.ErrAccessDenied, codes.PermissionDenied},
+	{"invalid-key", objclient.ErrInvalidKey, codes.InvalidArgument},
+	{"invalid-metadata", objclient.ErrInvalidMetadata, codes.InvalidArgument},
+	{"precondition-failed", objclient.ErrPreconditionFailed, codes.FailedPrecondition},
+	{"object-changed", objclient.ErrObjectChanged, codes.Aborted},
+	{"not-verified", objclient.ErrNotVerified, codes.DataLoss},
+	{"quota-exceeded", objclient.ErrQuotaExceeded, codes.ResourceExhausted},
+	{"throttled", objclient.ErrThrottled, codes.ResourceExhausted},
+	{"stalled", objclient.ErrStalled, codes.Unavailable},
+	{"unreachable", objclient.ErrUnreachable, codes.Unavailable},
+	{"canceled", context.Canceled, codes.Canceled},
+	{"deadline-exceeded", context.DeadlineExceeded, codes.DeadlineExceeded},
+}
+
+// defaultKinds are the kinds of the statuses without ErrorInfo details,
+// e.g. the failures of connections.
+var defaultKinds = map[codes.Code]string{
+	codes.Unavailable:      "unreachable",
+	codes.Canceled:         "canceled",
+	codes.DeadlineExceeded: "deadline-exceeded",
+}
+
+// statusError is a failed call. It wraps the objclient sentinel of its
+// kind, if any.
+type statusError struct {
+	status *status.Status
+	kind   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("rpc error: code %v: %v", e.status.Code(), e.status.Message())
+}
+
+func (e *statusError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+func (e *statusError) Unwrap() error {
+	for _, kind := range errorKinds {
+		if kind.kind == e.kind {
+			return kind.err
+		}
+	}
+	return nil
+}
+
+// errorKind returns the kind of the objclient error err, or "" if it has
+// none.
+func errorKind(err error) (string, codes.Code) {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			return kind.kind, kind.code
+		}
+	}
+	return "", codes.Unknown
+}
+
+// toStatus returns the status of the error of the server.
+func toStatus(err error) *status.Status {
+	if kind, code := errorKind(err); kind != "" {
+		return newStatus(code, kind, err.Error())
+	}
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+// newStatus returns the status of the objclient error of kind.
+func newStatus(code codes.Code, kind, message string) *status.Status {
+	s := status.New(code, message)
+	if detailed, err := s.WithDetails(&errdetails.ErrorInfo{Reason: kind, Domain: errorDomain}); err == nil {
+		return detailed
+	}
+	return s
+}
+
+// fromStatus returns the error of the status err of a call, which wraps
+// the objclient sentinel of its kind. Other errors are returned as is.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	kind := defaultKinds[s.Code()]
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			kind = info.Reason
+		}
+	}
+	return &statusError{status: s, kind: kind}
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,248 @@
+package objsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+// tempPattern is the pattern of the files being written by DirClient, they
+// aren't listed.
+const tempPattern = ".objsync-*.tmp"
+
+// DirClient is a client of the files under a local directory, keys are the
+// paths relative to it with "/" separators. Objects have no ETags or
+// metadata. Keys which aren't valid for ValidateKey are rejected.
+type DirClient struct {
+	root string
+}
+
+// NewDirClient returns a client of the files under root.
+func NewDirClient(root string) *DirClient {
+	return &DirClient{root: root}
+}
+
+func (client *DirClient) path(key string) (string, error) {
+	if err := objclient.ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(client.root, filepath.FromSlash(key)), nil
+}
+
+func notFound(key string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %v", objclient.ErrNotFound, key)
+	}
+	return err
+}
+
+func (client *DirClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *DirClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	if o != nil && (o.Process != "" || o.IfMatch != "") {
+		return nil, errors.New("the process and if-match options aren't supported")
+	}
+	path, err := client.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	if o == nil || o.Offset == 0 && o.Length == 0 {
+		return f, nil
+	}
+	if o.Offset < 0 || o.Length < 0 {
+		f.Close()
+		return nil, errors.New("invalid range")
+	}
+	length := o.Length
+	if length == 0 {
+		length = 1<<63 - 1 - o.Offset
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, o.Offset, length), f}, nil
+}
+
+func (client *DirClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// WriteWithResult writes to a temporary file which is renamed to the file
+// of key, so readers never see partial files.
+func (client *DirClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	path, err := client.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory of %v: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file of %v: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, contextReader{ctx, r})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %v: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to write %v: %w", key, err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &objclient.WriteResult{LastModified: stat.ModTime()}, nil
+}
+
+// contextReader fails reads after ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(data []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(data)
+}
+
+func (client *DirClient) Exist(ctx context.Context, key string) (bool, error) {
+	_, err := client.Info(ctx, key)
+	if errors.Is(err, objclient.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Remove removes the files of keys, and their parent directories which
+// become empty. Missing keys aren't errors.
+func (client *DirClient) Remove(ctx context.Context, keys ...string) error {
+	var results []objclient.RemoveResult
+	for _, key := range keys {
+		if err := client.remove(key); err != nil {
+			results = append(results, objclient.RemoveResult{Key: key, Err: err})
+		}
+	}
+	if len(results) > 0 {
+		return &objclient.RemoveError{Results: results}
+	}
+	return nil
+}
+
+func (client *DirClient) remove(key string) error {
+	path, err := client.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	root := filepath.Clean(client.root)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		// It fails if the directory isn't empty.
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List returns the files whose keys have prefix in the order of keys.
+func (client *DirClient) List(ctx context.Context, prefix string) ([]objclient.ObjectItem, error) {
+	var items []objclient.ObjectItem
+	err := filepath.WalkDir(client.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == client.root {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(client.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			// Directories which can't contain keys of prefix are skipped.
+			if key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		if ok, _ := filepath.Match(tempPattern, entry.Name()); ok {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		items = append(items, objclient.ObjectItem{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// WalkDir visits "a/b" before "a.b", which isn't the order of keys.
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, nil
+}
+
+func (client *DirClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	path, err := client.path(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %v isn't a file", objclient.ErrNotFound, key)
+	}
+	return &objclient.ObjectInfo{
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+		Metadata:     make(map[string]string),
+	}, nil
+}
+
+func (client *DirClient) Copy(ctx context.Context, src, dst string) error {
+	r, err := client.Read(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return client.Write(ctx, dst, r, nil)
+}
+
+func (client *DirClient) Close() error {
+	return nil
+}
@@ -0,0 +1,380 @@
+package objsync
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+type MigrateOptions struct {
+	// StateFile records the migrated objects. A migration with the file of
+	// an interrupted one skips the objects recorded, if they are still in
+	// the destination with the same size.
+	StateFile string
+	// Concurrency is the number of objects migrated at the same time,
+	// defaults to 8.
+	Concurrency int
+	// Retries is the number of retries of each failed object, defaults to
+	// 3. Backoff is the delay before the first retry, which is doubled for
+	// the next ones, defaults to 1 second.
+	Retries int
+	Backoff time.Duration
+}
+
+// Reconciliation is the final report of a migration, which compares the
+// listings of both clients after migrating.
+type Reconciliation struct {
+	SourceObjects int
+	SourceBytes   int64
+	// Migrated and MigratedBytes are the objects copied by this migration,
+	// Resumed are the ones skipped by the state file.
+	Migrated      int
+	MigratedBytes int64
+	Resumed       int
+	// Failed are the objects which failed after the retries.
+	Failed map[string]error
+	// Missing are the keys of the source which are missing in the
+	// destination, or of different sizes.
+	Missing []string
+}
+
+// OK returns whether all the objects are migrated.
+func (r *Reconciliation) OK() bool {
+	return len(r.Failed) == 0 && len(r.Missing) == 0
+}
+
+// migrated is a line of state files.
+type migrated struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	MD5  string `json:"md5"`
+}
+
+type migrator struct {
+	src, dst objclient.Client
+	opts     MigrateOptions
+
+	mutex  sync.Mutex
+	state  *os.File
+	report *Reconciliation
+}
+
+// Migrate copies all the objects of prefix from src to dst. Each object is
+// verified by comparing the MD5 of the data read from src with its ETag, if
+// it's the MD5, and with the object of dst, by its ETag or reading it back.
+// Failed objects are retried. The returned reconciliation is valid even if
+// it fails, and the error is a *objclient.MultiError if some objects
+// failed. The options can be nil.
+func Migrate(ctx context.Context, src, dst objclient.Client, prefix string, opts *MigrateOptions) (*Reconciliation, error) {
+	o := MigrateOptions{Concurrency: 8, Retries: 3, Backoff: time.Second}
+	if opts != nil {
+		o.StateFile = opts.StateFile
+		if opts.Concurrency > 0 {
+			o.Concurrency = opts.Concurrency
+		}
+		if opts.Retries > 0 {
+			o.Retries = opts.Retries
+		}
+		if opts.Backoff > 0 {
+			o.Backoff = opts.Backoff
+		}
+	}
+
+	m := &migrator{
+		src:    src,
+		dst:    objclient.NewVerifyingClient(dst, &objclient.VerifyOptions{Checksum: true}),
+		opts:   o,
+		report: &Reconciliation{Failed: make(map[string]error)},
+	}
+	done := make(map[string]migrated)
+	if o.StateFile != "" {
+		var err error
+		if done, err = loadState(o.StateFile); err != nil {
+			return nil, err
+		}
+		m.state, err = os.OpenFile(o.StateFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state file: %w", err)
+		}
+		defer m.state.Close()
+	}
+
+	items, err := src.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source: %w", err)
+	}
+	dstItems, err := dst.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destination: %w", err)
+	}
+	existing := make(map[string]int64, len(dstItems))
+	for _, item := range dstItems {
+		existing[item.Key] = item.Size
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan objclient.ObjectItem)
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				m.migrate(ctx, item)
+			}
+		}()
+	}
+	for _, item := range items {
+		m.report.SourceObjects++
+		m.report.SourceBytes += item.Size
+		if state, ok := done[item.Key]; ok && state.Size == item.Size {
+			if size, ok := existing[item.Key]; ok && size == item.Size {
+				m.report.Resumed++
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			m.fail(item.Key, ctx.Err())
+			continue
+		}
+		jobs <- item
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := m.reconcile(ctx, items, prefix); err != nil {
+		return m.report, err
+	}
+	if len(m.report.Failed) > 0 {
+		return m.report, &objclient.MultiError{Errors: m.report.Failed}
+	}
+	return m.report, nil
+}
+
+// loadState returns the objects in the state file, which may not exist.
+// The last line may be partial if the migration was killed.
+func loadState(path string) (map[string]migrated, error) {
+	done := make(map[string]migrated)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var state migrated
+		if json.Unmarshal(scanner.Bytes(), &state) == nil && state.Key != "" {
+			done[state.Key] = state
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	return done, nil
+}
+
+func (m *migrator) fail(key string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.report.Failed[key] = err
+}
+
+// migrate copies item with the retries, and records it.
+func (m *migrator) migrate(ctx context.Context, item objclient.ObjectItem) {
+	backoff := m.opts.Backoff
+	var (
+		sum string
+		err error
+	)
+	for i := 0; i <= m.opts.Retries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+			backoff *= 2
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		if sum, err = m.copy(ctx, item.Key); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		m.fail(item.Key, err)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.report.Migrated++
+	m.report.MigratedBytes += item.Size
+	if m.state != nil {
+		line, _ := json.Marshal(migrated{Key: item.Key, Size: item.Size, MD5: sum})
+		if _, err := m.state.Write(append(line, '\n')); err != nil {
+			m.report.Failed[item.Key] = fmt.Errorf("failed to record migrated object: %w", err)
+		}
+	}
+}
+
+// errETagMismatch is returned by the copies whose data read from the
+// source isn't of the MD5 of the ETag.
+var errETagMismatch = errors.New("md5 isn't the source etag")
+
+// etagReader hashes the data of r, and fails with errETagMismatch once
+// size bytes are read if the MD5 of them isn't etag, so the write of them
+// fails before it's done.
+type etagReader struct {
+	r     io.Reader
+	hash  hash.Hash
+	size  int64
+	read  int64
+	etag  string
+	check bool
+	// mismatch is set with errETagMismatch, which the destination may not
+	// wrap.
+	mismatch bool
+}
+
+func (reader *etagReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.hash.Write(data[:n])
+	reader.read += int64(n)
+	if reader.check && (err == io.EOF || reader.read >= reader.size) && !strings.EqualFold(reader.sum(), reader.etag) {
+		reader.mismatch = true
+		err = errETagMismatch
+	}
+	return n, err
+}
+
+func (reader *etagReader) sum() string {
+	return hex.EncodeToString(reader.hash.Sum(nil))
+}
+
+// copy copies key, and returns the MD5 of it. The data is compared with
+// the ETag of the source if it may be the MD5. If they don't match but the
+// data is read the same again, the ETag isn't the MD5, e.g. of encrypted
+// or appendable objects, and the data is copied without the comparison.
+func (m *migrator) copy(ctx context.Context, key string) (string, error) {
+	info, err := m.src.Info(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	sum, err := m.copyData(ctx, key, info, info.Size > 0 && objclient.ComparableETag(info.ETag))
+	if !errors.Is(err, errETagMismatch) {
+		return sum, err
+	}
+
+	again, err := m.readSum(ctx, key, info)
+	if err != nil {
+		return "", err
+	}
+	if again != sum {
+		return "", fmt.Errorf("md5 of %v is %v instead of the source etag %v", key, sum, info.ETag)
+	}
+	return m.copyData(ctx, key, info, false)
+}
+
+// copyData copies the data of the source object of info, which fails with
+// errETagMismatch if the ETag is checked. It returns the MD5 of the data.
+func (m *migrator) copyData(ctx context.Context, key string, info *objclient.ObjectInfo, check bool) (string, error) {
+	r, err := m.src.ReadWithOptions(ctx, key, &objclient.ReadOptions{IfMatch: info.ETag})
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	reader := &etagReader{r: r, hash: md5.New(), size: info.Size, etag: info.ETag, check: check}
+	err = m.dst.Write(ctx, key, reader, &objclient.WriteOptions{Size: info.Size, Metadata: info.Metadata})
+	if reader.mismatch {
+		return reader.sum(), errETagMismatch
+	}
+	if err != nil {
+		return "", err
+	}
+	return reader.sum(), nil
+}
+
+// readSum returns the MD5 of the source object of info.
+func (m *migrator) readSum(ctx context.Context, key string, info *objclient.ObjectInfo) (string, error) {
+	r, err := m.src.ReadWithOptions(ctx, key, &objclient.ReadOptions{IfMatch: info.ETag})
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// reconcile lists the destination again, and reports the missing objects.
+func (m *migrator) reconcile(ctx context.Context, items []objclient.ObjectItem, prefix string) error {
+	dstItems, err := m.dst.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list destination for reconciliation: %w", err)
+	}
+	sizes := make(map[string]int64, len(dstItems))
+	for _, item := range dstItems {
+		sizes[item.Key] = item.Size
+	}
+	for _, item := range items {
+		if size, ok := sizes[item.Key]; !ok || size != item.Size {
+			m.report.Missing = append(m.report.Missing, item.Key)
+		}
+	}
+	return nil
+}
+
+// WriteReconciliation writes the summary of r, and the failed and missing
+// keys.
+func WriteReconciliation(w io.Writer, r *Reconciliation) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "source\t%v objects\t%v bytes\n", r.SourceObjects, r.SourceBytes)
+	fmt.Fprintf(tw, "migrated\t%v objects\t%v bytes\n", r.Migrated, r.MigratedBytes)
+	fmt.Fprintf(tw, "resumed\t%v objects\t\n", r.Resumed)
+	fmt.Fprintf(tw, "failed\t%v objects\t\n", len(r.Failed))
+	fmt.Fprintf(tw, "missing\t%v objects\t\n", len(r.Missing))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(r.Failed))
+	for key := range r.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, "failed %v: %v\n", key, r.Failed[key]); err != nil {
+			return err
+		}
+	}
+	for _, key := range r.Missing {
+		if _, err := fmt.Fprintf(w, "missing %v\n", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
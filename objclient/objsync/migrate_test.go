@@ -0,0 +1,137 @@
+package objsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+// failingClient fails the writes of a key.
+type failingClient struct {
+	objclient.Client
+	key    string
+	writes int
+}
+
+func (client *failingClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *failingClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	if key == client.key {
+		client.writes++
+		return nil, errors.New("unavailable")
+	}
+	return client.Client.WriteWithResult(ctx, key, r, o)
+}
+
+func TestMigrate(t *testing.T) {
+	src, dst := NewDirClient(t.TempDir()), NewDirClient(t.TempDir())
+	write(t, src, "data/a", "a")
+	write(t, src, "data/b", "bb")
+	write(t, src, "data/c", "ccc")
+	opts := &MigrateOptions{
+		StateFile:   filepath.Join(t.TempDir(), "state"),
+		Concurrency: 2,
+		Retries:     1,
+		Backoff:     time.Millisecond,
+	}
+
+	failing := &failingClient{Client: dst, key: "data/b"}
+	report, err := Migrate(context.Background(), src, failing, "data/", opts)
+	var merr *objclient.MultiError
+	if !errors.As(err, &merr) || len(merr.Errors) != 1 || failing.writes != 2 {
+		t.Fatalf("failed object isn't reported: %v, %v writes", err, failing.writes)
+	}
+	if report.OK() || report.Migrated != 2 || !reflect.DeepEqual(report.Missing, []string{"data/b"}) {
+		t.Fatalf("invalid report: %+v", report)
+	}
+
+	// The migrated objects are skipped.
+	report, err = Migrate(context.Background(), src, dst, "data/", opts)
+	if err != nil || !report.OK() || report.Resumed != 2 || report.Migrated != 1 || report.MigratedBytes != 2 {
+		t.Fatalf("invalid resumed migration: %+v, %v", report, err)
+	}
+	var buf bytes.Buffer
+	if err := WriteReconciliation(&buf, report); err != nil || !strings.Contains(buf.String(), "resumed   2 objects") {
+		t.Fatalf("invalid written reconciliation: %q, %v", buf.String(), err)
+	}
+}
+
+// etagClient reports etag as the ETags of the objects, and corrupts the
+// first corrupt reads differently.
+type etagClient struct {
+	objclient.Client
+	etag    string
+	corrupt int
+}
+
+func (client *etagClient) Info(ctx context.Context, key string) (*objclient.ObjectInfo, error) {
+	info, err := client.Client.Info(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	info.ETag = client.etag
+	return info, nil
+}
+
+func (client *etagClient) ReadWithOptions(ctx context.Context, key string, o *objclient.ReadOptions) (io.ReadCloser, error) {
+	r, err := client.Client.ReadWithOptions(ctx, key, nil)
+	if err != nil || client.corrupt == 0 {
+		return r, err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	data[0] ^= byte(client.corrupt)
+	client.corrupt--
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestMigrateETags(t *testing.T) {
+	ctx := context.Background()
+	dir := NewDirClient(t.TempDir())
+	write(t, dir, "a", "data")
+	opts := &MigrateOptions{Retries: 1, Backoff: time.Millisecond}
+
+	// The ETags of encrypted objects aren't the MD5.
+	dst := NewDirClient(t.TempDir())
+	src := &etagClient{Client: dir, etag: "0123456789abcdef0123456789abcdef"}
+	if report, err := Migrate(ctx, src, dst, "", opts); err != nil || !report.OK() {
+		t.Fatalf("failed to migrate objects of other etags: %+v, %v", report, err)
+	}
+
+	// The corrupted reads aren't written, and they are retried.
+	dst = NewDirClient(t.TempDir())
+	src = &etagClient{Client: dir, etag: "8d777f385d3dfec8815d20f7496026dc", corrupt: 100}
+	if _, err := Migrate(ctx, src, dst, "", opts); err == nil {
+		t.Fatalf("corrupted read is migrated")
+	}
+	if exist, _ := dst.Exist(ctx, "a"); exist {
+		t.Fatalf("object of corrupted read is written")
+	}
+	src.corrupt = 1
+	if report, err := Migrate(ctx, src, dst, "", opts); err != nil || !report.OK() {
+		t.Fatalf("failed to migrate after corrupted read: %+v, %v", report, err)
+	}
+	r, err := dst.Read(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "data" {
+		t.Fatalf("corrupted data is migrated: %q", data)
+	}
+}
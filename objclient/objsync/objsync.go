@@ -0,0 +1,248 @@
+// Package objsync synchronizes the objects of a prefix from one client to
+// another one way, like rsync. Local directories are synchronized by
+// DirClient.
+package objsync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+// Compare is the set of attributes which decide whether an object of the
+// destination is out of date.
+type Compare int
+
+const (
+	// CompareSize copies objects of different sizes.
+	CompareSize Compare = 1 << iota
+	// CompareModTime copies objects modified after the ones of the
+	// destination, which are modified by the copies.
+	CompareModTime
+	// CompareETag copies objects of different ETags, the info of both
+	// objects is got for it. Objects without ETags, e.g. of DirClient, and
+	// multipart uploads whose ETags aren't comparable between backends,
+	// are compared by the other attributes.
+	CompareETag
+)
+
+type Options struct {
+	// Compare defaults to CompareSize|CompareModTime.
+	Compare Compare
+	// Concurrency is the number of objects copied at the same time,
+	// defaults to 8.
+	Concurrency int
+	// Delete removes the objects of the destination which aren't in the
+	// source.
+	Delete bool
+	// DryRun only reports the actions without running them.
+	DryRun bool
+}
+
+type ActionOp string
+
+const (
+	ActionCopy   ActionOp = "copy"
+	ActionDelete ActionOp = "delete"
+)
+
+// Action is a copy or deletion of a key.
+type Action struct {
+	Op     ActionOp
+	Key    string
+	Size   int64
+	Reason string
+}
+
+// Report is the actions of a sync in the order of keys. Failed actions are
+// also reported by the returned *objclient.MultiError.
+type Report struct {
+	Actions []Action
+	// Skipped is the number of objects which are up to date.
+	Skipped int
+	// Bytes is the total size of copied objects, or the ones to be copied
+	// by dry runs.
+	Bytes int64
+}
+
+// Sync copies the objects of prefix from src to dst which are missing or
+// out of date in dst, and removes the extraneous ones if Delete is set. The
+// options can be nil. It returns the report even if some objects failed,
+// which are reported by a *objclient.MultiError.
+func Sync(ctx context.Context, src, dst objclient.Client, prefix string, opts *Options) (*Report, error) {
+	o := Options{Compare: CompareSize | CompareModTime, Concurrency: 8}
+	if opts != nil {
+		if opts.Compare != 0 {
+			o.Compare = opts.Compare
+		}
+		if opts.Concurrency > 0 {
+			o.Concurrency = opts.Concurrency
+		}
+		o.Delete = opts.Delete
+		o.DryRun = opts.DryRun
+	}
+
+	srcItems, err := src.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source: %w", err)
+	}
+	dstItems, err := dst.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destination: %w", err)
+	}
+	existing := make(map[string]objclient.ObjectItem, len(dstItems))
+	for _, item := range dstItems {
+		existing[item.Key] = item
+	}
+
+	s := &syncer{src: src, dst: dst, opts: o, errs: make(map[string]error)}
+	report := &Report{}
+	for _, item := range srcItems {
+		old, ok := existing[item.Key]
+		delete(existing, item.Key)
+		reason := "missing"
+		if ok {
+			if reason, err = s.compare(ctx, item, old); err != nil {
+				s.fail(item.Key, err)
+				continue
+			}
+			if reason == "" {
+				report.Skipped++
+				continue
+			}
+		}
+		report.Actions = append(report.Actions, Action{Op: ActionCopy, Key: item.Key, Size: item.Size, Reason: reason})
+	}
+	if o.Delete {
+		for _, item := range dstItems {
+			if _, ok := existing[item.Key]; ok {
+				report.Actions = append(report.Actions, Action{Op: ActionDelete, Key: item.Key, Size: item.Size, Reason: "extraneous"})
+			}
+		}
+	}
+	sort.SliceStable(report.Actions, func(i, j int) bool { return report.Actions[i].Key < report.Actions[j].Key })
+
+	if !o.DryRun {
+		s.run(ctx, report.Actions)
+	}
+	for _, action := range report.Actions {
+		if _, failed := s.errs[action.Key]; action.Op == ActionCopy && !failed {
+			report.Bytes += action.Size
+		}
+	}
+
+	if len(s.errs) > 0 {
+		return report, &objclient.MultiError{Errors: s.errs}
+	}
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+type syncer struct {
+	src, dst objclient.Client
+	opts     Options
+
+	mutex sync.Mutex
+	errs  map[string]error
+}
+
+func (s *syncer) fail(key string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errs[key] = err
+}
+
+// compare returns why old of the destination should be replaced by item, or
+// empty if it's up to date.
+func (s *syncer) compare(ctx context.Context, item, old objclient.ObjectItem) (string, error) {
+	if s.opts.Compare&CompareSize != 0 && item.Size != old.Size {
+		return "size", nil
+	}
+	if s.opts.Compare&CompareModTime != 0 && item.LastModified.After(old.LastModified) {
+		return "modified", nil
+	}
+	if s.opts.Compare&CompareETag != 0 {
+		srcInfo, err := s.src.Info(ctx, item.Key)
+		if err != nil {
+			return "", err
+		}
+		dstInfo, err := s.dst.Info(ctx, old.Key)
+		if err != nil {
+			return "", err
+		}
+		if objclient.ComparableETag(srcInfo.ETag) && objclient.ComparableETag(dstInfo.ETag) && !strings.EqualFold(srcInfo.ETag, dstInfo.ETag) {
+			return "etag", nil
+		}
+	}
+	return "", nil
+}
+
+// run runs the actions with the concurrency, and records the failures.
+func (s *syncer) run(ctx context.Context, actions []Action) {
+	var wg sync.WaitGroup
+	jobs := make(chan Action)
+	for i := 0; i < s.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for action := range jobs {
+				var err error
+				if action.Op == ActionCopy {
+					err = s.copy(ctx, action.Key)
+				} else {
+					err = s.dst.Remove(ctx, action.Key)
+				}
+				if err != nil {
+					s.fail(action.Key, err)
+				}
+			}
+		}()
+	}
+	for _, action := range actions {
+		select {
+		case jobs <- action:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (s *syncer) copy(ctx context.Context, key string) error {
+	info, err := s.src.Info(ctx, key)
+	if err != nil {
+		return err
+	}
+	r, err := s.src.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return s.dst.Write(ctx, key, r, &objclient.WriteOptions{Size: info.Size, Metadata: info.Metadata})
+}
+
+// WriteReport writes the actions of report as a table, and a summary.
+func WriteReport(w io.Writer, report *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OP\tKEY\tSIZE\tREASON")
+	for _, action := range report.Actions {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", action.Op, action.Key, action.Size, action.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%v actions, %v up to date, %v bytes\n", len(report.Actions), report.Skipped, report.Bytes)
+	return err
+}
@@ -0,0 +1,112 @@
+package objsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+func write(t *testing.T, client objclient.Client, key, data string) {
+	if err := client.Write(context.Background(), key, strings.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to write %v: %v", key, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	src, dst := NewDirClient(t.TempDir()), NewDirClient(t.TempDir()+"/dst")
+	write(t, src, "data/a", "a")
+	write(t, src, "data/b/c", "changed")
+	write(t, src, "data/d", "d")
+	write(t, src, "other", "other")
+	// The destination is written later, so it's newer.
+	write(t, dst, "data/a", "a")
+	write(t, dst, "data/b/c", "c")
+	write(t, dst, "data/e/f", "e")
+
+	opts := &Options{Delete: true, DryRun: true, Concurrency: 2}
+	report, err := Sync(context.Background(), src, dst, "data/", opts)
+	if err != nil {
+		t.Fatalf("failed to sync dry run: %v", err)
+	}
+	expect := []Action{
+		{Op: ActionCopy, Key: "data/b/c", Size: 7, Reason: "size"},
+		{Op: ActionCopy, Key: "data/d", Size: 1, Reason: "missing"},
+		{Op: ActionDelete, Key: "data/e/f", Size: 1, Reason: "extraneous"},
+	}
+	if !reflect.DeepEqual(report.Actions, expect) || report.Skipped != 1 || report.Bytes != 8 {
+		t.Fatalf("invalid report: %+v", report)
+	}
+	if ok, _ := dst.Exist(context.Background(), "data/d"); ok {
+		t.Fatalf("dry run copied objects")
+	}
+
+	opts.DryRun = false
+	if _, err := Sync(context.Background(), src, dst, "data/", opts); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	items, err := dst.List(context.Background(), "")
+	if err != nil || len(items) != 3 {
+		t.Fatalf("invalid synced objects: %v, %v", items, err)
+	}
+	r, err := dst.Read(context.Background(), "data/b/c")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "changed" {
+		t.Fatalf("invalid synced data: %q", data)
+	}
+
+	report, err = Sync(context.Background(), src, dst, "data/", opts)
+	if err != nil || len(report.Actions) != 0 || report.Skipped != 3 {
+		t.Fatalf("invalid report of synced objects: %+v, %v", report, err)
+	}
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, report); err != nil || !strings.Contains(buf.String(), "0 actions, 3 up to date") {
+		t.Fatalf("invalid written report: %q, %v", buf.String(), err)
+	}
+}
+
+func TestDirClient(t *testing.T) {
+	client := NewDirClient(t.TempDir())
+	write(t, client, "a/b", "0123456789")
+	write(t, client, "a.b", "")
+
+	r, err := client.ReadWithOptions(context.Background(), "a/b", &objclient.ReadOptions{Offset: 2, Length: 3})
+	if err != nil {
+		t.Fatalf("failed to read range: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "234" {
+		t.Fatalf("invalid range: %q", data)
+	}
+
+	items, err := client.List(context.Background(), "a")
+	if err != nil || len(items) != 2 || items[0].Key != "a.b" || items[1].Key != "a/b" {
+		t.Fatalf("invalid items: %v, %v", items, err)
+	}
+	if _, err := client.Info(context.Background(), "missing"); !errors.Is(err, objclient.ErrNotFound) {
+		t.Fatalf("missing key isn't reported: %v", err)
+	}
+	if err := client.Write(context.Background(), "../escape", strings.NewReader(""), nil); !errors.Is(err, objclient.ErrInvalidKey) {
+		t.Fatalf("invalid key is written: %v", err)
+	}
+
+	if err := client.Remove(context.Background(), "a/b", "missing"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if exist, _ := client.Exist(context.Background(), "a/b"); exist {
+		t.Fatalf("key isn't removed")
+	}
+	if _, err := client.List(context.Background(), "a/"); err != nil {
+		t.Fatalf("failed to list removed directory: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package objsync
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+// ConflictPolicy decides the objects modified in both clients.
+type ConflictPolicy int
+
+const (
+	// ConflictSourceWins overwrites the objects of the destination.
+	ConflictSourceWins ConflictPolicy = iota
+	// ConflictNewerWins keeps the objects of the destination modified
+	// after the ones of the source, which are written to the destination
+	// directly instead of replicated.
+	ConflictNewerWins
+)
+
+type ReplicatorOptions struct {
+	// Interval is the interval of diff listings of both clients, which
+	// also catch the events lost by notifications. It defaults to 1
+	// minute.
+	Interval time.Duration
+	// Notifications of the source are applied as they are received if
+	// set, e.g. the S3 client of MinIO or the SQS queue of AWS.
+	Notifications objclient.Notifications
+	// Concurrency is the number of objects copied at the same time by
+	// diffs, defaults to 8.
+	Concurrency int
+	// Delete removes the objects of the destination which are removed
+	// from the source.
+	Delete   bool
+	Conflict ConflictPolicy
+	// OnConflict is called with the keys kept by ConflictNewerWins, each
+	// time they are found.
+	OnConflict func(key string)
+	// OnError is called when failed to replicate a key, or to receive
+	// events and list with empty keys.
+	OnError func(key string, err error)
+}
+
+// ReplicatorStats are the statistics of a Replicator since it started.
+type ReplicatorStats struct {
+	Copied    int64
+	Removed   int64
+	Conflicts int64
+	Failures  int64
+	// LastSync is the start of the last diff without failures, the changes
+	// of the source before it are all replicated.
+	LastSync time.Time
+	// Lag is the time since LastSync, or since the start of the replicator
+	// if no diffs succeeded yet.
+	Lag time.Duration
+	// EventLag is the delay between the change and the replication of the
+	// last event applied.
+	EventLag time.Duration
+}
+
+// Replicator replicates the objects of a prefix from the source to the
+// destination continuously, for warm standbys. The changes are found by
+// notifications if available, and periodic diff listings.
+type Replicator struct {
+	src, dst objclient.Client
+	prefix   string
+	opts     ReplicatorOptions
+	start    time.Time
+
+	mutex sync.Mutex
+	stats ReplicatorStats
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReplicator starts replicating prefix from src to dst in background,
+// the first diff starts immediately. The options can be nil. Close should
+// be called to stop it.
+func NewReplicator(src, dst objclient.Client, prefix string, opts *ReplicatorOptions) *Replicator {
+	var o ReplicatorOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 8
+	}
+
+	r := &Replicator{
+		src:    src,
+		dst:    dst,
+		prefix: prefix,
+		opts:   o,
+		start:  time.Now(),
+		done:   make(chan struct{}),
+	}
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	go r.loop(ctx)
+	return r
+}
+
+// loop applies the events and diffs one at a time, so the same key isn't
+// replicated concurrently.
+func (r *Replicator) loop(ctx context.Context) {
+	defer close(r.done)
+
+	var events <-chan objclient.Event
+	if r.opts.Notifications != nil {
+		var err error
+		if events, err = r.opts.Notifications.Subscribe(ctx, r.prefix); err != nil {
+			r.fail("", err)
+		}
+	}
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	r.diff(ctx)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Falls back to diffs only.
+				events = nil
+				continue
+			}
+			if event.Err != nil {
+				r.fail("", event.Err)
+				continue
+			}
+			r.apply(ctx, event)
+		case <-ticker.C:
+			r.diff(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Replicator) fail(key string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	r.mutex.Lock()
+	r.stats.Failures++
+	r.mutex.Unlock()
+	if r.opts.OnError != nil {
+		r.opts.OnError(key, err)
+	}
+}
+
+func (r *Replicator) conflict(key string) {
+	r.mutex.Lock()
+	r.stats.Conflicts++
+	r.mutex.Unlock()
+	if r.opts.OnConflict != nil {
+		r.opts.OnConflict(key)
+	}
+}
+
+// action returns the action replicating item of the source to old of the
+// destination, or nil if it's up to date or kept by the conflict policy.
+func (r *Replicator) action(item, old objclient.ObjectItem) *Action {
+	var reason string
+	switch {
+	case item.Size != old.Size:
+		reason = "size"
+	case objclient.ComparableETag(item.ETag) && objclient.ComparableETag(old.ETag) && !strings.EqualFold(item.ETag, old.ETag):
+		reason = "etag"
+	case item.LastModified.After(old.LastModified):
+		reason = "modified"
+	default:
+		return nil
+	}
+	// The copies are written after the source objects, so the objects of
+	// the destination which are different and newer are written directly.
+	if r.opts.Conflict == ConflictNewerWins && reason != "modified" && old.LastModified.After(item.LastModified) {
+		r.conflict(item.Key)
+		return nil
+	}
+	return &Action{Op: ActionCopy, Key: item.Key, Size: item.Size, Reason: reason}
+}
+
+// diff replicates the differences of the listings of both clients.
+func (r *Replicator) diff(ctx context.Context) {
+	start := time.Now()
+	srcItems, err := r.src.List(ctx, r.prefix)
+	if err != nil {
+		r.fail("", err)
+		return
+	}
+	dstItems, err := r.dst.List(ctx, r.prefix)
+	if err != nil {
+		r.fail("", err)
+		return
+	}
+	existing := make(map[string]objclient.ObjectItem, len(dstItems))
+	for _, item := range dstItems {
+		existing[item.Key] = item
+	}
+
+	var actions []Action
+	for _, item := range srcItems {
+		old, ok := existing[item.Key]
+		delete(existing, item.Key)
+		if !ok {
+			actions = append(actions, Action{Op: ActionCopy, Key: item.Key, Size: item.Size, Reason: "missing"})
+		} else if action := r.action(item, old); action != nil {
+			actions = append(actions, *action)
+		}
+	}
+	if r.opts.Delete {
+		for _, item := range existing {
+			actions = append(actions, Action{Op: ActionDelete, Key: item.Key, Size: item.Size, Reason: "removed"})
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Key < actions[j].Key })
+
+	s := &syncer{src: r.src, dst: r.dst, opts: Options{Concurrency: r.opts.Concurrency}, errs: make(map[string]error)}
+	s.run(ctx, actions)
+	if ctx.Err() != nil {
+		return
+	}
+	for key, err := range s.errs {
+		r.fail(key, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, action := range actions {
+		if _, failed := s.errs[action.Key]; failed {
+			continue
+		}
+		if action.Op == ActionCopy {
+			r.stats.Copied++
+		} else {
+			r.stats.Removed++
+		}
+	}
+	if len(s.errs) == 0 {
+		r.stats.LastSync = start
+	}
+}
+
+// apply replicates the key of event by its current state in the source, as
+// the events may be out of order or duplicated.
+func (r *Replicator) apply(ctx context.Context, event objclient.Event) {
+	key := event.Key
+	srcInfo, err := r.src.Info(ctx, key)
+	switch {
+	case errors.Is(err, objclient.ErrNotFound):
+		if !r.opts.Delete {
+			return
+		}
+		if ok, err := r.dst.Exist(ctx, key); err != nil || !ok {
+			if err != nil {
+				r.fail(key, err)
+			}
+			return
+		}
+		if err := r.dst.Remove(ctx, key); err != nil {
+			r.fail(key, err)
+			return
+		}
+		r.replicated(event, false)
+		return
+	case err != nil:
+		r.fail(key, err)
+		return
+	}
+
+	dstInfo, err := r.dst.Info(ctx, key)
+	switch {
+	case errors.Is(err, objclient.ErrNotFound):
+	case err != nil:
+		r.fail(key, err)
+		return
+	default:
+		item := objclient.ObjectItem{Key: key, Size: srcInfo.Size, LastModified: srcInfo.LastModified, ETag: srcInfo.ETag}
+		old := objclient.ObjectItem{Key: key, Size: dstInfo.Size, LastModified: dstInfo.LastModified, ETag: dstInfo.ETag}
+		if r.action(item, old) == nil {
+			return
+		}
+	}
+
+	s := &syncer{src: r.src, dst: r.dst}
+	if err := s.copy(ctx, key); err != nil {
+		r.fail(key, err)
+		return
+	}
+	r.replicated(event, true)
+}
+
+func (r *Replicator) replicated(event objclient.Event, copied bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if copied {
+		r.stats.Copied++
+	} else {
+		r.stats.Removed++
+	}
+	if !event.Time.IsZero() {
+		r.stats.EventLag = time.Since(event.Time)
+	}
+}
+
+// Stats returns the statistics of the replicator.
+func (r *Replicator) Stats() ReplicatorStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	stats := r.stats
+	if stats.LastSync.IsZero() {
+		stats.Lag = time.Since(r.start)
+	} else {
+		stats.Lag = time.Since(stats.LastSync)
+	}
+	return stats
+}
+
+// Close stops replicating and waits for the replication in progress to be
+// canceled.
+func (r *Replicator) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
@@ -0,0 +1,85 @@
+package objsync
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient"
+)
+
+type chanNotifications chan objclient.Event
+
+func (ch chanNotifications) Subscribe(ctx context.Context, prefix string, events ...objclient.EventType) (<-chan objclient.Event, error) {
+	return ch, nil
+}
+
+// waitStats waits for the stats of r to satisfy ok.
+func waitStats(t *testing.T, r *Replicator, ok func(stats ReplicatorStats) bool) ReplicatorStats {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := r.Stats()
+		if ok(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for stats: %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplicator(t *testing.T) {
+	ctx := context.Background()
+	src, dst := NewDirClient(t.TempDir()), NewDirClient(t.TempDir())
+	write(t, src, "data/a", "a")
+	write(t, src, "data/b", "b")
+	write(t, dst, "data/c", "c")
+	// The destination is written directly later.
+	write(t, dst, "data/b", "conflict")
+
+	events := make(chanNotifications)
+	var conflicts []string
+	r := NewReplicator(src, dst, "data/", &ReplicatorOptions{
+		Interval:      time.Hour,
+		Notifications: events,
+		Delete:        true,
+		Conflict:      ConflictNewerWins,
+		OnConflict:    func(key string) { conflicts = append(conflicts, key) },
+		OnError:       func(key string, err error) { t.Errorf("failed to replicate %q: %v", key, err) },
+	})
+	defer r.Close()
+
+	stats := waitStats(t, r, func(stats ReplicatorStats) bool { return !stats.LastSync.IsZero() })
+	if stats.Copied != 1 || stats.Removed != 1 || stats.Conflicts != 1 || len(conflicts) != 1 || conflicts[0] != "data/b" {
+		t.Fatalf("invalid stats of diff: %+v, %v", stats, conflicts)
+	}
+	if ok, _ := dst.Exist(ctx, "data/c"); ok {
+		t.Fatalf("removed object isn't removed")
+	}
+
+	write(t, src, "data/d", "d")
+	events <- objclient.Event{Type: objclient.EventObjectCreated, Key: "data/d", Time: time.Now()}
+	src.Remove(ctx, "data/a")
+	events <- objclient.Event{Type: objclient.EventObjectRemoved, Key: "data/a", Time: time.Now()}
+	// Duplicated events are ignored.
+	events <- objclient.Event{Type: objclient.EventObjectCreated, Key: "data/d", Time: time.Now()}
+	events <- objclient.Event{Type: objclient.EventObjectRemoved, Key: "data/a", Time: time.Now()}
+	stats = waitStats(t, r, func(stats ReplicatorStats) bool { return stats.Removed == 2 })
+	if stats.Copied != 2 || stats.Removed != 2 || stats.EventLag <= 0 {
+		t.Fatalf("invalid stats of events: %+v", stats)
+	}
+	reader, err := dst.Read(ctx, "data/d")
+	if err != nil {
+		t.Fatalf("failed to read replicated object: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "d" {
+		t.Fatalf("invalid replicated data %q", data)
+	}
+	if ok, _ := dst.Exist(ctx, "data/a"); ok {
+		t.Fatalf("removed object isn't removed by event")
+	}
+}
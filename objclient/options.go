@@ -0,0 +1,456 @@
+package objclient
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature is the signature version of S3 requests.
+type Signature int
+
+const (
+	SignatureV4 Signature = iota
+	SignatureV2
+)
+
+// clientOptions are the typed fields of S3Config and OSSConfig.
+type clientOptions struct {
+	endpoint  string
+	template  string
+	region    string
+	https     bool
+	pathStyle bool
+	signature Signature
+	keyID     string
+	key       string
+	token     string
+	ssecKey   string
+	anonymous bool
+	profile   string
+	ramRole   string
+
+	roleARN         string
+	roleExternalID  string
+	roleSessionName string
+	stsEndpoint     string
+	tokenFile       string
+	credentials     CredentialsProvider
+
+	proxyURL       string
+	caFile         string
+	rootCAs        *x509.CertPool
+	clientCertFile string
+	clientKeyFile  string
+	insecure       bool
+
+	connectTimeout time.Duration
+	requestTimeout time.Duration
+	stallTimeout   time.Duration
+
+	accelerate bool
+	dualStack  *bool
+	fips       bool
+	strictKeys bool
+
+	userAgent string
+	headers   []string
+
+	partSize          int64
+	uploadConcurrency int
+	uploadController  *AdaptiveConcurrency
+
+	maxIdleConnsPerHost   int
+	idleConnTimeout       time.Duration
+	expectContinueTimeout time.Duration
+	http2                 *bool
+
+	// unsupported are the options which only one of the backends supports,
+	// mapped to the name of the backend.
+	unsupported map[string]string
+}
+
+// Option configures the clients of NewS3 and NewOSS. Options only for one of
+// them fail the other.
+type Option func(o *clientOptions)
+
+func (o *clientOptions) only(backend, option string) {
+	if o.unsupported == nil {
+		o.unsupported = make(map[string]string)
+	}
+	o.unsupported[option] = backend
+}
+
+func (o *clientOptions) check(backend string) error {
+	for option, only := range o.unsupported {
+		if only != backend {
+			return fmt.Errorf("option %v isn't supported by %v", option, backend)
+		}
+	}
+	return nil
+}
+
+// WithEndpoint sets the host and optional port of the endpoint.
+func WithEndpoint(endpoint string) Option {
+	return func(o *clientOptions) { o.endpoint = endpoint }
+}
+
+// WithEndpointTemplate sets the endpoint by replacing {region} of template
+// with the region, e.g. s3.{region}.example.internal.
+func WithEndpointTemplate(template string) Option {
+	return func(o *clientOptions) { o.template = template }
+}
+
+func WithRegion(region string) Option {
+	return func(o *clientOptions) { o.region = region }
+}
+
+// WithHTTPS sets whether requests are sent by https, which defaults to true.
+func WithHTTPS(https bool) Option {
+	return func(o *clientOptions) { o.https = https }
+}
+
+// WithPathStyle puts the bucket in the path of URLs instead of the host, for
+// S3 only.
+func WithPathStyle(pathStyle bool) Option {
+	return func(o *clientOptions) {
+		o.pathStyle = pathStyle
+		o.only("s3", "WithPathStyle")
+	}
+}
+
+// WithSignature sets the signature version, which defaults to SignatureV4,
+// for S3 only.
+func WithSignature(signature Signature) Option {
+	return func(o *clientOptions) {
+		o.signature = signature
+		o.only("s3", "WithSignature")
+	}
+}
+
+func WithKeys(keyID, key string) Option {
+	return func(o *clientOptions) { o.keyID, o.key = keyID, key }
+}
+
+// WithSecurityToken sets the token of STS credentials of WithKeys, for OSS
+// only.
+func WithSecurityToken(token string) Option {
+	return func(o *clientOptions) {
+		o.token = token
+		o.only("oss", "WithSecurityToken")
+	}
+}
+
+// WithSSECKey encrypts objects by the 32 bytes key, for S3 only.
+func WithSSECKey(key string) Option {
+	return func(o *clientOptions) {
+		o.ssecKey = key
+		o.only("s3", "WithSSECKey")
+	}
+}
+
+// WithAnonymous sends unsigned requests, for S3 only.
+func WithAnonymous() Option {
+	return func(o *clientOptions) {
+		o.anonymous = true
+		o.only("s3", "WithAnonymous")
+	}
+}
+
+// WithProfile sets the profile of the AWS shared credentials file, for S3
+// only.
+func WithProfile(profile string) Option {
+	return func(o *clientOptions) {
+		o.profile = profile
+		o.only("s3", "WithProfile")
+	}
+}
+
+// WithRAMRole uses the credentials of the RAM role of ECS instance, for OSS
+// only.
+func WithRAMRole(role string) Option {
+	return func(o *clientOptions) {
+		o.ramRole = role
+		o.only("oss", "WithRAMRole")
+	}
+}
+
+// WithAssumeRole assumes roleARN by STS, the session name can be empty.
+func WithAssumeRole(roleARN, sessionName string) Option {
+	return func(o *clientOptions) { o.roleARN, o.roleSessionName = roleARN, sessionName }
+}
+
+// WithExternalID sets the external ID of WithAssumeRole, for S3 only.
+func WithExternalID(externalID string) Option {
+	return func(o *clientOptions) {
+		o.roleExternalID = externalID
+		o.only("s3", "WithExternalID")
+	}
+}
+
+func WithSTSEndpoint(endpoint string) Option {
+	return func(o *clientOptions) { o.stsEndpoint = endpoint }
+}
+
+// WithWebIdentityTokenFile exchanges the OIDC token in path for the
+// credentials of WithAssumeRole, for S3 only.
+func WithWebIdentityTokenFile(path string) Option {
+	return func(o *clientOptions) {
+		o.tokenFile = path
+		o.only("s3", "WithWebIdentityTokenFile")
+	}
+}
+
+func WithCredentials(provider CredentialsProvider) Option {
+	return func(o *clientOptions) { o.credentials = provider }
+}
+
+// WithProxy sets the proxy URL, the ones of environment variables are used
+// by default.
+func WithProxy(proxyURL string) Option {
+	return func(o *clientOptions) { o.proxyURL = proxyURL }
+}
+
+// WithCAFile trusts the CA certificates in the PEM file besides the system
+// ones.
+func WithCAFile(path string) Option {
+	return func(o *clientOptions) { o.caFile = path }
+}
+
+// WithRootCAs trusts the CA certificates of pool instead of the system ones.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(o *clientOptions) { o.rootCAs = pool }
+}
+
+// WithClientCert sets the PEM files of the certificate for mutual TLS.
+func WithClientCert(certFile, keyFile string) Option {
+	return func(o *clientOptions) { o.clientCertFile, o.clientKeyFile = certFile, keyFile }
+}
+
+func WithInsecureSkipVerify() Option {
+	return func(o *clientOptions) { o.insecure = true }
+}
+
+// WithTimeouts sets the timeouts of S3Config and OSSConfig, zero ones are
+// the defaults.
+func WithTimeouts(connect, request, readStall time.Duration) Option {
+	return func(o *clientOptions) {
+		o.connectTimeout, o.requestTimeout, o.stallTimeout = connect, request, readStall
+	}
+}
+
+func WithAccelerate() Option {
+	return func(o *clientOptions) { o.accelerate = true }
+}
+
+// WithDualStack sets whether endpoints of both IPv4 and IPv6 are used, which
+// defaults to true for S3 and false for OSS.
+func WithDualStack(dualStack bool) Option {
+	return func(o *clientOptions) { o.dualStack = &dualStack }
+}
+
+// WithFIPS uses the FIPS endpoint of the region, for S3 only.
+func WithFIPS() Option {
+	return func(o *clientOptions) {
+		o.fips = true
+		o.only("s3", "WithFIPS")
+	}
+}
+
+// WithStrictKeys rejects the keys of operations by ValidateKey before
+// sending requests.
+func WithStrictKeys() Option {
+	return func(o *clientOptions) { o.strictKeys = true }
+}
+
+// WithUserAgent appends product to the User-Agent of requests.
+func WithUserAgent(product string) Option {
+	return func(o *clientOptions) { o.userAgent = product }
+}
+
+// WithHeader sets the header of name to all the requests.
+func WithHeader(name, value string) Option {
+	return func(o *clientOptions) {
+		o.headers = append(o.headers, name+": "+value)
+	}
+}
+
+// WithMultipart sets the part size and the number of parts uploaded at the
+// same time for large objects, zero ones are the defaults.
+func WithMultipart(partSize int64, concurrency int) Option {
+	return func(o *clientOptions) { o.partSize, o.uploadConcurrency = partSize, concurrency }
+}
+
+// WithAdaptiveUploads adapts the number of parts uploaded at the same time
+// by controller, which is at most the concurrency of WithMultipart.
+func WithAdaptiveUploads(controller *AdaptiveConcurrency) Option {
+	return func(o *clientOptions) { o.uploadController = controller }
+}
+
+func newClientOptions(opts []Option) *clientOptions {
+	o := &clientOptions{https: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func formatBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// WithConnectionPool sets the idle connections kept for each host and their
+// timeout, zero ones are the defaults.
+func WithConnectionPool(maxIdleConnsPerHost int, idleTimeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.maxIdleConnsPerHost, o.idleConnTimeout = maxIdleConnsPerHost, idleTimeout
+	}
+}
+
+// WithExpectContinue sends uploads with "Expect: 100-continue", whose
+// response is waited for timeout before the body is sent.
+func WithExpectContinue(timeout time.Duration) Option {
+	return func(o *clientOptions) { o.expectContinueTimeout = timeout }
+}
+
+// WithHTTP2 forces or disables HTTP/2.
+func WithHTTP2(enabled bool) Option {
+	return func(o *clientOptions) { o.http2 = &enabled }
+}
+
+func formatInt(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (o *clientOptions) s3Config(bucket string) S3Config {
+	config := S3Config{
+		Endpoint:              o.endpoint,
+		EndpointTemplate:      o.template,
+		Region:                o.region,
+		HTTPS:                 formatBool(o.https),
+		Bucket:                bucket,
+		PathStyleRequest:      formatBool(o.pathStyle),
+		KeyID:                 o.keyID,
+		Key:                   o.key,
+		V4Signature:           formatBool(o.signature == SignatureV4),
+		SSECKey:               o.ssecKey,
+		Anonymous:             formatBool(o.anonymous),
+		Profile:               o.profile,
+		RoleARN:               o.roleARN,
+		RoleExternalID:        o.roleExternalID,
+		RoleSessionName:       o.roleSessionName,
+		STSEndpoint:           o.stsEndpoint,
+		WebIdentityTokenFile:  o.tokenFile,
+		ProxyURL:              o.proxyURL,
+		CAFile:                o.caFile,
+		RootCAs:               o.rootCAs,
+		ClientCertFile:        o.clientCertFile,
+		ClientKeyFile:         o.clientKeyFile,
+		InsecureSkipVerify:    formatBool(o.insecure),
+		ConnectTimeout:        formatDuration(o.connectTimeout),
+		RequestTimeout:        formatDuration(o.requestTimeout),
+		ReadStallTimeout:      formatDuration(o.stallTimeout),
+		Accelerate:            formatBool(o.accelerate),
+		FIPS:                  formatBool(o.fips),
+		StrictKeys:            formatBool(o.strictKeys),
+		UserAgent:             o.userAgent,
+		Headers:               strings.Join(o.headers, "\n"),
+		PartSize:              formatInt(o.partSize),
+		UploadConcurrency:     formatInt(int64(o.uploadConcurrency)),
+		UploadController:      o.uploadController,
+		MaxIdleConnsPerHost:   formatInt(int64(o.maxIdleConnsPerHost)),
+		IdleConnTimeout:       formatDuration(o.idleConnTimeout),
+		ExpectContinueTimeout: formatDuration(o.expectContinueTimeout),
+		Credentials:           o.credentials,
+	}
+	if o.dualStack != nil {
+		config.DualStack = formatBool(*o.dualStack)
+	}
+	if o.http2 != nil {
+		config.HTTP2 = formatBool(*o.http2)
+	}
+	return config
+}
+
+func (o *clientOptions) ossConfig(bucket string) OSSConfig {
+	config := OSSConfig{
+		Endpoint:              o.endpoint,
+		EndpointTemplate:      o.template,
+		Region:                o.region,
+		HTTPS:                 formatBool(o.https),
+		Bucket:                bucket,
+		KeyID:                 o.keyID,
+		Key:                   o.key,
+		SecurityToken:         o.token,
+		RAMRole:               o.ramRole,
+		RoleARN:               o.roleARN,
+		RoleSessionName:       o.roleSessionName,
+		STSEndpoint:           o.stsEndpoint,
+		ProxyURL:              o.proxyURL,
+		CAFile:                o.caFile,
+		RootCAs:               o.rootCAs,
+		ClientCertFile:        o.clientCertFile,
+		ClientKeyFile:         o.clientKeyFile,
+		InsecureSkipVerify:    formatBool(o.insecure),
+		ConnectTimeout:        formatDuration(o.connectTimeout),
+		RequestTimeout:        formatDuration(o.requestTimeout),
+		ReadStallTimeout:      formatDuration(o.stallTimeout),
+		Accelerate:            formatBool(o.accelerate),
+		StrictKeys:            formatBool(o.strictKeys),
+		UserAgent:             o.userAgent,
+		Headers:               strings.Join(o.headers, "\n"),
+		PartSize:              formatInt(o.partSize),
+		UploadConcurrency:     formatInt(int64(o.uploadConcurrency)),
+		UploadController:      o.uploadController,
+		MaxIdleConnsPerHost:   formatInt(int64(o.maxIdleConnsPerHost)),
+		IdleConnTimeout:       formatDuration(o.idleConnTimeout),
+		ExpectContinueTimeout: formatDuration(o.expectContinueTimeout),
+		Credentials:           o.credentials,
+	}
+	if o.dualStack != nil {
+		config.DualStack = formatBool(*o.dualStack)
+	}
+	if o.http2 != nil {
+		config.HTTP2 = formatBool(*o.http2)
+	}
+	return config
+}
+
+// NewS3 creates a S3 client of bucket. Unlike S3Config, https and v4
+// signature are the defaults.
+func NewS3(bucket string, opts ...Option) (*S3Client, error) {
+	o := newClientOptions(opts)
+	if err := o.check("s3"); err != nil {
+		return nil, err
+	}
+	config := o.s3Config(bucket)
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid s3 options: %w", err)
+	}
+	return newS3Client(config)
+}
+
+// NewOSS creates an OSS client of bucket.
+func NewOSS(bucket string, opts ...Option) (*OSSClient, error) {
+	o := newClientOptions(opts)
+	if err := o.check("oss"); err != nil {
+		return nil, err
+	}
+	config := o.ossConfig(bucket)
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid oss options: %w", err)
+	}
+	return newOSSClient(config)
+}
@@ -0,0 +1,53 @@
+package objclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	o := newClientOptions([]Option{
+		WithRegion("us-west-2"),
+		WithPathStyle(true),
+		WithKeys("id", "key"),
+		WithTimeouts(time.Second, 0, time.Minute),
+		WithDualStack(false),
+	})
+	config := o.s3Config("bucket")
+	if config.HTTPS != "true" || config.V4Signature != "true" || config.PathStyleRequest != "true" || config.DualStack != "false" {
+		t.Fatalf("invalid s3 config of options: %+v", config)
+	}
+	if config.ConnectTimeout != "1s" || config.RequestTimeout != "" || config.ReadStallTimeout != "1m0s" {
+		t.Fatalf("invalid timeouts of options: %+v", config)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid validation of options: %v", err)
+	}
+
+	client, err := NewS3("bucket", WithEndpoint("localhost:9000"), WithHTTPS(false), WithSignature(SignatureV2), WithKeys("id", "key"))
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	if client.https || client.endpoint != "localhost:9000" {
+		t.Fatalf("invalid s3 client of options: %+v", client)
+	}
+
+	if _, err := NewOSS("bucket", WithRegion("cn-hangzhou"), WithPathStyle(true)); err == nil {
+		t.Fatalf("s3 option is accepted by oss")
+	}
+	if _, err := NewS3("bucket", WithRAMRole("role")); err == nil {
+		t.Fatalf("oss option is accepted by s3")
+	}
+	if _, err := NewS3("bucket", WithHTTPS(false), WithSSECKey("01234567890123456789012345678901")); err == nil {
+		t.Fatalf("invalid options are accepted")
+	}
+	if _, err := NewOSS("bucket", WithRegion("cn-hangzhou"), WithSecurityToken("token"), WithKeys("id", "key")); err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+	if _, err := NewS3Client(S3Config{Bucket: "bucket", HTTPS: "yes"}); err == nil {
+		t.Fatalf("invalid s3 config is accepted")
+	}
+	if _, err := NewOSSClient(OSSConfig{Region: "cn-hangzhou"}); err == nil {
+		t.Fatalf("invalid oss config is accepted")
+	}
+}
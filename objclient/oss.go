@@ -2,6 +2,9 @@ package objclient
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,27 +15,163 @@ import (
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
 
+const (
+	ossMaxDeleteKeys = 1000
+)
+
+// OSSConfig is the config of NewOSSClient, whose fields are strings for the
+// configs of files and environment variables. HTTPS is false only if it's
+// "false", use NewOSS with options in code instead.
 type OSSConfig struct {
 	Endpoint string
-	Region   string
-	HTTPS    string
-	Bucket   string
-	KeyID    string
-	Key      string
+	// EndpointTemplate is the endpoint if Endpoint is empty, where {region}
+	// is replaced by Region, e.g. oss-{region}.example.internal.
+	EndpointTemplate string
+	// Region is detected by the info of Bucket if it's empty.
+	Region string
+	HTTPS  string
+	Bucket string
+	KeyID  string
+	Key    string
+	// SecurityToken is the token of STS credentials in KeyID and Key, they
+	// are not refreshed.
+	SecurityToken string
+	// RAMRole is the RAM role of the ECS instance, whose credentials are
+	// used instead of the keys.
+	RAMRole string
+	// RoleARN is assumed by STS with the credentials of keys or RAMRole.
+	// The temporary credentials are refreshed before they expire.
+	RoleARN         string
+	RoleSessionName string
+	// STSEndpoint defaults to https://sts.aliyuncs.com.
+	STSEndpoint string
+	// ProxyURL is the proxy of requests, e.g. http://proxy:3128. The ones of
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if it's empty.
+	ProxyURL string
+	// CAFile is the PEM file of CA certificates, which are trusted besides
+	// RootCAs, or the system ones if it's nil.
+	CAFile  string
+	RootCAs *x509.CertPool
+	// ClientCertFile and ClientKeyFile are the PEM files of the certificate
+	// for mutual TLS.
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify string
+	// ConnectTimeout, RequestTimeout and ReadStallTimeout are durations like
+	// "10s". ConnectTimeout defaults to 30 seconds. RequestTimeout limits the
+	// requests other than reading and writing objects, defaults to 30
+	// seconds. Reads and writes of objects fail with ErrStalled if no data
+	// is transferred in ReadStallTimeout, which defaults to 60 seconds.
+	ConnectTimeout   string
+	RequestTimeout   string
+	ReadStallTimeout string
+	// Accelerate sends requests to the global acceleration endpoint, but
+	// List still uses Endpoint or the one of Region.
+	Accelerate string
+	// DualStack uses the endpoint of Region for both IPv4 and IPv6 if
+	// Endpoint is empty.
+	DualStack string
+	// StrictKeys rejects the keys of operations by ValidateKey before
+	// sending requests if it's "true".
+	StrictKeys string
+	// PartSize is the size of parts for objects larger than it, like "64MiB",
+	// defaults to 16MiB. UploadConcurrency is the number of parts uploaded
+	// at the same time, defaults to 4, each of them buffers a part.
+	PartSize          string
+	UploadConcurrency string
+	// UploadController adapts the number of parts uploaded at the same time,
+	// which is at most UploadConcurrency, if it's set.
+	UploadController *AdaptiveConcurrency
+	// UserAgent is the product identifier appended to the User-Agent of
+	// requests, e.g. "gateway/1.2".
+	UserAgent string
+	// Headers are set to all the requests, in lines of "Name: value". The
+	// ones which are signed like x-oss-* can't be set.
+	Headers string
+	// MaxIdleConnsPerHost and IdleConnTimeout like "90s" tune the pool of
+	// connections. ExpectContinueTimeout like "1s" sends uploads with
+	// "Expect: 100-continue" and waits for the response in it. HTTP2 forces
+	// HTTP/2 if it's "true", or disables it if it's "false".
+	MaxIdleConnsPerHost   string
+	IdleConnTimeout       string
+	ExpectContinueTimeout string
+	HTTP2                 string
+	// Credentials supplies the credentials instead of the keys and others
+	// above if it's set.
+	Credentials CredentialsProvider
 }
 
 type OSSClient struct {
-	bucket *oss.Bucket
+	bucket   *oss.Bucket
+	endpoint string
+	https    bool
+	timeout  time.Duration
+	stall    time.Duration
+	upload   uploadConfig
+	// listBucket is bucket unless accelerated.
+	listBucket *oss.Bucket
+	tenants    *tenantCache[ossBuckets]
+	region     string
+	strict     bool
+	transport  http.RoundTripper
+	transfers  transfers
+}
+
+// ossBuckets are the buckets of the same credentials.
+type ossBuckets struct {
+	bucket, list *oss.Bucket
+}
+
+// bucketOf returns the bucket of the credentials of ctx.
+func (client *OSSClient) bucketOf(ctx context.Context) (*oss.Bucket, error) {
+	creds, ok := credentialsOf(ctx)
+	if !ok || client.tenants == nil {
+		return client.bucket, nil
+	}
+	buckets, err := client.tenants.get(creds)
+	return buckets.bucket, err
+}
+
+// listBucketOf returns the list bucket of the credentials of ctx.
+func (client *OSSClient) listBucketOf(ctx context.Context) (*oss.Bucket, error) {
+	creds, ok := credentialsOf(ctx)
+	if !ok || client.tenants == nil {
+		return client.listBucket, nil
+	}
+	buckets, err := client.tenants.get(creds)
+	return buckets.list, err
 }
 
+// NewOSSClient creates an OSS client of config, which fails if the config
+// isn't valid, see Validate.
+//
+// Deprecated: use NewOSS in code.
 func NewOSSClient(config OSSConfig) (Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid oss config: %w", err)
+	}
+	return newOSSClient(config)
+}
+
+func newOSSClient(config OSSConfig) (*OSSClient, error) {
 	var client OSSClient
 
 	region := config.Region
 
 	endpoint := config.Endpoint
-	if endpoint == "" && region != "" {
-		endpoint = "oss-" + region + ".aliyuncs.com"
+	switch {
+	case endpoint == "" && config.EndpointTemplate != "":
+		var err error
+		endpoint, err = templateEndpoint(config.EndpointTemplate, region)
+		if err != nil {
+			return nil, err
+		}
+	case endpoint == "" && region != "":
+		if stringToBool(config.DualStack, false) {
+			endpoint = ossDualStackEndpoint(region)
+		} else {
+			endpoint = ossEndpoint(region)
+		}
 	}
 	uri := url.URL{Host: endpoint}
 
@@ -43,127 +182,420 @@ func NewOSSClient(config OSSConfig) (Client, error) {
 		uri.Scheme = "http"
 	}
 
-	backend, err := oss.New(uri.String(), config.KeyID, config.Key)
+	timeouts, err := parseTimeouts(config.ConnectTimeout, config.RequestTimeout, config.ReadStallTimeout, ossReadWriteTimeout)
 	if err != nil {
 		return nil, err
 	}
-	bucket, err := backend.Bucket(config.Bucket)
+	upload, err := parseUpload(config.PartSize, config.UploadConcurrency)
 	if err != nil {
 		return nil, err
 	}
+	upload.controller = config.UploadController
 
-	client.bucket = bucket
+	httpClient, err := newOSSHTTPClient(config.transportConfig(), timeouts)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(ossCredentialsOptions(config), oss.HTTPClient(httpClient))
+
+	// The buckets of the credentials of ContextWithCredentials share the
+	// HTTP client.
+	accelerate := stringToBool(config.Accelerate, false)
+	newBuckets := func(keyID, key string, opts ...oss.ClientOption) (ossBuckets, error) {
+		backend, err := oss.New(uri.String(), keyID, key, opts...)
+		if err != nil {
+			return ossBuckets{}, err
+		}
+		bucket, err := backend.Bucket(config.Bucket)
+		if err != nil {
+			return ossBuckets{}, err
+		}
+		buckets := ossBuckets{bucket: bucket, list: bucket}
+		if accelerate {
+			accelerated := uri
+			accelerated.Host = ossAccelerateEndpoint
+			backend, err := oss.New(accelerated.String(), keyID, key, opts...)
+			if err != nil {
+				return ossBuckets{}, err
+			}
+			buckets.bucket, err = backend.Bucket(config.Bucket)
+			if err != nil {
+				return ossBuckets{}, err
+			}
+		}
+		return buckets, nil
+	}
+	buckets, err := newBuckets(config.KeyID, config.Key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		region = detectOSSRegion(&buckets.list.Client, config.Bucket, timeouts.request)
+	}
+	client.tenants = newTenantCache(func(creds Credentials) (ossBuckets, error) {
+		opts := []oss.ClientOption{oss.HTTPClient(httpClient)}
+		if creds.Token != "" {
+			opts = append(opts, oss.SecurityToken(creds.Token))
+		}
+		return newBuckets(creds.KeyID, creds.Key, opts...)
+	})
+
+	client.bucket = buckets.bucket
+	client.listBucket = buckets.list
+	client.endpoint = endpoint
+	client.https = https
+	client.timeout = timeouts.request
+	client.stall = timeouts.stall
+	client.upload = upload
+	client.region = region
+	client.strict = stringToBool(config.StrictKeys, false)
+	client.transport = httpClient.Transport
+	client.transfers = newTransfers()
 
 	return &client, nil
 }
 
+// detectOSSRegion returns the region of bucket, or empty if it's failed.
+func detectOSSRegion(backend *oss.Client, bucket string, timeout time.Duration) string {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := backend.GetBucketInfo(bucket, oss.WithContext(ctx))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(result.BucketInfo.Location, "oss-")
+}
+
+// Region returns the region of config, or the detected one of the bucket
+// if it's empty.
+func (client *OSSClient) Region() string {
+	return client.region
+}
+
 func (client *OSSClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
-	return client.bucket.GetObject(key, oss.WithContext(ctx))
+	return client.ReadWithOptions(ctx, key, nil)
 }
 
-func (client *OSSClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+func (client *OSSClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return nil, err
+	}
+
+	offset, length, err := readRange(o)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := client.transfers.context(ctx)
 	var opts []oss.Option
 	opts = append(opts, oss.WithContext(ctx))
+	if o != nil && o.Process != "" {
+		opts = append(opts, oss.Process(o.Process))
+	}
+	if o != nil && o.IfMatch != "" {
+		opts = append(opts, oss.IfMatch(`"`+o.IfMatch+`"`))
+	}
+	extra, err := ossReadOptions(o)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	opts = append(opts, extra...)
+	if length > 0 {
+		opts = append(opts, oss.Range(offset, offset+length-1))
+	} else if offset > 0 {
+		opts = append(opts, oss.NormalizedRange(fmt.Sprintf("%d-", offset)))
+	}
+	if offset > 0 || length > 0 {
+		// Invalid ranges are ignored without the standard behavior.
+		opts = append(opts, oss.RangeBehavior("standard"))
+	}
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	var header http.Header
+	opts = append(opts, oss.GetResponseHeader(&header))
+	r, err := bucket.GetObject(key, opts...)
+	if err != nil {
+		cancel()
+		return nil, translateError(err)
+	}
+	total, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		total = -1
+	}
+	return wrapReader(newStallReader(r, r, cancel, client.stall, "Read", key), total, o), nil
+}
+
+// ossReadOptions returns the options of the extra headers and response
+// header overrides of o.
+func ossReadOptions(o *ReadOptions) ([]oss.Option, error) {
+	params, err := responseParams(o)
+	if err != nil {
+		return nil, err
+	}
+	var opts []oss.Option
+	for k := range params {
+		opts = append(opts, oss.AddParam(k, params.Get(k)))
+	}
+	if o != nil {
+		for k, values := range o.Header {
+			if len(values) > 0 {
+				opts = append(opts, oss.SetHeader(k, values[0]))
+			}
+		}
+	}
+	return opts, nil
+}
+
+func (client *OSSClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *OSSClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return nil, err
+	}
+
+	var header http.Header
 
-	if o != nil && len(o.Metadata) > 0 {
-		for key, val := range o.Metadata {
-			key = strings.ToLower(key)
+	var opts []oss.Option
+	if o != nil {
+		metadata, err := NormalizeMetadata(o.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range metadata {
 			opts = append(opts, oss.Meta(key, val))
 		}
 	}
+	if o != nil && !o.Expires.IsZero() {
+		opts = append(opts, oss.Expires(o.Expires))
+		opts = append(opts, oss.SetTagging(oss.Tagging{
+			Tags: []oss.Tag{{Key: ExpiresTagKey, Value: expiresDays(o.Expires)}},
+		}))
+	}
 
-	return client.bucket.PutObject(key, io.NopCloser(r), opts...)
+	if o != nil && o.ContentType != "" {
+		opts = append(opts, oss.ContentType(o.ContentType))
+	}
+	if o != nil {
+		for k, values := range o.Header {
+			if len(values) > 0 {
+				opts = append(opts, oss.SetHeader(k, values[0]))
+			}
+		}
+	}
+	if o != nil && o.IfMatch != "" {
+		opts = append(opts, oss.IfMatch(`"`+o.IfMatch+`"`))
+	}
+	if o != nil && o.IfNoneMatch == "*" {
+		// OSS fails the write of existing objects by the header.
+		opts = append(opts, oss.ForbidOverWrite(true))
+	} else if o != nil && o.IfNoneMatch != "" {
+		opts = append(opts, oss.IfNoneMatch(`"`+o.IfNoneMatch+`"`))
+	}
+
+	size, ok := writeSize(r, o)
+	if o != nil && o.Progress != nil {
+		total := size
+		if !ok {
+			total = -1
+		}
+		r = withProgress(r, total, o.Progress)
+	}
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := client.transfers.context(ctx)
+	reader := newStallReader(r, nil, cancel, client.stall, "Write", key)
+	defer reader.Close()
+
+	result := &WriteResult{}
+	if ok && size > client.upload.partSize && !o.conditional() {
+		parts, err := client.uploadParts(ctx, key, reader, size, &header, opts)
+		if err != nil {
+			return nil, reader.wrapError(translateError(err))
+		}
+		result.ETag = strings.Trim(parts.ETag, "\"")
+	} else {
+		opts = append(opts, oss.WithContext(ctx), oss.GetResponseHeader(&header))
+		err := bucket.PutObject(key, io.NopCloser(reader), opts...)
+		if err != nil {
+			return nil, reader.wrapError(translateError(err))
+		}
+		result.ETag = strings.Trim(header.Get("ETag"), "\"")
+	}
+	result.VersionID = header.Get("X-Oss-Version-Id")
+	// OSS doesn't return Last-Modified for uploads, but the server time of
+	// the response is the time the object was stored.
+	result.LastModified, _ = time.Parse(http.TimeFormat, header.Get("Date"))
+
+	return result, nil
 }
 
 func (client *OSSClient) Exist(ctx context.Context, key string) (bool, error) {
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	if err := checkKeys(client.strict, key); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
-	return client.bucket.IsObjectExist(key, oss.WithContext(ctx))
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return false, err
+	}
+	return bucket.IsObjectExist(key, oss.WithContext(ctx))
 }
 
 func (client *OSSClient) Remove(ctx context.Context, keys ...string) error {
+	if err := checkKeys(client.strict, keys...); err != nil {
+		return err
+	}
+
 	if len(keys) == 0 {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
-	_, err := client.bucket.DeleteObjects(keys, oss.WithContext(ctx))
-	return err
-}
+	var results []RemoveResult
+	for len(keys) > 0 {
+		// OSS deletes at most 1000 objects per request.
+		batch := keys[:min(len(keys), ossMaxDeleteKeys)]
+		keys = keys[len(batch):]
 
-func (client *OSSClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
-	var opts []oss.Option
-	opts = append(opts, oss.WithContext(ctx))
-	opts = append(opts, oss.Prefix(prefix))
-	opts = append(opts, oss.MaxKeys(1000))
-
-	var (
-		items []ObjectItem
-		token string
-	)
-	for {
-		o := append(opts, oss.ContinuationToken(token))
-		list, err := client.bucket.ListObjectsV2(o...)
+		result, err := bucket.DeleteObjects(batch, oss.WithContext(ctx))
 		if err != nil {
-			return nil, err
-		}
-		for _, obj := range list.Objects {
-			items = append(items, ObjectItem{
-				Key:          obj.Key,
-				Size:         obj.Size,
-				LastModified: obj.LastModified,
-			})
+			for _, key := range batch {
+				results = append(results, RemoveResult{Key: key, Err: translateError(err)})
+			}
+			continue
 		}
 
-		if !list.IsTruncated {
-			break
+		deleted := make(map[string]bool, len(result.DeletedObjects))
+		for _, key := range result.DeletedObjects {
+			deleted[key] = true
 		}
-		token = list.NextContinuationToken
+		for _, key := range batch {
+			if !deleted[key] {
+				results = append(results, RemoveResult{Key: key, Err: errors.New("not deleted")})
+			}
+		}
+	}
+	if len(results) > 0 {
+		return &RemoveError{Results: results}
+	}
+
+	return nil
+}
+
+func (client *OSSClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	var items []ObjectItem
+	err := client.ListPages(ctx, prefix, "", func(page []ObjectItem) bool {
+		items = append(items, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return items, nil
 }
 
 func (client *OSSClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	if err := checkKeys(client.strict, key); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
-	header, err := client.bucket.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+	bucket, err := client.bucketOf(ctx)
 	if err != nil {
 		return nil, err
 	}
+	header, err := bucket.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+	if err != nil {
+		return nil, translateError(err)
+	}
 
 	var info ObjectInfo
 
 	info.Size, err = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	info.LastModified, err = time.Parse(http.TimeFormat, header.Get("Last-Modified"))
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
-	info.Metadata = make(map[string]string)
+	info.ETag = strings.Trim(header.Get("ETag"), "\"")
+	info.ContentType = header.Get("Content-Type")
+
+	metadata := make(map[string]string)
 	for key := range header {
 		if !strings.HasPrefix(key, "X-Oss-Meta-") {
 			continue
 		}
-		k := strings.TrimPrefix(key, "X-Oss-Meta-")
-		info.Metadata[strings.ToLower(k)] = header.Get(key)
+		metadata[strings.TrimPrefix(key, "X-Oss-Meta-")] = header.Get(key)
 	}
+	info.Metadata = decodeMetadata(metadata)
 
 	return &info, nil
 }
 
 func (client *OSSClient) Copy(ctx context.Context, src, dst string) error {
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	if err := checkKeys(client.strict, src, dst); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
-	_, err := client.bucket.CopyObject(src, dst, oss.WithContext(ctx))
-	return err
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.CopyObject(src, dst, oss.WithContext(ctx))
+	return translateError(err)
+}
+
+// Close cancels the reads and writes in flight, and closes the idle
+// connections.
+func (client *OSSClient) Close() error {
+	client.transfers.close(client.transport)
+	return nil
+}
+
+func (config OSSConfig) transportConfig() transportConfig {
+	return transportConfig{
+		ProxyURL:           config.ProxyURL,
+		CAFile:             config.CAFile,
+		RootCAs:            config.RootCAs,
+		ClientCertFile:     config.ClientCertFile,
+		ClientKeyFile:      config.ClientKeyFile,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		UserAgent:          config.UserAgent,
+		Headers:            config.Headers,
+
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		HTTP2:                 config.HTTP2,
+	}
 }
@@ -0,0 +1,74 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	pingTimeout = 5 * time.Second
+)
+
+// Pinger is implemented by clients that can check the connectivity to the
+// bucket cheaply. Ping returns an error wrapping ErrAccessDenied,
+// ErrBucketNotFound or ErrUnreachable if the check failed for that reason.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func (client *S3Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return err
+	}
+	exist, err := backend.BucketExists(ctx, client.bucket)
+	if err != nil {
+		resp := minio.ToErrorResponse(err)
+		switch {
+		case accessDeniedCodes[resp.Code] || resp.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+		case isNetworkError(err):
+			return fmt.Errorf("%w: %v", ErrUnreachable, err)
+		}
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("%w: %v", ErrBucketNotFound, client.bucket)
+	}
+
+	return nil
+}
+
+func (client *OSSClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.Client.GetBucketInfo(bucket.BucketName, oss.WithContext(ctx))
+	if err != nil {
+		var serr oss.ServiceError
+		switch {
+		case errors.As(err, &serr) && serr.Code == "NoSuchBucket":
+			return fmt.Errorf("%w: %v", ErrBucketNotFound, client.bucket.BucketName)
+		case errors.As(err, &serr) && (accessDeniedCodes[serr.Code] || serr.StatusCode == http.StatusForbidden):
+			return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+		case isNetworkError(err):
+			return fmt.Errorf("%w: %v", ErrUnreachable, err)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+type prefixClient struct {
+	inner  Client
+	prefix string
+}
+
+// WithPrefix returns a client of the keys under prefix of inner. The prefix
+// is prepended to keys of all operations, and stripped from List results.
+// It usually ends with a "/".
+func WithPrefix(inner Client, prefix string) Client {
+	if prefix == "" {
+		return inner
+	}
+	switch p := inner.(type) {
+	case *prefixClient:
+		inner, prefix = p.inner, p.prefix+prefix
+	case *prefixPageClient:
+		inner, prefix = p.inner, p.prefix+prefix
+	}
+	client := &prefixClient{inner: inner, prefix: prefix}
+	if _, ok := AsPageLister(inner); ok {
+		return &prefixPageClient{client}
+	}
+	return client
+}
+
+// prefixPageClient is the prefixClient of a PageLister.
+type prefixPageClient struct {
+	*prefixClient
+}
+
+func (client *prefixPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	if startAfter != "" {
+		startAfter = client.prefix + startAfter
+	}
+	return client.inner.(PageLister).ListPages(ctx, client.prefix+prefix, startAfter, func(items []ObjectItem) bool {
+		for i := range items {
+			items[i].Key = strings.TrimPrefix(items[i].Key, client.prefix)
+		}
+		return fn(items)
+	})
+}
+
+func (client *prefixClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, client.prefix+key)
+}
+
+func (client *prefixClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, client.prefix+key, o)
+}
+
+func (client *prefixClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	return client.inner.Write(ctx, client.prefix+key, r, o)
+}
+
+func (client *prefixClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	return client.inner.WriteWithResult(ctx, client.prefix+key, r, o)
+}
+
+func (client *prefixClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, client.prefix+key)
+}
+
+func (client *prefixClient) Remove(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = client.prefix + key
+	}
+
+	err := client.inner.Remove(ctx, prefixed...)
+	var rerr *RemoveError
+	if errors.As(err, &rerr) {
+		results := make([]RemoveResult, len(rerr.Results))
+		for i, result := range rerr.Results {
+			results[i] = RemoveResult{Key: strings.TrimPrefix(result.Key, client.prefix), Err: result.Err}
+		}
+		return &RemoveError{Results: results}
+	}
+	return err
+}
+
+func (client *prefixClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	items, err := client.inner.List(ctx, client.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Key = strings.TrimPrefix(items[i].Key, client.prefix)
+	}
+	return items, nil
+}
+
+func (client *prefixClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, client.prefix+key)
+}
+
+func (client *prefixClient) Copy(ctx context.Context, src, dst string) error {
+	return client.inner.Copy(ctx, client.prefix+src, client.prefix+dst)
+}
+
+func (client *prefixClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,33 @@
+package objclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithPrefix(t *testing.T) {
+	mem := newMemClient()
+	client := WithPrefix(WithPrefix(mem, "tenant/"), "lib/")
+
+	err := client.Write(ctx, "objclient/test", strings.NewReader("demo"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, mem, "tenant/lib/objclient/test"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	if data := readString(t, client, "objclient/test"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+
+	if err := client.Copy(ctx, "objclient/test", "objclient/copy"); err != nil {
+		t.Fatal(err)
+	}
+	items, err := client.List(ctx, "objclient/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Key != "objclient/copy" || items[1].Key != "objclient/test" {
+		t.Fatalf("invalid items: %v", items)
+	}
+}
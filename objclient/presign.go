@@ -0,0 +1,68 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// Presigner is implemented by clients that can create presigned URLs, which
+// allow reading an object without credentials until expires.
+type Presigner interface {
+	PresignRead(ctx context.Context, key string, expires time.Duration, o *ReadOptions) (string, error)
+}
+
+func (client *S3Client) PresignRead(ctx context.Context, key string, expires time.Duration, o *ReadOptions) (string, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return "", err
+	}
+
+	if o != nil && o.Process != "" {
+		return "", errors.New("the process option isn't supported")
+	}
+	if client.sseckey != nil {
+		// The SSE-C key must be sent in headers, which can't be presigned.
+		return "", errors.New("presigned url isn't supported with SSE-C key")
+	}
+
+	params, err := responseParams(o)
+	if err != nil {
+		return "", err
+	}
+	var header http.Header
+	if o != nil {
+		header = o.Header
+	}
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := backend.PresignHeader(ctx, http.MethodGet, client.bucket, key, expires, params, header)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (client *OSSClient) PresignRead(ctx context.Context, key string, expires time.Duration, o *ReadOptions) (string, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return "", err
+	}
+
+	opts, err := ossReadOptions(o)
+	if err != nil {
+		return "", err
+	}
+	if o != nil && o.Process != "" {
+		opts = append(opts, oss.Process(o.Process))
+	}
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return "", err
+	}
+	return bucket.SignURL(key, oss.HTTPGet, int64(expires/time.Second), opts...)
+}
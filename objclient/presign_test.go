@@ -0,0 +1,66 @@
+package objclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient/s3test"
+)
+
+func TestS3Headers(t *testing.T) {
+	ctx := context.Background()
+	server := s3test.NewTLSServer("bucket")
+	defer server.Close()
+	client, err := NewS3Client(S3Config{
+		Endpoint: server.Endpoint(), Region: s3test.Region, HTTPS: "true", Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true", RootCAs: server.RootCAs(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	// The headers without values are skipped.
+	header := http.Header{"Cache-Control": {"no-cache"}, "Content-Language": {}}
+	if err := client.Write(ctx, "export.csv", strings.NewReader("a,b"), &WriteOptions{ContentType: "text/csv", Header: header}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Write(ctx, "other", strings.NewReader(""), &WriteOptions{Header: http.Header{"X-Custom": {"v"}}}); err == nil {
+		t.Fatalf("unsupported header is written")
+	}
+
+	// The presigned URL downloads it as a named attachment.
+	url, err := client.(Presigner).PresignRead(ctx, "export.csv", time.Minute, &ReadOptions{
+		ResponseHeader: http.Header{"Content-Disposition": {`attachment; filename="report.csv"`}},
+	})
+	if err != nil {
+		t.Fatalf("failed to presign: %v", err)
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: server.RootCAs()}}}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(data) != "a,b" ||
+		resp.Header.Get("Content-Disposition") != `attachment; filename="report.csv"` ||
+		resp.Header.Get("Content-Type") != "text/csv" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("invalid response %v %q: %v", resp.Status, data, resp.Header)
+	}
+
+	r, err := client.ReadWithOptions(ctx, "export.csv", &ReadOptions{
+		Header:         http.Header{"X-Amz-Request-Payer": {"requester"}, "X-Amz-Expected-Bucket-Owner": nil},
+		ResponseHeader: http.Header{"Content-Type": {"text/plain"}, "Content-Language": {}},
+	})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	r.Close()
+	if _, err := client.ReadWithOptions(ctx, "export.csv", &ReadOptions{ResponseHeader: http.Header{"Etag": {"x"}}}); err == nil {
+		t.Fatalf("invalid response header is overridden")
+	}
+}
@@ -0,0 +1,74 @@
+package objclient
+
+import (
+	"io"
+	"sync"
+)
+
+// Progress is called with the bytes transferred so far and the total bytes,
+// which is -1 if it's unknown. The calls are serialized.
+type Progress func(transferred, total int64)
+
+// progressCounter reports the sum of bytes of concurrent transfers.
+type progressCounter struct {
+	mutex       sync.Mutex
+	transferred int64
+	total       int64
+	fn          Progress
+}
+
+func newProgressCounter(total int64, fn Progress) *progressCounter {
+	return &progressCounter{total: total, fn: fn}
+}
+
+func (counter *progressCounter) add(n int64) {
+	if counter == nil || counter.fn == nil || n == 0 {
+		return
+	}
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.transferred += n
+	counter.fn(counter.transferred, counter.total)
+}
+
+type progressReader struct {
+	r       io.Reader
+	counter *progressCounter
+}
+
+func (reader *progressReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.counter.add(int64(n))
+	return n, err
+}
+
+// withProgress returns r if fn is nil.
+func withProgress(r io.Reader, total int64, fn Progress) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &progressReader{r: r, counter: newProgressCounter(total, fn)}
+}
+
+// readCloserWithProgress is withProgress of the readers of Read.
+func readCloserWithProgress(r io.ReadCloser, total int64, fn Progress) io.ReadCloser {
+	if fn == nil {
+		return r
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{withProgress(r, total, fn), r}
+}
+
+// progressWriter counts the bytes written to w.
+type progressWriter struct {
+	w       io.Writer
+	counter *progressCounter
+}
+
+func (writer *progressWriter) Write(data []byte) (int, error) {
+	n, err := writer.w.Write(data)
+	writer.counter.add(int64(n))
+	return n, err
+}
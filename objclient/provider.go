@@ -0,0 +1,195 @@
+package objclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Credentials are the keys of backends, Token is set for temporary ones.
+type Credentials struct {
+	KeyID string
+	Key   string
+	Token string
+	// Expiration is zero if they don't expire.
+	Expiration time.Time
+}
+
+// CredentialsProvider supplies the credentials of clients, from e.g. Vault
+// or secret managers. It's set by the Credentials field of configs.
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+	// IsExpired reports whether the credentials returned by Retrieve should
+	// be retrieved again.
+	IsExpired() bool
+	// OnRotate registers fn to be called after the credentials are changed,
+	// so clients retrieve them before the next request.
+	OnRotate(fn func())
+}
+
+const (
+	credentialsRetrieveTimeout = 30 * time.Second
+)
+
+// providerAdapter adapts CredentialsProvider to the ones of SDKs.
+type providerAdapter struct {
+	provider CredentialsProvider
+	rotated  atomic.Bool
+
+	mutex sync.Mutex
+	creds *Credentials
+}
+
+func newProviderAdapter(provider CredentialsProvider) *providerAdapter {
+	adapter := &providerAdapter{provider: provider}
+	provider.OnRotate(func() { adapter.rotated.Store(true) })
+	return adapter
+}
+
+func (adapter *providerAdapter) expired() bool {
+	return adapter.rotated.Load() || adapter.provider.IsExpired()
+}
+
+func (adapter *providerAdapter) retrieve() (Credentials, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsRetrieveTimeout)
+	defer cancel()
+
+	adapter.rotated.Store(false)
+	creds, err := adapter.provider.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// Retrieve and IsExpired implement credentials.Provider of minio, which
+// caches the credentials.
+func (adapter *providerAdapter) Retrieve() (credentials.Value, error) {
+	creds, err := adapter.retrieve()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	return credentials.Value{
+		AccessKeyID:     creds.KeyID,
+		SecretAccessKey: creds.Key,
+		SessionToken:    creds.Token,
+		Expiration:      creds.Expiration,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+func (adapter *providerAdapter) IsExpired() bool {
+	return adapter.expired()
+}
+
+// GetCredentials and GetCredentialsE implement oss.CredentialsProviderE.
+func (adapter *providerAdapter) GetCredentials() oss.Credentials {
+	creds, err := adapter.GetCredentialsE()
+	if err != nil {
+		return &ossCredentials{}
+	}
+	return creds
+}
+
+func (adapter *providerAdapter) GetCredentialsE() (oss.Credentials, error) {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+
+	if adapter.creds == nil || adapter.expired() {
+		creds, err := adapter.retrieve()
+		if err != nil {
+			return nil, err
+		}
+		adapter.creds = &creds
+	}
+	return &ossCredentials{keyID: adapter.creds.KeyID, secret: adapter.creds.Key, token: adapter.creds.Token}, nil
+}
+
+type fileCredentials struct {
+	KeyID string `json:"key_id"`
+	Key   string `json:"key"`
+	Token string `json:"token,omitempty"`
+}
+
+type fileProvider struct {
+	path string
+
+	mutex    sync.Mutex
+	modified time.Time
+	checked  time.Time
+	stale    bool
+	handlers []func()
+}
+
+// NewFileCredentialsProvider returns the credentials in the JSON file of
+// path, e.g. {"key_id": "...", "key": "..."}. The file is reloaded after
+// it's changed, which is checked at most once a second.
+func NewFileCredentialsProvider(path string) CredentialsProvider {
+	return &fileProvider{path: path}
+}
+
+func (p *fileProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	stat, err := os.Stat(p.path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var creds fileCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("invalid credentials file %v: %w", p.path, err)
+	}
+	if creds.KeyID == "" || creds.Key == "" {
+		return Credentials{}, fmt.Errorf("missing key_id or key in %v", p.path)
+	}
+
+	p.mutex.Lock()
+	p.modified = stat.ModTime()
+	p.checked = time.Now()
+	p.stale = false
+	p.mutex.Unlock()
+
+	return Credentials{KeyID: creds.KeyID, Key: creds.Key, Token: creds.Token}, nil
+}
+
+func (p *fileProvider) IsExpired() bool {
+	p.mutex.Lock()
+	if p.stale {
+		p.mutex.Unlock()
+		return true
+	}
+	if time.Since(p.checked) < time.Second {
+		p.mutex.Unlock()
+		return false
+	}
+	p.checked = time.Now()
+
+	stat, err := os.Stat(p.path)
+	if err != nil || stat.ModTime().Equal(p.modified) {
+		p.mutex.Unlock()
+		return false
+	}
+	p.stale = true
+	handlers := p.handlers
+	p.mutex.Unlock()
+
+	for _, fn := range handlers {
+		fn()
+	}
+	return true
+}
+
+func (p *fileProvider) OnRotate(fn func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.handlers = append(p.handlers, fn)
+}
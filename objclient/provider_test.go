@@ -0,0 +1,60 @@
+package objclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCredentialsProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"key_id": "id1", "key": "secret1"}`), 0o600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+
+	adapter := newProviderAdapter(NewFileCredentialsProvider(path))
+	creds, err := adapter.GetCredentialsE()
+	if err != nil {
+		t.Fatalf("failed to get credentials: %v", err)
+	}
+	if creds.GetAccessKeyID() != "id1" || creds.GetAccessKeySecret() != "secret1" {
+		t.Fatalf("invalid credentials: %v", creds.GetAccessKeyID())
+	}
+	if adapter.IsExpired() {
+		t.Fatalf("invalid expiration before rotation")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"key_id": "id2", "key": "secret2", "token": "token2"}`), 0o600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+	modified := time.Now().Add(time.Minute)
+	os.Chtimes(path, modified, modified)
+	time.Sleep(1100 * time.Millisecond)
+
+	value, err := adapter.Retrieve()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if value.AccessKeyID != "id2" || value.SessionToken != "token2" {
+		t.Fatalf("invalid rotated credentials: %v", value.AccessKeyID)
+	}
+	// The OSS adapter notices the rotation too.
+	adapter.creds = nil
+	creds, err = adapter.GetCredentialsE()
+	if err != nil || creds.GetAccessKeyID() != "id2" {
+		t.Fatalf("invalid rotated credentials: %v", err)
+	}
+}
+
+func TestCredentialsProviderConfig(t *testing.T) {
+	provider := NewFileCredentialsProvider("credentials.json")
+	config := &S3Config{Bucket: "bucket", Endpoint: "s3.amazonaws.com", KeyID: "id", Key: "key", Credentials: provider}
+	if err := config.Validate(); err == nil {
+		t.Fatalf("invalid validation of keys with credentials")
+	}
+	config.KeyID, config.Key = "", ""
+	if _, err := NewS3Client(*config); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageStore keeps the usage in bytes of prefixes. Implementations shared
+// by multiple processes should make Add atomic.
+type UsageStore interface {
+	Usage(ctx context.Context, prefix string) (int64, error)
+	// Add adds delta to the usage of prefix, and returns the new usage.
+	Add(ctx context.Context, prefix string, delta int64) (int64, error)
+	Set(ctx context.Context, prefix string, usage int64) error
+	// Prefixes returns the prefixes with usage.
+	Prefixes(ctx context.Context) ([]string, error)
+}
+
+type memoryUsageStore struct {
+	mutex sync.Mutex
+	usage map[string]int64
+}
+
+// NewMemoryUsageStore returns a UsageStore in memory, which is only
+// suitable for a single process.
+func NewMemoryUsageStore() UsageStore {
+	return &memoryUsageStore{usage: make(map[string]int64)}
+}
+
+func (store *memoryUsageStore) Usage(ctx context.Context, prefix string) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.usage[prefix], nil
+}
+
+func (store *memoryUsageStore) Add(ctx context.Context, prefix string, delta int64) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.usage[prefix] += delta
+	return store.usage[prefix], nil
+}
+
+func (store *memoryUsageStore) Set(ctx context.Context, prefix string, usage int64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.usage[prefix] = usage
+	return nil
+}
+
+func (store *memoryUsageStore) Prefixes(ctx context.Context) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	prefixes := make([]string, 0, len(store.usage))
+	for prefix := range store.usage {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+type QuotaOptions struct {
+	// PrefixFunc returns the prefix of key which the quota applies to. By
+	// default it's the key up to and including the first "/".
+	PrefixFunc func(key string) string
+	// ReconcileInterval is the interval of reconciling the usage of all
+	// prefixes in store against listings, no reconciliation in background
+	// if it's 0.
+	ReconcileInterval time.Duration
+	// OnReconcileError is called when reconciling in background failed.
+	OnReconcileError func(prefix string, err error)
+}
+
+func firstSegment(key string) string {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return ""
+}
+
+// QuotaClient rejects writes exceeding the quota of their prefix with
+// ErrQuotaExceeded. The usage is tracked by the writes, copies and removes
+// through the client, and drifts with operations not through it, which is
+// fixed by Reconcile.
+type QuotaClient struct {
+	inner Client
+	quota int64
+	store UsageStore
+	opts  QuotaOptions
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewQuotaClient limits the usage of each prefix to quotaBytes. The options
+// can be nil. Close should be called to stop reconciling in background.
+func NewQuotaClient(inner Client, quotaBytes int64, store UsageStore, opts *QuotaOptions) *QuotaClient {
+	var o QuotaOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.PrefixFunc == nil {
+		o.PrefixFunc = firstSegment
+	}
+
+	client := &QuotaClient{
+		inner: inner,
+		quota: quotaBytes,
+		store: store,
+		opts:  o,
+		done:  make(chan struct{}),
+	}
+
+	var ctx context.Context
+	ctx, client.cancel = context.WithCancel(context.Background())
+	if o.ReconcileInterval > 0 {
+		go client.reconcileLoop(ctx)
+	} else {
+		close(client.done)
+	}
+
+	return client
+}
+
+func (client *QuotaClient) reconcileLoop(ctx context.Context) {
+	defer close(client.done)
+
+	ticker := time.NewTicker(client.opts.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		prefixes, err := client.store.Prefixes(ctx)
+		if err != nil {
+			client.reconcileError("", err)
+			continue
+		}
+		for _, prefix := range prefixes {
+			if err := client.Reconcile(ctx, prefix); err != nil {
+				client.reconcileError(prefix, err)
+			}
+		}
+	}
+}
+
+func (client *QuotaClient) reconcileError(prefix string, err error) {
+	if client.opts.OnReconcileError != nil {
+		client.opts.OnReconcileError(prefix, err)
+	}
+}
+
+// Reconcile sets the usage of prefix to the total size of objects listed,
+// whose keys are of the prefix by PrefixFunc. E.g. the prefix "" of the
+// default is of the keys without "/", not the whole bucket.
+func (client *QuotaClient) Reconcile(ctx context.Context, prefix string) error {
+	items, err := client.inner.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list %v: %w", prefix, err)
+	}
+
+	var usage int64
+	for _, item := range items {
+		if client.opts.PrefixFunc(item.Key) == prefix {
+			usage += item.Size
+		}
+	}
+	return client.store.Set(ctx, prefix, usage)
+}
+
+// Close stops reconciling in background, and closes the inner client.
+func (client *QuotaClient) Close() error {
+	client.cancel()
+	<-client.done
+	return client.inner.Close()
+}
+
+// size returns the size of key, or 0 if it doesn't exist.
+func (client *QuotaClient) size(ctx context.Context, key string) (int64, error) {
+	info, err := client.inner.Info(ctx, key)
+	if isNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// reserve adds delta to the usage of prefix, and fails if the quota is
+// exceeded by a positive delta.
+func (client *QuotaClient) reserve(ctx context.Context, prefix string, delta int64) (int64, error) {
+	usage, err := client.store.Add(ctx, prefix, delta)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update usage of %v: %w", prefix, err)
+	}
+	if delta > 0 && usage > client.quota {
+		client.store.Add(ctx, prefix, -delta)
+		return 0, fmt.Errorf("%w: %v uses %v of %v bytes", ErrQuotaExceeded, prefix, usage, client.quota)
+	}
+	return usage, nil
+}
+
+func (client *QuotaClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, key)
+}
+
+func (client *QuotaClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *QuotaClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+// quotaReader fails reading if more than limit bytes are read.
+type quotaReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (reader *quotaReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.n += int64(n)
+	if reader.n > reader.limit {
+		return n, ErrQuotaExceeded
+	}
+	return n, err
+}
+
+// WriteWithResult reserves the size of the write before writing. If the
+// size isn't set or detected, the content is counted and the write is
+// aborted once it exceeds the quota.
+func (client *QuotaClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	prefix := client.opts.PrefixFunc(key)
+	o = SizedWriteOptions(r, o)
+
+	old, err := client.size(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	if o != nil && o.Size > 0 {
+		size = o.Size
+	}
+
+	reserved := size - old
+	usage, err := client.reserve(ctx, prefix, reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &quotaReader{r: r, limit: client.quota - usage + size}
+	result, err := client.inner.WriteWithResult(ctx, key, reader, o)
+	if err != nil {
+		client.store.Add(ctx, prefix, -reserved)
+		if reader.n > reader.limit {
+			return nil, fmt.Errorf("%w: writing %v to %v", ErrQuotaExceeded, key, prefix)
+		}
+		return nil, err
+	}
+	if reader.n != size {
+		client.store.Add(ctx, prefix, reader.n-size)
+	}
+
+	return result, nil
+}
+
+func (client *QuotaClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *QuotaClient) Remove(ctx context.Context, keys ...string) error {
+	sizes := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		size, err := client.size(ctx, key)
+		if err != nil {
+			return err
+		}
+		sizes[key] = size
+	}
+
+	err := client.inner.Remove(ctx, keys...)
+	var rerr *RemoveError
+	if err != nil && !errors.As(err, &rerr) {
+		return err
+	}
+	if rerr != nil {
+		for _, key := range rerr.Keys() {
+			delete(sizes, key)
+		}
+	}
+
+	for key, size := range sizes {
+		if size > 0 {
+			client.store.Add(ctx, client.opts.PrefixFunc(key), -size)
+		}
+	}
+	return err
+}
+
+func (client *QuotaClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+// ListPages implements PageLister by the pages of inner, or of its List.
+func (client *QuotaClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return listPages(ctx, client.inner, prefix, startAfter, fn)
+}
+
+// listsPages reports whether inner is a PageLister, for AsPageLister.
+func (client *QuotaClient) listsPages() bool {
+	_, ok := AsPageLister(client.inner)
+	return ok
+}
+
+func (client *QuotaClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *QuotaClient) Copy(ctx context.Context, src, dst string) error {
+	info, err := client.inner.Info(ctx, src)
+	if err != nil {
+		return err
+	}
+	old, err := client.size(ctx, dst)
+	if err != nil {
+		return err
+	}
+
+	prefix := client.opts.PrefixFunc(dst)
+	reserved := info.Size - old
+	if _, err := client.reserve(ctx, prefix, reserved); err != nil {
+		return err
+	}
+	if err := client.inner.Copy(ctx, src, dst); err != nil {
+		client.store.Add(ctx, prefix, -reserved)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package objclient
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuotaClient(t *testing.T) {
+	mem := newMemClient()
+	store := NewMemoryUsageStore()
+	client := NewQuotaClient(mem, 10, store, nil)
+	defer client.Close()
+
+	err := client.Write(ctx, "lib1/a", strings.NewReader("123456"), &WriteOptions{Size: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Write(ctx, "lib1/b", strings.NewReader("123456"), &WriteOptions{Size: 6})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expect quota exceeded: %v", err)
+	}
+	// Size is unknown, it's counted.
+	err = client.Write(ctx, "lib1/b", strings.NewReader("123456"), nil)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expect quota exceeded: %v", err)
+	}
+	if err := client.Write(ctx, "lib2/b", strings.NewReader("123456"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwriting frees the old size.
+	if err := client.Write(ctx, "lib1/a", strings.NewReader("1234567890"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := store.Usage(ctx, "lib1/"); usage != 10 {
+		t.Fatalf("invalid usage: %v", usage)
+	}
+
+	if err := client.Copy(ctx, "lib1/a", "lib2/a"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expect quota exceeded: %v", err)
+	}
+	if err := client.Remove(ctx, "lib1/a"); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := store.Usage(ctx, "lib1/"); usage != 0 {
+		t.Fatalf("invalid usage: %v", usage)
+	}
+
+	mem.put("lib2/c", "123")
+	if err := client.Reconcile(ctx, "lib2/"); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := store.Usage(ctx, "lib2/"); usage != 9 {
+		t.Fatalf("invalid usage: %v", usage)
+	}
+
+	// The prefix of root keys is "", whose usage isn't of the others.
+	mem.put("root", "12")
+	if err := client.Reconcile(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := store.Usage(ctx, ""); usage != 2 {
+		t.Fatalf("invalid usage of root: %v", usage)
+	}
+
+	// The sizes of the objects replaced are got by Info only.
+	mem.fail = func(op, key string) error {
+		if op == "Exist" {
+			t.Fatalf("size of %v is checked by Exist", key)
+		}
+		return nil
+	}
+	if err := client.Write(ctx, "lib2/c", strings.NewReader("1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Write(ctx, "lib2/d", strings.NewReader("1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := store.Usage(ctx, "lib2/"); usage != 8 {
+		t.Fatalf("invalid usage: %v", usage)
+	}
+}
@@ -0,0 +1,312 @@
+package objclient
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+const (
+	defaultAliyunSTSEndpoint = "https://sts.aliyuncs.com"
+	ramRequestTimeout        = 10 * time.Second
+)
+
+// ecsMetadataEndpoint is the metadata service of ECS instances.
+var ecsMetadataEndpoint = "http://100.100.100.200"
+
+type ossCredentials struct {
+	keyID  string
+	secret string
+	token  string
+}
+
+func (creds *ossCredentials) GetAccessKeyID() string     { return creds.keyID }
+func (creds *ossCredentials) GetAccessKeySecret() string { return creds.secret }
+func (creds *ossCredentials) GetSecurityToken() string   { return creds.token }
+
+// ossCredentialsFetcher returns temporary credentials and their expiration.
+type ossCredentialsFetcher func() (*ossCredentials, time.Time, error)
+
+// refreshingProvider caches the credentials of fetch. They are refreshed in
+// background after 80% of their lifetime, or before the request if they
+// are expired.
+type refreshingProvider struct {
+	fetch ossCredentialsFetcher
+
+	mutex      sync.Mutex
+	creds      *ossCredentials
+	refreshAt  time.Time
+	expiration time.Time
+	refreshing bool
+}
+
+func newRefreshingProvider(fetch ossCredentialsFetcher) *refreshingProvider {
+	return &refreshingProvider{fetch: fetch}
+}
+
+func (p *refreshingProvider) GetCredentials() oss.Credentials {
+	creds, err := p.GetCredentialsE()
+	if err != nil {
+		return &ossCredentials{}
+	}
+	return creds
+}
+
+func (p *refreshingProvider) GetCredentialsE() (oss.Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.creds == nil || !now.Before(p.expiration) {
+		if err := p.refresh(); err != nil {
+			return nil, err
+		}
+		return p.creds, nil
+	}
+
+	if !now.Before(p.refreshAt) && !p.refreshing {
+		p.refreshing = true
+		go func() {
+			creds, expiration, err := p.fetch()
+
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			p.refreshing = false
+			// It's retried by the next request if failed.
+			if err == nil {
+				p.store(creds, expiration)
+			}
+		}()
+	}
+	return p.creds, nil
+}
+
+// refresh should be called with the mutex locked.
+func (p *refreshingProvider) refresh() error {
+	creds, expiration, err := p.fetch()
+	if err != nil {
+		return err
+	}
+	p.store(creds, expiration)
+	return nil
+}
+
+func (p *refreshingProvider) store(creds *ossCredentials, expiration time.Time) {
+	now := time.Now()
+	p.creds = creds
+	p.expiration = expiration
+	p.refreshAt = now.Add(expiration.Sub(now) * 4 / 5)
+}
+
+type ramCredentialsResponse struct {
+	Code            string
+	AccessKeyId     string
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      time.Time
+}
+
+// fetchRAMRole returns the credentials of the RAM role attached to the ECS
+// instance.
+func fetchRAMRole(role string) ossCredentialsFetcher {
+	client := &http.Client{Timeout: ramRequestTimeout}
+
+	return func() (*ossCredentials, time.Time, error) {
+		req, err := http.NewRequest(http.MethodGet, ecsMetadataEndpoint+"/latest/meta-data/ram/security-credentials/"+role, nil)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		// The token is required by the hardened mode of metadata service.
+		if token, err := ecsMetadataToken(client); err == nil {
+			req.Header.Set("X-aliyun-ecs-metadata-token", token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to get credentials of RAM role %v: %w", role, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, time.Time{}, fmt.Errorf("failed to get credentials of RAM role %v: %v", role, resp.Status)
+		}
+
+		var result ramCredentialsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid credentials of RAM role %v: %w", role, err)
+		}
+		if result.Code != "Success" {
+			return nil, time.Time{}, fmt.Errorf("failed to get credentials of RAM role %v: %v", role, result.Code)
+		}
+
+		creds := &ossCredentials{keyID: result.AccessKeyId, secret: result.AccessKeySecret, token: result.SecurityToken}
+		return creds, result.Expiration, nil
+	}
+}
+
+func ecsMetadataToken(client *http.Client) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, ecsMetadataEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aliyun-ecs-metadata-token-ttl-seconds", "21600")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get metadata token: %v", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// aliyunPercentEncode encodes s by the rules of Aliyun RPC signatures.
+func aliyunPercentEncode(s string) string {
+	s = url.QueryEscape(s)
+	return strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(s)
+}
+
+type stsAssumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyId     string
+		AccessKeySecret string
+		SecurityToken   string
+		Expiration      time.Time
+	}
+	Code    string
+	Message string
+}
+
+// fetchAssumeRole returns the credentials of assuming the role of roleARN
+// by STS with the credentials of source.
+func fetchAssumeRole(endpoint, roleARN, sessionName string, source func() (oss.Credentials, error)) ossCredentialsFetcher {
+	client := &http.Client{Timeout: ramRequestTimeout}
+
+	return func() (*ossCredentials, time.Time, error) {
+		creds, err := source()
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to get source credentials: %w", err)
+		}
+
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, time.Time{}, err
+		}
+		params := map[string]string{
+			"Action":           "AssumeRole",
+			"Format":           "JSON",
+			"Version":          "2015-04-01",
+			"AccessKeyId":      creds.GetAccessKeyID(),
+			"SignatureMethod":  "HMAC-SHA1",
+			"SignatureVersion": "1.0",
+			"SignatureNonce":   hex.EncodeToString(nonce),
+			"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+			"RoleArn":          roleARN,
+			"RoleSessionName":  sessionName,
+			"DurationSeconds":  "3600",
+		}
+		if token := creds.GetSecurityToken(); token != "" {
+			params["SecurityToken"] = token
+		}
+
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = aliyunPercentEncode(k) + "=" + aliyunPercentEncode(params[k])
+		}
+		query := strings.Join(pairs, "&")
+
+		mac := hmac.New(sha1.New, []byte(creds.GetAccessKeySecret()+"&"))
+		mac.Write([]byte("GET&%2F&" + aliyunPercentEncode(query)))
+		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		resp, err := client.Get(endpoint + "/?" + query + "&Signature=" + aliyunPercentEncode(signature))
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to assume role %v: %w", roleARN, err)
+		}
+		defer resp.Body.Close()
+
+		var result stsAssumeRoleResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid response of assuming role %v: %w", roleARN, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, time.Time{}, fmt.Errorf("failed to assume role %v: %v %v", roleARN, result.Code, result.Message)
+		}
+
+		c := result.Credentials
+		return &ossCredentials{keyID: c.AccessKeyId, secret: c.AccessKeySecret, token: c.SecurityToken}, c.Expiration, nil
+	}
+}
+
+// ossCredentialsOptions returns the client options of the credentials of
+// config other than the static keys.
+func ossCredentialsOptions(config OSSConfig) []oss.ClientOption {
+	var source func() (oss.Credentials, error)
+	switch {
+	case config.Credentials != nil:
+		return []oss.ClientOption{oss.SetCredentialsProvider(newProviderAdapter(config.Credentials))}
+	case config.RAMRole != "":
+		source = newRefreshingProvider(fetchRAMRole(config.RAMRole)).GetCredentialsE
+	case config.RoleARN != "":
+		static := &ossCredentials{keyID: config.KeyID, secret: config.Key, token: config.SecurityToken}
+		source = func() (oss.Credentials, error) { return static, nil }
+	case config.SecurityToken != "":
+		return []oss.ClientOption{oss.SecurityToken(config.SecurityToken)}
+	default:
+		return nil
+	}
+
+	if config.RoleARN == "" {
+		return []oss.ClientOption{oss.SetCredentialsProvider(&providerFunc{source})}
+	}
+
+	endpoint := config.STSEndpoint
+	if endpoint == "" {
+		endpoint = defaultAliyunSTSEndpoint
+	}
+	sessionName := config.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+	provider := newRefreshingProvider(fetchAssumeRole(endpoint, config.RoleARN, sessionName, source))
+	return []oss.ClientOption{oss.SetCredentialsProvider(provider)}
+}
+
+type providerFunc struct {
+	get func() (oss.Credentials, error)
+}
+
+func (p *providerFunc) GetCredentials() oss.Credentials {
+	creds, err := p.get()
+	if err != nil {
+		return &ossCredentials{}
+	}
+	return creds
+}
+
+func (p *providerFunc) GetCredentialsE() (oss.Credentials, error) {
+	return p.get()
+}
@@ -0,0 +1,69 @@
+package objclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRAMRoleAssumeRole(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("metadata-token"))
+			return
+		}
+		if r.URL.Path != "/latest/meta-data/ram/security-credentials/ecs-role" || r.Header.Get("X-aliyun-ecs-metadata-token") != "metadata-token" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"Code":            "Success",
+			"AccessKeyId":     "ecs-id",
+			"AccessKeySecret": "ecs-secret",
+			"SecurityToken":   "ecs-token",
+			"Expiration":      time.Now().Add(time.Hour).UTC(),
+		})
+	}))
+	defer metadata.Close()
+	ecsMetadataEndpoint = metadata.URL
+	defer func() { ecsMetadataEndpoint = "http://100.100.100.200" }()
+
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("Action") != "AssumeRole" || q.Get("AccessKeyId") != "ecs-id" || q.Get("SecurityToken") != "ecs-token" || q.Get("Signature") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"Code": "InvalidParameter", "Message": "invalid request"}`))
+			return
+		}
+		var resp stsAssumeRoleResponse
+		resp.Credentials.AccessKeyId = "sts-id"
+		resp.Credentials.AccessKeySecret = "sts-secret"
+		resp.Credentials.SecurityToken = "sts-token"
+		resp.Credentials.Expiration = time.Now().Add(time.Hour).UTC()
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer sts.Close()
+
+	options := ossCredentialsOptions(OSSConfig{RAMRole: "ecs-role", RoleARN: "acs:ram::1:role/test", STSEndpoint: sts.URL})
+	if len(options) != 1 {
+		t.Fatalf("invalid options: %v", options)
+	}
+
+	provider := newRefreshingProvider(fetchAssumeRole(sts.URL, "acs:ram::1:role/test", "test",
+		newRefreshingProvider(fetchRAMRole("ecs-role")).GetCredentialsE))
+	creds, err := provider.GetCredentialsE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.GetAccessKeyID() != "sts-id" || creds.GetSecurityToken() != "sts-token" {
+		t.Fatalf("invalid credentials: %+v", creds)
+	}
+}
+
+func TestAliyunPercentEncode(t *testing.T) {
+	if s := aliyunPercentEncode("a b*c~d/e"); s != "a%20b%2Ac~d%2Fe" {
+		t.Fatalf("invalid encoding: %v", s)
+	}
+}
@@ -0,0 +1,121 @@
+package objclient
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+type rateLimitedClient struct {
+	inner    Client
+	limiters map[OpClass]*rate.Limiter
+}
+
+// NewRateLimitedClient limits the requests sent by inner to opsPerSecond
+// with bursts of burst requests. Each class of operations has its own
+// limit, so e.g. heavy listing doesn't delay reads.
+func NewRateLimitedClient(inner Client, opsPerSecond float64, burst int) Client {
+	client := &rateLimitedClient{
+		inner:    inner,
+		limiters: make(map[OpClass]*rate.Limiter),
+	}
+	for _, class := range opClasses {
+		client.limiters[class] = rate.NewLimiter(rate.Limit(opsPerSecond), burst)
+	}
+	if _, ok := AsPageLister(inner); ok {
+		return &rateLimitedPageClient{client}
+	}
+	return client
+}
+
+// rateLimitedPageClient is the rateLimitedClient of a PageLister, each page
+// is a request of OpList.
+type rateLimitedPageClient struct {
+	*rateLimitedClient
+}
+
+func (client *rateLimitedPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	if err := client.wait(ctx, OpList); err != nil {
+		return err
+	}
+	var werr error
+	err := client.inner.(PageLister).ListPages(ctx, prefix, startAfter, func(items []ObjectItem) bool {
+		if !fn(items) {
+			return false
+		}
+		// The next page is requested after fn returns.
+		werr = client.wait(ctx, OpList)
+		return werr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return werr
+}
+
+func (client *rateLimitedClient) wait(ctx context.Context, class OpClass) error {
+	return client.limiters[class].Wait(ctx)
+}
+
+func (client *rateLimitedClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *rateLimitedClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if err := client.wait(ctx, OpRead); err != nil {
+		return nil, err
+	}
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *rateLimitedClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *rateLimitedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if err := client.wait(ctx, OpWrite); err != nil {
+		return nil, err
+	}
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *rateLimitedClient) Exist(ctx context.Context, key string) (bool, error) {
+	if err := client.wait(ctx, OpRead); err != nil {
+		return false, err
+	}
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *rateLimitedClient) Remove(ctx context.Context, keys ...string) error {
+	if err := client.wait(ctx, OpDelete); err != nil {
+		return err
+	}
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *rateLimitedClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	if err := client.wait(ctx, OpList); err != nil {
+		return nil, err
+	}
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *rateLimitedClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := client.wait(ctx, OpRead); err != nil {
+		return nil, err
+	}
+	return client.inner.Info(ctx, key)
+}
+
+func (client *rateLimitedClient) Copy(ctx context.Context, src, dst string) error {
+	if err := client.wait(ctx, OpWrite); err != nil {
+		return err
+	}
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *rateLimitedClient) Close() error {
+	return client.inner.Close()
+}
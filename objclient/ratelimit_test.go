@@ -0,0 +1,27 @@
+package objclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitedClient(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/test", "demo")
+	limited := NewRateLimitedClient(mem, 20, 1)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := limited.Info(ctx, "objclient/test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other classes have their own limits.
+	if _, err := limited.List(ctx, "objclient/"); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+	if elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("invalid elapsed time: %v", elapsed)
+	}
+}
@@ -0,0 +1,98 @@
+package objclient
+
+import (
+	"io"
+	"sync"
+)
+
+const readAheadChunkSize = 1 << 20
+
+type readAheadChunk struct {
+	buffer *[]byte
+	data   []byte
+	err    error
+}
+
+// readAheadReader reads r in the background to the buffered chunks, so
+// reads of the consumer don't wait for the network if it's slower.
+type readAheadReader struct {
+	r       io.ReadCloser
+	chunks  chan *readAheadChunk
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	current *readAheadChunk
+}
+
+// newReadAheadReader returns r if size isn't positive, otherwise a reader
+// which reads up to size bytes ahead of the consumer.
+func newReadAheadReader(r io.ReadCloser, size int64) io.ReadCloser {
+	if size <= 0 {
+		return r
+	}
+	chunkSize := min(size, readAheadChunkSize)
+	count := int((size + chunkSize - 1) / chunkSize)
+	reader := &readAheadReader{
+		r: r,
+		// The chunk being filled is ahead too.
+		chunks: make(chan *readAheadChunk, max(count-1, 0)),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go reader.fill(chunkSize)
+	return reader
+}
+
+func (reader *readAheadReader) fill(chunkSize int64) {
+	defer close(reader.done)
+	defer close(reader.chunks)
+	for {
+		chunk := &readAheadChunk{buffer: getPart(chunkSize)}
+		n, err := io.ReadFull(reader.r, *chunk.buffer)
+		chunk.data = (*chunk.buffer)[:n]
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		chunk.err = err
+
+		select {
+		case reader.chunks <- chunk:
+		case <-reader.stop:
+			putPart(chunk.buffer)
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (reader *readAheadReader) Read(data []byte) (int, error) {
+	for reader.current == nil || len(reader.current.data) == 0 {
+		if reader.current != nil {
+			if reader.current.err != nil {
+				return 0, reader.current.err
+			}
+			putPart(reader.current.buffer)
+		}
+		chunk, ok := <-reader.chunks
+		if !ok {
+			return 0, io.ErrClosedPipe
+		}
+		reader.current = chunk
+	}
+	n := copy(data, reader.current.data)
+	reader.current.data = reader.current.data[n:]
+	return n, nil
+}
+
+func (reader *readAheadReader) Close() error {
+	reader.once.Do(func() { close(reader.stop) })
+	// Close interrupts the read of the filler.
+	err := reader.r.Close()
+	<-reader.done
+	for chunk := range reader.chunks {
+		putPart(chunk.buffer)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package objclient
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadAhead(t *testing.T) {
+	data := make([]byte, 3<<20+100)
+	rand.Read(data)
+
+	pr, pw := io.Pipe()
+	var written atomic.Int64
+	go func() {
+		for i := 0; i < len(data); i += 64 << 10 {
+			n, err := pw.Write(data[i:min(i+64<<10, len(data))])
+			written.Add(int64(n))
+			if err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	r := newReadAheadReader(pr, 2<<20)
+	// The data is read before the consumer reads.
+	for deadline := time.Now().Add(5 * time.Second); written.Load() < 2<<20; {
+		if time.Now().After(deadline) {
+			t.Fatalf("data isn't read ahead: %v", written.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	content, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(content, data) {
+		t.Fatalf("invalid content: %v bytes, %v", len(content), err)
+	}
+	r.Close()
+
+	// Close stops the read of the background.
+	pr, _ = io.Pipe()
+	r = newReadAheadReader(pr, 1<<20)
+	done := make(chan struct{})
+	go func() {
+		r.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("close is blocked")
+	}
+
+	if rc := newReadAheadReader(pr, 0); rc != io.ReadCloser(pr) {
+		t.Fatalf("reader is wrapped without read ahead")
+	}
+}
@@ -0,0 +1,73 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrReadOnly = errors.New("client is read only")
+
+type readOnlyClient struct {
+	inner ReadOnlyClient
+}
+
+// NewReadOnlyClient returns a client of inner whose Write, Remove and Copy
+// fail with ErrReadOnly. Code which only reads should take a ReadOnlyClient
+// instead, so writes are rejected at compile time.
+func NewReadOnlyClient(inner ReadOnlyClient) Client {
+	client := &readOnlyClient{inner: inner}
+	if _, ok := AsPageLister(inner); ok {
+		return &readOnlyPageClient{client}
+	}
+	return client
+}
+
+// readOnlyPageClient is the readOnlyClient of a PageLister.
+type readOnlyPageClient struct {
+	*readOnlyClient
+}
+
+func (client *readOnlyPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *readOnlyClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, key)
+}
+
+func (client *readOnlyClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *readOnlyClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	return ErrReadOnly
+}
+
+func (client *readOnlyClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	return nil, ErrReadOnly
+}
+
+func (client *readOnlyClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *readOnlyClient) Remove(ctx context.Context, keys ...string) error {
+	return ErrReadOnly
+}
+
+func (client *readOnlyClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *readOnlyClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *readOnlyClient) Copy(ctx context.Context, src, dst string) error {
+	return ErrReadOnly
+}
+
+func (client *readOnlyClient) Close() error {
+	return closeReadOnly(client.inner)
+}
@@ -0,0 +1,29 @@
+package objclient
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyClient(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "demo")
+	client := NewReadOnlyClient(mem)
+
+	if data := readString(t, client, "objclient/a"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	if err := client.Write(ctx, "objclient/b", strings.NewReader("demo"), nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expect read only: %v", err)
+	}
+	if err := client.Remove(ctx, "objclient/a"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expect read only: %v", err)
+	}
+	if err := client.Copy(ctx, "objclient/a", "objclient/b"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expect read only: %v", err)
+	}
+	if exist, _ := mem.Exist(ctx, "objclient/a"); !exist {
+		t.Fatalf("object is removed")
+	}
+}
@@ -0,0 +1,332 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// regionProbeKey is the key checked by Exist to probe the clients which
+// aren't Pingers.
+const regionProbeKey = "objclient-probe"
+
+type MultiRegionPolicy struct {
+	// Primary is the region of writes, and of reads when no other region is
+	// known to be healthy and faster.
+	Primary string
+	// ProbeInterval is the interval of probing the regions, defaults to 30
+	// seconds. The regions are probed by Ping if the clients are Pingers,
+	// or by Exist of a key otherwise.
+	ProbeInterval time.Duration
+	// OnProbe is called with the latency or the error of each probe.
+	OnProbe func(region string, latency time.Duration, err error)
+}
+
+// RegionStatus is the status of a region of MultiRegionClient.
+type RegionStatus struct {
+	Region  string
+	Healthy bool
+	// Latency is the moving average of the latencies of probes, zero if the
+	// region isn't probed successfully yet.
+	Latency time.Duration
+	// Err is the error of the last failed probe or read, which makes the
+	// region unhealthy until the next successful probe.
+	Err error
+}
+
+type regionState struct {
+	latency time.Duration
+	err     error
+}
+
+// MultiRegionClient routes the reads to the healthy region of the lowest
+// latency, and the writes to the primary region. The reads which aren't
+// found in other regions are served by the primary, since the replication
+// may not have been done. The read failures of other regions are served by
+// the primary too, and make the regions unhealthy.
+type MultiRegionClient struct {
+	clients map[string]Client
+	policy  MultiRegionPolicy
+
+	mutex   sync.Mutex
+	regions map[string]*regionState
+	// reader is the region serving the reads.
+	reader string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMultiRegionClient returns a client of the regions of clients, including
+// the primary region of policy. The regions are probed in background until
+// it's closed.
+func NewMultiRegionClient(clients map[string]Client, policy MultiRegionPolicy) (*MultiRegionClient, error) {
+	if _, ok := clients[policy.Primary]; !ok {
+		return nil, fmt.Errorf("primary region %q isn't in the clients", policy.Primary)
+	}
+	if policy.ProbeInterval <= 0 {
+		policy.ProbeInterval = 30 * time.Second
+	}
+
+	client := &MultiRegionClient{
+		clients: make(map[string]Client, len(clients)),
+		policy:  policy,
+		regions: make(map[string]*regionState, len(clients)),
+		reader:  policy.Primary,
+		done:    make(chan struct{}),
+	}
+	for region, c := range clients {
+		client.clients[region] = c
+		client.regions[region] = &regionState{}
+	}
+	var ctx context.Context
+	ctx, client.cancel = context.WithCancel(context.Background())
+	go client.run(ctx)
+	return client, nil
+}
+
+func (client *MultiRegionClient) run(ctx context.Context) {
+	defer close(client.done)
+	ticker := time.NewTicker(client.policy.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		client.probeAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// probeAll probes the regions at the same time.
+func (client *MultiRegionClient) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for region, c := range client.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency, err := probe(ctx, c)
+			if ctx.Err() != nil {
+				return
+			}
+			if client.policy.OnProbe != nil {
+				client.policy.OnProbe(region, latency, err)
+			}
+			client.update(region, latency, err)
+		}()
+	}
+	wg.Wait()
+}
+
+func probe(ctx context.Context, client Client) (time.Duration, error) {
+	start := time.Now()
+	if pinger, ok := client.(Pinger); ok {
+		err := pinger.Ping(ctx)
+		return time.Since(start), err
+	}
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	_, err := client.Exist(ctx, regionProbeKey)
+	return time.Since(start), err
+}
+
+// update records the result of a probe of region, and chooses the reader.
+func (client *MultiRegionClient) update(region string, latency time.Duration, err error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	state := client.regions[region]
+	state.err = err
+	if err == nil {
+		if state.latency == 0 {
+			state.latency = latency
+		} else {
+			state.latency = (state.latency*7 + latency*3) / 10
+		}
+	}
+	client.chooseLocked()
+}
+
+func (client *MultiRegionClient) chooseLocked() {
+	client.reader = client.policy.Primary
+	var best time.Duration
+	for region, state := range client.regions {
+		if state.err != nil || state.latency == 0 {
+			continue
+		}
+		if best == 0 || state.latency < best || (state.latency == best && region == client.policy.Primary) {
+			client.reader, best = region, state.latency
+		}
+	}
+}
+
+// fail makes region unhealthy by err of a read.
+func (client *MultiRegionClient) fail(region string, err error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.regions[region].err = err
+	client.chooseLocked()
+}
+
+// read returns the region and the client serving the reads.
+func (client *MultiRegionClient) read() (string, Client) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.reader, client.clients[client.reader]
+}
+
+func (client *MultiRegionClient) primary() Client {
+	return client.clients[client.policy.Primary]
+}
+
+// fallback returns whether the read of region failed with err is served by
+// the primary.
+func (client *MultiRegionClient) fallback(region string, err error) bool {
+	if err == nil || region == client.policy.Primary {
+		return false
+	}
+	if isNotFound(err) {
+		return true
+	}
+	if !shouldFailover(err) {
+		return false
+	}
+	client.fail(region, err)
+	return true
+}
+
+// Status returns the status of the regions in the order of the names.
+func (client *MultiRegionClient) Status() []RegionStatus {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	status := make([]RegionStatus, 0, len(client.regions))
+	for region, state := range client.regions {
+		status = append(status, RegionStatus{
+			Region:  region,
+			Healthy: state.err == nil && state.latency > 0,
+			Latency: state.latency,
+			Err:     state.err,
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Region < status[j].Region })
+	return status
+}
+
+// Reader returns the region serving the reads.
+func (client *MultiRegionClient) Reader() string {
+	region, _ := client.read()
+	return region
+}
+
+func (client *MultiRegionClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *MultiRegionClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	region, reader := client.read()
+	r, err := reader.ReadWithOptions(ctx, key, o)
+	if client.fallback(region, err) {
+		return client.primary().ReadWithOptions(ctx, key, o)
+	}
+	return r, err
+}
+
+func (client *MultiRegionClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	return client.primary().Write(ctx, key, r, o)
+}
+
+func (client *MultiRegionClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	return client.primary().WriteWithResult(ctx, key, r, o)
+}
+
+// Exist checks the primary if the object doesn't exist in other regions.
+func (client *MultiRegionClient) Exist(ctx context.Context, key string) (bool, error) {
+	region, reader := client.read()
+	exist, err := reader.Exist(ctx, key)
+	if region != client.policy.Primary && err == nil && !exist {
+		return client.primary().Exist(ctx, key)
+	}
+	if client.fallback(region, err) {
+		return client.primary().Exist(ctx, key)
+	}
+	return exist, err
+}
+
+func (client *MultiRegionClient) Remove(ctx context.Context, keys ...string) error {
+	return client.primary().Remove(ctx, keys...)
+}
+
+// List lists the region serving the reads, whose listings may miss the
+// objects not replicated yet.
+func (client *MultiRegionClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	region, reader := client.read()
+	items, err := reader.List(ctx, prefix)
+	if err != nil && region != client.policy.Primary && shouldFailover(err) {
+		client.fail(region, err)
+		return client.primary().List(ctx, prefix)
+	}
+	return items, err
+}
+
+// ListPages implements PageLister like List, the listing failed over in the
+// middle continues on the primary after the last key listed.
+func (client *MultiRegionClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	region, reader := client.read()
+	last, stopped := startAfter, false
+	err := listPages(ctx, reader, prefix, startAfter, func(items []ObjectItem) bool {
+		if len(items) > 0 {
+			last = items[len(items)-1].Key
+		}
+		stopped = !fn(items)
+		return !stopped
+	})
+	if err != nil && !stopped && region != client.policy.Primary && shouldFailover(err) {
+		client.fail(region, err)
+		return listPages(ctx, client.primary(), prefix, last, fn)
+	}
+	return err
+}
+
+// listsPages reports whether the clients of all the regions are
+// PageListers, for AsPageLister.
+func (client *MultiRegionClient) listsPages() bool {
+	for _, c := range client.clients {
+		if _, ok := AsPageLister(c); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (client *MultiRegionClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	region, reader := client.read()
+	info, err := reader.Info(ctx, key)
+	if client.fallback(region, err) {
+		return client.primary().Info(ctx, key)
+	}
+	return info, err
+}
+
+func (client *MultiRegionClient) Copy(ctx context.Context, src, dst string) error {
+	return client.primary().Copy(ctx, src, dst)
+}
+
+// Close stops probing, and closes the clients of all the regions.
+func (client *MultiRegionClient) Close() error {
+	client.cancel()
+	<-client.done
+
+	regions := make([]string, 0, len(client.clients))
+	for region := range client.clients {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	clients := make([]Client, 0, len(regions))
+	for _, region := range regions {
+		clients = append(clients, client.clients[region])
+	}
+	return closeAll(clients...)
+}
@@ -0,0 +1,105 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitReader(t *testing.T, client *MultiRegionClient, region string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Reader() != region {
+		if time.Now().After(deadline) {
+			t.Fatalf("invalid reader %v, status %+v", client.Reader(), client.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMultiRegionClient(t *testing.T) {
+	ctx := context.Background()
+	primary, near, far := newMemClient(), newMemClient(), newMemClient()
+	primary.fail = func(op, key string) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	far.fail = func(op, key string) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	var nearDown atomic.Bool
+	near.fail = func(op, key string) error {
+		if nearDown.Load() {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	for _, mem := range []*memClient{primary, near, far} {
+		mem.put("a", "replicated")
+	}
+	var probes atomic.Int32
+	client, err := NewMultiRegionClient(map[string]Client{"primary": primary, "near": near, "far": far}, MultiRegionPolicy{
+		Primary:       "primary",
+		ProbeInterval: 10 * time.Millisecond,
+		OnProbe:       func(region string, latency time.Duration, err error) { probes.Add(1) },
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+	waitReader(t, client, "near")
+
+	// The writes are sent to the primary, and the objects not replicated
+	// are read from it.
+	if err := client.Write(ctx, "b", strings.NewReader("new"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if _, ok := near.objects["b"]; ok {
+		t.Fatalf("write is sent to replica")
+	}
+	r, err := client.Read(ctx, "b")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "new" {
+		t.Fatalf("invalid data %q", data)
+	}
+	if exist, err := client.Exist(ctx, "b"); err != nil || !exist {
+		t.Fatalf("invalid exist %v: %v", exist, err)
+	}
+	if _, err := client.Info(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect not found: %v", err)
+	}
+
+	// The failed region is unhealthy until it's probed successfully.
+	nearDown.Store(true)
+	if info, err := client.Info(ctx, "a"); err != nil || info.Size != int64(len("replicated")) {
+		t.Fatalf("invalid info %+v: %v", info, err)
+	}
+	if client.Reader() == "near" {
+		t.Fatalf("failed region serves reads")
+	}
+	waitReader(t, client, "primary")
+	for _, status := range client.Status() {
+		if status.Region == "near" && (status.Healthy || status.Err == nil) {
+			t.Fatalf("invalid status %+v", status)
+		}
+	}
+	nearDown.Store(false)
+	waitReader(t, client, "near")
+	if probes.Load() == 0 {
+		t.Fatalf("probes aren't reported")
+	}
+}
+
+func TestMultiRegionClientPrimary(t *testing.T) {
+	if _, err := NewMultiRegionClient(map[string]Client{"a": newMemClient()}, MultiRegionPolicy{Primary: "b"}); err == nil {
+		t.Fatalf("missing primary is accepted")
+	}
+}
@@ -0,0 +1,132 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+const removeBatchSize = 1000
+
+// RemovePrefixOptions are the options of RemovePrefix.
+type RemovePrefixOptions struct {
+	// Workers is the number of concurrent removals, defaults to 1.
+	Workers int
+	// Progress is called with the number of removed objects after each
+	// batch, the total is -1.
+	Progress Progress
+	// Controller adapts the number of concurrent removals, which is at most
+	// Workers, if it's set.
+	Controller *AdaptiveConcurrency
+}
+
+// RemovePrefix removes the objects of prefix by workers in batches. If
+// client is a PageLister, the pages are removed while the next ones are
+// listed, instead of listing all first. It returns the number of removed
+// objects. The keys failed to be removed are reported by a *RemoveError
+// after the others are removed, other errors stop the removal.
+func RemovePrefix(ctx context.Context, client Client, prefix string, o *RemovePrefixOptions) (int64, error) {
+	workers := 1
+	var (
+		counter    *progressCounter
+		controller *AdaptiveConcurrency
+	)
+	if o != nil {
+		workers = max(o.Workers, 1)
+		counter = newProgressCounter(-1, o.Progress)
+		controller = o.Controller
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		removed atomic.Int64
+		failed  []RemoveResult
+		stopErr error
+	)
+	stop := func(err error) {
+		mutex.Lock()
+		if stopErr == nil {
+			stopErr = err
+		}
+		mutex.Unlock()
+		cancel()
+	}
+
+	batches := make(chan []string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				release, err := controller.Acquire(ctx)
+				if err != nil {
+					stop(err)
+					continue
+				}
+				err = client.Remove(ctx, batch...)
+				release(err)
+				var rerr *RemoveError
+				switch {
+				case err == nil:
+					removed.Add(int64(len(batch)))
+					counter.add(int64(len(batch)))
+				case errors.As(err, &rerr):
+					removed.Add(int64(len(batch) - len(rerr.Results)))
+					counter.add(int64(len(batch) - len(rerr.Results)))
+					mutex.Lock()
+					failed = append(failed, rerr.Results...)
+					mutex.Unlock()
+				default:
+					stop(err)
+				}
+			}
+		}()
+	}
+
+	send := func(items []ObjectItem) bool {
+		for len(items) > 0 {
+			batch := make([]string, 0, min(len(items), removeBatchSize))
+			for _, item := range items[:cap(batch)] {
+				batch = append(batch, item.Key)
+			}
+			items = items[len(batch):]
+
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	var err error
+	if lister, ok := AsPageLister(client); ok {
+		err = lister.ListPages(ctx, prefix, "", send)
+	} else {
+		var items []ObjectItem
+		if items, err = client.List(ctx, prefix); err == nil {
+			send(items)
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if stopErr != nil {
+		return removed.Load(), stopErr
+	}
+	if err != nil {
+		return removed.Load(), err
+	}
+	if err := ctx.Err(); err != nil {
+		return removed.Load(), err
+	}
+	if len(failed) > 0 {
+		return removed.Load(), &RemoveError{Results: failed}
+	}
+	return removed.Load(), nil
+}
@@ -0,0 +1,58 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRemovePrefix(t *testing.T) {
+	client := &pageMemClient{memClient: newMemClient()}
+	for i := 0; i < 2500; i++ {
+		client.put(fmt.Sprintf("prefix/%04d", i), "")
+	}
+	client.put("other/key", "")
+
+	errFail := errors.New("failed")
+	client.fail = func(op, key string) error {
+		if op == "Remove" && key == "prefix/1234" {
+			return errFail
+		}
+		return nil
+	}
+
+	var progress atomic.Int64
+	removed, err := RemovePrefix(context.Background(), client, "prefix/", &RemovePrefixOptions{
+		Workers: 4,
+		Progress: func(transferred, total int64) {
+			if total != -1 || transferred <= progress.Load() {
+				t.Errorf("invalid progress %v of %v", transferred, total)
+			}
+			progress.Store(transferred)
+		},
+	})
+	var rerr *RemoveError
+	if !errors.As(err, &rerr) || len(rerr.Results) != 1 || rerr.Results[0].Key != "prefix/1234" {
+		t.Fatalf("invalid error of failed key: %v", err)
+	}
+	if removed != 2499 || progress.Load() != 2499 {
+		t.Fatalf("invalid removed count %v, progress %v", removed, progress.Load())
+	}
+	items, _ := client.List(context.Background(), "")
+	if len(items) != 2 || items[0].Key != "other/key" || items[1].Key != "prefix/1234" {
+		t.Fatalf("invalid objects left: %v", items)
+	}
+
+	// The removal is stopped by the errors other than RemoveError.
+	client.fail = func(op, key string) error {
+		if op == "List" {
+			return errFail
+		}
+		return nil
+	}
+	if _, err := RemovePrefix(context.Background(), client.memClient, "prefix/", nil); !errors.Is(err, errFail) {
+		t.Fatalf("list error isn't reported: %v", err)
+	}
+}
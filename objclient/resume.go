@@ -0,0 +1,241 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ResumeOptions are the options of NewResumingClient.
+type ResumeOptions struct {
+	// MaxResumes is the number of reconnects of each reader, defaults to 5.
+	MaxResumes int
+	// Backoff is the delay before the first reconnect, which is doubled for
+	// the next ones. It defaults to 1 second.
+	Backoff time.Duration
+}
+
+type resumingClient struct {
+	inner Client
+	opts  ResumeOptions
+}
+
+// NewResumingClient returns a client whose readers reconnect to the rest of
+// the object with ranged reads if reading fails in the middle, instead of
+// reporting the error to the consumer. The object is read with IfMatch of
+// its ETag, so a reader fails with ErrObjectChanged if it's overwritten.
+// Reads with the Process option aren't resumed.
+func NewResumingClient(inner Client, opts *ResumeOptions) Client {
+	o := ResumeOptions{MaxResumes: 5, Backoff: time.Second}
+	if opts != nil {
+		if opts.MaxResumes > 0 {
+			o.MaxResumes = opts.MaxResumes
+		}
+		if opts.Backoff > 0 {
+			o.Backoff = opts.Backoff
+		}
+	}
+	client := &resumingClient{inner: inner, opts: o}
+	if _, ok := AsPageLister(inner); ok {
+		return &resumingPageClient{client}
+	}
+	return client
+}
+
+// resumingPageClient is the resumingClient of a PageLister.
+type resumingPageClient struct {
+	*resumingClient
+}
+
+func (client *resumingPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *resumingClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *resumingClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if o != nil && o.Process != "" {
+		return client.inner.ReadWithOptions(ctx, key, o)
+	}
+	offset, length, err := readRange(o)
+	if err != nil {
+		return nil, err
+	}
+
+	key, info, err := client.resolve(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	remaining := max(info.Size-offset, 0)
+	if length > 0 {
+		remaining = min(remaining, length)
+	}
+
+	var ro ReadOptions
+	if o != nil {
+		ro = *o
+	}
+	// Progress reports the whole read instead of each request.
+	progress := ro.Progress
+	ro.Progress = nil
+	if ro.IfMatch == "" {
+		ro.IfMatch = info.ETag
+	}
+
+	reader := &resumingReader{
+		ctx:       ctx,
+		client:    client,
+		key:       key,
+		o:         ro,
+		offset:    offset,
+		remaining: remaining,
+	}
+	if err := reader.open(); err != nil {
+		return nil, reader.changed(err)
+	}
+	return readCloserWithProgress(reader, remaining, progress), nil
+}
+
+// resolve returns the target key and info of key, following emulated
+// symlinks which readers of S3 clients follow too.
+func (client *resumingClient) resolve(ctx context.Context, key string) (string, *ObjectInfo, error) {
+	for i := 0; ; i++ {
+		if i > maxSymlinkFollow {
+			return "", nil, fmt.Errorf("too many levels of symlinks: %v", key)
+		}
+		info, err := client.inner.Info(ctx, key)
+		if err != nil {
+			return "", nil, err
+		}
+		target, ok := symlinkTarget(info.Metadata)
+		if !ok {
+			return key, info, nil
+		}
+		key = target
+	}
+}
+
+type resumingReader struct {
+	ctx       context.Context
+	client    *resumingClient
+	key       string
+	o         ReadOptions
+	r         io.ReadCloser
+	offset    int64
+	remaining int64
+	resumes   int
+}
+
+// open reads the rest of the object from the current offset.
+func (reader *resumingReader) open() error {
+	o := reader.o
+	o.Offset = reader.offset
+	o.Length = reader.remaining
+	r, err := reader.client.inner.ReadWithOptions(reader.ctx, reader.key, &o)
+	if err != nil {
+		return err
+	}
+	reader.r = r
+	return nil
+}
+
+func (reader *resumingReader) changed(err error) error {
+	if isPreconditionFailed(err) {
+		return fmt.Errorf("%w: %v", ErrObjectChanged, reader.key)
+	}
+	return err
+}
+
+func (reader *resumingReader) Read(data []byte) (int, error) {
+	if reader.r == nil {
+		return 0, io.ErrClosedPipe
+	}
+	if reader.remaining <= 0 {
+		return 0, io.EOF
+	}
+	n, err := reader.r.Read(data)
+	reader.offset += int64(n)
+	reader.remaining -= int64(n)
+	if err == nil || (err == io.EOF && reader.remaining <= 0) {
+		return n, err
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err := reader.resume(err); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// resume reconnects after err, until the reconnects are run out.
+func (reader *resumingReader) resume(err error) error {
+	reader.r.Close()
+	reader.r = nil
+	for {
+		if reader.ctx.Err() != nil || reader.resumes >= reader.client.opts.MaxResumes {
+			return err
+		}
+		backoff := reader.client.opts.Backoff << reader.resumes
+		reader.resumes++
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-reader.ctx.Done():
+			timer.Stop()
+			return err
+		}
+
+		if err = reader.open(); err == nil {
+			return nil
+		}
+		if isPreconditionFailed(err) {
+			return reader.changed(err)
+		}
+	}
+}
+
+func (reader *resumingReader) Close() error {
+	if reader.r == nil {
+		return nil
+	}
+	err := reader.r.Close()
+	reader.r = nil
+	return err
+}
+
+func (client *resumingClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	return client.inner.Write(ctx, key, r, o)
+}
+
+func (client *resumingClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *resumingClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *resumingClient) Remove(ctx context.Context, keys ...string) error {
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *resumingClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *resumingClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *resumingClient) Copy(ctx context.Context, src, dst string) error {
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *resumingClient) Close() error {
+	return client.inner.Close()
+}
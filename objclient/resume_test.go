@@ -0,0 +1,88 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errBrokenRead = errors.New("connection reset")
+
+// brokenReader fails after n bytes are read.
+type brokenReader struct {
+	io.ReadCloser
+	n int
+}
+
+func (reader *brokenReader) Read(data []byte) (int, error) {
+	if reader.n <= 0 {
+		return 0, errBrokenRead
+	}
+	n, err := reader.ReadCloser.Read(data[:min(len(data), reader.n)])
+	reader.n -= n
+	return n, err
+}
+
+// brokenClient returns readers failing after every 1000 bytes.
+type brokenClient struct {
+	*memClient
+	reads atomic.Int32
+	hook  func()
+}
+
+func (client *brokenClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if client.reads.Add(1) > 1 && client.hook != nil {
+		client.hook()
+	}
+	r, err := client.memClient.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		return nil, err
+	}
+	return &brokenReader{ReadCloser: r, n: 1000}, nil
+}
+
+func TestResumingClient(t *testing.T) {
+	inner := &brokenClient{memClient: newMemClient()}
+	data := strings.Repeat("0123456789", 500)
+	inner.put("key", data)
+	client := NewResumingClient(inner, &ResumeOptions{MaxResumes: 10, Backoff: time.Millisecond})
+
+	var progress int64
+	r, err := client.ReadWithOptions(context.Background(), "key", &ReadOptions{
+		Offset:   100,
+		Length:   4000,
+		Progress: func(transferred, total int64) { progress = transferred },
+	})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(content) != data[100:4100] {
+		t.Fatalf("invalid resumed content: %v bytes, %v", len(content), err)
+	}
+	if inner.reads.Load() != 4 || progress != 4000 {
+		t.Fatalf("invalid reads %v, progress %v", inner.reads.Load(), progress)
+	}
+
+	// The resumes are run out.
+	client = NewResumingClient(inner, &ResumeOptions{MaxResumes: 2, Backoff: time.Millisecond})
+	r, _ = client.Read(context.Background(), "key")
+	if _, err := io.ReadAll(r); !errors.Is(err, errBrokenRead) {
+		t.Fatalf("read error isn't reported: %v", err)
+	}
+	r.Close()
+
+	// The object is overwritten between the reads.
+	inner.reads.Store(0)
+	inner.hook = func() { inner.put("key", "changed") }
+	r, _ = client.Read(context.Background(), "key")
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("change isn't reported: %v", err)
+	}
+	r.Close()
+}
@@ -0,0 +1,198 @@
+package objclient
+
+import "errors"
+
+// The Reed-Solomon code of ErasureClient over GF(2^8) of the polynomial
+// x^8+x^4+x^3+x^2+1. The encoding matrix is a Vandermonde matrix multiplied
+// by the inverse of its top square, so the data shards are the data as is,
+// and any dataShards rows of it are invertible.
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+	gfMul [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i], gfExp[i+255] = byte(x), byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMul[a][b] = gfExp[int(gfLog[a])+int(gfLog[b])]
+		}
+	}
+}
+
+func gfInverse(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+func gfPow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])*n%255]
+}
+
+type gfMatrix [][]byte
+
+func newGFMatrix(rows, cols int) gfMatrix {
+	m := make(gfMatrix, rows)
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m
+}
+
+func (m gfMatrix) multiply(other gfMatrix) gfMatrix {
+	result := newGFMatrix(len(m), len(other[0]))
+	for i := range m {
+		for j := range other[0] {
+			var v byte
+			for k := range other {
+				v ^= gfMul[m[i][k]][other[k][j]]
+			}
+			result[i][j] = v
+		}
+	}
+	return result
+}
+
+var errSingularMatrix = errors.New("singular matrix")
+
+// invert returns the inverse of the square matrix by Gauss-Jordan
+// elimination.
+func (m gfMatrix) invert() (gfMatrix, error) {
+	n := len(m)
+	work := newGFMatrix(n, 2*n)
+	for i := range m {
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, errSingularMatrix
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		if scale := gfInverse(work[col][col]); scale != 1 {
+			for j := range work[col] {
+				work[col][j] = gfMul[scale][work[col][j]]
+			}
+		}
+		for row := 0; row < n; row++ {
+			if row == col || work[row][col] == 0 {
+				continue
+			}
+			factor := work[row][col]
+			for j := range work[row] {
+				work[row][j] ^= gfMul[factor][work[col][j]]
+			}
+		}
+	}
+	inverse := newGFMatrix(n, n)
+	for i := range work {
+		copy(inverse[i], work[i][n:])
+	}
+	return inverse, nil
+}
+
+// reedSolomon encodes data to dataShards of data and the parity shards.
+type reedSolomon struct {
+	dataShards, totalShards int
+	matrix                  gfMatrix
+}
+
+func newReedSolomon(dataShards, totalShards int) (*reedSolomon, error) {
+	vandermonde := newGFMatrix(totalShards, dataShards)
+	for r := range vandermonde {
+		for c := range vandermonde[r] {
+			vandermonde[r][c] = gfPow(byte(r), c)
+		}
+	}
+	top, err := vandermonde[:dataShards].invert()
+	if err != nil {
+		return nil, err
+	}
+	return &reedSolomon{dataShards: dataShards, totalShards: totalShards, matrix: vandermonde.multiply(top)}, nil
+}
+
+// mulAdd adds the product of c and in to out.
+func mulAdd(c byte, in, out []byte) {
+	table := &gfMul[c]
+	for i, v := range in {
+		out[i] ^= table[v]
+	}
+}
+
+// encode splits data into the shards, whose sizes are the same.
+func (rs *reedSolomon) encode(data []byte) [][]byte {
+	size := (len(data) + rs.dataShards - 1) / rs.dataShards
+	padded := make([]byte, size*rs.totalShards)
+	copy(padded, data)
+	shards := make([][]byte, rs.totalShards)
+	for i := range shards {
+		shards[i] = padded[i*size : (i+1)*size]
+	}
+	for r := rs.dataShards; r < rs.totalShards; r++ {
+		for c := 0; c < rs.dataShards; c++ {
+			mulAdd(rs.matrix[r][c], shards[c], shards[r])
+		}
+	}
+	return shards
+}
+
+// decode returns the data of size from the shards, at least dataShards of
+// them aren't nil.
+func (rs *reedSolomon) decode(shards [][]byte, size int64) ([]byte, error) {
+	var rows []int
+	for i, shard := range shards {
+		if shard != nil && len(rows) < rs.dataShards {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) < rs.dataShards {
+		return nil, errors.New("too few shards")
+	}
+
+	shardSize := len(shards[rows[0]])
+	data := make([]byte, shardSize*rs.dataShards)
+	if int64(len(data)) < size {
+		return nil, errors.New("shards too small")
+	}
+	if rows[rs.dataShards-1] == rs.dataShards-1 {
+		for i := 0; i < rs.dataShards; i++ {
+			copy(data[i*shardSize:], shards[i])
+		}
+		return data[:size], nil
+	}
+
+	sub := make(gfMatrix, rs.dataShards)
+	for i, row := range rows {
+		sub[i] = rs.matrix[row]
+	}
+	inverse, err := sub.invert()
+	if err != nil {
+		return nil, err
+	}
+	for c := 0; c < rs.dataShards; c++ {
+		out := data[c*shardSize : (c+1)*shardSize]
+		for j, row := range rows {
+			mulAdd(inverse[c][j], shards[row], out)
+		}
+	}
+	return data[:size], nil
+}
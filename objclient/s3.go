@@ -1,20 +1,31 @@
 package objclient
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
+// S3Config is the config of NewS3Client, whose fields are strings for the
+// configs of files and environment variables. Boolean fields are true only
+// if they are "true", use NewS3 with options in code instead.
 type S3Config struct {
-	Endpoint         string
+	Endpoint string
+	// EndpointTemplate is the endpoint if Endpoint is empty, where {region}
+	// is replaced by Region, e.g. s3.{region}.example.internal.
+	EndpointTemplate string
+	// Region is detected by the location of Bucket if it's empty, and
+	// defaults to us-east-1 for V4Signature if that's failed.
 	Region           string
 	HTTPS            string
 	Bucket           string
@@ -23,15 +34,145 @@ type S3Config struct {
 	Key              string
 	V4Signature      string
 	SSECKey          string
+	// Anonymous sends unsigned requests, for public buckets. If it's not
+	// set and no keys are configured, the AWS default credential chain is
+	// used: the environment variables, the shared credentials file, the web
+	// identity of AWS_WEB_IDENTITY_TOKEN_FILE, and the role of ECS task or
+	// EC2 instance.
+	Anonymous string
+	// Profile of the shared credentials file for the credential chain,
+	// defaults to AWS_PROFILE or "default".
+	Profile string
+	// RoleARN is assumed by STS with the credentials above if it or
+	// STSEndpoint is set. The temporary credentials are refreshed before
+	// they expire.
+	RoleARN         string
+	RoleExternalID  string
+	RoleSessionName string
+	// STSEndpoint is the URL of STS, defaults to the one of the region. Set
+	// it to the URL of MinIO server for its STS API, which needs no RoleARN.
+	STSEndpoint string
+	// WebIdentityTokenFile is the file of the OIDC token, e.g. the one of
+	// EKS IRSA, which is exchanged for the credentials of RoleARN by
+	// AssumeRoleWithWebIdentity of STSEndpoint. The file is read again on
+	// each refresh. Keys can't be set with it.
+	WebIdentityTokenFile string
+	// ProxyURL is the proxy of requests, e.g. http://proxy:3128. The ones of
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if it's empty.
+	ProxyURL string
+	// CAFile is the PEM file of CA certificates, which are trusted besides
+	// RootCAs, or the system ones if it's nil.
+	CAFile  string
+	RootCAs *x509.CertPool
+	// ClientCertFile and ClientKeyFile are the PEM files of the certificate
+	// for mutual TLS.
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify string
+	// ConnectTimeout, RequestTimeout and ReadStallTimeout are durations like
+	// "10s". ConnectTimeout defaults to 30 seconds. RequestTimeout limits the
+	// requests other than reading and writing objects, defaults to 30
+	// seconds. Reads and writes of objects fail with ErrStalled if no data
+	// is transferred in ReadStallTimeout, which defaults to 30 seconds.
+	ConnectTimeout   string
+	RequestTimeout   string
+	ReadStallTimeout string
+	// Accelerate sends requests of AWS to the Transfer Acceleration
+	// endpoint, except List which isn't supported by it.
+	Accelerate string
+	// DualStack uses the endpoints of AWS for both IPv4 and IPv6, defaults
+	// to true.
+	DualStack string
+	// FIPS uses the FIPS endpoint of Region, Endpoint can't be set with it.
+	FIPS string
+	// StrictKeys rejects the keys of operations by ValidateKey before
+	// sending requests if it's "true".
+	StrictKeys string
+	// PartSize is the size of parts for objects larger than it, like "64MiB",
+	// defaults to 16MiB. UploadConcurrency is the number of parts uploaded
+	// at the same time, defaults to 4, each of them buffers a part.
+	PartSize          string
+	UploadConcurrency string
+	// UploadController adapts the number of parts uploaded at the same time,
+	// which is at most UploadConcurrency, if it's set. The parts are sent by
+	// the SDK, so it's the number of parts of each upload when it starts,
+	// and only the throttled uploads update it.
+	UploadController *AdaptiveConcurrency
+	// UserAgent is the product identifier appended to the User-Agent of
+	// requests, e.g. "gateway/1.2".
+	UserAgent string
+	// Headers are set to all the requests, in lines of "Name: value". The
+	// ones which are signed like x-amz-* can't be set.
+	Headers string
+	// MaxIdleConnsPerHost and IdleConnTimeout like "90s" tune the pool of
+	// connections. ExpectContinueTimeout like "1s" sends uploads with
+	// "Expect: 100-continue" and waits for the response in it. HTTP2 forces
+	// HTTP/2 if it's "true", or disables it if it's "false".
+	MaxIdleConnsPerHost   string
+	IdleConnTimeout       string
+	ExpectContinueTimeout string
+	HTTP2                 string
+	// Credentials supplies the credentials instead of the keys and others
+	// above if it's set.
+	Credentials CredentialsProvider
 }
 
 type S3Client struct {
-	backend *minio.Client
-	bucket  string
-	sseckey encrypt.ServerSide
+	backend   *minio.Client
+	bucket    string
+	sseckey   encrypt.ServerSide
+	endpoint  string
+	https     bool
+	pathStyle bool
+	timeout   time.Duration
+	stall     time.Duration
+	upload    uploadConfig
+	// listBackend is backend unless accelerated.
+	listBackend *minio.Client
+	tenants     *tenantCache[s3Backends]
+	region      string
+	strict      bool
+	transport   http.RoundTripper
+	transfers   transfers
 }
 
+// s3Backends are the backends of the same credentials.
+type s3Backends struct {
+	backend, list *minio.Client
+}
+
+// backendOf returns the backend of the credentials of ctx.
+func (client *S3Client) backendOf(ctx context.Context) (*minio.Client, error) {
+	creds, ok := credentialsOf(ctx)
+	if !ok || client.tenants == nil {
+		return client.backend, nil
+	}
+	backends, err := client.tenants.get(creds)
+	return backends.backend, err
+}
+
+// listBackendOf returns the list backend of the credentials of ctx.
+func (client *S3Client) listBackendOf(ctx context.Context) (*minio.Client, error) {
+	creds, ok := credentialsOf(ctx)
+	if !ok || client.tenants == nil {
+		return client.listBackend, nil
+	}
+	backends, err := client.tenants.get(creds)
+	return backends.list, err
+}
+
+// NewS3Client creates a S3 client of config, which fails if the config
+// isn't valid, see Validate.
+//
+// Deprecated: use NewS3 in code.
 func NewS3Client(config S3Config) (Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid s3 config: %w", err)
+	}
+	return newS3Client(config)
+}
+
+func newS3Client(config S3Config) (*S3Client, error) {
 	var client S3Client
 
 	v4Signature := stringToBool(config.V4Signature, false)
@@ -41,24 +182,69 @@ func NewS3Client(config S3Config) (Client, error) {
 		region = "us-east-1"
 	}
 
+	dualStack := stringToBool(config.DualStack, true)
+	fips := stringToBool(config.FIPS, false)
+
 	endpoint := config.Endpoint
-	if endpoint == "" {
-		if region != "" {
-			endpoint = "s3." + region + ".amazonaws.com"
-		} else {
-			endpoint = "s3.amazonaws.com"
+	switch {
+	case fips:
+		if endpoint != "" || config.EndpointTemplate != "" {
+			return nil, errors.New("endpoint can't be set for fips")
+		}
+		if region == "" {
+			return nil, errors.New("region is required for fips")
+		}
+		endpoint = s3FIPSEndpoint(region, dualStack)
+	case endpoint == "" && config.EndpointTemplate != "":
+		var err error
+		endpoint, err = templateEndpoint(config.EndpointTemplate, config.Region)
+		if err != nil {
+			return nil, err
 		}
+	case endpoint == "":
+		// The region is detected by the global endpoint if it's empty.
+		endpoint = s3Endpoint(config.Region)
 	}
 
-	creds := credentials.NewStaticV2(config.KeyID, config.Key, "")
-	if v4Signature {
+	anonymous := stringToBool(config.Anonymous, false)
+
+	var creds *credentials.Credentials
+	switch {
+	case anonymous:
+		if config.KeyID != "" || config.Key != "" {
+			return nil, errors.New("keys can't be set for anonymous access")
+		}
+		creds = credentials.NewStatic("", "", "", credentials.SignatureAnonymous)
+	case config.Credentials != nil:
+		if config.KeyID != "" || config.Key != "" {
+			return nil, errors.New("keys can't be set with credentials provider")
+		}
+		creds = credentials.New(newProviderAdapter(config.Credentials))
+	case config.WebIdentityTokenFile != "":
+		if config.KeyID != "" || config.Key != "" {
+			return nil, errors.New("keys can't be set for web identity")
+		}
+		creds = webIdentity(config, region)
+	case config.KeyID == "" && config.Key == "":
+		creds = defaultCredentialChain(config.Profile)
+	case v4Signature:
 		creds = credentials.NewStaticV4(config.KeyID, config.Key, "")
+	default:
+		creds = credentials.NewStaticV2(config.KeyID, config.Key, "")
+	}
+
+	if config.Credentials == nil && config.WebIdentityTokenFile == "" && (config.RoleARN != "" || config.STSEndpoint != "") {
+		if anonymous {
+			return nil, errors.New("role can't be assumed for anonymous access")
+		}
+		creds = assumeRole(creds, config, region)
 	}
 
 	https := stringToBool(config.HTTPS, false)
 
+	pathStyle := stringToBool(config.PathStyleRequest, false)
 	lookup := minio.BucketLookupDNS
-	if stringToBool(config.PathStyleRequest, false) {
+	if pathStyle {
 		lookup = minio.BucketLookupPath
 	}
 
@@ -79,73 +265,359 @@ func NewS3Client(config S3Config) (Client, error) {
 		client.sseckey = key
 	}
 
-	backend, err := minio.New(endpoint, &minio.Options{
-		Region:       region,
+	timeouts, err := parseTimeouts(config.ConnectTimeout, config.RequestTimeout, config.ReadStallTimeout, defaultStallTimeout)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := parseUpload(config.PartSize, config.UploadConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	upload.controller = config.UploadController
+
+	transport, err := newS3Transport(https, config.transportConfig(), timeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &minio.Options{
+		Region:       config.Region,
 		Creds:        creds,
 		Secure:       https,
 		BucketLookup: lookup,
-	})
+		Transport:    transport,
+	}
+	if options.Region == "" {
+		// Requests may fail with redirects of another region otherwise.
+		options.Region = detectS3Region(endpoint, options, config.Bucket, timeouts.request)
+		if options.Region == "" {
+			options.Region = region
+		}
+	}
+	accelerate := stringToBool(config.Accelerate, false)
+	if accelerate {
+		if strings.Contains(config.Bucket, ".") {
+			return nil, errors.New("bucket names with dots can't be accelerated")
+		}
+		if fips {
+			return nil, errors.New("fips endpoint can't be accelerated")
+		}
+		if awsDomain(region) != "amazonaws.com" {
+			return nil, fmt.Errorf("acceleration isn't supported in %v", region)
+		}
+	}
+	// The backends of the credentials of ContextWithCredentials share the
+	// transport.
+	newBackends := func(creds *credentials.Credentials) (s3Backends, error) {
+		options := *options
+		options.Creds = creds
+		backend, err := minio.New(endpoint, &options)
+		if err != nil {
+			return s3Backends{}, fmt.Errorf("failed to create s3 client: %w", err)
+		}
+		backend.SetS3EnableDualstack(dualStack)
+		backends := s3Backends{backend: backend, list: backend}
+		if accelerate {
+			backends.list, err = minio.New(endpoint, &options)
+			if err != nil {
+				return s3Backends{}, fmt.Errorf("failed to create s3 client: %w", err)
+			}
+			backends.list.SetS3EnableDualstack(dualStack)
+			if dualStack {
+				backend.SetS3TransferAccelerate(s3AccelerateDualStackEndpoint)
+			} else {
+				backend.SetS3TransferAccelerate(s3AccelerateEndpoint)
+			}
+		}
+		return backends, nil
+	}
+	backends, err := newBackends(creds)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+		return nil, err
 	}
+	client.tenants = newTenantCache(func(creds Credentials) (s3Backends, error) {
+		return newBackends(credentials.NewStaticV4(creds.KeyID, creds.Key, creds.Token))
+	})
 
-	client.backend = backend
+	client.backend = backends.backend
+	client.listBackend = backends.list
 	client.bucket = config.Bucket
+	client.endpoint = endpoint
+	client.https = https
+	client.pathStyle = pathStyle
+	client.timeout = timeouts.request
+	client.stall = timeouts.stall
+	client.upload = upload
+	client.region = options.Region
+	client.strict = stringToBool(config.StrictKeys, false)
+	client.transport = transport
+	client.transfers = newTransfers()
 
 	return &client, nil
 }
 
+// detectS3Region returns the location of bucket, or empty if it's failed.
+func detectS3Region(endpoint string, options *minio.Options, bucket string, timeout time.Duration) string {
+	backend, err := minio.New(endpoint, options)
+	if err != nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	region, err := backend.GetBucketLocation(ctx, bucket)
+	if err != nil {
+		return ""
+	}
+	return region
+}
+
+// Region returns the region of config, or the detected one of the bucket
+// if it's empty.
+func (client *S3Client) Region() string {
+	return client.region
+}
+
 func (client *S3Client) Read(ctx context.Context, key string) (io.ReadCloser, error) {
-	ctx, cancel := context.WithCancel(ctx)
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *S3Client) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return nil, err
+	}
+
+	if o != nil && o.Process != "" {
+		return nil, errors.New("the process option isn't supported")
+	}
+
+	offset, length, err := readRange(o)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	ranged := offset > 0 || length > 0
+
+	for i := 0; ; i++ {
+		if i > maxSymlinkFollow {
+			return nil, fmt.Errorf("too many levels of symlinks: %v", key)
+		}
+
+		var ifMatch string
+		if o != nil && i == 0 {
+			ifMatch = o.IfMatch
+		}
+		obj, stat, cancel, err := client.getObject(ctx, key, offset, length, ifMatch, o)
+		if err != nil {
+			// Ranges of emulated symlinks are invalid since they are empty.
+			var resp minio.ErrorResponse
+			if ranged && errors.As(err, &resp) && resp.Code == "InvalidRange" {
+				info, ierr := client.Info(ctx, key)
+				if ierr == nil {
+					if target, ok := symlinkTarget(info.Metadata); ok {
+						key = target
+						continue
+					}
+				}
+			}
+			return nil, translateError(err)
+		}
+
+		target, ok := symlinkTarget(stat.UserMetadata)
+		if !ok {
+			r := newStallReader(obj, obj, cancel, client.stall, "Read", key)
+			return wrapReader(r, stat.Size, o), nil
+		}
+		obj.Close()
+		cancel()
 
+		key = target
+	}
+}
+
+// getObject sends the GET request of the object. The reader of
+// minio.Client.GetObject isn't used, since the range is dropped by its
+// requests after Stat.
+func (client *S3Client) getObject(ctx context.Context, key string, offset, length int64, ifMatch string, o *ReadOptions) (io.ReadCloser, minio.ObjectInfo, context.CancelFunc, error) {
 	var opts minio.GetObjectOptions
 	if client.sseckey != nil {
 		opts.ServerSideEncryption = client.sseckey
 	}
+	params, err := responseParams(o)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, nil, err
+	}
+	for k := range params {
+		opts.SetReqParam(k, params.Get(k))
+	}
+	if o != nil {
+		for k, values := range o.Header {
+			if len(values) > 0 {
+				opts.Set(k, values[0])
+			}
+		}
+	}
+	if ifMatch != "" {
+		opts.SetMatchETag(ifMatch)
+	}
+	if length > 0 {
+		if err := opts.SetRange(offset, offset+length-1); err != nil {
+			return nil, minio.ObjectInfo{}, nil, err
+		}
+	} else if offset > 0 {
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, minio.ObjectInfo{}, nil, err
+		}
+	}
 
-	obj, err := client.backend.GetObject(ctx, client.bucket, key, opts)
+	ctx, cancel := client.transfers.context(ctx)
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, nil, err
+	}
+	obj, stat, _, err := minio.Core{Client: backend}.GetObject(ctx, client.bucket, key, opts)
 	if err != nil {
 		cancel()
-		return nil, err
+		return nil, stat, nil, err
 	}
 
-	r := newTimeoutReader(obj, obj, cancel)
-	return r, nil
+	return obj, stat, cancel, nil
 }
 
 func (client *S3Client) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
-	if o == nil || o.Size == 0 {
-		// The minio client will consume memory heavily without knowning the size.
-		return errors.New("the size option must be specified")
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *S3Client) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
-	reader := newTimeoutReader(r, nil, cancel)
+	size, ok := writeSize(r, o)
+	if !ok {
+		// The minio client will consume memory heavily without knowning the
+		// size, unless the part size is set for the unknown size.
+		if o == nil || o.Size >= 0 {
+			return nil, errors.New("the size option must be specified")
+		}
+		size = -1
+		// The minio client fails the unknown sizes of empty readers.
+		var first [1]byte
+		if n, err := io.ReadFull(r, first[:]); n == 0 {
+			if err != io.EOF {
+				return nil, fmt.Errorf("failed to read data of %v: %w", key, err)
+			}
+			size, r = 0, bytes.NewReader(nil)
+		} else {
+			r = io.MultiReader(bytes.NewReader(first[:]), r)
+		}
+	}
+	if o == nil {
+		o = &WriteOptions{}
+	}
+	metadata, err := NormalizeMetadata(o.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := client.transfers.context(ctx)
+	reader := newStallReader(withProgress(r, size, o.Progress), nil, cancel, client.stall, "Write", key)
 	defer reader.Close()
 
 	var opts minio.PutObjectOptions
 	if client.sseckey != nil {
 		opts.ServerSideEncryption = client.sseckey
 	}
-	if len(o.Metadata) > 0 {
-		opts.UserMetadata = make(map[string]string)
-		for key, val := range o.Metadata {
-			key = strings.ToLower(key)
-			opts.UserMetadata[key] = val
+	opts.UserMetadata = metadata
+	opts.ContentType = o.ContentType
+	if err := setPutHeaders(&opts, o.Header); err != nil {
+		return nil, err
+	}
+	if !o.Expires.IsZero() {
+		opts.Expires = o.Expires
+		opts.UserTags = map[string]string{ExpiresTagKey: expiresDays(o.Expires)}
+	}
+	// The parts are buffered and uploaded concurrently, since the reader
+	// can't be read at offsets.
+	opts.PartSize = uint64(client.upload.partSizeOf(size))
+	concurrency := client.upload.concurrency
+	if controller := client.upload.controller; controller != nil {
+		concurrency = min(concurrency, controller.Limit())
+	}
+	opts.NumThreads = uint(concurrency)
+	opts.ConcurrentStreamParts = concurrency > 1
+	if o.conditional() {
+		opts.DisableMultipart = true
+		if o.IfMatch != "" {
+			opts.SetMatchETag(o.IfMatch)
+		}
+		if o.IfNoneMatch != "" {
+			opts.SetMatchETagExcept(o.IfNoneMatch)
 		}
-		opts.UserMetadata = o.Metadata
 	}
 
-	_, err := client.backend.PutObject(ctx, client.bucket, key, reader, o.Size, opts)
+	start := time.Now()
+	backend, err := client.backendOf(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	info, err := backend.PutObject(ctx, client.bucket, key, reader, size, opts)
+	if err != nil {
+		if isOverloaded(err) {
+			client.upload.controller.backoff(start)
+		}
+		return nil, reader.wrapError(translateError(err))
+	}
+
+	result := &WriteResult{
+		ETag:         info.ETag,
+		VersionID:    info.VersionID,
+		LastModified: info.LastModified,
+	}
+	return result, nil
+}
 
+// setPutHeaders sets the headers of writes to opts, since minio only sends
+// the headers of its options.
+func setPutHeaders(opts *minio.PutObjectOptions, header http.Header) error {
+	for k, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		v := values[0]
+		switch k := http.CanonicalHeaderKey(k); {
+		case k == "Cache-Control":
+			opts.CacheControl = v
+		case k == "Content-Disposition":
+			opts.ContentDisposition = v
+		case k == "Content-Encoding":
+			opts.ContentEncoding = v
+		case k == "Content-Language":
+			opts.ContentLanguage = v
+		case k == "Content-Type":
+			opts.ContentType = v
+		case k == "X-Amz-Storage-Class":
+			opts.StorageClass = v
+		case k == "X-Amz-Acl" || strings.HasPrefix(k, "X-Amz-Grant-"):
+			// minio sends the user metadata of the X-Amz headers as is.
+			if opts.UserMetadata == nil {
+				opts.UserMetadata = make(map[string]string)
+			}
+			opts.UserMetadata[k] = v
+		default:
+			return fmt.Errorf("header %v isn't supported by S3 writes", k)
+		}
+	}
 	return nil
 }
 
 func (client *S3Client) Exist(ctx context.Context, key string) (bool, error) {
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	if err := checkKeys(client.strict, key); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
 	var opts minio.StatObjectOptions
@@ -153,22 +625,30 @@ func (client *S3Client) Exist(ctx context.Context, key string) (bool, error) {
 		opts.ServerSideEncryption = client.sseckey
 	}
 
-	_, err := client.backend.StatObject(ctx, client.bucket, key, opts)
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, err = backend.StatObject(ctx, client.bucket, key, opts)
 	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
 		return false, nil
 	} else if err != nil {
-		return false, err
+		return false, translateError(err)
 	}
 
 	return true, nil
 }
 
 func (client *S3Client) Remove(ctx context.Context, keys ...string) error {
+	if err := checkKeys(client.strict, keys...); err != nil {
+		return err
+	}
+
 	if len(keys) == 0 {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
 	objs := make(chan minio.ObjectInfo, len(keys))
@@ -178,17 +658,22 @@ func (client *S3Client) Remove(ctx context.Context, keys ...string) error {
 	close(objs)
 
 	var (
-		opts minio.RemoveObjectsOptions
-		err  error
+		opts    minio.RemoveObjectsOptions
+		results []RemoveResult
 	)
-	errs := client.backend.RemoveObjects(ctx, client.bucket, objs, opts)
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return err
+	}
+	errs := backend.RemoveObjects(ctx, client.bucket, objs, opts)
 	for e := range errs {
-		if err == nil {
-			err = fmt.Errorf("failed to remove %v: %w", e.ObjectName, e.Err)
-		}
+		results = append(results, RemoveResult{Key: e.ObjectName, Err: translateError(e.Err)})
+	}
+	if len(results) > 0 {
+		return &RemoveError{Results: results}
 	}
 
-	return err
+	return nil
 }
 
 func (client *S3Client) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
@@ -196,12 +681,13 @@ func (client *S3Client) List(ctx context.Context, prefix string) ([]ObjectItem,
 	opts.Prefix = prefix
 	opts.Recursive = true
 
-	objs := client.backend.ListObjects(ctx, client.bucket, opts)
+	backend, err := client.listBackendOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objs := backend.ListObjects(ctx, client.bucket, opts)
 
-	var (
-		items []ObjectItem
-		err   error
-	)
+	var items []ObjectItem
 	for obj := range objs {
 		if obj.Err != nil {
 			err = obj.Err
@@ -212,17 +698,23 @@ func (client *S3Client) List(ctx context.Context, prefix string) ([]ObjectItem,
 			Key:          obj.Key,
 			Size:         obj.Size,
 			LastModified: obj.LastModified,
+			ETag:         obj.ETag,
+			StorageClass: obj.StorageClass,
 		})
 	}
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return items, nil
 }
 
 func (client *S3Client) Info(ctx context.Context, key string) (*ObjectInfo, error) {
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	if err := checkKeys(client.strict, key); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
 	var opts minio.StatObjectOptions
@@ -230,26 +722,32 @@ func (client *S3Client) Info(ctx context.Context, key string) (*ObjectInfo, erro
 		opts.ServerSideEncryption = client.sseckey
 	}
 
-	stat, err := client.backend.StatObject(ctx, client.bucket, key, opts)
+	backend, err := client.backendOf(ctx)
 	if err != nil {
 		return nil, err
 	}
+	stat, err := backend.StatObject(ctx, client.bucket, key, opts)
+	if err != nil {
+		return nil, translateError(err)
+	}
 
 	info := &ObjectInfo{
 		Size:         stat.Size,
-		Metadata:     make(map[string]string),
+		Metadata:     decodeMetadata(stat.UserMetadata),
 		LastModified: stat.LastModified,
-	}
-	for key, val := range stat.UserMetadata {
-		key = strings.ToLower(key)
-		info.Metadata[key] = val
+		ETag:         stat.ETag,
+		ContentType:  stat.ContentType,
 	}
 
 	return info, nil
 }
 
 func (client *S3Client) Copy(ctx context.Context, src, dst string) error {
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	if err := checkKeys(client.strict, src, dst); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
 	defer cancel()
 
 	srcOpts := minio.CopySrcOptions{
@@ -266,10 +764,39 @@ func (client *S3Client) Copy(ctx context.Context, src, dst string) error {
 		dstOpts.Encryption = client.sseckey
 	}
 
-	_, err := client.backend.CopyObject(ctx, dstOpts, srcOpts)
+	backend, err := client.backendOf(ctx)
 	if err != nil {
 		return err
 	}
+	_, err = backend.CopyObject(ctx, dstOpts, srcOpts)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
 
+// Close cancels the reads and writes in flight, and closes the idle
+// connections.
+func (client *S3Client) Close() error {
+	client.transfers.close(client.transport)
 	return nil
 }
+
+func (config S3Config) transportConfig() transportConfig {
+	return transportConfig{
+		ProxyURL:           config.ProxyURL,
+		CAFile:             config.CAFile,
+		RootCAs:            config.RootCAs,
+		ClientCertFile:     config.ClientCertFile,
+		ClientKeyFile:      config.ClientKeyFile,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		UserAgent:          config.UserAgent,
+		Headers:            config.Headers,
+
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		HTTP2:                 config.HTTP2,
+	}
+}
@@ -0,0 +1,271 @@
+package s3gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	emptySHA256      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	maxClockSkew  = 15 * time.Minute
+	maxPresignAge = 7 * 24 * 60 * 60
+)
+
+// signature is the verified signature of a request, which is the seed of
+// the chunk signatures of streaming uploads.
+type signature struct {
+	key   []byte
+	date  string
+	scope string
+	seed  string
+}
+
+// authenticate verifies the v4 signature in the Authorization header or the
+// query of presigned URLs. It returns nil if no credentials are configured.
+func (handler *handler) authenticate(r *http.Request) (*signature, error) {
+	if len(handler.opts.Credentials) == 0 {
+		return nil, nil
+	}
+
+	query := r.URL.Query()
+	presigned := query.Get("X-Amz-Algorithm") != ""
+	var credential, signedHeaders, sig, date, payload string
+	if presigned {
+		if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+			return nil, errUnsupportedAuth
+		}
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		sig = query.Get("X-Amz-Signature")
+		date = query.Get("X-Amz-Date")
+		payload = unsignedPayload
+		if v := r.Header.Get("X-Amz-Content-Sha256"); v != "" {
+			payload = v
+		}
+	} else {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			return nil, errAccessDenied
+		}
+		fields, ok := strings.CutPrefix(auth, sigV4Algorithm+" ")
+		if !ok {
+			return nil, errUnsupportedAuth
+		}
+		for _, field := range strings.Split(fields, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				sig = value
+			}
+		}
+		date = r.Header.Get("X-Amz-Date")
+		payload = r.Header.Get("X-Amz-Content-Sha256")
+		if payload == "" {
+			return nil, errMissingContentSHA256
+		}
+	}
+
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" || signedHeaders == "" || sig == "" {
+		return nil, errMalformedAuth
+	}
+	if parts[2] != handler.opts.Region {
+		return nil, errWrongRegion
+	}
+	secret, ok := handler.opts.Credentials[parts[0]]
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+	t, err := time.Parse(sigV4TimeFormat, date)
+	if err != nil || t.Format("20060102") != parts[1] {
+		return nil, errMalformedAuth
+	}
+	now := time.Now()
+	if presigned {
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires <= 0 || expires > maxPresignAge {
+			return nil, errMalformedAuth
+		}
+		if t.After(now.Add(maxClockSkew)) || now.After(t.Add(time.Duration(expires)*time.Second)) {
+			return nil, errExpiredRequest
+		}
+	} else if t.Before(now.Add(-maxClockSkew)) || t.After(now.Add(maxClockSkew)) {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	names := strings.Split(signedHeaders, ";")
+	if !sort.StringsAreSorted(names) || !contains(names, "host") {
+		return nil, errMalformedAuth
+	}
+	if presigned {
+		query.Del("X-Amz-Signature")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURIPath(r.URL),
+		canonicalQuery(query),
+		canonicalHeaders(r, names),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := strings.Join(parts[1:], "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		date,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secret), parts[1])
+	key = hmacSHA256(key, parts[2])
+	key = hmacSHA256(key, parts[3])
+	key = hmacSHA256(key, parts[4])
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return nil, errSignatureMismatch
+	}
+	return &signature{key: key, date: date, scope: scope, seed: sig}, nil
+}
+
+// chunk returns the signature of a chunk of streaming uploads after the one
+// of prev.
+func (sig *signature) chunk(prev string, data []byte) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm + "-PAYLOAD",
+		sig.date,
+		sig.scope,
+		prev,
+		emptySHA256,
+		sha256Hex(data),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(sig.key, stringToSign))
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalURIPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3api.URIEncode(k)+"="+s3api.URIEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaders returns the lines of signed headers, whose values are
+// trimmed and have spaces collapsed.
+func canonicalHeaders(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			var values []string
+			for _, v := range r.Header.Values(name) {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name + ":" + value + "\n")
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// chunkVerifier returns the verifier of the chunk signatures of sig, each
+// of which is chained from the previous one. The chunks aren't verified if
+// sig is nil.
+func chunkVerifier(sig *signature) func(signature string, data []byte) bool {
+	if sig == nil {
+		return nil
+	}
+	prev := sig.seed
+	return func(signature string, data []byte) bool {
+		if !hmac.Equal([]byte(signature), []byte(sig.chunk(prev, data))) {
+			return false
+		}
+		prev = signature
+		return true
+	}
+}
+
+// digestReader fails with err if the digest of the data isn't sum. It's
+// checked once size bytes are read, since the clients read the size of
+// the objects without reaching the end of r, or at the end of r.
+type digestReader struct {
+	r    io.Reader
+	size int64
+	hash hash.Hash
+	sum  []byte
+	err  error
+	read int64
+}
+
+func (reader *digestReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.hash.Write(data[:n])
+	reader.read += int64(n)
+	if (err == io.EOF || reader.read >= reader.size) && !hmac.Equal(reader.hash.Sum(nil), reader.sum) {
+		err = reader.err
+	}
+	return n, err
+}
@@ -0,0 +1,81 @@
+package s3gateway
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+)
+
+// apiError is an error response of S3.
+type apiError = s3api.Error
+
+var (
+	errAccessDenied          = s3api.NewError(http.StatusForbidden, "AccessDenied", "Access denied.")
+	errInvalidAccessKeyID    = s3api.NewError(http.StatusForbidden, "InvalidAccessKeyId", "The access key ID doesn't exist.")
+	errSignatureMismatch     = s3api.ErrSignatureMismatch
+	errRequestTimeTooSkewed  = s3api.NewError(http.StatusForbidden, "RequestTimeTooSkewed", "The request time is too far from the server time.")
+	errExpiredRequest        = s3api.NewError(http.StatusForbidden, "AccessDenied", "The presigned request has expired.")
+	errMalformedAuth         = s3api.NewError(http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization is malformed.")
+	errWrongRegion           = s3api.NewError(http.StatusBadRequest, "AuthorizationHeaderMalformed", "The region of the authorization is wrong.")
+	errUnsupportedAuth       = s3api.NewError(http.StatusBadRequest, "InvalidRequest", "Only AWS4-HMAC-SHA256 signatures are supported.")
+	errMissingContentSHA256  = s3api.NewError(http.StatusBadRequest, "InvalidRequest", "The x-amz-content-sha256 header is missing.")
+	errContentSHA256Mismatch = s3api.NewError(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The x-amz-content-sha256 header doesn't match the body.")
+	errInvalidDigest         = s3api.NewError(http.StatusBadRequest, "InvalidDigest", "The Content-MD5 header is invalid.")
+	errBadDigest             = s3api.NewError(http.StatusBadRequest, "BadDigest", "The Content-MD5 header doesn't match the body.")
+	errIncompleteBody        = s3api.ErrIncompleteBody
+	errMissingContentLength  = s3api.NewError(http.StatusLengthRequired, "MissingContentLength", "The content length is required.")
+	errMalformedXML          = s3api.NewError(http.StatusBadRequest, "MalformedXML", "The XML is malformed.")
+	errInvalidArgument       = s3api.ErrInvalidArgument
+	errInvalidRange          = s3api.ErrInvalidRange
+	errPreconditionFailed    = s3api.NewError(http.StatusPreconditionFailed, "PreconditionFailed", "The precondition failed.")
+	errNoSuchBucket          = s3api.NewError(http.StatusNotFound, "NoSuchBucket", "The bucket doesn't exist.")
+	errNoSuchKey             = s3api.NewError(http.StatusNotFound, "NoSuchKey", "The key doesn't exist.")
+	errMethodNotAllowed      = s3api.NewError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The method isn't allowed.")
+	errNotImplemented        = s3api.NewError(http.StatusNotImplemented, "NotImplemented", "The operation isn't supported by the gateway.")
+	errQuotaExceeded         = s3api.NewError(http.StatusForbidden, "QuotaExceeded", "The quota is exceeded.")
+	errSlowDown              = s3api.NewError(http.StatusServiceUnavailable, "SlowDown", "Please reduce the request rate.")
+	errUnavailable           = s3api.NewError(http.StatusServiceUnavailable, "ServiceUnavailable", "The backend is unreachable.")
+	errInternal              = s3api.NewError(http.StatusInternalServerError, "InternalError", "The backend failed.")
+)
+
+// toAPIError returns the response of err, by the sentinels of objclient
+// errors. The errors of readers, e.g. mismatched digests, are wrapped by the
+// writes of clients.
+func toAPIError(err error) *apiError {
+	var aerr *apiError
+	switch {
+	case errors.As(err, &aerr):
+		return aerr
+	case errors.Is(err, objclient.ErrNotFound):
+		return errNoSuchKey
+	case errors.Is(err, objclient.ErrInvalidKey), errors.Is(err, objclient.ErrInvalidMetadata):
+		return errInvalidArgument
+	case errors.Is(err, objclient.ErrAccessDenied):
+		return errAccessDenied
+	case errors.Is(err, objclient.ErrPreconditionFailed):
+		return errPreconditionFailed
+	case errors.Is(err, objclient.ErrQuotaExceeded):
+		return errQuotaExceeded
+	case errors.Is(err, objclient.ErrThrottled):
+		return errSlowDown
+	case errors.Is(err, objclient.ErrUnreachable):
+		return errUnavailable
+	}
+	return errInternal
+}
+
+func (handler *handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	aerr := toAPIError(err)
+	if aerr.Status >= http.StatusInternalServerError {
+		handler.log(r, err)
+	}
+	s3api.WriteError(w, r, aerr)
+}
+
+func (handler *handler) log(r *http.Request, err error) {
+	if handler.opts.Logger != nil {
+		handler.opts.Logger.Error("s3gateway request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+	}
+}
@@ -0,0 +1,431 @@
+// Package s3gateway serves the objects of any objclient.Client by a subset
+// of the S3 HTTP API, so tools which only speak S3 can use OSS or local
+// directories through it. The supported operations are GetObject,
+// PutObject, HeadObject, DeleteObject and ListObjectsV2 of path style
+// requests, signed by AWS signature v4. DeleteObjects is served too, since
+// S3 clients remove multiple keys by it.
+package s3gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+)
+
+const (
+	defaultRegion  = "us-east-1"
+	metadataPrefix = "x-amz-meta-"
+	maxDeleteKeys  = 1000
+	maxDeleteSize  = 2 << 20
+)
+
+// unsupportedParams are the sub-resources of objects which aren't served,
+// they fail instead of being taken as the object itself.
+var unsupportedParams = []string{
+	"acl", "attributes", "legal-hold", "restore", "retention", "select",
+	"tagging", "torrent", "uploadId", "uploads",
+}
+
+type Options struct {
+	// Bucket is the name of the bucket in the paths of requests,
+	// "/bucket/key". Virtual hosted style requests aren't supported.
+	Bucket string
+	// Region of the signatures, defaults to "us-east-1".
+	Region string
+	// Credentials maps the access key IDs to the secret keys of accepted
+	// signatures. Requests aren't authenticated if it's empty.
+	Credentials map[string]string
+	// Logger logs the failures of the client if it's set.
+	Logger *slog.Logger
+}
+
+type handler struct {
+	client objclient.Client
+	opts   Options
+}
+
+// NewHandler serves the objects of client as the bucket of opts.
+func NewHandler(client objclient.Client, opts Options) http.Handler {
+	if opts.Region == "" {
+		opts.Region = defaultRegion
+	}
+	return &handler{client: client, opts: opts}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sig, err := handler.authenticate(r)
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		// ListBuckets
+		handler.writeError(w, r, errNotImplemented)
+		return
+	}
+	if bucket != handler.opts.Bucket {
+		handler.writeError(w, r, errNoSuchBucket)
+		return
+	}
+	if key == "" {
+		handler.serveBucket(w, r, sig)
+		return
+	}
+
+	query := r.URL.Query()
+	for _, param := range unsupportedParams {
+		if query.Has(param) {
+			handler.writeError(w, r, errNotImplemented)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		handler.getObject(w, r, key)
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			handler.writeError(w, r, errNotImplemented)
+			return
+		}
+		handler.putObject(w, r, key, sig)
+	case http.MethodDelete:
+		handler.deleteObject(w, r, key)
+	default:
+		handler.writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+func (handler *handler) serveBucket(w http.ResponseWriter, r *http.Request, sig *signature) {
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodHead:
+		// HeadBucket
+	case r.Method == http.MethodGet && query.Has("location"):
+		s3api.WriteXML(w, http.StatusOK, &s3api.LocationConstraint{Region: handler.opts.Region})
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		handler.listObjects(w, r)
+	case r.Method == http.MethodPost && query.Has("delete"):
+		handler.deleteObjects(w, r, sig)
+	case r.Method == http.MethodGet:
+		// ListObjects v1 and the other sub-resources of buckets.
+		handler.writeError(w, r, errNotImplemented)
+	default:
+		handler.writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+func (handler *handler) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	ctx := r.Context()
+	info, err := handler.client.Info(ctx, key)
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+	if status := s3api.CheckPreconditions(r, info.ETag, info.LastModified); status == http.StatusNotModified {
+		setETag(w.Header(), info.ETag)
+		w.WriteHeader(status)
+		return
+	} else if status != 0 {
+		handler.writeError(w, r, errPreconditionFailed)
+		return
+	}
+
+	offset, length, partial, err := s3api.ParseRange(r.Header.Get("Range"), info.Size)
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+
+	var reader io.ReadCloser
+	if r.Method == http.MethodGet {
+		o := &objclient.ReadOptions{Offset: offset}
+		if partial {
+			o.Length = length
+		}
+		reader, err = handler.client.ReadWithOptions(ctx, key, o)
+		if err != nil {
+			handler.writeError(w, r, err)
+			return
+		}
+		defer reader.Close()
+	}
+
+	header := w.Header()
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+	header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	setETag(header, info.ETag)
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		header.Set("Content-Type", t)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	// The values are encoded as they are stored.
+	if metadata, err := objclient.NormalizeMetadata(info.Metadata); err == nil {
+		for k, v := range metadata {
+			header.Set(metadataPrefix+k, v)
+		}
+	}
+	status := http.StatusOK
+	if partial {
+		header.Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+
+			strconv.FormatInt(offset+length-1, 10)+"/"+strconv.FormatInt(info.Size, 10))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+
+	if reader != nil {
+		// The response is truncated by the error, which is only logged.
+		if _, err := io.Copy(w, reader); err != nil {
+			handler.log(r, err)
+		}
+	}
+}
+
+func setETag(header http.Header, etag string) {
+	if etag != "" {
+		header.Set("ETag", s3api.QuoteETag(etag))
+	}
+}
+
+// requestBody returns the body of r and its size, the body fails once it's
+// read if it doesn't match the digests of the headers.
+func requestBody(r *http.Request, sig *signature) (io.Reader, int64, error) {
+	size := r.ContentLength
+	var (
+		body      io.Reader = r.Body
+		sha256Sum []byte
+	)
+	switch payload := r.Header.Get("X-Amz-Content-Sha256"); {
+	case payload == streamingPayload:
+		decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || decoded < 0 {
+			return nil, 0, errMissingContentLength
+		}
+		size = decoded
+		body = s3api.NewChunkReader(body, chunkVerifier(sig))
+	case strings.HasPrefix(payload, "STREAMING-"):
+		// Trailers and unsigned streaming.
+		return nil, 0, errNotImplemented
+	case payload != "" && payload != unsignedPayload:
+		sum, err := hex.DecodeString(payload)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, 0, errInvalidArgument
+		}
+		sha256Sum = sum
+	}
+	if size < 0 {
+		return nil, 0, errMissingContentLength
+	}
+	if sha256Sum != nil {
+		body = &digestReader{r: body, size: size, hash: sha256.New(), sum: sha256Sum, err: errContentSHA256Mismatch}
+	}
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		sum, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(sum) != md5.Size {
+			return nil, 0, errInvalidDigest
+		}
+		body = &digestReader{r: body, size: size, hash: md5.New(), sum: sum, err: errBadDigest}
+	}
+	return body, size, nil
+}
+
+func (handler *handler) putObject(w http.ResponseWriter, r *http.Request, key string, sig *signature) {
+	body, size, err := requestBody(r, sig)
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+	if size == 0 {
+		// Size 0 of WriteOptions is detected from the reader, the body is
+		// still checked.
+		n, err := io.Copy(io.Discard, body)
+		if err == nil && n > 0 {
+			err = errIncompleteBody
+		}
+		if err != nil {
+			handler.writeError(w, r, err)
+			return
+		}
+		body = bytes.NewReader(nil)
+	}
+
+	o := &objclient.WriteOptions{Size: size}
+	for name, values := range r.Header {
+		if k, ok := strings.CutPrefix(strings.ToLower(name), metadataPrefix); ok && k != "" {
+			if o.Metadata == nil {
+				o.Metadata = make(map[string]string)
+			}
+			o.Metadata[k] = values[0]
+		}
+	}
+	if t, err := http.ParseTime(r.Header.Get("Expires")); err == nil {
+		o.Expires = t
+	}
+	o.ContentType = r.Header.Get("Content-Type")
+	// The conditional writes are passed to the client.
+	o.IfMatch = strings.Trim(r.Header.Get("If-Match"), `"`)
+	o.IfNoneMatch = strings.Trim(r.Header.Get("If-None-Match"), `"`)
+
+	result, err := handler.client.WriteWithResult(r.Context(), key, body, o)
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+	setETag(w.Header(), result.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (handler *handler) deleteObject(w http.ResponseWriter, r *http.Request, key string) {
+	err := handler.client.Remove(r.Context(), key)
+	var rerr *objclient.RemoveError
+	if errors.As(err, &rerr) && len(rerr.Results) == 1 {
+		err = rerr.Results[0].Err
+	}
+	// Removing a missing key succeeds like S3.
+	if err != nil && !errors.Is(err, objclient.ErrNotFound) {
+		handler.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type deleteRequest struct {
+	Quiet   bool
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject
+	Errors  []deleteError `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key string
+}
+
+type deleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// deleteObjects serves DeleteObjects, which is used by S3 clients to remove
+// multiple keys.
+func (handler *handler) deleteObjects(w http.ResponseWriter, r *http.Request, sig *signature) {
+	body, size, err := requestBody(r, sig)
+	if err == nil && size > maxDeleteSize {
+		err = errInvalidArgument
+	}
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		handler.writeError(w, r, err)
+		return
+	}
+	var req deleteRequest
+	if err := xml.Unmarshal(data, &req); err != nil || len(req.Objects) > maxDeleteKeys {
+		handler.writeError(w, r, errMalformedXML)
+		return
+	}
+
+	keys := make([]string, len(req.Objects))
+	for i, object := range req.Objects {
+		keys[i] = object.Key
+	}
+	failed := make(map[string]error)
+	if err := handler.client.Remove(r.Context(), keys...); err != nil {
+		var rerr *objclient.RemoveError
+		if !errors.As(err, &rerr) {
+			handler.writeError(w, r, err)
+			return
+		}
+		for _, result := range rerr.Results {
+			if !errors.Is(result.Err, objclient.ErrNotFound) {
+				failed[result.Key] = result.Err
+			}
+		}
+	}
+
+	result := &deleteResult{}
+	for _, key := range keys {
+		if err, ok := failed[key]; ok {
+			aerr := toAPIError(err)
+			if aerr.Status >= http.StatusInternalServerError {
+				handler.log(r, err)
+			}
+			result.Errors = append(result.Errors, deleteError{Key: key, Code: aerr.Code, Message: aerr.Message})
+		} else if !req.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: key})
+		}
+	}
+	s3api.WriteXML(w, http.StatusOK, result)
+}
+
+// listObjects serves ListObjectsV2 by the listing of the client.
+func (handler *handler) listObjects(w http.ResponseWriter, r *http.Request) {
+	err := s3api.ListObjects(w, r, handler.opts.Bucket, func(prefix, startAfter string, fn func(item s3api.ListItem) bool) error {
+		return handler.walk(r.Context(), prefix, startAfter, func(item objclient.ObjectItem) bool {
+			return fn(s3api.ListItem{
+				Key:          item.Key,
+				LastModified: item.LastModified,
+				ETag:         item.ETag,
+				Size:         item.Size,
+				StorageClass: item.StorageClass,
+			})
+		})
+	})
+	if err != nil {
+		handler.writeError(w, r, err)
+	}
+}
+
+// walk calls fn with the objects of prefix after startAfter in the order of
+// keys, until it returns false. The listing is stopped early for clients
+// which are objclient.PageLister.
+func (handler *handler) walk(ctx context.Context, prefix, startAfter string, fn func(item objclient.ObjectItem) bool) error {
+	if lister, ok := objclient.AsPageLister(handler.client); ok {
+		return lister.ListPages(ctx, prefix, startAfter, func(items []objclient.ObjectItem) bool {
+			for _, item := range items {
+				if item.Key > startAfter && !fn(item) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	items, err := objclient.ListWithOptions(ctx, handler.client, prefix, &objclient.ListOptions{StartAfter: startAfter})
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if !fn(item) {
+			break
+		}
+	}
+	return nil
+}
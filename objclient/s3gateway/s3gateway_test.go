@@ -0,0 +1,206 @@
+package s3gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+	"github.com/haiwen/goutils/objclient/objsync"
+)
+
+func newGateway(t *testing.T, credentials map[string]string) (*httptest.Server, *objsync.DirClient) {
+	dir := objsync.NewDirClient(t.TempDir())
+	server := httptest.NewServer(NewHandler(dir, Options{Bucket: "bucket", Credentials: credentials}))
+	t.Cleanup(server.Close)
+	return server, dir
+}
+
+func newS3Client(t *testing.T, server *httptest.Server, key string) objclient.Client {
+	client, err := objclient.NewS3("bucket",
+		objclient.WithEndpoint(strings.TrimPrefix(server.URL, "http://")), objclient.WithRegion("us-east-1"),
+		objclient.WithHTTPS(false), objclient.WithKeys("id", key), objclient.WithPathStyle(true))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestGateway(t *testing.T) {
+	ctx := context.Background()
+	server, dir := newGateway(t, map[string]string{"id": "secret"})
+	client := newS3Client(t, server, "secret")
+
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	if err := client.Write(ctx, "a/b", bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Write(ctx, "a/empty", bytes.NewReader(nil), nil); err != nil {
+		t.Fatalf("failed to write empty object: %v", err)
+	}
+	if err := client.Write(ctx, "c", strings.NewReader("c"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if info, err := dir.Info(ctx, "a/b"); err != nil || info.Size != int64(len(data)) {
+		t.Fatalf("invalid object of backend: %+v, %v", info, err)
+	}
+
+	r, err := client.Read(ctx, "a/b")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	read, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("invalid data read: %v bytes, %v", len(read), err)
+	}
+	r, err = client.ReadWithOptions(ctx, "a/b", &objclient.ReadOptions{Offset: 5, Length: 3})
+	if err != nil {
+		t.Fatalf("failed to read range: %v", err)
+	}
+	read, _ = io.ReadAll(r)
+	r.Close()
+	if string(read) != "567" {
+		t.Fatalf("invalid range read %q", read)
+	}
+
+	info, err := client.Info(ctx, "a/b")
+	if err != nil || info.Size != int64(len(data)) {
+		t.Fatalf("invalid info: %+v, %v", info, err)
+	}
+	items, err := client.List(ctx, "a/")
+	if err != nil || len(items) != 2 || items[0].Key != "a/b" || items[1].Key != "a/empty" {
+		t.Fatalf("invalid list: %+v, %v", items, err)
+	}
+
+	if err := client.Remove(ctx, "a/b", "missing"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if _, err := client.Info(ctx, "a/b"); !errors.Is(err, objclient.ErrNotFound) {
+		t.Fatalf("removed object is found: %v", err)
+	}
+	if _, err := client.Read(ctx, "a/b"); !errors.Is(err, objclient.ErrNotFound) {
+		t.Fatalf("removed object is read: %v", err)
+	}
+
+	denied := newS3Client(t, server, "wrong")
+	if _, err := denied.Info(ctx, "c"); err == nil {
+		t.Fatalf("request of wrong key is accepted")
+	}
+	if err := denied.Write(ctx, "d", strings.NewReader("d"), nil); !errors.Is(err, objclient.ErrAccessDenied) {
+		t.Fatalf("write of wrong key isn't denied: %v", err)
+	}
+	if exist, _ := dir.Exist(ctx, "d"); exist {
+		t.Fatalf("denied write is stored")
+	}
+	resp, err := http.Get(server.URL + "/bucket/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("invalid status of anonymous request %v", resp.StatusCode)
+	}
+}
+
+func TestGatewayListPages(t *testing.T) {
+	ctx := context.Background()
+	server, dir := newGateway(t, nil)
+	for _, key := range []string{"a/1", "a/2", "a/sub/1", "a/sub/2", "a/x", "b"} {
+		if err := dir.Write(ctx, key, strings.NewReader(key), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+	var token string
+	for pages := 0; ; pages++ {
+		url := server.URL + "/bucket?list-type=2&prefix=a/&delimiter=/&max-keys=2"
+		if token != "" {
+			url += "&continuation-token=" + token
+		}
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result s3api.ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to decode list result: %v", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		for _, prefix := range result.CommonPrefixes {
+			keys = append(keys, prefix.Prefix)
+		}
+		if !result.IsTruncated {
+			break
+		}
+		if pages > 3 || result.NextContinuationToken == "" {
+			t.Fatalf("invalid list result %+v", result)
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "a/1,a/2,a/sub/,a/x" {
+		t.Fatalf("invalid listed keys %v", keys)
+	}
+}
+
+// sizedClient reads the size of the writes without reaching the end of the
+// readers, as the S3 and OSS clients do.
+type sizedClient struct {
+	objclient.Client
+}
+
+func (client sizedClient) Write(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client sizedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *objclient.WriteOptions) (*objclient.WriteResult, error) {
+	return client.Client.WriteWithResult(ctx, key, io.LimitReader(r, o.Size), o)
+}
+
+func TestGatewayBadDigest(t *testing.T) {
+	dir := objsync.NewDirClient(t.TempDir())
+	server := httptest.NewServer(NewHandler(sizedClient{dir}, Options{Bucket: "bucket"}))
+	defer server.Close()
+
+	// The chunks of the body end after the data is read.
+	put := func(key, header, value string) *http.Response {
+		body := "4;chunk-signature=0\r\ndata\r\n0;chunk-signature=0\r\n\r\n"
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/bucket/"+key, strings.NewReader(body))
+		req.Header.Set("X-Amz-Content-Sha256", streamingPayload)
+		req.Header.Set("X-Amz-Decoded-Content-Length", "4")
+		req.Header.Set(header, value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	// The digest of "other".
+	if resp := put("a", "Content-MD5", "eV8yArF8trw9S3cdjGyerw=="); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid status of bad digest %v", resp.StatusCode)
+	}
+	if exist, _ := dir.Exist(context.Background(), "a"); exist {
+		t.Fatalf("body of bad digest is stored")
+	}
+	// The digest of "data".
+	if resp := put("b", "Content-MD5", "jXd/OF09/siBXSD3SWAm3A=="); resp.StatusCode != http.StatusOK {
+		t.Fatalf("invalid status of digest %v", resp.StatusCode)
+	}
+}
@@ -0,0 +1,41 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+)
+
+// apiError is an error response of S3.
+type apiError = s3api.Error
+
+var (
+	errAccessDenied               = s3api.NewError(http.StatusForbidden, "AccessDenied", "Access denied.")
+	errContentSHA256Mismatch      = s3api.NewError(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The x-amz-content-sha256 header doesn't match the body.")
+	errBadDigest                  = s3api.NewError(http.StatusBadRequest, "BadDigest", "The Content-MD5 header doesn't match the body.")
+	errIncompleteBody             = s3api.ErrIncompleteBody
+	errMalformedXML               = s3api.NewError(http.StatusBadRequest, "MalformedXML", "The XML is malformed.")
+	errInvalidArgument            = s3api.ErrInvalidArgument
+	errInvalidEncryptionAlgorithm = s3api.NewError(http.StatusBadRequest, "InvalidEncryptionAlgorithmError", "The SSE-C algorithm must be AES256.")
+	errInvalidSSECKey             = s3api.NewError(http.StatusBadRequest, "InvalidArgument", "The SSE-C key or its MD5 is invalid.")
+	errMissingSSECKey             = s3api.NewError(http.StatusBadRequest, "InvalidRequest", "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+	errUnencryptedObject          = s3api.NewError(http.StatusBadRequest, "InvalidRequest", "The encryption parameters are not applicable to this object.")
+	errInvalidRange               = s3api.ErrInvalidRange
+	errInvalidPart                = s3api.NewError(http.StatusBadRequest, "InvalidPart", "One or more of the parts can't be found.")
+	errInvalidPartOrder           = s3api.NewError(http.StatusBadRequest, "InvalidPartOrder", "The parts must be in ascending order.")
+	errEntityTooSmall             = s3api.NewError(http.StatusBadRequest, "EntityTooSmall", "The parts except the last one must be at least 5MiB.")
+	errPreconditionFailed         = s3api.NewError(http.StatusPreconditionFailed, "PreconditionFailed", "The precondition failed.")
+	errNoSuchBucket               = s3api.NewError(http.StatusNotFound, "NoSuchBucket", "The bucket doesn't exist.")
+	errNoSuchKey                  = s3api.NewError(http.StatusNotFound, "NoSuchKey", "The key doesn't exist.")
+	errNoSuchUpload               = s3api.NewError(http.StatusNotFound, "NoSuchUpload", "The upload doesn't exist.")
+	errMethodNotAllowed           = s3api.NewError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The method isn't allowed.")
+	errNotImplemented             = s3api.NewError(http.StatusNotImplemented, "NotImplemented", "The operation isn't supported by the test server.")
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	aerr, ok := err.(*apiError)
+	if !ok {
+		aerr = s3api.NewError(http.StatusInternalServerError, "InternalError", err.Error())
+	}
+	s3api.WriteError(w, r, aerr)
+}
@@ -0,0 +1,397 @@
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+)
+
+const (
+	minPartSize       = 5 << 20
+	maxPartNumber     = 10000
+	defaultMaxParts   = 1000
+	defaultMaxUploads = 1000
+)
+
+type part struct {
+	data    []byte
+	etag    string
+	modTime time.Time
+}
+
+type upload struct {
+	id        string
+	key       string
+	initiated time.Time
+	// header has the content type and metadata of the object.
+	header http.Header
+	sseKey string
+	parts  map[int]*part
+}
+
+// Uploads returns the keys of the multipart uploads in bucket which aren't
+// completed or aborted, in order.
+func (s *Server) Uploads(bucket string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, u := range b.sortedUploads() {
+		keys = append(keys, u.key)
+	}
+	return keys
+}
+
+// sortedUploads returns the uploads in the order of keys and IDs.
+func (b *bucket) sortedUploads() []*upload {
+	uploads := make([]*upload, 0, len(b.uploads))
+	for _, u := range b.uploads {
+		uploads = append(uploads, u)
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].key != uploads[j].key {
+			return uploads[i].key < uploads[j].key
+		}
+		return uploads[i].id < uploads[j].id
+	})
+	return uploads
+}
+
+type initiateUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadID string `xml:"UploadId"`
+}
+
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, b *bucket, name, key string) {
+	keyMD5, err := sseKey(r.Header, sseHeader)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	s.mutex.Lock()
+	s.nextID++
+	// The IDs are ordered by the time of creation, like S3.
+	id := fmt.Sprintf("upload-%08d", s.nextID)
+	b.uploads[id] = &upload{
+		id:        id,
+		key:       key,
+		initiated: time.Now(),
+		header:    r.Header.Clone(),
+		sseKey:    keyMD5,
+		parts:     make(map[int]*part),
+	}
+	s.mutex.Unlock()
+
+	setSSEHeaders(w.Header(), keyMD5)
+	s3api.WriteXML(w, http.StatusOK, &initiateUploadResult{Bucket: name, Key: key, UploadID: id})
+}
+
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request, b *bucket, name, key, id string) {
+	s.mutex.Lock()
+	u, ok := b.uploads[id]
+	s.mutex.Unlock()
+	if !ok || u.key != key {
+		writeError(w, r, errNoSuchUpload)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			// UploadPartCopy
+			writeError(w, r, errNotImplemented)
+			return
+		}
+		s.uploadPart(w, r, u)
+	case http.MethodGet:
+		s.listParts(w, r, u, name)
+	case http.MethodPost:
+		s.completeUpload(w, r, b, u, name)
+	case http.MethodDelete:
+		s.mutex.Lock()
+		delete(b.uploads, id)
+		s.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, u *upload) {
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || number < 1 || number > maxPartNumber {
+		writeError(w, r, errInvalidArgument)
+		return
+	}
+	// The parts of SSE-C uploads are sent with the key of the upload.
+	keyMD5, err := sseKey(r.Header, sseHeader)
+	if err == nil && keyMD5 != u.sseKey {
+		err = errInvalidSSECKey
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	sum := md5.Sum(data)
+	p := &part{data: data, etag: hex.EncodeToString(sum[:]), modTime: time.Now()}
+	s.mutex.Lock()
+	u.parts[number] = p
+	s.mutex.Unlock()
+
+	w.Header().Set("ETag", `"`+p.etag+`"`)
+	setSSEHeaders(w.Header(), keyMD5)
+	w.WriteHeader(http.StatusOK)
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string
+	Key                  string
+	UploadID             string `xml:"UploadId"`
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []partInfo `xml:"Part"`
+}
+
+type partInfo struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+func (s *Server) listParts(w http.ResponseWriter, r *http.Request, u *upload, name string) {
+	query := r.URL.Query()
+	result := &listPartsResult{Bucket: name, Key: u.key, UploadID: u.id, MaxParts: defaultMaxParts}
+	if v := query.Get("part-number-marker"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument)
+			return
+		}
+		result.PartNumberMarker = n
+	}
+	if v := query.Get("max-parts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument)
+			return
+		}
+		result.MaxParts = min(n, defaultMaxParts)
+	}
+
+	s.mutex.Lock()
+	numbers := make([]int, 0, len(u.parts))
+	for number := range u.parts {
+		if number > result.PartNumberMarker {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		if len(result.Parts) == result.MaxParts {
+			result.IsTruncated = true
+			break
+		}
+		p := u.parts[number]
+		result.Parts = append(result.Parts, partInfo{
+			PartNumber:   number,
+			LastModified: s3api.FormatTime(p.modTime),
+			ETag:         `"` + p.etag + `"`,
+			Size:         int64(len(p.data)),
+		})
+		result.NextPartNumberMarker = number
+	}
+	s.mutex.Unlock()
+	s3api.WriteXML(w, http.StatusOK, result)
+}
+
+type completeUploadRequest struct {
+	Parts []struct {
+		PartNumber int
+		ETag       string
+	} `xml:"Part"`
+}
+
+type completeUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// completeUpload serves CompleteMultipartUpload. The ETag of the object is
+// the MD5 of the MD5s of the parts with the count of them, like S3.
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, b *bucket, u *upload, name string) {
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var req completeUploadRequest
+	if err := xml.Unmarshal(data, &req); err != nil || len(req.Parts) == 0 {
+		writeError(w, r, errMalformedXML)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var (
+		content bytes.Buffer
+		sums    []byte
+	)
+	for i, p := range req.Parts {
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			writeError(w, r, errInvalidPartOrder)
+			return
+		}
+		uploaded, ok := u.parts[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != uploaded.etag {
+			writeError(w, r, errInvalidPart)
+			return
+		}
+		if i < len(req.Parts)-1 && len(uploaded.data) < minPartSize {
+			writeError(w, r, errEntityTooSmall)
+			return
+		}
+		content.Write(uploaded.data)
+		sum, _ := hex.DecodeString(uploaded.etag)
+		sums = append(sums, sum...)
+	}
+	if _, ok := b.uploads[u.id]; !ok {
+		// Aborted by another request meanwhile.
+		writeError(w, r, errNoSuchUpload)
+		return
+	}
+
+	obj := newObject(u.header, content.Bytes(), u.sseKey)
+	sum := md5.Sum(sums)
+	obj.etag = hex.EncodeToString(sum[:]) + "-" + strconv.Itoa(len(req.Parts))
+	b.objects[u.key] = obj
+	delete(b.uploads, u.id)
+
+	setSSEHeaders(w.Header(), u.sseKey)
+	s3api.WriteXML(w, http.StatusOK, &completeUploadResult{
+		Location: "/" + name + "/" + u.key,
+		Bucket:   name,
+		Key:      u.key,
+		ETag:     `"` + obj.etag + `"`,
+	})
+}
+
+type listUploadsResult struct {
+	XMLName            xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket             string
+	KeyMarker          string
+	UploadIDMarker     string `xml:"UploadIdMarker"`
+	NextKeyMarker      string
+	NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+	Prefix             string
+	Delimiter          string `xml:",omitempty"`
+	EncodingType       string `xml:",omitempty"`
+	MaxUploads         int
+	IsTruncated        bool
+	Uploads            []uploadInfo `xml:"Upload"`
+	CommonPrefixes     []s3api.CommonPrefix
+}
+
+type uploadInfo struct {
+	Key          string
+	UploadID     string `xml:"UploadId"`
+	Initiated    string
+	StorageClass string
+}
+
+// listUploads serves ListMultipartUploads. The uploads are listed after the
+// key marker, or after the upload ID marker of the key.
+func (s *Server) listUploads(w http.ResponseWriter, r *http.Request, b *bucket) {
+	query := r.URL.Query()
+	result := &listUploadsResult{
+		KeyMarker:      query.Get("key-marker"),
+		UploadIDMarker: query.Get("upload-id-marker"),
+		Prefix:         query.Get("prefix"),
+		Delimiter:      query.Get("delimiter"),
+		MaxUploads:     defaultMaxUploads,
+	}
+	result.Bucket, _, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if v := query.Get("max-uploads"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument)
+			return
+		}
+		result.MaxUploads = min(n, defaultMaxUploads)
+	}
+	switch v := query.Get("encoding-type"); v {
+	case "", "url":
+		result.EncodingType = v
+	default:
+		writeError(w, r, errInvalidArgument)
+		return
+	}
+
+	s.mutex.Lock()
+	uploads := b.sortedUploads()
+	s.mutex.Unlock()
+
+	var lastPrefix string
+	for _, u := range uploads {
+		if !strings.HasPrefix(u.key, result.Prefix) {
+			continue
+		}
+		if result.KeyMarker != "" && (u.key < result.KeyMarker ||
+			u.key == result.KeyMarker && (result.UploadIDMarker == "" || u.id <= result.UploadIDMarker)) {
+			continue
+		}
+		if result.Delimiter != "" {
+			if i := strings.Index(u.key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				entry := u.key[:len(result.Prefix)+i+len(result.Delimiter)]
+				if entry != lastPrefix {
+					lastPrefix = entry
+					result.CommonPrefixes = append(result.CommonPrefixes, s3api.CommonPrefix{Prefix: s3api.EncodeName(entry, result.EncodingType)})
+				}
+				continue
+			}
+		}
+		if len(result.Uploads) == result.MaxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, uploadInfo{
+			Key:          s3api.EncodeName(u.key, result.EncodingType),
+			UploadID:     u.id,
+			Initiated:    s3api.FormatTime(u.initiated),
+			StorageClass: "STANDARD",
+		})
+		result.NextKeyMarker, result.NextUploadIDMarker = u.key, u.id
+	}
+	result.NextKeyMarker = s3api.EncodeName(result.NextKeyMarker, result.EncodingType)
+	for _, s := range []*string{&result.Prefix, &result.Delimiter, &result.KeyMarker} {
+		*s = s3api.EncodeName(*s, result.EncodingType)
+	}
+	s3api.WriteXML(w, http.StatusOK, result)
+}
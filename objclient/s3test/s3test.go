@@ -0,0 +1,533 @@
+// Package s3test provides an in-memory S3 server for tests, so S3 clients
+// can be tested without credentials and buckets. It serves path style
+// requests of the operations used by objclient: the objects, copies,
+// ListObjectsV2, DeleteObjects, multipart uploads and SSE-C. Requests
+// aren't authenticated, the signatures are ignored.
+//
+// It doesn't import objclient, so the tests of objclient can use it.
+package s3test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haiwen/goutils/objclient/internal/s3api"
+)
+
+const (
+	// Region is the location of the buckets.
+	Region = "us-east-1"
+
+	maxDeleteKeys  = 1000
+	metadataPrefix = "X-Amz-Meta-"
+	sseHeader      = "X-Amz-Server-Side-Encryption-Customer-"
+	copySSEHeader  = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-"
+)
+
+// unsupportedParams are the sub-resources which aren't served, they fail
+// instead of being taken as the object or bucket itself.
+var unsupportedParams = []string{
+	"acl", "attributes", "cors", "legal-hold", "lifecycle", "notification",
+	"policy", "restore", "retention", "select", "tagging", "torrent",
+	"versioning", "versions",
+}
+
+type object struct {
+	data        []byte
+	etag        string
+	modTime     time.Time
+	contentType string
+	metadata    http.Header
+	// sseKey is the MD5 of the SSE-C key, it's empty if the object isn't
+	// encrypted.
+	sseKey string
+}
+
+type bucket struct {
+	objects map[string]*object
+	uploads map[string]*upload
+}
+
+// Server is an in-memory S3 server of the buckets.
+type Server struct {
+	*httptest.Server
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	nextID  int
+}
+
+func newServer(buckets []string) *Server {
+	s := &Server{buckets: make(map[string]*bucket)}
+	for _, name := range buckets {
+		s.buckets[name] = &bucket{objects: make(map[string]*object), uploads: make(map[string]*upload)}
+	}
+	return s
+}
+
+// NewServer starts a server of the buckets by HTTP. The caller should call
+// Close when finished.
+func NewServer(buckets ...string) *Server {
+	s := newServer(buckets)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewTLSServer starts a server of the buckets by HTTPS, which is required
+// by the SSE-C of clients. The certificate is trusted by RootCAs.
+func NewTLSServer(buckets ...string) *Server {
+	s := newServer(buckets)
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Endpoint returns the host and port of the server.
+func (s *Server) Endpoint() string {
+	u, _ := url.Parse(s.URL)
+	return u.Host
+}
+
+// RootCAs returns the pool of the certificate of the TLS server.
+func (s *Server) RootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if cert := s.Certificate(); cert != nil {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// Keys returns the keys of the objects in bucket in order.
+func (s *Server) Keys(bucket string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	return b.sortedKeys()
+}
+
+func (b *bucket) sortedKeys() []string {
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	name, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if name == "" {
+		// ListBuckets
+		writeError(w, r, errNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	for _, param := range unsupportedParams {
+		if query.Has(param) {
+			writeError(w, r, errNotImplemented)
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	b, ok := s.buckets[name]
+	s.mutex.Unlock()
+	if !ok {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	if key == "" {
+		s.serveBucket(w, r, b)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.createUpload(w, r, b, name, key)
+	case query.Has("uploadId"):
+		s.serveUpload(w, r, b, name, key, query.Get("uploadId"))
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		s.getObject(w, r, b, key)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, b, key)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, b, key)
+	case r.Method == http.MethodDelete:
+		s.mutex.Lock()
+		delete(b.objects, key)
+		s.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, b *bucket) {
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodHead:
+		// HeadBucket
+	case r.Method == http.MethodGet && query.Has("location"):
+		s3api.WriteXML(w, http.StatusOK, &s3api.LocationConstraint{Region: Region})
+	case r.Method == http.MethodGet && query.Has("uploads"):
+		s.listUploads(w, r, b)
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		s.listObjects(w, r, b)
+	case r.Method == http.MethodPost && query.Has("delete"):
+		s.deleteObjects(w, r, b)
+	case r.Method == http.MethodGet:
+		// ListObjects v1 and the other sub-resources of buckets.
+		writeError(w, r, errNotImplemented)
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+// sseKey returns the MD5 of the SSE-C key of the headers with prefix, or
+// empty if there's none.
+func sseKey(header http.Header, prefix string) (string, error) {
+	algorithm := header.Get(prefix + "Algorithm")
+	key := header.Get(prefix + "Key")
+	keyMD5 := header.Get(prefix + "Key-Md5")
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return "", nil
+	}
+	if algorithm != "AES256" {
+		return "", errInvalidEncryptionAlgorithm
+	}
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(data) != 32 {
+		return "", errInvalidSSECKey
+	}
+	sum := md5.Sum(data)
+	if base64.StdEncoding.EncodeToString(sum[:]) != keyMD5 {
+		return "", errInvalidSSECKey
+	}
+	return keyMD5, nil
+}
+
+// checkSSEKey checks the SSE-C key of the request for obj like S3, the
+// encrypted objects can only be read with the same key.
+func checkSSEKey(obj *object, keyMD5 string) error {
+	switch {
+	case obj.sseKey == keyMD5:
+		return nil
+	case obj.sseKey == "":
+		return errUnencryptedObject
+	case keyMD5 == "":
+		return errMissingSSECKey
+	}
+	return errAccessDenied
+}
+
+func setSSEHeaders(header http.Header, keyMD5 string) {
+	if keyMD5 != "" {
+		header.Set(sseHeader+"Algorithm", "AES256")
+		header.Set(sseHeader+"Key-Md5", keyMD5)
+	}
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	s.mutex.Lock()
+	obj, ok := b.objects[key]
+	s.mutex.Unlock()
+	if !ok {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	keyMD5, err := sseKey(r.Header, sseHeader)
+	if err == nil {
+		err = checkSSEKey(obj, keyMD5)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	header := w.Header()
+	if status := s3api.CheckPreconditions(r, obj.etag, obj.modTime); status == http.StatusNotModified {
+		header.Set("ETag", `"`+obj.etag+`"`)
+		w.WriteHeader(status)
+		return
+	} else if status != 0 {
+		writeError(w, r, errPreconditionFailed)
+		return
+	}
+
+	size := int64(len(obj.data))
+	offset, length, partial, err := s3api.ParseRange(r.Header.Get("Range"), size)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	for k, v := range obj.metadata {
+		header[k] = v
+	}
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+	header.Set("Content-Type", obj.contentType)
+	header.Set("ETag", `"`+obj.etag+`"`)
+	header.Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+	setSSEHeaders(header, obj.sseKey)
+	// The response-* parameters override the headers of the object.
+	for k, v := range r.URL.Query() {
+		if name, ok := strings.CutPrefix(k, "response-"); ok {
+			header.Set(name, v[0])
+		}
+	}
+	status := http.StatusOK
+	if partial {
+		header.Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+
+			strconv.FormatInt(offset+length-1, 10)+"/"+strconv.FormatInt(size, 10))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(obj.data[offset : offset+length])
+	}
+}
+
+// checkWritePreconditions returns whether the conditional headers of the
+// write of old, which is nil if it doesn't exist, pass.
+func checkWritePreconditions(r *http.Request, old *object) bool {
+	if match := r.Header.Get("If-Match"); match != "" && (old == nil || !s3api.MatchETag(match, old.etag)) {
+		return false
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && old != nil && s3api.MatchETag(match, old.etag) {
+		return false
+	}
+	return true
+}
+
+// readBody reads the body of r, which is decoded if it's streaming, and
+// checks it by the digests of the headers.
+func readBody(r *http.Request) ([]byte, error) {
+	var body io.Reader = r.Body
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if strings.HasPrefix(payload, "STREAMING-") {
+		body = s3api.NewChunkReader(body, nil)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errIncompleteBody
+	}
+
+	if strings.HasPrefix(payload, "STREAMING-") {
+		if v := r.Header.Get("X-Amz-Decoded-Content-Length"); v != "" && v != strconv.Itoa(len(data)) {
+			return nil, errIncompleteBody
+		}
+	} else if payload != "" && payload != "UNSIGNED-PAYLOAD" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != payload {
+			return nil, errContentSHA256Mismatch
+		}
+	}
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		sum := md5.Sum(data)
+		if base64.StdEncoding.EncodeToString(sum[:]) != v {
+			return nil, errBadDigest
+		}
+	}
+	return data, nil
+}
+
+// newObject returns the object of data with the content type and metadata
+// of the request headers.
+func newObject(header http.Header, data []byte, keyMD5 string) *object {
+	sum := md5.Sum(data)
+	obj := &object{
+		data:        data,
+		etag:        hex.EncodeToString(sum[:]),
+		modTime:     time.Now(),
+		contentType: header.Get("Content-Type"),
+		metadata:    make(http.Header),
+		sseKey:      keyMD5,
+	}
+	if obj.contentType == "" {
+		obj.contentType = "binary/octet-stream"
+	}
+	copyMetadata(obj.metadata, header)
+	return obj
+}
+
+// copyMetadata copies the user metadata and the headers of objects which are
+// returned by GET.
+func copyMetadata(dst, src http.Header) {
+	for k, v := range src {
+		switch {
+		case strings.HasPrefix(k, metadataPrefix), k == "Cache-Control", k == "Content-Disposition",
+			k == "Content-Encoding", k == "Content-Language", k == "Expires":
+			dst[k] = v
+		}
+	}
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	keyMD5, err := sseKey(r.Header, sseHeader)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	obj := newObject(r.Header, data, keyMD5)
+	s.mutex.Lock()
+	if !checkWritePreconditions(r, b.objects[key]) {
+		s.mutex.Unlock()
+		writeError(w, r, errPreconditionFailed)
+		return
+	}
+	b.objects[key] = obj
+	s.mutex.Unlock()
+
+	w.Header().Set("ETag", `"`+obj.etag+`"`)
+	setSSEHeaders(w.Header(), keyMD5)
+	w.WriteHeader(http.StatusOK)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string
+	ETag         string
+}
+
+// copyObject serves CopyObject, the source is the "/bucket/key" of the
+// X-Amz-Copy-Source header.
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeError(w, r, errInvalidArgument)
+		return
+	}
+	source, _, _ = strings.Cut(source, "?versionId=")
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	srcSSEKey, err := sseKey(r.Header, copySSEHeader)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	keyMD5, err := sseKey(r.Header, sseHeader)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	src, ok := s.buckets[srcBucket]
+	if !ok {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+	srcObj, ok := src.objects[srcKey]
+	if !ok {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	if err := checkSSEKey(srcObj, srcSSEKey); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	header := r.Header
+	if r.Header.Get("X-Amz-Metadata-Directive") != "REPLACE" {
+		header = srcObj.metadata.Clone()
+		header.Set("Content-Type", srcObj.contentType)
+	}
+	obj := newObject(header, srcObj.data, keyMD5)
+	b.objects[key] = obj
+
+	setSSEHeaders(w.Header(), keyMD5)
+	s3api.WriteXML(w, http.StatusOK, &copyObjectResult{
+		LastModified: s3api.FormatTime(obj.modTime),
+		ETag:         `"` + obj.etag + `"`,
+	})
+}
+
+type deleteRequest struct {
+	Quiet   bool
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject
+}
+
+type deletedObject struct {
+	Key string
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, b *bucket) {
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var req deleteRequest
+	if err := xml.Unmarshal(data, &req); err != nil || len(req.Objects) > maxDeleteKeys {
+		writeError(w, r, errMalformedXML)
+		return
+	}
+
+	result := &deleteResult{}
+	s.mutex.Lock()
+	for _, obj := range req.Objects {
+		delete(b.objects, obj.Key)
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: obj.Key})
+		}
+	}
+	s.mutex.Unlock()
+	s3api.WriteXML(w, http.StatusOK, result)
+}
+
+// listObjects serves ListObjectsV2 by the keys of b when it's called.
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, b *bucket) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	s.mutex.Lock()
+	keys := b.sortedKeys()
+	objects := make(map[string]*object, len(b.objects))
+	for key, obj := range b.objects {
+		objects[key] = obj
+	}
+	s.mutex.Unlock()
+
+	err := s3api.ListObjects(w, r, name, func(prefix, startAfter string, fn func(item s3api.ListItem) bool) error {
+		for _, key := range keys[sort.SearchStrings(keys, startAfter):] {
+			if key <= startAfter || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			obj := objects[key]
+			if !fn(s3api.ListItem{Key: key, LastModified: obj.modTime, ETag: obj.etag, Size: int64(len(obj.data))}) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
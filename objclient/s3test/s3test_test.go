@@ -0,0 +1,117 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/haiwen/goutils/objclient"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func newClient(t *testing.T, server *Server, opts ...objclient.Option) *objclient.S3Client {
+	opts = append([]objclient.Option{
+		objclient.WithEndpoint(server.Endpoint()), objclient.WithRegion(Region),
+		objclient.WithHTTPS(server.TLS != nil), objclient.WithRootCAs(server.RootCAs()),
+		objclient.WithKeys("id", "key"), objclient.WithPathStyle(true),
+	}, opts...)
+	client, err := objclient.NewS3("bucket", opts...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestMultipart(t *testing.T) {
+	ctx := context.Background()
+	// The uploads of plain HTTP are streaming signed.
+	server := NewServer("bucket")
+	defer server.Close()
+	client := newClient(t, server, objclient.WithMultipart(5<<20, 2))
+
+	data := make([]byte, 11<<20)
+	rand.Read(data)
+	result, err := client.WriteWithResult(ctx, "a/b", bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if !strings.HasSuffix(result.ETag, "-3") {
+		t.Fatalf("invalid ETag of multipart upload %q", result.ETag)
+	}
+	r, err := client.ReadWithOptions(ctx, "a/b", &objclient.ReadOptions{Offset: 6 << 20, Length: 1 << 20})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	read, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(read, data[6<<20:7<<20]) {
+		t.Fatalf("invalid data read: %v bytes, %v", len(read), err)
+	}
+	if uploads := server.Uploads("bucket"); len(uploads) != 0 {
+		t.Fatalf("uploads aren't completed: %v", uploads)
+	}
+
+	core, err := minio.NewCore(server.Endpoint(), &minio.Options{Creds: credentials.NewStaticV4("id", "key", ""), Region: Region})
+	if err != nil {
+		t.Fatalf("failed to create minio client: %v", err)
+	}
+	id, err := core.NewMultipartUpload(ctx, "bucket", "c", minio.PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	if _, err := core.PutObjectPart(ctx, "bucket", "c", id, 1, strings.NewReader("1"), 1, minio.PutObjectPartOptions{}); err != nil {
+		t.Fatalf("failed to upload part: %v", err)
+	}
+	if _, err := core.PutObjectPart(ctx, "bucket", "c", id, 2, strings.NewReader("2"), 1, minio.PutObjectPartOptions{}); err != nil {
+		t.Fatalf("failed to upload part: %v", err)
+	}
+	listed, err := core.ListMultipartUploads(ctx, "bucket", "", "", "", "", 1000)
+	if err != nil || len(listed.Uploads) != 1 || listed.Uploads[0].UploadID != id {
+		t.Fatalf("invalid uploads listed: %+v, %v", listed, err)
+	}
+	parts := []minio.CompletePart{{PartNumber: 1, ETag: "c4ca4238a0b923820dcc509a6f75849b"}, {PartNumber: 2, ETag: "c81e728d9d4c2f636f067f89cc14862c"}}
+	if _, err := core.CompleteMultipartUpload(ctx, "bucket", "c", id, parts, minio.PutObjectOptions{}); minio.ToErrorResponse(err).Code != "EntityTooSmall" {
+		t.Fatalf("small parts are completed: %v", err)
+	}
+	if err := core.AbortMultipartUpload(ctx, "bucket", "c", id); err != nil {
+		t.Fatalf("failed to abort upload: %v", err)
+	}
+	if uploads := server.Uploads("bucket"); len(uploads) != 0 {
+		t.Fatalf("upload isn't aborted: %v", uploads)
+	}
+	if keys := server.Keys("bucket"); strings.Join(keys, ",") != "a/b" {
+		t.Fatalf("invalid keys %v", keys)
+	}
+}
+
+func TestSSEC(t *testing.T) {
+	ctx := context.Background()
+	server := NewTLSServer("bucket")
+	defer server.Close()
+	key := strings.Repeat("k", 32)
+	client := newClient(t, server, objclient.WithSSECKey(key))
+
+	if err := client.Write(ctx, "a", strings.NewReader("a"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Copy(ctx, "a", "b"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if info, err := client.Info(ctx, "b"); err != nil || info.Size != 1 {
+		t.Fatalf("invalid info of copy: %+v, %v", info, err)
+	}
+
+	if _, err := newClient(t, server).Info(ctx, "a"); err == nil {
+		t.Fatalf("encrypted object is read without key")
+	}
+	other := newClient(t, server, objclient.WithSSECKey(strings.Repeat("o", 32)))
+	if _, err := other.Read(ctx, "b"); !errors.Is(err, objclient.ErrAccessDenied) {
+		t.Fatalf("encrypted object is read with another key: %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyPolicy describes how keys are checked and transformed before being
+// sent to the backend.
+type KeyPolicy struct {
+	// RejectInvalid rejects keys with control characters or invalid UTF-8.
+	RejectInvalid bool
+	// CleanPath removes "." and ".." segments and repeated slashes, keys
+	// escaping the root by ".." are rejected.
+	CleanPath bool
+	// Escape is the characters to be percent-encoded, "%" is encoded too if
+	// it's not empty. Keys in List results are decoded.
+	Escape string
+}
+
+// DefaultKeyPolicy rejects keys that some backends don't accept, and
+// cleans the paths.
+var DefaultKeyPolicy = KeyPolicy{RejectInvalid: true, CleanPath: true}
+
+// Sanitize returns the key sent to the backend, or an error wrapping
+// ErrInvalidKey if the key is rejected.
+func (policy KeyPolicy) Sanitize(key string) (string, error) {
+	if policy.RejectInvalid {
+		if !utf8.ValidString(key) {
+			return "", fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidKey, key)
+		}
+		if strings.IndexFunc(key, unicode.IsControl) >= 0 {
+			return "", fmt.Errorf("%w: %q contains control characters", ErrInvalidKey, key)
+		}
+	}
+
+	if policy.CleanPath && key != "" {
+		dir := strings.HasSuffix(key, "/")
+		cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+		// Clean of a rooted path drops leading "..", so check it on the
+		// relative path.
+		if rel := path.Clean(key); rel == ".." || strings.HasPrefix(rel, "../") {
+			return "", fmt.Errorf("%w: %q is out of the root", ErrInvalidKey, key)
+		}
+		if dir && cleaned != "" {
+			cleaned += "/"
+		}
+		key = cleaned
+	}
+
+	if policy.Escape != "" {
+		key = policy.escape(key)
+	}
+
+	return key, nil
+}
+
+func (policy KeyPolicy) escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == '%' || strings.IndexByte(policy.Escape, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescape returns the key of the user from the key of the backend.
+func (policy KeyPolicy) unescape(key string) string {
+	if policy.Escape == "" {
+		return key
+	}
+	unescaped, err := url.PathUnescape(key)
+	if err != nil {
+		return key
+	}
+	return unescaped
+}
+
+type sanitizingClient struct {
+	inner  Client
+	policy KeyPolicy
+}
+
+// NewSanitizingClient applies policy to the keys of all operations before
+// sending them to inner.
+func NewSanitizingClient(inner Client, policy KeyPolicy) Client {
+	client := &sanitizingClient{inner: inner, policy: policy}
+	if _, ok := AsPageLister(inner); ok {
+		return &sanitizingPageClient{client}
+	}
+	return client
+}
+
+// sanitizingPageClient is the sanitizingClient of a PageLister.
+type sanitizingPageClient struct {
+	*sanitizingClient
+}
+
+func (client *sanitizingPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	prefix, err := client.policy.Sanitize(prefix)
+	if err != nil {
+		return err
+	}
+	if startAfter != "" {
+		if startAfter, err = client.policy.Sanitize(startAfter); err != nil {
+			return err
+		}
+	}
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, func(items []ObjectItem) bool {
+		for i := range items {
+			items[i].Key = client.policy.unescape(items[i].Key)
+		}
+		return fn(items)
+	})
+}
+
+func (client *sanitizingClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *sanitizingClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	key, err := client.policy.Sanitize(key)
+	if err != nil {
+		return nil, err
+	}
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *sanitizingClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *sanitizingClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	key, err := client.policy.Sanitize(key)
+	if err != nil {
+		return nil, err
+	}
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *sanitizingClient) Exist(ctx context.Context, key string) (bool, error) {
+	key, err := client.policy.Sanitize(key)
+	if err != nil {
+		return false, err
+	}
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *sanitizingClient) Remove(ctx context.Context, keys ...string) error {
+	sanitized := make([]string, len(keys))
+	for i, key := range keys {
+		var err error
+		sanitized[i], err = client.policy.Sanitize(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := client.inner.Remove(ctx, sanitized...)
+	var rerr *RemoveError
+	if errors.As(err, &rerr) {
+		results := make([]RemoveResult, len(rerr.Results))
+		for i, result := range rerr.Results {
+			results[i] = RemoveResult{Key: client.policy.unescape(result.Key), Err: result.Err}
+		}
+		return &RemoveError{Results: results}
+	}
+	return err
+}
+
+func (client *sanitizingClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	prefix, err := client.policy.Sanitize(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := client.inner.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Key = client.policy.unescape(items[i].Key)
+	}
+	return items, nil
+}
+
+func (client *sanitizingClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, err := client.policy.Sanitize(key)
+	if err != nil {
+		return nil, err
+	}
+	return client.inner.Info(ctx, key)
+}
+
+func (client *sanitizingClient) Copy(ctx context.Context, src, dst string) error {
+	src, err := client.policy.Sanitize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = client.policy.Sanitize(dst)
+	if err != nil {
+		return err
+	}
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *sanitizingClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,66 @@
+package objclient
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	policy := KeyPolicy{RejectInvalid: true, CleanPath: true, Escape: "#?"}
+
+	tests := []struct {
+		key    string
+		expect string
+		err    bool
+	}{
+		{"a/b", "a/b", false},
+		{"/a//b/./c/../d", "a/b/d", false},
+		{"a/b/", "a/b/", false},
+		{"a/#1?", "a/%231%3F", false},
+		{"a/100%", "a/100%25", false},
+		{"../a", "", true},
+		{"a/../../b", "", true},
+		{"a\nb", "", true},
+		{"a\xffb", "", true},
+	}
+
+	for _, test := range tests {
+		key, err := policy.Sanitize(test.key)
+		if test.err {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("expect invalid key %q: %v", test.key, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != test.expect {
+			t.Fatalf("invalid key of %q: %q", test.key, key)
+		}
+		if test.key[0] != '/' && !strings.Contains(test.key, "//") && policy.unescape(key) != test.key {
+			t.Fatalf("invalid unescaped key of %q: %q", key, policy.unescape(key))
+		}
+	}
+}
+
+func TestSanitizingClient(t *testing.T) {
+	mem := newMemClient()
+	client := NewSanitizingClient(mem, KeyPolicy{Escape: "#"})
+
+	err := client.Write(ctx, "objclient/#1", strings.NewReader("demo"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readString(t, mem, "objclient/%231"); data != "demo" {
+		t.Fatalf("invalid data from Read(): %q", data)
+	}
+	items, err := client.List(ctx, "objclient/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Key != "objclient/#1" {
+		t.Fatalf("invalid items: %v", items)
+	}
+}
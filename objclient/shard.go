@@ -0,0 +1,222 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ShardedClient routes keys to one of the shards by the hash of key.
+type ShardedClient struct {
+	shards []Client
+	hashFn func(key string) uint32
+}
+
+func fnvHash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// NewShardedClient creates a client of shards. The hashFn can be nil, then
+// FNV-1a is used. Changing the shards or hashFn moves keys to other shards,
+// call Rebalance after that.
+func NewShardedClient(shards []Client, hashFn func(key string) uint32) (*ShardedClient, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shards")
+	}
+	if hashFn == nil {
+		hashFn = fnvHash
+	}
+
+	return &ShardedClient{shards: shards, hashFn: hashFn}, nil
+}
+
+func (client *ShardedClient) shardIndex(key string) int {
+	return int(client.hashFn(key) % uint32(len(client.shards)))
+}
+
+// Shard returns the shard of key.
+func (client *ShardedClient) Shard(key string) Client {
+	return client.shards[client.shardIndex(key)]
+}
+
+// Rebalance moves the objects under prefix which are not in their shard.
+// It returns the number of moved objects.
+func (client *ShardedClient) Rebalance(ctx context.Context, prefix string) (int, error) {
+	var moved int
+	for i, shard := range client.shards {
+		items, err := shard.List(ctx, prefix)
+		if err != nil {
+			return moved, err
+		}
+
+		for _, item := range items {
+			j := client.shardIndex(item.Key)
+			if i == j {
+				continue
+			}
+
+			err := copyObject(ctx, shard, item.Key, client.shards[j], item.Key)
+			if err != nil {
+				return moved, err
+			}
+			if err := shard.Remove(ctx, item.Key); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+
+	return moved, nil
+}
+
+func (client *ShardedClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.Shard(key).Read(ctx, key)
+}
+
+func (client *ShardedClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.Shard(key).ReadWithOptions(ctx, key, o)
+}
+
+func (client *ShardedClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	return client.Shard(key).Write(ctx, key, r, o)
+}
+
+func (client *ShardedClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	return client.Shard(key).WriteWithResult(ctx, key, r, o)
+}
+
+func (client *ShardedClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.Shard(key).Exist(ctx, key)
+}
+
+func (client *ShardedClient) Remove(ctx context.Context, keys ...string) error {
+	groups := make(map[int][]string)
+	for _, key := range keys {
+		i := client.shardIndex(key)
+		groups[i] = append(groups[i], key)
+	}
+
+	var results []RemoveResult
+	for i, keys := range groups {
+		err := client.shards[i].Remove(ctx, keys...)
+		if err == nil {
+			continue
+		}
+		var rerr *RemoveError
+		if errors.As(err, &rerr) {
+			results = append(results, rerr.Results...)
+			continue
+		}
+		for _, key := range keys {
+			results = append(results, RemoveResult{Key: key, Err: err})
+		}
+	}
+	if len(results) > 0 {
+		return &RemoveError{Results: results}
+	}
+
+	return nil
+}
+
+// List lists all the shards concurrently, items are sorted by key.
+func (client *ShardedClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	var (
+		wg    sync.WaitGroup
+		lists = make([][]ObjectItem, len(client.shards))
+		errs  = make([]error, len(client.shards))
+	)
+	for i, shard := range client.shards {
+		wg.Add(1)
+		go func(i int, shard Client) {
+			defer wg.Done()
+			lists[i], errs[i] = shard.List(ctx, prefix)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var items []ObjectItem
+	for i := range client.shards {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		items = append(items, lists[i]...)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+
+	return items, nil
+}
+
+// ListPages implements PageLister by merging the pages of the shards.
+func (client *ShardedClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	its := make([]*itemIterator, len(client.shards))
+	for i, shard := range client.shards {
+		its[i] = newItemIterator(ctx, shard, prefix, startAfter)
+	}
+	page := make([]ObjectItem, 0, listPageSize)
+	for {
+		var first *itemIterator
+		var item ObjectItem
+		for _, it := range its {
+			next, ok := it.peek()
+			if !ok {
+				if it.listErr != nil {
+					return it.listErr
+				}
+				continue
+			}
+			if first == nil || next.Key < item.Key {
+				first, item = it, next
+			}
+		}
+		if first == nil {
+			break
+		}
+		first.next()
+		page = append(page, item)
+		if len(page) == listPageSize {
+			if !fn(page) {
+				return nil
+			}
+			page = page[:0]
+		}
+	}
+	if len(page) > 0 {
+		fn(page)
+	}
+	return nil
+}
+
+// listsPages reports whether the shards are PageListers, for AsPageLister.
+func (client *ShardedClient) listsPages() bool {
+	for _, shard := range client.shards {
+		if _, ok := AsPageLister(shard); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (client *ShardedClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.Shard(key).Info(ctx, key)
+}
+
+// Copy is server side only if src and dst are of the same shard.
+func (client *ShardedClient) Copy(ctx context.Context, src, dst string) error {
+	i, j := client.shardIndex(src), client.shardIndex(dst)
+	if i == j {
+		return client.shards[i].Copy(ctx, src, dst)
+	}
+	return copyObject(ctx, client.shards[i], src, client.shards[j], dst)
+}
+
+func (client *ShardedClient) Close() error {
+	return closeAll(client.shards...)
+}
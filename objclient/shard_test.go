@@ -0,0 +1,55 @@
+package objclient
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestShardedClient(t *testing.T) {
+	shards := []Client{newMemClient(), newMemClient()}
+	client, err := NewShardedClient(shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("objclient/%d", i)
+		if err := client.Write(ctx, key, strings.NewReader(key), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, shard := range shards {
+		items, _ := shard.List(ctx, "objclient/")
+		if len(items) == 0 || len(items) == 10 {
+			t.Fatalf("keys are not sharded: %v", items)
+		}
+	}
+	items, err := client.List(ctx, "objclient/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 10 || items[0].Key != "objclient/0" {
+		t.Fatalf("invalid items: %v", items)
+	}
+
+	// Adding a shard moves keys.
+	shards = append(shards, newMemClient())
+	client, err = NewShardedClient(shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := client.Rebalance(ctx, "objclient/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved == 0 {
+		t.Fatal("expect keys moved")
+	}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("objclient/%d", i)
+		if data := readString(t, client, key); data != key {
+			t.Fatalf("invalid data from Read(): %q", data)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotManifestKey        = "manifest.json"
+	defaultSnapshotConcurrency = 16
+)
+
+// SnapshotManifest is the manifest object of a snapshot.
+type SnapshotManifest struct {
+	Created time.Time `json:"created"`
+	// Prefix is the source prefix of the snapshot.
+	Prefix  string           `json:"prefix"`
+	Objects []SnapshotObject `json:"objects"`
+}
+
+// SnapshotObject is an object of a snapshot, whose key is relative to the
+// prefixes.
+type SnapshotObject struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	ETag string `json:"etag"`
+}
+
+// Snapshot copies the objects of srcPrefix to snapshotPrefix by concurrent
+// copies in the backend, with at most concurrency in flight, a default is
+// used if concurrency <= 0. Then the manifest of the copies is written to
+// "manifest.json" of snapshotPrefix, which is only written if all the
+// objects are copied. The objects written during the snapshot may be
+// copied or not. The prefixes can't contain each other. Failed copies are
+// reported by a *MultiError.
+func Snapshot(ctx context.Context, client Client, srcPrefix, snapshotPrefix string, concurrency int) (*SnapshotManifest, error) {
+	if strings.HasPrefix(srcPrefix, snapshotPrefix) || strings.HasPrefix(snapshotPrefix, srcPrefix) {
+		return nil, fmt.Errorf("prefixes %q and %q overlap", srcPrefix, snapshotPrefix)
+	}
+	created := time.Now()
+	items, err := client.List(ctx, srcPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %v: %w", srcPrefix, err)
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = strings.TrimPrefix(item.Key, srcPrefix)
+	}
+	if err := copyMulti(ctx, client, srcPrefix, snapshotPrefix, keys, concurrency); err != nil {
+		return nil, err
+	}
+
+	copies := make([]string, len(keys))
+	for i, key := range keys {
+		copies[i] = snapshotPrefix + key
+	}
+	infos, err := InfoMulti(ctx, client, copies, concurrency)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &SnapshotManifest{Created: created, Prefix: srcPrefix, Objects: make([]SnapshotObject, len(keys))}
+	for i, key := range keys {
+		info := infos[copies[i]]
+		manifest.Objects[i] = SnapshotObject{Key: key, Size: info.Size, ETag: info.ETag}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Write(ctx, snapshotPrefix+snapshotManifestKey, bytes.NewReader(data), &WriteOptions{Size: int64(len(data))}); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// ReadSnapshotManifest reads the manifest of the snapshot of
+// snapshotPrefix.
+func ReadSnapshotManifest(ctx context.Context, client ReadOnlyClient, snapshotPrefix string) (*SnapshotManifest, error) {
+	r, err := client.Read(ctx, snapshotPrefix+snapshotManifestKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest SnapshotManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// RestoreSnapshot copies the objects in the manifest of snapshotPrefix to
+// dstPrefix like Snapshot. The objects of dstPrefix which aren't in the
+// snapshot are kept. It fails with ErrObjectChanged if an object of the
+// snapshot isn't the one in the manifest.
+func RestoreSnapshot(ctx context.Context, client Client, snapshotPrefix, dstPrefix string, concurrency int) error {
+	manifest, err := ReadSnapshotManifest(ctx, client, snapshotPrefix)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, len(manifest.Objects))
+	copies := make([]string, len(manifest.Objects))
+	for i, obj := range manifest.Objects {
+		keys[i] = obj.Key
+		copies[i] = snapshotPrefix + obj.Key
+	}
+	infos, err := InfoMulti(ctx, client, copies, concurrency)
+	if err != nil {
+		return err
+	}
+	for i, obj := range manifest.Objects {
+		if info := infos[copies[i]]; info.Size != obj.Size || obj.ETag != "" && info.ETag != obj.ETag {
+			return fmt.Errorf("%w: %v of the snapshot", ErrObjectChanged, copies[i])
+		}
+	}
+	return copyMulti(ctx, client, snapshotPrefix, dstPrefix, keys, concurrency)
+}
+
+// copyMulti copies keys from srcPrefix to dstPrefix with at most
+// concurrency copies in flight.
+func copyMulti(ctx context.Context, client Client, srcPrefix, dstPrefix string, keys []string, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = defaultSnapshotConcurrency
+	}
+
+	var (
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+		errs   = make(map[string]error)
+		tokens = make(chan struct{}, concurrency)
+	)
+	for _, key := range keys {
+		select {
+		case tokens <- struct{}{}:
+		case <-ctx.Done():
+			mutex.Lock()
+			errs[srcPrefix+key] = ctx.Err()
+			mutex.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-tokens }()
+
+			if err := client.Copy(ctx, srcPrefix+key, dstPrefix+key); err != nil {
+				mutex.Lock()
+				errs[srcPrefix+key] = err
+				mutex.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	mem := newMemClient()
+	mem.put("data/a", "a")
+	mem.put("data/b/c", "bc")
+
+	manifest, err := Snapshot(context.Background(), mem, "data/", "snapshots/1/", 2)
+	if err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	if len(manifest.Objects) != 2 || manifest.Objects[1].Key != "b/c" || manifest.Objects[1].Size != 2 || manifest.Objects[1].ETag == "" {
+		t.Fatalf("invalid manifest: %+v", manifest)
+	}
+	if _, err := Snapshot(context.Background(), mem, "data/", "data/snapshot/", 0); err == nil {
+		t.Fatalf("overlapped prefixes are accepted")
+	}
+
+	mem.put("data/a", "changed")
+	mem.put("data/d", "d")
+	if err := RestoreSnapshot(context.Background(), mem, "snapshots/1/", "data/", 0); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if data := string(mem.objects["data/a"].data); data != "a" {
+		t.Fatalf("invalid restored object: %q", data)
+	}
+	if _, ok := mem.objects["data/d"]; !ok {
+		t.Fatalf("objects out of the snapshot are removed")
+	}
+
+	// The snapshot is modified.
+	mem.put("snapshots/1/a", "modified")
+	if err := RestoreSnapshot(context.Background(), mem, "snapshots/1/", "data/", 0); !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("modified snapshot is restored: %v", err)
+	}
+}
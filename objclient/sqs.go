@@ -0,0 +1,174 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type SQSConfig struct {
+	// QueueURL looks like https://sqs.us-east-1.amazonaws.com/123456789012/name.
+	QueueURL string
+	// Region is parsed from QueueURL if empty.
+	Region string
+	KeyID  string
+	Key    string
+}
+
+type sqsNotifications struct {
+	queue  *url.URL
+	region string
+	keyID  string
+	key    string
+	client *http.Client
+}
+
+// NewSQSNotifications returns notifications consuming S3 events delivered
+// to an SQS queue, see S3Client.ConfigureNotifications. Received messages
+// are deleted from the queue.
+func NewSQSNotifications(config SQSConfig) (Notifications, error) {
+	queue, err := url.Parse(config.QueueURL)
+	if err != nil || queue.Host == "" {
+		return nil, fmt.Errorf("invalid queue url: %q", config.QueueURL)
+	}
+
+	region := config.Region
+	if region == "" {
+		parts := strings.Split(queue.Host, ".")
+		if len(parts) < 3 || parts[0] != "sqs" {
+			return nil, errors.New("region is required for the queue url")
+		}
+		region = parts[1]
+	}
+
+	n := &sqsNotifications{
+		queue:  queue,
+		region: region,
+		keyID:  config.KeyID,
+		key:    config.Key,
+		client: &http.Client{Timeout: time.Minute},
+	}
+	return n, nil
+}
+
+func (n *sqsNotifications) Subscribe(ctx context.Context, prefix string, events ...EventType) (<-chan Event, error) {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			msgs, err := n.receive(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if !sendEvent(ctx, ch, Event{Err: err}) || !sleepContext(ctx, pollErrorDelay) {
+					return
+				}
+				continue
+			}
+
+			for _, msg := range msgs {
+				records, err := parseS3Records([]byte(msg.Body))
+				if err != nil {
+					err = fmt.Errorf("invalid message %v: %w", msg.MessageID, err)
+					if !sendEvent(ctx, ch, Event{Err: err}) {
+						return
+					}
+				}
+				for _, event := range records {
+					if !matchEvent(event, prefix, events) {
+						continue
+					}
+					if !sendEvent(ctx, ch, event) {
+						return
+					}
+				}
+
+				// Unparsable messages are deleted as well, or they
+				// will be received again and again.
+				if err := n.delete(ctx, msg.ReceiptHandle); err != nil {
+					if !sendEvent(ctx, ch, Event{Err: err}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+type sqsMessage struct {
+	MessageID     string `xml:"MessageId"`
+	ReceiptHandle string `xml:"ReceiptHandle"`
+	Body          string `xml:"Body"`
+}
+
+func (n *sqsNotifications) receive(ctx context.Context) ([]sqsMessage, error) {
+	form := url.Values{}
+	form.Set("Action", "ReceiveMessage")
+	form.Set("MaxNumberOfMessages", "10")
+	form.Set("WaitTimeSeconds", "20")
+
+	body, err := n.do(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Messages []sqsMessage `xml:"ReceiveMessageResult>Message"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid sqs response: %w", err)
+	}
+	return resp.Messages, nil
+}
+
+func (n *sqsNotifications) delete(ctx context.Context, handle string) error {
+	form := url.Values{}
+	form.Set("Action", "DeleteMessage")
+	form.Set("ReceiptHandle", handle)
+
+	_, err := n.do(ctx, form)
+	return err
+}
+
+func (n *sqsNotifications) do(ctx context.Context, form url.Values) ([]byte, error) {
+	form.Set("Version", "2012-11-05")
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.queue.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	signV4(req, body, n.keyID, n.key, n.region, "sqs", time.Now())
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(data, &e)
+		return nil, fmt.Errorf("sqs %v failed: %v %v: %v", form.Get("Action"), resp.StatusCode, e.Code, e.Message)
+	}
+
+	return data, nil
+}
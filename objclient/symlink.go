@@ -0,0 +1,117 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// symlinkMetaKey marks the emulated symlink objects of S3 clients.
+	symlinkMetaKey   = "objclient-symlink-target"
+	maxSymlinkFollow = 8
+)
+
+var ErrNotSymlink = errors.New("object is not a symlink")
+
+// Symlinker is implemented by clients supporting symlink objects. Reading a
+// symlink returns the content of its target.
+type Symlinker interface {
+	PutSymlink(ctx context.Context, key, target string) error
+	GetSymlink(ctx context.Context, key string) (string, error)
+}
+
+// symlinkTarget returns the target of an emulated symlink from the user
+// metadata of the object.
+func symlinkTarget(metadata map[string]string) (string, bool) {
+	for k, v := range metadata {
+		if strings.EqualFold(k, symlinkMetaKey) {
+			// Target is escaped since metadata values must be ascii.
+			target, err := url.PathUnescape(v)
+			if err != nil {
+				return v, true
+			}
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// PutSymlink emulates symlinks by empty objects with the target stored in
+// metadata.
+func (client *S3Client) PutSymlink(ctx context.Context, key, target string) error {
+	if err := checkKeys(client.strict, key, target); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	var opts minio.PutObjectOptions
+	if client.sseckey != nil {
+		opts.ServerSideEncryption = client.sseckey
+	}
+	opts.UserMetadata = map[string]string{symlinkMetaKey: url.PathEscape(target)}
+
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = backend.PutObject(ctx, client.bucket, key, strings.NewReader(""), 0, opts)
+	return translateError(err)
+}
+
+func (client *S3Client) GetSymlink(ctx context.Context, key string) (string, error) {
+	info, err := client.Info(ctx, key)
+	if err != nil {
+		return "", translateError(err)
+	}
+
+	target, ok := symlinkTarget(info.Metadata)
+	if !ok {
+		return "", ErrNotSymlink
+	}
+	return target, nil
+}
+
+func (client *OSSClient) PutSymlink(ctx context.Context, key, target string) error {
+	if err := checkKeys(client.strict, key, target); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.PutSymlink(key, target, oss.WithContext(ctx))
+}
+
+func (client *OSSClient) GetSymlink(ctx context.Context, key string) (string, error) {
+	if err := checkKeys(client.strict, key); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return "", err
+	}
+	header, err := bucket.GetSymlink(key, oss.WithContext(ctx))
+	if err != nil {
+		var serr oss.ServiceError
+		if errors.As(err, &serr) && serr.Code == "NotSymlink" {
+			return "", ErrNotSymlink
+		}
+		return "", translateError(err)
+	}
+	return header.Get(oss.HTTPHeaderOssSymlinkTarget), nil
+}
@@ -0,0 +1,93 @@
+package objclient
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// maxTenantBackends limits the backends of the credentials of
+// ContextWithCredentials cached by each client.
+const maxTenantBackends = 1024
+
+type credentialsKey struct{}
+
+// ContextWithCredentials returns a context whose requests of S3 and OSS
+// clients are signed by creds instead of the credentials of the clients, so
+// a client and its connections are shared by the tenants of a service. The
+// clients cache the backends of the recent credentials. The credentials
+// aren't refreshed, the Expiration of them is ignored.
+func ContextWithCredentials(ctx context.Context, creds Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+// credentialsOf returns the credentials of ContextWithCredentials of ctx.
+func credentialsOf(ctx context.Context) (Credentials, bool) {
+	creds, ok := ctx.Value(credentialsKey{}).(Credentials)
+	return creds, ok
+}
+
+// tenantCredentials returns the credentials of ContextWithCredentials of
+// ctx, or nil if there aren't. Wrappers making requests in background keep
+// them for credentialsContext.
+func tenantCredentials(ctx context.Context) *Credentials {
+	creds, ok := credentialsOf(ctx)
+	if !ok {
+		return nil
+	}
+	return &creds
+}
+
+// credentialsContext returns ctx with creds, or ctx itself if it's nil.
+func credentialsContext(ctx context.Context, creds *Credentials) context.Context {
+	if creds == nil {
+		return ctx
+	}
+	return ContextWithCredentials(ctx, *creds)
+}
+
+// tenantKey identifies the backends of credentials.
+type tenantKey struct {
+	keyID, key, token string
+}
+
+type tenantEntry[T any] struct {
+	key     tenantKey
+	backend T
+}
+
+// tenantCache caches the backends of credentials by LRU.
+type tenantCache[T any] struct {
+	create func(creds Credentials) (T, error)
+
+	mutex   sync.Mutex
+	entries map[tenantKey]*list.Element
+	lru     *list.List
+}
+
+func newTenantCache[T any](create func(creds Credentials) (T, error)) *tenantCache[T] {
+	return &tenantCache[T]{create: create, entries: make(map[tenantKey]*list.Element), lru: list.New()}
+}
+
+// get returns the backend of creds, which is created if it isn't cached.
+func (cache *tenantCache[T]) get(creds Credentials) (T, error) {
+	key := tenantKey{keyID: creds.KeyID, key: creds.Key, token: creds.Token}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if elem, ok := cache.entries[key]; ok {
+		cache.lru.MoveToFront(elem)
+		return elem.Value.(*tenantEntry[T]).backend, nil
+	}
+
+	backend, err := cache.create(creds)
+	if err != nil {
+		return backend, err
+	}
+	cache.entries[key] = cache.lru.PushFront(&tenantEntry[T]{key: key, backend: backend})
+	for cache.lru.Len() > maxTenantBackends {
+		elem := cache.lru.Back()
+		cache.lru.Remove(elem)
+		delete(cache.entries, elem.Value.(*tenantEntry[T]).key)
+	}
+	return backend, nil
+}
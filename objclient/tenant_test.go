@@ -0,0 +1,185 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient/s3test"
+)
+
+func TestContextWithCredentials(t *testing.T) {
+	ctx := context.Background()
+	backend := s3test.NewServer("bucket")
+	defer backend.Close()
+	target, _ := url.Parse("http://" + backend.Endpoint())
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	// The proxy records the key IDs signing the requests.
+	credential := regexp.MustCompile(`Credential=([^/]+)/`)
+	var (
+		mutex  sync.Mutex
+		keyIDs []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m := credential.FindStringSubmatch(r.Header.Get("Authorization")); m != nil {
+			mutex.Lock()
+			keyIDs = append(keyIDs, m[1])
+			mutex.Unlock()
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client, err := NewS3Client(S3Config{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"), Region: s3test.Region, Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	signed := func(f func() error) []string {
+		mutex.Lock()
+		keyIDs = nil
+		mutex.Unlock()
+		if err := f(); err != nil {
+			t.Fatalf("failed to request: %v", err)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		return keyIDs
+	}
+
+	tenant := ContextWithCredentials(ctx, Credentials{KeyID: "tenant", Key: "secret"})
+	got := signed(func() error { return client.Write(tenant, "a", strings.NewReader("data"), nil) })
+	if len(got) == 0 || got[len(got)-1] != "tenant" {
+		t.Fatalf("invalid key IDs of tenant %v", got)
+	}
+	got = signed(func() error {
+		_, err := client.Info(ctx, "a")
+		return err
+	})
+	if len(got) == 0 || got[len(got)-1] != "id" {
+		t.Fatalf("invalid key IDs of client %v", got)
+	}
+	got = signed(func() error {
+		_, err := client.List(tenant, "")
+		return err
+	})
+	if len(got) == 0 || got[len(got)-1] != "tenant" {
+		t.Fatalf("invalid key IDs of list %v", got)
+	}
+
+	// The backends of the tenant are reused.
+	s3 := client.(*S3Client)
+	first, _ := s3.backendOf(tenant)
+	second, _ := s3.backendOf(ContextWithCredentials(ctx, Credentials{KeyID: "tenant", Key: "secret"}))
+	if first != second {
+		t.Fatalf("backend of tenant isn't reused")
+	}
+}
+
+func TestTenantCache(t *testing.T) {
+	var created int
+	cache := newTenantCache(func(creds Credentials) (string, error) {
+		created++
+		return creds.KeyID, nil
+	})
+	for i := 0; i < maxTenantBackends+1; i++ {
+		if got, _ := cache.get(Credentials{KeyID: fmt.Sprint(i)}); got != fmt.Sprint(i) {
+			t.Fatalf("invalid backend %v", got)
+		}
+		// The first one is kept recent.
+		cache.get(Credentials{KeyID: "0"})
+	}
+	if created != maxTenantBackends+1 || cache.lru.Len() != maxTenantBackends {
+		t.Fatalf("invalid cache of %v created, %v cached", created, cache.lru.Len())
+	}
+	if _, ok := cache.entries[tenantKey{keyID: "1"}]; ok {
+		t.Fatalf("least recently used backend isn't evicted")
+	}
+	if _, ok := cache.entries[tenantKey{keyID: "0"}]; !ok {
+		t.Fatalf("recently used backend is evicted")
+	}
+}
+
+// tenantClient records the key IDs of ContextWithCredentials of the writes.
+type tenantClient struct {
+	*memClient
+	keyIDs chan string
+}
+
+func (client *tenantClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	creds, _ := credentialsOf(ctx)
+	select {
+	case client.keyIDs <- creds.KeyID:
+	default:
+	}
+	return client.memClient.Write(ctx, key, r, o)
+}
+
+func TestBackgroundCredentials(t *testing.T) {
+	tenant := ContextWithCredentials(context.Background(), Credentials{KeyID: "tenant", Key: "secret"})
+	expect := func(client *tenantClient) {
+		t.Helper()
+		select {
+		case keyID := <-client.keyIDs:
+			if keyID != "tenant" {
+				t.Fatalf("invalid key ID of background write %q", keyID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("background write isn't made")
+		}
+	}
+
+	secondary := &tenantClient{memClient: newMemClient(), keyIDs: make(chan string, 1)}
+	mirror := NewMirrorClient(newMemClient(), secondary, MirrorAsync, nil)
+	if err := mirror.Write(tenant, "a", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	expect(secondary)
+
+	inner := &tenantClient{memClient: newMemClient(), keyIDs: make(chan string, 1)}
+	w, err := NewAsyncWriter(inner, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	defer w.Close()
+	if err := w.Write(tenant, "a", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	expect(inner)
+
+	// The replays of failover are made by the credentials of the writes.
+	primary := &tenantClient{memClient: newMemClient(), keyIDs: make(chan string, 100)}
+	var down sync.Mutex
+	failed := true
+	primary.fail = func(op, key string) error {
+		down.Lock()
+		defer down.Unlock()
+		if failed {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	failover := NewFailoverClient(primary, newMemClient(), &FailoverPolicy{ReplayWrites: true, ProbeInterval: 10 * time.Millisecond})
+	defer failover.Close()
+	if err := failover.Write(tenant, "a", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	expect(primary)
+	down.Lock()
+	failed = false
+	down.Unlock()
+	expect(primary)
+}
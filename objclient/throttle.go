@@ -0,0 +1,132 @@
+package objclient
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	maxThrottleBurst = 256 * 1024
+)
+
+type BandwidthLimits struct {
+	// Zero means unlimited.
+	MaxUploadBytesPerSec   int64
+	MaxDownloadBytesPerSec int64
+}
+
+type throttledClient struct {
+	inner    Client
+	upload   *rate.Limiter
+	download *rate.Limiter
+}
+
+// NewThrottledClient limits the bandwidth of the data read and written
+// through inner. The limits are shared by all the concurrent operations.
+func NewThrottledClient(inner Client, limits BandwidthLimits) Client {
+	client := &throttledClient{
+		inner:    inner,
+		upload:   newBytesLimiter(limits.MaxUploadBytesPerSec),
+		download: newBytesLimiter(limits.MaxDownloadBytesPerSec),
+	}
+	if _, ok := AsPageLister(inner); ok {
+		return &throttledPageClient{client}
+	}
+	return client
+}
+
+// throttledPageClient is the throttledClient of a PageLister.
+type throttledPageClient struct {
+	*throttledClient
+}
+
+func (client *throttledPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func newBytesLimiter(bytesPerSec int64) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	burst := min(bytesPerSec, maxThrottleBurst)
+	return rate.NewLimiter(rate.Limit(bytesPerSec), int(burst))
+}
+
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (reader *throttledReader) Read(data []byte) (int, error) {
+	if burst := reader.limiter.Burst(); len(data) > burst {
+		data = data[:burst]
+	}
+	n, err := reader.r.Read(data)
+	if n > 0 {
+		if werr := reader.limiter.WaitN(reader.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type throttledReadCloser struct {
+	throttledReader
+	c io.Closer
+}
+
+func (reader *throttledReadCloser) Close() error {
+	return reader.c.Close()
+}
+
+func (client *throttledClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *throttledClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	if err != nil || client.download == nil {
+		return r, err
+	}
+	return &throttledReadCloser{throttledReader{ctx, r, client.download}, r}, nil
+}
+
+func (client *throttledClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *throttledClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	if client.upload != nil {
+		o = SizedWriteOptions(r, o)
+		r = &throttledReader{ctx, r, client.upload}
+	}
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *throttledClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *throttledClient) Remove(ctx context.Context, keys ...string) error {
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *throttledClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *throttledClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *throttledClient) Copy(ctx context.Context, src, dst string) error {
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *throttledClient) Close() error {
+	return client.inner.Close()
+}
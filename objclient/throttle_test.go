@@ -0,0 +1,66 @@
+package objclient
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottledClient(t *testing.T) {
+	mem := newMemClient()
+	throttled := NewThrottledClient(mem, BandwidthLimits{MaxDownloadBytesPerSec: 4096})
+
+	data := strings.Repeat("a", 8192)
+	err := throttled.Write(ctx, "objclient/test", strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	r, err := throttled.Read(ctx, "objclient/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	read, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(read) != data {
+		t.Fatalf("invalid data from Read(): %d bytes", len(read))
+	}
+	// The first 4096 bytes are the burst.
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("invalid elapsed time: %v", elapsed)
+	}
+}
+
+func TestThrottledClientUpload(t *testing.T) {
+	mem := newMemClient()
+	throttled := NewThrottledClient(mem, BandwidthLimits{MaxUploadBytesPerSec: 4096})
+
+	data := strings.Repeat("a", 8192)
+	start := time.Now()
+	err := throttled.Write(ctx, "objclient/test", strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first 4096 bytes are the burst.
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("invalid elapsed time: %v", elapsed)
+	}
+	if read := readString(t, mem, "objclient/test"); read != data {
+		t.Fatalf("invalid data of Write(): %d bytes", len(read))
+	}
+
+	// Downloads aren't limited by the upload limit.
+	start = time.Now()
+	if read := readString(t, throttled, "objclient/test"); read != data {
+		t.Fatalf("invalid data from Read(): %d bytes", len(read))
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("invalid elapsed time of Read(): %v", elapsed)
+	}
+}
@@ -0,0 +1,419 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TierStubMetadata is the metadata key of the stubs left in the hot client
+// by MoveCold, whose objects are in the cold client.
+const TierStubMetadata = "tier-stub"
+
+// AccessLog records the last access times of keys in memory, for the
+// LastAccess of MoveOptions.
+type AccessLog struct {
+	mutex sync.Mutex
+	times map[string]time.Time
+}
+
+func NewAccessLog() *AccessLog {
+	return &AccessLog{times: make(map[string]time.Time)}
+}
+
+// Touch records the access of key now.
+func (log *AccessLog) Touch(key string) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.times[key] = time.Now()
+}
+
+// LastAccess returns the last access time of key, or false if it isn't
+// accessed.
+func (log *AccessLog) LastAccess(key string) (time.Time, bool) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	t, ok := log.times[key]
+	return t, ok
+}
+
+// Hooks returns the hooks of WithHooks touching the keys read.
+func (log *AccessLog) Hooks() Hooks {
+	return Hooks{
+		After: func(ctx context.Context, op *Operation) {
+			if op.Err == nil && op.Name == "Read" {
+				log.Touch(op.Key)
+			}
+		},
+	}
+}
+
+// TieredClient moves the cold objects of the hot client to the cold client
+// by MoveCold, and follows the stubs left in the hot client transparently.
+// The cold client may be of a cheaper bucket or storage class. The hot
+// client must keep metadata, which marks the stubs.
+type TieredClient struct {
+	hot, cold  Client
+	coldPrefix string
+}
+
+// NewTieredClient returns a client of the objects of hot, the objects moved
+// are stored in cold at the keys prefixed by coldPrefix, which can be empty
+// unless both are of the same bucket.
+func NewTieredClient(hot, cold Client, coldPrefix string) *TieredClient {
+	return &TieredClient{hot: hot, cold: cold, coldPrefix: coldPrefix}
+}
+
+// stub returns whether key of the hot client is a stub.
+func (client *TieredClient) stub(ctx context.Context, key string) (bool, error) {
+	info, err := client.hot.Info(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return info.Metadata[TierStubMetadata] != "", nil
+}
+
+func (client *TieredClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+// peekedReader returns the bytes peeked before the rest of the reader.
+type peekedReader struct {
+	peeked []byte
+	io.ReadCloser
+}
+
+func (reader *peekedReader) Read(data []byte) (int, error) {
+	if len(reader.peeked) > 0 {
+		n := copy(data, reader.peeked)
+		reader.peeked = reader.peeked[n:]
+		return n, nil
+	}
+	return reader.ReadCloser.Read(data)
+}
+
+// ReadWithOptions reads the cold object of stubs. The stubs are empty, so
+// only the empty reads and the failed ones are checked by Info, e.g. of
+// ranges or ETags of stubs.
+func (client *TieredClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	r, err := client.hot.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, err
+		}
+		if stub, serr := client.stub(ctx, key); serr != nil || !stub {
+			return nil, err
+		}
+		return client.cold.ReadWithOptions(ctx, client.coldPrefix+key, o)
+	}
+
+	var peeked [1]byte
+	n, err := io.ReadFull(r, peeked[:])
+	if n > 0 || err != io.EOF {
+		return &peekedReader{peeked: peeked[:n], ReadCloser: r}, nil
+	}
+	r.Close()
+	stub, err := client.stub(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !stub {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return client.cold.ReadWithOptions(ctx, client.coldPrefix+key, o)
+}
+
+// Write replaces the stub of key, the cold object of the stub is removed
+// after the write.
+func (client *TieredClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *TieredClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	result, err := client.hot.WriteWithResult(ctx, key, r, o)
+	if err != nil {
+		return nil, err
+	}
+	// The write succeeded, a cold object left by failed removal is only
+	// stale, which is overwritten when it's moved again.
+	client.cold.Remove(ctx, client.coldPrefix+key)
+	return result, nil
+}
+
+func (client *TieredClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.hot.Exist(ctx, key)
+}
+
+// Remove removes the keys of both clients. The cold objects of the keys
+// failed to be removed from the hot client are kept.
+func (client *TieredClient) Remove(ctx context.Context, keys ...string) error {
+	err := client.hot.Remove(ctx, keys...)
+	failed := make(map[string]bool)
+	var rerr *RemoveError
+	switch {
+	case errors.As(err, &rerr):
+		for _, result := range rerr.Results {
+			failed[result.Key] = true
+		}
+	case err != nil:
+		return err
+	}
+
+	var coldKeys []string
+	for _, key := range keys {
+		if !failed[key] {
+			coldKeys = append(coldKeys, client.coldPrefix+key)
+		}
+	}
+	if len(coldKeys) > 0 {
+		if cerr := client.cold.Remove(ctx, coldKeys...); cerr != nil {
+			return errors.Join(err, fmt.Errorf("failed to remove cold objects: %w", cerr))
+		}
+	}
+	return err
+}
+
+// List returns the infos of cold objects for stubs, the empty objects are
+// checked by Info.
+func (client *TieredClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	items, err := client.hot.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var empty []string
+	for _, item := range items {
+		if item.Size == 0 {
+			empty = append(empty, item.Key)
+		}
+	}
+	if len(empty) == 0 {
+		return items, nil
+	}
+
+	infos, err := InfoMulti(ctx, client, empty, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if info, ok := infos[item.Key]; ok && item.Size == 0 {
+			items[i].Size = info.Size
+			items[i].ETag = info.ETag
+		}
+	}
+	return items, nil
+}
+
+// ListPages implements PageLister like List, the empty objects of each
+// page are checked by Info.
+func (client *TieredClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	var ierr error
+	err := listPages(ctx, client.hot, prefix, startAfter, func(items []ObjectItem) bool {
+		var empty []string
+		for _, item := range items {
+			if item.Size == 0 {
+				empty = append(empty, item.Key)
+			}
+		}
+		if len(empty) > 0 {
+			infos, err := InfoMulti(ctx, client, empty, 0)
+			if err != nil {
+				ierr = err
+				return false
+			}
+			for i, item := range items {
+				if info, ok := infos[item.Key]; ok && item.Size == 0 {
+					items[i].Size = info.Size
+					items[i].ETag = info.ETag
+				}
+			}
+		}
+		return fn(items)
+	})
+	if err != nil {
+		return err
+	}
+	return ierr
+}
+
+// listsPages reports whether the hot client is a PageLister, for
+// AsPageLister.
+func (client *TieredClient) listsPages() bool {
+	_, ok := AsPageLister(client.hot)
+	return ok
+}
+
+// Info returns the info of the cold object for stubs.
+func (client *TieredClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := client.hot.Info(ctx, key)
+	if err != nil || info.Metadata[TierStubMetadata] == "" {
+		return info, err
+	}
+	return client.cold.Info(ctx, client.coldPrefix+key)
+}
+
+// Copy copies the cold object of stubs too.
+func (client *TieredClient) Copy(ctx context.Context, src, dst string) error {
+	stub, err := client.stub(ctx, src)
+	if err != nil {
+		return err
+	}
+	if stub {
+		if err := client.cold.Copy(ctx, client.coldPrefix+src, client.coldPrefix+dst); err != nil {
+			return fmt.Errorf("failed to copy cold object: %w", err)
+		}
+	}
+	if err := client.hot.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	if !stub {
+		client.cold.Remove(ctx, client.coldPrefix+dst)
+	}
+	return nil
+}
+
+func (client *TieredClient) Close() error {
+	return errors.Join(client.hot.Close(), client.cold.Close())
+}
+
+// MoveOptions are the options of MoveCold.
+type MoveOptions struct {
+	// MinAge moves the objects which aren't accessed or modified in it,
+	// defaults to 30 days.
+	MinAge time.Duration
+	// LastAccess returns the last access time of key, e.g. of AccessLog, or
+	// false if it's unknown. Objects are aged by their modification times
+	// if it's nil.
+	LastAccess func(key string) (time.Time, bool)
+	// DryRun only reports the objects which would be moved.
+	DryRun bool
+	// Logger records each cold object moved to the cold client, or found
+	// by dry runs, and the errors of the moves failed. It's nil for no logs.
+	Logger *slog.Logger
+}
+
+// MoveResult is the result of MoveCold.
+type MoveResult struct {
+	// Scanned is the number of objects of the prefix.
+	Scanned int
+	// Moved and MovedBytes are the objects which are moved, or would be
+	// moved by dry runs.
+	Moved      int
+	MovedBytes int64
+}
+
+// MoveCold copies the cold objects of prefix of the hot client to the cold
+// client, and replaces them by stubs. Empty objects aren't moved. The
+// objects modified while they are copied are kept. The options can be nil.
+// The objects failed to be moved are reported by a *MultiError after the
+// others are moved.
+func (client *TieredClient) MoveCold(ctx context.Context, prefix string, opts *MoveOptions) (*MoveResult, error) {
+	o := MoveOptions{MinAge: 30 * 24 * time.Hour}
+	if opts != nil {
+		if opts.MinAge > 0 {
+			o.MinAge = opts.MinAge
+		}
+		o.LastAccess = opts.LastAccess
+		o.DryRun = opts.DryRun
+		o.Logger = opts.Logger
+	}
+
+	items, err := client.hot.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %v: %w", prefix, err)
+	}
+	deadline := time.Now().Add(-o.MinAge)
+	result := &MoveResult{Scanned: len(items)}
+	errs := make(map[string]error)
+	for _, item := range items {
+		last := item.LastModified
+		if o.LastAccess != nil {
+			if t, ok := o.LastAccess(item.Key); ok && t.After(last) {
+				last = t
+			}
+		}
+		if item.Size == 0 || !last.Before(deadline) {
+			continue
+		}
+
+		moved, err := true, error(nil)
+		if !o.DryRun {
+			moved, err = client.move(ctx, item.Key)
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if !moved && err == nil {
+			continue
+		}
+		client.moveAudit(ctx, o, item, err)
+		if err != nil {
+			errs[item.Key] = err
+			continue
+		}
+		result.Moved++
+		result.MovedBytes += item.Size
+	}
+	if len(errs) > 0 {
+		return result, &MultiError{Errors: errs}
+	}
+	return result, nil
+}
+
+// move returns false if key is a stub, or modified while it's copied.
+func (client *TieredClient) move(ctx context.Context, key string) (bool, error) {
+	info, err := client.hot.Info(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if info.Metadata[TierStubMetadata] != "" {
+		return false, nil
+	}
+
+	r, err := client.hot.ReadWithOptions(ctx, key, &ReadOptions{IfMatch: info.ETag})
+	if err != nil {
+		return false, err
+	}
+	coldKey := client.coldPrefix + key
+	err = client.cold.Write(ctx, coldKey, r, &WriteOptions{Size: info.Size, Metadata: info.Metadata})
+	r.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to write cold object: %w", err)
+	}
+
+	current, err := client.hot.Info(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if current.ETag != info.ETag || !current.LastModified.Equal(info.LastModified) {
+		return false, client.cold.Remove(ctx, coldKey)
+	}
+	stub := &WriteOptions{Metadata: map[string]string{TierStubMetadata: coldKey}}
+	if _, err := client.hot.WriteWithResult(ctx, key, strings.NewReader(""), stub); err != nil {
+		return false, fmt.Errorf("failed to write stub: %w", err)
+	}
+	return true, nil
+}
+
+func (client *TieredClient) moveAudit(ctx context.Context, o MoveOptions, item ObjectItem, err error) {
+	if o.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("key", item.Key),
+		slog.Int64("size", item.Size),
+		slog.Time("modified", item.LastModified),
+		slog.Bool("dry_run", o.DryRun),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+		o.Logger.LogAttrs(ctx, slog.LevelError, "objclient tier move", attrs...)
+		return
+	}
+	o.Logger.LogAttrs(ctx, slog.LevelInfo, "objclient tier move", attrs...)
+}
@@ -0,0 +1,77 @@
+package objclient
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTieredClient(t *testing.T) {
+	ctx := context.Background()
+	hot, cold := newMemClient(), newMemClient()
+	old := time.Now().Add(-48 * time.Hour)
+	hot.objects["a"] = memObject{data: []byte("cold data"), metadata: map[string]string{"k": "v"}, modified: old}
+	hot.objects["b"] = memObject{data: []byte("accessed"), metadata: map[string]string{}, modified: old}
+	hot.put("c", "hot data")
+	hot.objects["empty"] = memObject{data: nil, metadata: map[string]string{}, modified: old}
+
+	client := NewTieredClient(hot, cold, "cold/")
+	access := NewAccessLog()
+	reader := Chain(client, WithHooks(access.Hooks()))
+	if r, err := reader.Read(ctx, "b"); err == nil {
+		r.Close()
+	}
+
+	opts := &MoveOptions{MinAge: 24 * time.Hour, LastAccess: access.LastAccess}
+	result, err := client.MoveCold(ctx, "", opts)
+	if err != nil || *result != (MoveResult{Scanned: 4, Moved: 1, MovedBytes: 9}) {
+		t.Fatalf("failed to move: %+v, %v", result, err)
+	}
+	if len(hot.objects["a"].data) != 0 || string(cold.objects["cold/a"].data) != "cold data" || cold.objects["cold/a"].metadata["k"] != "v" {
+		t.Fatalf("invalid moved object: %v, %v", hot.objects["a"], cold.objects)
+	}
+	// It's already moved.
+	if result, err := client.MoveCold(ctx, "", opts); err != nil || result.Moved != 0 {
+		t.Fatalf("stub is moved: %+v, %v", result, err)
+	}
+
+	r, err := client.ReadWithOptions(ctx, "a", &ReadOptions{Offset: 5})
+	if err != nil {
+		t.Fatalf("failed to read stub: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "data" {
+		t.Fatalf("invalid data of stub %q", data)
+	}
+	if r, err := client.Read(ctx, "empty"); err != nil {
+		t.Fatalf("failed to read empty object: %v", err)
+	} else if data, _ := io.ReadAll(r); len(data) != 0 {
+		t.Fatalf("invalid data of empty object %q", data)
+	}
+	if info, err := client.Info(ctx, "a"); err != nil || info.Size != 9 {
+		t.Fatalf("invalid info of stub: %+v, %v", info, err)
+	}
+	items, err := client.List(ctx, "")
+	if err != nil || len(items) != 4 || items[0].Key != "a" || items[0].Size != 9 {
+		t.Fatalf("invalid listing: %+v, %v", items, err)
+	}
+
+	if err := client.Copy(ctx, "a", "d"); err != nil {
+		t.Fatalf("failed to copy stub: %v", err)
+	}
+	if _, ok := cold.objects["cold/d"]; !ok {
+		t.Fatalf("cold object of stub isn't copied")
+	}
+	if err := client.Write(ctx, "a", strings.NewReader("new"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := client.Remove(ctx, "d"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if len(cold.objects) != 0 {
+		t.Fatalf("cold objects aren't removed: %v", cold.objects)
+	}
+}
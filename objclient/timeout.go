@@ -0,0 +1,153 @@
+package objclient
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type TimeoutPolicy struct {
+	// Timeouts are the deadlines of each class of operations, there is no
+	// deadline for a class without timeout. For Read, it's the deadline
+	// until the reader is returned.
+	Timeouts map[OpClass]time.Duration
+	// ReadStall cancels reading the object if no data is read for about
+	// the duration. Reading has no deadline otherwise. Zero disables it.
+	ReadStall time.Duration
+}
+
+// DefaultTimeoutPolicy is used by NewTimeoutClient for nil policy.
+var DefaultTimeoutPolicy = TimeoutPolicy{
+	Timeouts: map[OpClass]time.Duration{
+		OpRead:   10 * time.Second,
+		OpWrite:  5 * time.Minute,
+		OpList:   time.Minute,
+		OpDelete: 30 * time.Second,
+	},
+	ReadStall: 30 * time.Second,
+}
+
+type timeoutClient struct {
+	inner  Client
+	policy TimeoutPolicy
+}
+
+// NewTimeoutClient applies the deadlines of policy to the operations of
+// inner. Backend clients have timeouts of their own, deadlines longer than
+// them have no effect.
+func NewTimeoutClient(inner Client, policy *TimeoutPolicy) Client {
+	p := DefaultTimeoutPolicy
+	if policy != nil {
+		p = *policy
+	}
+	client := &timeoutClient{inner: inner, policy: p}
+	if _, ok := AsPageLister(inner); ok {
+		return &timeoutPageClient{client}
+	}
+	return client
+}
+
+// timeoutPageClient is the timeoutClient of a PageLister. The deadline of
+// OpList applies to the whole listing, like List.
+type timeoutPageClient struct {
+	*timeoutClient
+}
+
+func (client *timeoutPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	ctx, cancel := client.withTimeout(ctx, OpList)
+	defer cancel()
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+func (client *timeoutClient) withTimeout(ctx context.Context, class OpClass) (context.Context, context.CancelFunc) {
+	if timeout := client.policy.Timeouts[class]; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (client *timeoutClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.ReadWithOptions(ctx, key, nil)
+}
+
+func (client *timeoutClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	if timeout := client.policy.Timeouts[OpRead]; timeout > 0 {
+		timer := time.AfterFunc(timeout, cancel)
+		defer timer.Stop()
+	}
+
+	r, err := client.inner.ReadWithOptions(ctx, key, o)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		// The deadline passed just after the reader is returned.
+		r.Close()
+		cancel()
+		return nil, context.DeadlineExceeded
+	}
+
+	if client.policy.ReadStall > 0 {
+		return newStallReader(r, r, cancel, client.policy.ReadStall, "Read", key), nil
+	}
+	return &cancelReader{ReadCloser: r, cancel: cancel}, nil
+}
+
+// cancelReader calls cancel when it's closed.
+type cancelReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (reader *cancelReader) Close() error {
+	err := reader.ReadCloser.Close()
+	reader.cancel()
+	return err
+}
+
+func (client *timeoutClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *timeoutClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	ctx, cancel := client.withTimeout(ctx, OpWrite)
+	defer cancel()
+	return client.inner.WriteWithResult(ctx, key, r, o)
+}
+
+func (client *timeoutClient) Exist(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := client.withTimeout(ctx, OpRead)
+	defer cancel()
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *timeoutClient) Remove(ctx context.Context, keys ...string) error {
+	ctx, cancel := client.withTimeout(ctx, OpDelete)
+	defer cancel()
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *timeoutClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	ctx, cancel := client.withTimeout(ctx, OpList)
+	defer cancel()
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *timeoutClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	ctx, cancel := client.withTimeout(ctx, OpRead)
+	defer cancel()
+	return client.inner.Info(ctx, key)
+}
+
+func (client *timeoutClient) Copy(ctx context.Context, src, dst string) error {
+	ctx, cancel := client.withTimeout(ctx, OpWrite)
+	defer cancel()
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *timeoutClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,119 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimeoutClient(t *testing.T) {
+	mem := newMemClient()
+	mem.put("objclient/a", "1234567890")
+	slow := NewChaosClient(mem, ChaosOptions{Faults: map[OpClass]Fault{
+		OpList: {Latency: time.Second},
+		OpRead: {StallRate: 1, StallAfter: 2},
+	}})
+
+	client := NewTimeoutClient(slow, &TimeoutPolicy{
+		Timeouts:  map[OpClass]time.Duration{OpList: 20 * time.Millisecond},
+		ReadStall: 20 * time.Millisecond,
+	})
+	if _, err := client.List(ctx, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded: %v", err)
+	}
+
+	r, err := client.Read(ctx, "objclient/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if !errors.Is(err, context.Canceled) || string(data) != "12" {
+		t.Fatalf("invalid stalled read: %q, %v", data, err)
+	}
+}
+
+func TestBackendStall(t *testing.T) {
+	// The server sends a part of the objects, and doesn't read uploads.
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead, http.MethodGet:
+			w.Header().Set("Content-Length", "10")
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Header().Set("ETag", `"object"`)
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.Write([]byte("12"))
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	var mutex sync.Mutex
+	var events []StallEvent
+	SetStallHook(func(event StallEvent) {
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	})
+	defer SetStallHook(nil)
+
+	for _, backend := range []string{"s3", "oss"} {
+		var (
+			client Client
+			err    error
+		)
+		opts := []Option{WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key"),
+			WithTimeouts(0, 0, 200*time.Millisecond)}
+		if backend == "s3" {
+			client, err = NewS3("bucket", append(opts, WithPathStyle(true))...)
+		} else {
+			client, err = NewOSS("bucket", opts...)
+		}
+		if err != nil {
+			t.Fatalf("failed to create %v client: %v", backend, err)
+		}
+
+		r, err := client.Read(context.Background(), "key")
+		if err != nil {
+			t.Fatalf("failed to read by %v: %v", backend, err)
+		}
+		if _, err := io.ReadAll(r); !errors.Is(err, ErrStalled) {
+			t.Fatalf("stalled read of %v isn't reported: %v", backend, err)
+		}
+		r.Close()
+
+		// The upload is blocked after the buffers of the connection are full.
+		data := make([]byte, 8<<20)
+		err = client.Write(context.Background(), "key", bytes.NewReader(data), nil)
+		if !errors.Is(err, ErrStalled) {
+			t.Fatalf("stalled write of %v isn't reported: %v", backend, err)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != 4 {
+		t.Fatalf("invalid stall events %v", events)
+	}
+	for i, event := range events {
+		if event.Op != []string{"Read", "Write"}[i%2] || event.Key != "key" || event.Window != 200*time.Millisecond {
+			t.Fatalf("invalid stall event %+v", event)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package objclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	defaultConnectTimeout = 30 * time.Second
+
+	ossReadWriteTimeout = 60 * time.Second
+	ossHeaderTimeout    = 60 * time.Second
+	ossIdleConnTimeout  = 50 * time.Second
+	ossMaxIdleConns     = 100
+)
+
+// proxyFunc returns the proxy of proxyURL, or the one of HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY if it's empty.
+func proxyFunc(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %v", proxyURL)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// clientTimeouts are the parsed timeouts of configs.
+type clientTimeouts struct {
+	connect time.Duration
+	request time.Duration
+	stall   time.Duration
+}
+
+func parseTimeouts(connect, request, stall string, defaultStall time.Duration) (clientTimeouts, error) {
+	timeouts := clientTimeouts{
+		connect: defaultConnectTimeout,
+		request: defaultTimeout,
+		stall:   defaultStall,
+	}
+	for _, timeout := range []struct {
+		field string
+		value string
+		d     *time.Duration
+	}{
+		{"ConnectTimeout", connect, &timeouts.connect},
+		{"RequestTimeout", request, &timeouts.request},
+		{"ReadStallTimeout", stall, &timeouts.stall},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil || d <= 0 {
+			return timeouts, fmt.Errorf("invalid %v %q", timeout.field, timeout.value)
+		}
+		*timeout.d = d
+	}
+	return timeouts, nil
+}
+
+// transportConfig is the transport fields of S3Config and OSSConfig.
+type transportConfig struct {
+	ProxyURL           string
+	CAFile             string
+	RootCAs            *x509.CertPool
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify string
+	UserAgent          string
+	Headers            string
+
+	MaxIdleConnsPerHost   string
+	IdleConnTimeout       string
+	ExpectContinueTimeout string
+	HTTP2                 string
+}
+
+// tune sets the connection fields of config to transport.
+func (config transportConfig) tune(transport *http.Transport) error {
+	if config.MaxIdleConnsPerHost != "" {
+		n, err := strconv.Atoi(config.MaxIdleConnsPerHost)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid MaxIdleConnsPerHost %q", config.MaxIdleConnsPerHost)
+		}
+		transport.MaxIdleConnsPerHost = n
+		transport.MaxIdleConns = max(transport.MaxIdleConns, n)
+	}
+	for _, timeout := range []struct {
+		field string
+		value string
+		d     *time.Duration
+	}{
+		{"IdleConnTimeout", config.IdleConnTimeout, &transport.IdleConnTimeout},
+		{"ExpectContinueTimeout", config.ExpectContinueTimeout, &transport.ExpectContinueTimeout},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid %v %q", timeout.field, timeout.value)
+		}
+		*timeout.d = d
+	}
+	switch config.HTTP2 {
+	case "":
+	case "true":
+		transport.ForceAttemptHTTP2 = true
+	case "false":
+		// HTTP/2 isn't negotiated with the empty map.
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	default:
+		return fmt.Errorf("invalid HTTP2 %q", config.HTTP2)
+	}
+	return nil
+}
+
+// tlsConfig returns nil if none of the TLS fields is set.
+func (config transportConfig) tlsConfig() (*tls.Config, error) {
+	insecure := stringToBool(config.InsecureSkipVerify, false)
+	if config.CAFile == "" && config.RootCAs == nil && config.ClientCertFile == "" && config.ClientKeyFile == "" && !insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		RootCAs:            config.RootCAs,
+		InsecureSkipVerify: insecure,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		if tlsConfig.RootCAs == nil {
+			// The CAs are added to the system ones.
+			tlsConfig.RootCAs, err = x509.SystemCertPool()
+			if err != nil {
+				tlsConfig.RootCAs = x509.NewCertPool()
+			}
+		} else {
+			tlsConfig.RootCAs = tlsConfig.RootCAs.Clone()
+		}
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %v", config.CAFile)
+		}
+	}
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func newS3Transport(https bool, config transportConfig, timeouts clientTimeouts) (http.RoundTripper, error) {
+	proxy, err := proxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport, err := minio.DefaultTransport(https)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+	transport.DialContext = (&net.Dialer{Timeout: timeouts.connect, KeepAlive: 30 * time.Second}).DialContext
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	if err := config.tune(transport); err != nil {
+		return nil, err
+	}
+	return config.withHeaders(transport)
+}
+
+// newOSSHTTPClient returns the client like the default one of OSS SDK, which
+// doesn't use the proxy of environment variables.
+func newOSSHTTPClient(config transportConfig, timeouts clientTimeouts) (*http.Client, error) {
+	proxy, err := proxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeouts.connect, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &deadlineConn{Conn: conn, timeout: timeouts.stall}, nil
+		},
+		MaxIdleConns:          ossMaxIdleConns,
+		MaxIdleConnsPerHost:   ossMaxIdleConns,
+		IdleConnTimeout:       ossIdleConnTimeout,
+		ResponseHeaderTimeout: ossHeaderTimeout,
+		TLSClientConfig:       tlsConfig,
+	}
+	if err := config.tune(transport); err != nil {
+		return nil, err
+	}
+	withHeaders, err := config.withHeaders(transport)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: withHeaders}, nil
+}
+
+// headerTransport appends the product to User-Agent, and sets the default
+// headers which are not set by requests. Uploads wait for 100 Continue if
+// expectContinue is set.
+type headerTransport struct {
+	base           http.RoundTripper
+	product        string
+	headers        map[string]string
+	expectContinue bool
+}
+
+func (config transportConfig) withHeaders(base http.RoundTripper) (http.RoundTripper, error) {
+	headers, err := parseHeaders(config.Headers, "")
+	if err != nil {
+		return nil, err
+	}
+	expectContinue := config.ExpectContinueTimeout != ""
+	if config.UserAgent == "" && len(headers) == 0 && !expectContinue {
+		return base, nil
+	}
+	return &headerTransport{base: base, product: config.UserAgent, headers: headers, expectContinue: expectContinue}, nil
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.product != "" {
+		req.Header.Set("User-Agent", strings.TrimSpace(req.Header.Get("User-Agent")+" "+t.product))
+	}
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	if t.expectContinue && req.Method == http.MethodPut && req.Body != nil && req.ContentLength != 0 {
+		// Rejected uploads fail before the body is sent.
+		req.Header.Set("Expect", "100-continue")
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (t *headerTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// parseHeaders parses the lines of "Name: value". The headers which should
+// be signed are rejected, since they are set after requests are signed.
+func parseHeaders(s, signedPrefix string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+
+		lower := strings.ToLower(name)
+		if signedPrefix != "" && strings.HasPrefix(lower, signedPrefix) || lower == "authorization" || lower == "host" || lower == "date" {
+			return nil, fmt.Errorf("invalid header %v, it should be signed", name)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// deadlineConn fails reads and writes which don't finish in timeout with
+// ErrStalled.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// stallError is a timeout of deadlineConn, it's still a net.Error.
+type stallError struct {
+	err net.Error
+}
+
+func (e stallError) Error() string   { return e.err.Error() }
+func (e stallError) Timeout() bool   { return true }
+func (e stallError) Temporary() bool { return true }
+func (e stallError) Unwrap() error   { return e.err }
+
+func (e stallError) Is(target error) bool {
+	return target == ErrStalled
+}
+
+func stalled(err error) error {
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return stallError{nerr}
+	}
+	return err
+}
+
+func (conn *deadlineConn) Read(data []byte) (int, error) {
+	conn.Conn.SetReadDeadline(time.Now().Add(conn.timeout))
+	n, err := conn.Conn.Read(data)
+	// Idle connections in the pool are waited by reads too.
+	conn.Conn.SetReadDeadline(time.Time{})
+	return n, stalled(err)
+}
+
+func (conn *deadlineConn) Write(data []byte) (int, error) {
+	conn.Conn.SetWriteDeadline(time.Now().Add(conn.timeout))
+	n, err := conn.Conn.Write(data)
+	// The response is read while the request body is still written, so the
+	// read isn't stalled as long as writes go on.
+	conn.Conn.SetReadDeadline(time.Now().Add(conn.timeout))
+	return n, stalled(err)
+}
@@ -0,0 +1,447 @@
+package objclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyURL(t *testing.T) {
+	var hosts atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts.Store(r.Host)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	s3, err := NewS3Client(S3Config{
+		Endpoint:    "s3.example.com",
+		Bucket:      "bucket",
+		KeyID:       "id",
+		Key:         "key",
+		V4Signature: "true",
+		ProxyURL:    proxy.URL,
+	})
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	s3.Exist(context.Background(), "key")
+	if host, _ := hosts.Load().(string); host != "bucket.s3.example.com" {
+		t.Fatalf("invalid host of s3 request to proxy: %v", host)
+	}
+
+	oss, err := NewOSSClient(OSSConfig{
+		Endpoint: "oss.example.com",
+		HTTPS:    "false",
+		Bucket:   "bucket",
+		KeyID:    "id",
+		Key:      "key",
+		ProxyURL: proxy.URL,
+	})
+	if err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+	oss.Exist(context.Background(), "key")
+	if host, _ := hosts.Load().(string); host != "bucket.oss.example.com" {
+		t.Fatalf("invalid host of oss request to proxy: %v", host)
+	}
+
+	if _, err := proxyFunc("proxy:3128"); err == nil {
+		t.Fatalf("invalid proxy url is accepted")
+	}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %v: %v", path, err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", cert)
+	writePEM(t, keyFile, "EC PRIVATE KEY", der)
+
+	endpoint := strings.TrimPrefix(server.URL, "https://")
+	config := S3Config{
+		Endpoint:         endpoint,
+		Region:           "us-east-1",
+		HTTPS:            "true",
+		Bucket:           "bucket",
+		PathStyleRequest: "true",
+		KeyID:            "id",
+		Key:              "key",
+		V4Signature:      "true",
+	}
+
+	// Neither the CA nor the client certificate.
+	client, err := NewS3Client(config)
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	client.Exist(context.Background(), "key")
+	if requests.Load() != 0 {
+		t.Fatalf("invalid request without CA")
+	}
+
+	config.CAFile = caFile
+	config.ClientCertFile, config.ClientKeyFile = certFile, keyFile
+	client, err = NewS3Client(config)
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	client.Exist(context.Background(), "key")
+	if requests.Load() != 1 {
+		t.Fatalf("invalid requests with CA and client certificate: %v", requests.Load())
+	}
+
+	oss, err := NewOSSClient(OSSConfig{
+		Endpoint:           endpoint,
+		Region:             "cn-hangzhou",
+		Bucket:             "bucket",
+		KeyID:              "id",
+		Key:                "key",
+		ClientCertFile:     certFile,
+		ClientKeyFile:      keyFile,
+		InsecureSkipVerify: "true",
+	})
+	if err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+	// The bucket is in the host, which isn't verified.
+	oss.Exist(context.Background(), "key")
+	if requests.Load() != 2 {
+		t.Fatalf("invalid requests with insecure skip verify: %v", requests.Load())
+	}
+}
+
+func TestReadStallTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write([]byte("stall"))
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	s3, err := NewS3Client(S3Config{
+		Endpoint:         endpoint,
+		Region:           "us-east-1",
+		Bucket:           "bucket",
+		PathStyleRequest: "true",
+		KeyID:            "id",
+		Key:              "key",
+		V4Signature:      "true",
+		ReadStallTimeout: "200ms",
+	})
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	oss, err := NewOSSClient(OSSConfig{
+		Endpoint:         endpoint,
+		Region:           "cn-hangzhou",
+		HTTPS:            "false",
+		Bucket:           "bucket",
+		KeyID:            "id",
+		Key:              "key",
+		ReadStallTimeout: "200ms",
+	})
+	if err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+
+	for name, client := range map[string]Client{"s3": s3, "oss": oss} {
+		r, err := client.Read(context.Background(), "key")
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", name, err)
+		}
+		_, err = io.ReadAll(r)
+		r.Close()
+		if !errors.Is(err, ErrStalled) {
+			t.Fatalf("invalid error of stalled %v read: %v", name, err)
+		}
+	}
+
+	if _, err := parseTimeouts("10", "", "", 0); err == nil {
+		t.Fatalf("invalid timeout is accepted")
+	}
+}
+
+// hostRecorder is a proxy which records the hosts of requests.
+type hostRecorder struct {
+	*httptest.Server
+	mutex sync.Mutex
+	hosts []string
+}
+
+func newHostRecorder() *hostRecorder {
+	recorder := &hostRecorder{}
+	recorder.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder.mutex.Lock()
+		recorder.hosts = append(recorder.hosts, r.Host)
+		recorder.mutex.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	return recorder
+}
+
+func (recorder *hostRecorder) take() []string {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	hosts := recorder.hosts
+	recorder.hosts = nil
+	return hosts
+}
+
+func TestAccelerate(t *testing.T) {
+	proxy := newHostRecorder()
+	defer proxy.Close()
+
+	s3, err := NewS3Client(S3Config{
+		Region:      "us-west-2",
+		Bucket:      "bucket",
+		KeyID:       "id",
+		Key:         "key",
+		V4Signature: "true",
+		ProxyURL:    proxy.URL,
+		Accelerate:  "true",
+	})
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	oss, err := NewOSSClient(OSSConfig{
+		Region:     "cn-hangzhou",
+		HTTPS:      "false",
+		Bucket:     "bucket",
+		KeyID:      "id",
+		Key:        "key",
+		ProxyURL:   proxy.URL,
+		Accelerate: "true",
+	})
+	if err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+
+	ctx := context.Background()
+	for name, test := range map[string]struct {
+		client         Client
+		accelerated    string
+		notAccelerated string
+	}{
+		"s3":  {s3, "bucket.s3-accelerate.dualstack.amazonaws.com", "bucket.s3.dualstack.us-west-2.amazonaws.com"},
+		"oss": {oss, "bucket.oss-accelerate.aliyuncs.com", "bucket.oss-cn-hangzhou.aliyuncs.com"},
+	} {
+		test.client.Info(ctx, "key")
+		test.client.List(ctx, "")
+		hosts := proxy.take()
+		if len(hosts) != 2 || hosts[0] != test.accelerated || hosts[1] != test.notAccelerated {
+			t.Fatalf("invalid hosts of %v requests: %v", name, hosts)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	proxy := newHostRecorder()
+	defer proxy.Close()
+
+	for _, test := range []struct {
+		config any
+		host   string
+	}{
+		{S3Config{Region: "us-west-2", DualStack: "false"}, "bucket.s3.us-west-2.amazonaws.com"},
+		{S3Config{Region: "us-west-2", FIPS: "true"}, "bucket.s3-fips.dualstack.us-west-2.amazonaws.com"},
+		{S3Config{Region: "us-gov-west-1", FIPS: "true", DualStack: "false"}, "bucket.s3-fips.us-gov-west-1.amazonaws.com"},
+		{S3Config{Region: "cn-north-1", DualStack: "false"}, "bucket.s3.cn-north-1.amazonaws.com.cn"},
+		{S3Config{Region: "us-gov-east-1", DualStack: "false"}, "bucket.s3.us-gov-east-1.amazonaws.com"},
+		{S3Config{Region: "eu-west-1", EndpointTemplate: "s3.{region}.example.internal"}, "bucket.s3.eu-west-1.example.internal"},
+		{OSSConfig{Region: "cn-hangzhou", DualStack: "true"}, "bucket.cn-hangzhou.oss.aliyuncs.com"},
+		{OSSConfig{Region: "cn-beijing", EndpointTemplate: "oss-{region}.example.internal"}, "bucket.oss-cn-beijing.example.internal"},
+	} {
+		var (
+			client Client
+			err    error
+		)
+		switch config := test.config.(type) {
+		case S3Config:
+			config.Bucket, config.KeyID, config.Key = "bucket", "id", "key"
+			config.V4Signature, config.ProxyURL = "true", proxy.URL
+			client, err = NewS3Client(config)
+		case OSSConfig:
+			config.Bucket, config.KeyID, config.Key = "bucket", "id", "key"
+			config.HTTPS, config.ProxyURL = "false", proxy.URL
+			client, err = NewOSSClient(config)
+		}
+		if err != nil {
+			t.Fatalf("failed to create client of %+v: %v", test.config, err)
+		}
+
+		client.Info(context.Background(), "key")
+		if hosts := proxy.take(); len(hosts) != 1 || hosts[0] != test.host {
+			t.Fatalf("invalid hosts of %+v: %v", test.config, hosts)
+		}
+	}
+
+	if _, err := NewS3Client(S3Config{Bucket: "bucket", FIPS: "true"}); err == nil {
+		t.Fatalf("fips without region is accepted")
+	}
+	if _, err := NewS3("bucket", WithEndpointTemplate("s3.{region}.example.internal")); err == nil {
+		t.Fatalf("endpoint template without region is accepted")
+	}
+}
+
+func TestDetectRegion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Has("location"):
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">eu-west-1</LocationConstraint>`))
+		case r.URL.Query().Has("bucketInfo"):
+			w.Write([]byte(`<BucketInfo><Bucket><Name>bucket</Name><Location>oss-cn-shanghai</Location></Bucket></BucketInfo>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	s3, err := NewS3("bucket", WithEndpoint(endpoint), WithHTTPS(false), WithPathStyle(true), WithKeys("id", "key"))
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	if s3.Region() != "eu-west-1" {
+		t.Fatalf("invalid detected s3 region: %v", s3.Region())
+	}
+
+	oss, err := NewOSS("bucket", WithEndpoint(endpoint), WithHTTPS(false), WithKeys("id", "key"))
+	if err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+	if oss.Region() != "cn-shanghai" {
+		t.Fatalf("invalid detected oss region: %v", oss.Region())
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	var headers atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers.Store(r.Header.Clone())
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	opts := []Option{
+		WithEndpoint(endpoint), WithRegion("us-east-1"), WithHTTPS(false), WithKeys("id", "key"),
+		WithUserAgent("gateway/1.2"), WithHeader("X-Request-Source", "gateway"),
+	}
+	s3, err := NewS3("bucket", append(opts, WithPathStyle(true))...)
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	oss, err := NewOSS("bucket", opts...)
+	if err != nil {
+		t.Fatalf("failed to create oss client: %v", err)
+	}
+
+	for name, client := range map[string]Client{"s3": s3, "oss": oss} {
+		client.Exist(context.Background(), "key")
+		header, _ := headers.Load().(http.Header)
+		if !strings.HasSuffix(header.Get("User-Agent"), " gateway/1.2") || header.Get("X-Request-Source") != "gateway" {
+			t.Fatalf("invalid headers of %v: %v", name, header)
+		}
+	}
+
+	if _, err := NewS3("bucket", WithHeader("X-Amz-Date", "now")); err == nil {
+		t.Fatalf("signed header is accepted")
+	}
+}
+
+func TestTransportTuning(t *testing.T) {
+	config := transportConfig{MaxIdleConnsPerHost: "64", IdleConnTimeout: "2m", HTTP2: "false"}
+	rt, err := newS3Transport(true, config, clientTimeouts{})
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+	transport := rt.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 64 || transport.IdleConnTimeout != 2*time.Minute || transport.TLSNextProto == nil {
+		t.Fatalf("transport isn't tuned: %v, %v", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+	for _, invalid := range []transportConfig{{MaxIdleConnsPerHost: "0"}, {IdleConnTimeout: "1"}, {HTTP2: "yes"}} {
+		if err := invalid.tune(new(http.Transport)); err == nil {
+			t.Fatalf("invalid config is accepted: %+v", invalid)
+		}
+	}
+
+	var expect atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect.Store(r.Header.Get("Expect"))
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer server.Close()
+
+	s3, err := NewS3("bucket", WithEndpoint(strings.TrimPrefix(server.URL, "http://")), WithRegion("us-east-1"),
+		WithHTTPS(false), WithPathStyle(true), WithKeys("id", "key"), WithExpectContinue(time.Second))
+	if err != nil {
+		t.Fatalf("failed to create s3 client: %v", err)
+	}
+	if err := s3.Write(context.Background(), "key", strings.NewReader("data"), &WriteOptions{Size: 4}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if expect.Load() != "100-continue" {
+		t.Fatalf("invalid Expect header %q", expect.Load())
+	}
+}
@@ -0,0 +1,228 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ExpiresAtMetadata is the metadata key of the RFC 3339 time after which
+// the object is removed by TTLEnforcer, for the rules with CheckExpiresAt.
+const ExpiresAtMetadata = "expires-at"
+
+// TTLRule is the retention policy of the objects of a prefix.
+type TTLRule struct {
+	Prefix string
+	// MaxAge removes the objects modified earlier than it, the age isn't
+	// limited if it's 0.
+	MaxAge time.Duration
+	// CheckExpiresAt removes the objects whose ExpiresAtMetadata is passed.
+	// The metadata isn't listed, so it costs an Info of each object not
+	// removed by MaxAge.
+	CheckExpiresAt bool
+}
+
+// TTLOptions are the options of NewTTLEnforcer.
+type TTLOptions struct {
+	// Interval is the interval of sweeping in background, the first sweep
+	// starts immediately. No sweeps in background if it's 0, then Sweep is
+	// called on demand.
+	Interval time.Duration
+	// BatchSize is the number of keys of each Remove, defaults to 1000.
+	BatchSize int
+	// DryRun only reports the objects which would be removed.
+	DryRun bool
+	// Logger records the expired objects of the sweeps, which are logged at
+	// the error level if they fail to be removed. Nothing is logged if it's
+	// nil.
+	Logger *slog.Logger
+	// OnSweep is called with the result of each rule swept in background,
+	// which can be exported as metrics.
+	OnSweep func(result *TTLResult, err error)
+}
+
+// TTLResult is the result of sweeping a rule.
+type TTLResult struct {
+	Prefix string
+	// Scanned is the number of objects of the prefix.
+	Scanned int
+	// Removed and RemovedBytes are the expired objects which are removed,
+	// or would be removed by dry runs.
+	Removed      int
+	RemovedBytes int64
+	Duration     time.Duration
+}
+
+// TTLEnforcer removes expired objects by the rules periodically, for the
+// backends without lifecycle rules, such as MinIO gateways and directories.
+type TTLEnforcer struct {
+	client Client
+	rules  []TTLRule
+	opts   TTLOptions
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTTLEnforcer enforces the rules on client. The options can be nil.
+// Close should be called to stop sweeping in background.
+func NewTTLEnforcer(client Client, rules []TTLRule, opts *TTLOptions) *TTLEnforcer {
+	var o TTLOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = removeBatchSize
+	}
+
+	enforcer := &TTLEnforcer{
+		client: client,
+		rules:  rules,
+		opts:   o,
+		done:   make(chan struct{}),
+	}
+	var ctx context.Context
+	ctx, enforcer.cancel = context.WithCancel(context.Background())
+	if o.Interval > 0 {
+		go enforcer.loop(ctx)
+	} else {
+		close(enforcer.done)
+	}
+	return enforcer
+}
+
+func (enforcer *TTLEnforcer) loop(ctx context.Context) {
+	defer close(enforcer.done)
+
+	ticker := time.NewTicker(enforcer.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		for _, rule := range enforcer.rules {
+			result, err := enforcer.SweepRule(ctx, rule)
+			if ctx.Err() != nil {
+				return
+			}
+			if enforcer.opts.OnSweep != nil {
+				enforcer.opts.OnSweep(result, err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep sweeps all the rules once, and returns their results. The errors of
+// the rules are joined after all are swept.
+func (enforcer *TTLEnforcer) Sweep(ctx context.Context) ([]*TTLResult, error) {
+	var (
+		results []*TTLResult
+		errs    []error
+	)
+	for _, rule := range enforcer.rules {
+		result, err := enforcer.SweepRule(ctx, rule)
+		results = append(results, result)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// SweepRule removes the expired objects of rule. The objects failed to be
+// checked are kept. The keys failed to be removed are reported by a
+// *RemoveError after the others are removed.
+func (enforcer *TTLEnforcer) SweepRule(ctx context.Context, rule TTLRule) (*TTLResult, error) {
+	start := time.Now()
+	result := &TTLResult{Prefix: rule.Prefix}
+	defer func() { result.Duration = time.Since(start) }()
+
+	items, err := enforcer.client.List(ctx, rule.Prefix)
+	if err != nil {
+		return result, fmt.Errorf("failed to list %v: %w", rule.Prefix, err)
+	}
+	result.Scanned = len(items)
+
+	var (
+		expired []ObjectItem
+		errs    []error
+	)
+	for _, item := range items {
+		ok, err := enforcer.expired(ctx, rule, item, start)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			errs = append(errs, err)
+		case ok:
+			expired = append(expired, item)
+		}
+	}
+
+	removed, removedBytes, err := removeItems(ctx, enforcer.client, expired, enforcer.opts.BatchSize, enforcer.opts.DryRun, func(item ObjectItem, err error) {
+		enforcer.audit(ctx, item, err)
+	})
+	result.Removed, result.RemovedBytes = removed, removedBytes
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return result, errors.Join(errs...)
+}
+
+func (enforcer *TTLEnforcer) expired(ctx context.Context, rule TTLRule, item ObjectItem, now time.Time) (bool, error) {
+	if rule.MaxAge > 0 && item.LastModified.Before(now.Add(-rule.MaxAge)) {
+		return true, nil
+	}
+	if !rule.CheckExpiresAt {
+		return false, nil
+	}
+
+	info, err := enforcer.client.Info(ctx, item.Key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get info of %v: %w", item.Key, err)
+	}
+	value, ok := info.Metadata[ExpiresAtMetadata]
+	if !ok {
+		return false, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %v of %v: %w", ExpiresAtMetadata, item.Key, err)
+	}
+	return expiresAt.Before(now), nil
+}
+
+func (enforcer *TTLEnforcer) audit(ctx context.Context, item ObjectItem, err error) {
+	if enforcer.opts.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("key", item.Key),
+		slog.Int64("size", item.Size),
+		slog.Time("modified", item.LastModified),
+		slog.Bool("dry_run", enforcer.opts.DryRun),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+		enforcer.opts.Logger.LogAttrs(ctx, slog.LevelError, "objclient ttl remove", attrs...)
+		return
+	}
+	enforcer.opts.Logger.LogAttrs(ctx, slog.LevelInfo, "objclient ttl remove", attrs...)
+}
+
+// Close stops sweeping and waits for the sweep in progress to be canceled.
+func (enforcer *TTLEnforcer) Close() error {
+	enforcer.cancel()
+	<-enforcer.done
+	return nil
+}
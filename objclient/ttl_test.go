@@ -0,0 +1,54 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLEnforcer(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	old := time.Now().Add(-48 * time.Hour)
+	mem.objects["logs/a"] = memObject{data: []byte("a"), modified: old}
+	mem.put("logs/b", "b")
+	mem.objects["tmp/c"] = memObject{data: []byte("c"), metadata: map[string]string{ExpiresAtMetadata: old.Format(time.RFC3339)}, modified: time.Now()}
+	mem.objects["tmp/d"] = memObject{data: []byte("d"), metadata: map[string]string{ExpiresAtMetadata: time.Now().Add(time.Hour).Format(time.RFC3339)}, modified: old}
+	mem.put("tmp/e", "e")
+
+	rules := []TTLRule{{Prefix: "logs/", MaxAge: 24 * time.Hour}, {Prefix: "tmp/", CheckExpiresAt: true}}
+	var audit bytes.Buffer
+	enforcer := NewTTLEnforcer(mem, rules, &TTLOptions{DryRun: true, Logger: slog.New(slog.NewTextHandler(&audit, nil))})
+	results, err := enforcer.Sweep(ctx)
+	if err != nil || len(results) != 2 || results[0].Scanned != 2 || results[0].Removed != 1 || results[1].Scanned != 3 || results[1].Removed != 1 {
+		t.Fatalf("invalid dry run: %+v, %v", results, err)
+	}
+	if len(mem.objects) != 5 || strings.Count(audit.String(), "dry_run=true") != 2 {
+		t.Fatalf("objects are removed by dry run: %v", audit.String())
+	}
+	enforcer.Close()
+
+	swept := make(chan *TTLResult, len(rules))
+	enforcer = NewTTLEnforcer(mem, rules, &TTLOptions{
+		Interval: time.Hour,
+		OnSweep: func(result *TTLResult, err error) {
+			if err != nil {
+				t.Errorf("failed to sweep %v: %v", result.Prefix, err)
+			}
+			swept <- result
+		},
+	})
+	defer enforcer.Close()
+	for range rules {
+		<-swept
+	}
+	if _, ok := mem.objects["logs/a"]; ok || len(mem.objects) != 3 {
+		t.Fatalf("expired objects aren't removed: %v", mem.objects)
+	}
+	if _, ok := mem.objects["tmp/c"]; ok {
+		t.Fatalf("object expired by metadata isn't removed")
+	}
+}
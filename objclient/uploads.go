@@ -0,0 +1,149 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+// listUploadsPageSize is the max number of uploads of each listing request.
+const listUploadsPageSize = 1000
+
+// MultipartUpload is a multipart upload in progress.
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// MultipartUploads is implemented by clients of backends with multipart
+// uploads, whose parts are charged until they are completed or aborted.
+type MultipartUploads interface {
+	// ListMultipartUploads returns the uploads in progress of the keys of
+	// prefix.
+	ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error)
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+func (client *S3Client) ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error) {
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	core := minio.Core{Client: backend}
+	var (
+		uploads                   []MultipartUpload
+		keyMarker, uploadIDMarker string
+	)
+	for {
+		result, err := core.ListMultipartUploads(ctx, client.bucket, prefix, keyMarker, uploadIDMarker, "", listUploadsPageSize)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		for _, upload := range result.Uploads {
+			uploads = append(uploads, MultipartUpload{Key: upload.Key, UploadID: upload.UploadID, Initiated: upload.Initiated})
+		}
+		if !result.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
+
+func (client *S3Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if err := checkKeys(client.strict, key); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	backend, err := client.backendOf(ctx)
+	if err != nil {
+		return err
+	}
+	err = minio.Core{Client: backend}.AbortMultipartUpload(ctx, client.bucket, key, uploadID)
+	return translateError(err)
+}
+
+func (client *OSSClient) ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error) {
+	var (
+		uploads                   []MultipartUpload
+		keyMarker, uploadIDMarker string
+	)
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		result, err := bucket.ListMultipartUploads(oss.Prefix(prefix), oss.KeyMarker(keyMarker), oss.UploadIDMarker(uploadIDMarker),
+			oss.MaxUploads(listUploadsPageSize), oss.WithContext(ctx))
+		if err != nil {
+			return nil, translateError(err)
+		}
+		for _, upload := range result.Uploads {
+			uploads = append(uploads, MultipartUpload{Key: upload.Key, UploadID: upload.UploadID, Initiated: upload.Initiated})
+		}
+		if !result.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
+
+func (client *OSSClient) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if err := checkKeys(client.strict, key); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	bucket, err := client.bucketOf(ctx)
+	if err != nil {
+		return err
+	}
+	imur := oss.InitiateMultipartUploadResult{Bucket: bucket.BucketName, Key: key, UploadID: uploadID}
+	return translateError(bucket.AbortMultipartUpload(imur, oss.WithContext(ctx)))
+}
+
+// AbortStaleMultipartUploads aborts the uploads of prefix initiated earlier
+// than olderThan, which are abandoned by crashed writers. The uploads
+// failed to be aborted are reported by a *MultiError keyed by upload IDs
+// after the others are aborted. It returns the uploads aborted.
+func AbortStaleMultipartUploads(ctx context.Context, client Client, prefix string, olderThan time.Duration) ([]MultipartUpload, error) {
+	uploader, ok := client.(MultipartUploads)
+	if !ok {
+		return nil, errors.New("multipart uploads aren't supported by the client")
+	}
+
+	uploads, err := uploader.ListMultipartUploads(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list multipart uploads of %v: %w", prefix, err)
+	}
+
+	deadline := time.Now().Add(-olderThan)
+	var aborted []MultipartUpload
+	errs := make(map[string]error)
+	for _, upload := range uploads {
+		if !upload.Initiated.Before(deadline) {
+			continue
+		}
+		if err := uploader.AbortMultipartUpload(ctx, upload.Key, upload.UploadID); err != nil && !errors.Is(err, ErrNotFound) {
+			if ctx.Err() != nil {
+				return aborted, ctx.Err()
+			}
+			errs[upload.UploadID] = fmt.Errorf("failed to abort upload of %v: %w", upload.Key, err)
+			continue
+		}
+		aborted = append(aborted, upload)
+	}
+	if len(errs) > 0 {
+		return aborted, &MultiError{Errors: errs}
+	}
+	return aborted, nil
+}
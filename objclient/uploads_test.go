@@ -0,0 +1,44 @@
+package objclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/haiwen/goutils/objclient/s3test"
+	"github.com/minio/minio-go/v7"
+)
+
+func TestAbortStaleMultipartUploads(t *testing.T) {
+	ctx := context.Background()
+	server := s3test.NewTLSServer("bucket")
+	defer server.Close()
+	client, err := NewS3Client(S3Config{
+		Endpoint: server.Endpoint(), Region: s3test.Region, HTTPS: "true", Bucket: "bucket",
+		PathStyleRequest: "true", KeyID: "id", Key: "key", V4Signature: "true", RootCAs: server.RootCAs(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	core := minio.Core{Client: client.(*S3Client).backend}
+	for _, key := range []string{"a/1", "a/1", "a/2", "b/1"} {
+		if _, err := core.NewMultipartUpload(ctx, "bucket", key, minio.PutObjectOptions{}); err != nil {
+			t.Fatalf("failed to create upload: %v", err)
+		}
+	}
+
+	aborted, err := AbortStaleMultipartUploads(ctx, client, "a/", time.Hour)
+	if err != nil || len(aborted) != 0 {
+		t.Fatalf("new uploads are aborted: %v, %v", aborted, err)
+	}
+	aborted, err = AbortStaleMultipartUploads(ctx, client, "a/", -time.Second)
+	if err != nil || len(aborted) != 3 || aborted[0].Key != "a/1" || aborted[2].Key != "a/2" {
+		t.Fatalf("invalid uploads aborted: %v, %v", aborted, err)
+	}
+	if uploads := server.Uploads("bucket"); len(uploads) != 1 {
+		t.Fatalf("invalid uploads left: %v", uploads)
+	}
+	if _, err := AbortStaleMultipartUploads(ctx, newMemClient(), "", 0); err == nil {
+		t.Fatalf("uploads of client without multipart uploads are aborted")
+	}
+}
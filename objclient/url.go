@@ -0,0 +1,111 @@
+package objclient
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+type URLOptions struct {
+	// Domain is a custom domain (CNAME) bound to the bucket. The bucket
+	// name is not part of the URL if it's set.
+	Domain string
+	// Scheme overrides the one derived from the HTTPS config, e.g. "http".
+	Scheme string
+}
+
+// URLBuilder is implemented by clients that can build the public URL of
+// an object. The URL is only accessible if the object is publicly readable.
+type URLBuilder interface {
+	ObjectURL(key string, opts *URLOptions) string
+}
+
+const (
+	s3AccelerateEndpoint          = "s3-accelerate.amazonaws.com"
+	s3AccelerateDualStackEndpoint = "s3-accelerate.dualstack.amazonaws.com"
+	ossAccelerateEndpoint         = "oss-accelerate.aliyuncs.com"
+)
+
+// awsDomain returns the domain of the partition of region, e.g. the one
+// of China regions.
+func awsDomain(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "amazonaws.com.cn"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "sc2s.sgov.gov"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "c2s.ic.gov"
+	}
+	// GovCloud regions are of the standard domain.
+	return "amazonaws.com"
+}
+
+func s3Endpoint(region string) string {
+	if region == "" {
+		return "s3.amazonaws.com"
+	}
+	return "s3." + region + "." + awsDomain(region)
+}
+
+// s3FIPSEndpoint returns the FIPS endpoint of region, which isn't changed
+// to the one of bucket location by minio.
+func s3FIPSEndpoint(region string, dualStack bool) string {
+	if dualStack {
+		return "s3-fips.dualstack." + region + "." + awsDomain(region)
+	}
+	return "s3-fips." + region + "." + awsDomain(region)
+}
+
+// templateEndpoint replaces {region} in template, e.g. the template
+// s3.{region}.example.internal of S3 compatible clusters.
+func templateEndpoint(template, region string) (string, error) {
+	if strings.Contains(template, "{region}") && region == "" {
+		return "", errors.New("region is required for the endpoint template")
+	}
+	return strings.ReplaceAll(template, "{region}", region), nil
+}
+
+func ossEndpoint(region string) string {
+	return "oss-" + region + ".aliyuncs.com"
+}
+
+// ossDualStackEndpoint returns the endpoint of region for both IPv4 and
+// IPv6.
+func ossDualStackEndpoint(region string) string {
+	return region + ".oss.aliyuncs.com"
+}
+
+// buildObjectURL returns the URL of key, the bucket is put in the host
+// unless pathStyle is true.
+func buildObjectURL(endpoint, bucket, key string, https, pathStyle bool, opts *URLOptions) string {
+	uri := url.URL{Scheme: "http"}
+	if https {
+		uri.Scheme = "https"
+	}
+	if opts != nil && opts.Scheme != "" {
+		uri.Scheme = opts.Scheme
+	}
+
+	switch {
+	case opts != nil && opts.Domain != "":
+		uri.Host = opts.Domain
+		uri.Path = "/" + key
+	case pathStyle:
+		uri.Host = endpoint
+		uri.Path = "/" + bucket + "/" + key
+	default:
+		uri.Host = bucket + "." + endpoint
+		uri.Path = "/" + key
+	}
+
+	return strings.ReplaceAll(uri.String(), "+", "%2B")
+}
+
+func (client *S3Client) ObjectURL(key string, opts *URLOptions) string {
+	return buildObjectURL(client.endpoint, client.bucket, key, client.https, client.pathStyle, opts)
+}
+
+func (client *OSSClient) ObjectURL(key string, opts *URLOptions) string {
+	return buildObjectURL(client.endpoint, client.bucket.BucketName, key, client.https, false, opts)
+}
@@ -0,0 +1,24 @@
+package objclient
+
+import "testing"
+
+func TestBuildObjectURL(t *testing.T) {
+	tests := []struct {
+		https     bool
+		pathStyle bool
+		opts      *URLOptions
+		expect    string
+	}{
+		{true, false, nil, "https://bucket.s3.amazonaws.com/dir/a%20b%2Bc"},
+		{false, true, nil, "http://s3.amazonaws.com/bucket/dir/a%20b%2Bc"},
+		{true, true, &URLOptions{Domain: "cdn.example.com"}, "https://cdn.example.com/dir/a%20b%2Bc"},
+		{true, false, &URLOptions{Scheme: "http"}, "http://bucket.s3.amazonaws.com/dir/a%20b%2Bc"},
+	}
+
+	for _, test := range tests {
+		u := buildObjectURL("s3.amazonaws.com", "bucket", "dir/a b+c", test.https, test.pathStyle, test.opts)
+		if u != test.expect {
+			t.Fatalf("invalid url: %v, expect %v", u, test.expect)
+		}
+	}
+}
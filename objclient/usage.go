@@ -0,0 +1,273 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PrefixUsage is the usage of a prefix reported by UsageReporter.
+type PrefixUsage struct {
+	Prefix  string
+	Objects int64
+	Bytes   int64
+	// Watermark is the start of the last listing of the prefix, the events
+	// before it are counted by the listing.
+	Watermark time.Time
+}
+
+type UsageReporterOptions struct {
+	// PrefixFunc returns the prefix of key whose usage is reported, which
+	// must be a prefix of key. By default it's the key up to and including
+	// the first "/", or empty for the keys without it.
+	PrefixFunc func(key string) string
+	// Notifications of the client update the usage incrementally if set,
+	// e.g. of EventBusClient. The usage of prefixes is only changed by
+	// the listings otherwise.
+	Notifications Notifications
+	// MaxAge is the age of the listings of prefixes after which they are
+	// listed again, defaults to 1 hour. Set it longer with notifications,
+	// which only need listings to catch the events lost.
+	MaxAge time.Duration
+	// OnError is called when receiving events failed.
+	OnError func(err error)
+}
+
+// prefixIndex is the sizes of the objects of a prefix, which make the
+// events idempotent.
+type prefixIndex struct {
+	sizes     map[string]int64
+	bytes     int64
+	watermark time.Time
+}
+
+// UsageReporter reports the number and total size of objects of prefixes
+// by listings of the prefixes, cached until MaxAge, and the events after
+// them. The sizes of the objects are kept in memory.
+type UsageReporter struct {
+	client ReadOnlyClient
+	opts   UsageReporterOptions
+
+	mutex    sync.Mutex
+	prefixes map[string]*prefixIndex
+	// listed is the watermark of the last listing of all prefixes.
+	listed time.Time
+	// pending are the events received during the listings in progress,
+	// which are applied again to the indexes listed.
+	listings int
+	pending  []Event
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewUsageReporter returns a reporter of the usage of client. The options
+// can be nil. Close should be called to stop receiving events.
+func NewUsageReporter(client ReadOnlyClient, opts *UsageReporterOptions) (*UsageReporter, error) {
+	var o UsageReporterOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.PrefixFunc == nil {
+		o.PrefixFunc = firstSegment
+	}
+	if o.MaxAge <= 0 {
+		o.MaxAge = time.Hour
+	}
+
+	reporter := &UsageReporter{
+		client:   client,
+		opts:     o,
+		prefixes: make(map[string]*prefixIndex),
+		done:     make(chan struct{}),
+	}
+	var ctx context.Context
+	ctx, reporter.cancel = context.WithCancel(context.Background())
+	if o.Notifications == nil {
+		close(reporter.done)
+		return reporter, nil
+	}
+	events, err := o.Notifications.Subscribe(ctx, "")
+	if err != nil {
+		reporter.cancel()
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	go reporter.receive(events)
+	return reporter, nil
+}
+
+func (reporter *UsageReporter) receive(events <-chan Event) {
+	defer close(reporter.done)
+	for event := range events {
+		if event.Err != nil {
+			if reporter.opts.OnError != nil {
+				reporter.opts.OnError(event.Err)
+			}
+			continue
+		}
+		reporter.apply(event)
+	}
+}
+
+// apply updates the usage of the prefix listed by event, unless the event
+// is before the listing.
+func (reporter *UsageReporter) apply(event Event) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	if reporter.listings > 0 {
+		reporter.pending = append(reporter.pending, event)
+	}
+	reporter.applyLocked(event)
+}
+
+func (reporter *UsageReporter) applyLocked(event Event) {
+	index := reporter.prefixes[reporter.opts.PrefixFunc(event.Key)]
+	if index == nil || (!event.Time.IsZero() && event.Time.Before(index.watermark)) {
+		return
+	}
+	size, ok := index.sizes[event.Key]
+	switch event.Type {
+	case EventObjectCreated:
+		index.bytes += event.Size - size
+		index.sizes[event.Key] = event.Size
+	case EventObjectCopied:
+		// The size of the source is known if it's of a listed prefix.
+		src := reporter.prefixes[reporter.opts.PrefixFunc(event.Source)]
+		if src == nil {
+			return
+		}
+		if srcSize, ok := src.sizes[event.Source]; ok {
+			index.bytes += srcSize - size
+			index.sizes[event.Key] = srcSize
+		}
+	case EventObjectRemoved:
+		if ok {
+			index.bytes -= size
+			delete(index.sizes, event.Key)
+		}
+	}
+}
+
+// list lists prefix from the watermark, the indexes of the prefixes listed
+// replace the current ones.
+func (reporter *UsageReporter) list(ctx context.Context, prefix string) error {
+	reporter.mutex.Lock()
+	reporter.listings++
+	reporter.mutex.Unlock()
+	defer func() {
+		reporter.mutex.Lock()
+		defer reporter.mutex.Unlock()
+		if reporter.listings--; reporter.listings == 0 {
+			reporter.pending = nil
+		}
+	}()
+
+	watermark := time.Now()
+	indexes := make(map[string]*prefixIndex)
+	add := func(items []ObjectItem) bool {
+		for _, item := range items {
+			p := reporter.opts.PrefixFunc(item.Key)
+			index := indexes[p]
+			if index == nil {
+				index = &prefixIndex{sizes: make(map[string]int64), watermark: watermark}
+				indexes[p] = index
+			}
+			index.sizes[item.Key] = item.Size
+			index.bytes += item.Size
+		}
+		return true
+	}
+
+	if lister, ok := AsPageLister(reporter.client); ok {
+		if err := lister.ListPages(ctx, prefix, "", add); err != nil {
+			return fmt.Errorf("failed to list %v: %w", prefix, err)
+		}
+	} else {
+		items, err := reporter.client.List(ctx, prefix)
+		if err != nil {
+			return fmt.Errorf("failed to list %v: %w", prefix, err)
+		}
+		add(items)
+	}
+
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	if prefix == "" {
+		reporter.prefixes = indexes
+		reporter.listed = watermark
+	} else {
+		index := indexes[prefix]
+		if index == nil {
+			index = &prefixIndex{sizes: make(map[string]int64), watermark: watermark}
+		}
+		reporter.prefixes[prefix] = index
+	}
+	// The events during the listing may be missed by it, they are applied
+	// again as the indexes make them idempotent.
+	for _, event := range reporter.pending {
+		reporter.applyLocked(event)
+	}
+	return nil
+}
+
+// Refresh lists all the prefixes.
+func (reporter *UsageReporter) Refresh(ctx context.Context) error {
+	return reporter.list(ctx, "")
+}
+
+// Usage returns the usage of prefix, which is listed if it isn't listed in
+// MaxAge.
+func (reporter *UsageReporter) Usage(ctx context.Context, prefix string) (*PrefixUsage, error) {
+	reporter.mutex.Lock()
+	index := reporter.prefixes[prefix]
+	stale := index == nil || time.Since(index.watermark) > reporter.opts.MaxAge
+	reporter.mutex.Unlock()
+	if stale {
+		if err := reporter.list(ctx, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	index = reporter.prefixes[prefix]
+	if index == nil {
+		return &PrefixUsage{Prefix: prefix}, nil
+	}
+	return index.usage(prefix), nil
+}
+
+func (index *prefixIndex) usage(prefix string) *PrefixUsage {
+	return &PrefixUsage{Prefix: prefix, Objects: int64(len(index.sizes)), Bytes: index.bytes, Watermark: index.watermark}
+}
+
+// Report returns the usage of all the prefixes in the order of prefixes,
+// which are listed if they aren't listed in MaxAge.
+func (reporter *UsageReporter) Report(ctx context.Context) ([]PrefixUsage, error) {
+	reporter.mutex.Lock()
+	stale := time.Since(reporter.listed) > reporter.opts.MaxAge
+	reporter.mutex.Unlock()
+	if stale {
+		if err := reporter.Refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	report := make([]PrefixUsage, 0, len(reporter.prefixes))
+	for prefix, index := range reporter.prefixes {
+		report = append(report, *index.usage(prefix))
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Prefix < report[j].Prefix })
+	return report, nil
+}
+
+// Close stops receiving events.
+func (reporter *UsageReporter) Close() error {
+	reporter.cancel()
+	<-reporter.done
+	return nil
+}
@@ -0,0 +1,97 @@
+package objclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitUsage(t *testing.T, reporter *UsageReporter, prefix string, objects, bytes int64) {
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		usage, err := reporter.Usage(ctx, prefix)
+		if err != nil {
+			t.Fatalf("failed to get usage of %v: %v", prefix, err)
+		}
+		if usage.Objects == objects && usage.Bytes == bytes {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("invalid usage %+v", usage)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUsageReporter(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	mem.put("lib1/a", "12345")
+	mem.put("lib1/dir/b", "123")
+	mem.put("lib2/a", "1")
+	mem.put("top", "12")
+	client := NewEventBusClient(&pageMemClient{memClient: mem})
+	defer client.Close()
+
+	reporter, err := NewUsageReporter(client, &UsageReporterOptions{Notifications: client})
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	defer reporter.Close()
+
+	report, err := reporter.Report(ctx)
+	if err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+	want := []PrefixUsage{{Prefix: "", Objects: 1, Bytes: 2}, {Prefix: "lib1/", Objects: 2, Bytes: 8}, {Prefix: "lib2/", Objects: 1, Bytes: 1}}
+	if len(report) != len(want) {
+		t.Fatalf("invalid report %+v", report)
+	}
+	for i, usage := range report {
+		if usage.Prefix != want[i].Prefix || usage.Objects != want[i].Objects || usage.Bytes != want[i].Bytes || usage.Watermark.IsZero() {
+			t.Fatalf("invalid usage %+v", usage)
+		}
+	}
+
+	// The mutations are applied without listings.
+	mem.put("lib3/a", "1234")
+	if err := client.Write(ctx, "lib1/a", strings.NewReader("1"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	waitUsage(t, reporter, "lib1/", 2, 4)
+	if err := client.Copy(ctx, "lib1/dir/b", "lib2/b"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	waitUsage(t, reporter, "lib2/", 2, 4)
+	if err := client.Remove(ctx, "lib1/dir/b", "lib1/missing"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	waitUsage(t, reporter, "lib1/", 1, 1)
+
+	// The prefixes unknown are listed.
+	waitUsage(t, reporter, "lib3/", 1, 4)
+	waitUsage(t, reporter, "none/", 0, 0)
+
+	// The events before the listings are counted by them.
+	reporter.apply(Event{Type: EventObjectCreated, Key: "lib2/c", Size: 10, Time: time.Now().Add(-time.Minute)})
+	waitUsage(t, reporter, "lib2/", 2, 4)
+	reporter.apply(Event{Type: EventObjectRemoved, Key: "lib2/a", Time: time.Now().Add(-time.Minute)})
+	waitUsage(t, reporter, "lib2/", 2, 4)
+}
+
+func TestUsageReporterMaxAge(t *testing.T) {
+	mem := newMemClient()
+	mem.put("lib/a", "12")
+	reporter, err := NewUsageReporter(mem, &UsageReporterOptions{MaxAge: time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	defer reporter.Close()
+
+	waitUsage(t, reporter, "lib/", 1, 2)
+	mem.put("lib/b", "123")
+	time.Sleep(5 * time.Millisecond)
+	waitUsage(t, reporter, "lib/", 2, 5)
+}
@@ -0,0 +1,185 @@
+package objclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// maxValueSize limits the objects read by GetValue, which are small state
+// objects instead of files.
+const maxValueSize = 64 << 20
+
+// The retries of UpdateJSON wait for a random delay up to the backoff, which
+// is doubled for each retry up to the max one.
+const (
+	updateBackoff    = 10 * time.Millisecond
+	maxUpdateBackoff = time.Second
+)
+
+// Codec encodes the values of GetValue and PutValue.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	// ContentType is the Content-Type of the objects written.
+	ContentType() string
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) ContentType() string                { return "application/json" }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) ContentType() string { return "application/x-gob" }
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T isn't a proto message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T isn't a proto message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (protoCodec) ContentType() string { return "application/x-protobuf" }
+
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+	// ProtoCodec encodes the values of proto.Message.
+	ProtoCodec Codec = protoCodec{}
+)
+
+// GetValue reads the object of key into v decoded by codec, and returns its
+// ETag for the conditional writes of PutValue. The objects larger than 64MiB
+// are rejected.
+func GetValue(ctx context.Context, client ReadOnlyClient, key string, v any, codec Codec) (string, error) {
+	info, err := client.Info(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if info.Size > maxValueSize {
+		return "", fmt.Errorf("object %v of %v bytes is too large", key, info.Size)
+	}
+	r, err := client.ReadWithOptions(ctx, key, &ReadOptions{IfMatch: info.ETag})
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxValueSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %v: %w", key, err)
+	}
+	if len(data) > maxValueSize {
+		return "", fmt.Errorf("object %v is too large", key)
+	}
+	if err := codec.Unmarshal(data, v); err != nil {
+		return "", fmt.Errorf("failed to decode %v: %w", key, err)
+	}
+	return info.ETag, nil
+}
+
+// PutValue writes v encoded by codec to key. The options can be nil, the
+// size and the Content-Type of the codec are set. IfMatch of the ETag
+// returned by GetValue writes it only if the object isn't changed since,
+// and IfNoneMatch "*" writes it only if it doesn't exist.
+func PutValue(ctx context.Context, client Client, key string, v any, codec Codec, o *WriteOptions) (*WriteResult, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %v: %w", key, err)
+	}
+	var wo WriteOptions
+	if o != nil {
+		wo = *o
+	}
+	wo.Size = int64(len(data))
+	if wo.ContentType == "" {
+		wo.ContentType = codec.ContentType()
+	}
+	return client.WriteWithResult(ctx, key, bytes.NewReader(data), &wo)
+}
+
+// GetJSON reads the JSON object of key into v, and returns its ETag.
+func GetJSON(ctx context.Context, client ReadOnlyClient, key string, v any) (string, error) {
+	return GetValue(ctx, client, key, v, JSONCodec)
+}
+
+// PutJSON writes v as JSON to key, the options can be nil.
+func PutJSON(ctx context.Context, client Client, key string, v any, o *WriteOptions) (*WriteResult, error) {
+	return PutValue(ctx, client, key, v, JSONCodec, o)
+}
+
+// UpdateJSON reads the JSON object of key into v, calls update with it, and
+// writes v back if the object isn't changed since, or retries it with a
+// jittered backoff until ctx is done. v is the zero value for missing
+// objects, and update is called with exist false.
+func UpdateJSON[T any](ctx context.Context, client Client, key string, update func(v *T, exist bool) error) (*T, error) {
+	backoff := updateBackoff
+	for i := 0; ; i++ {
+		if i > 0 {
+			timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff))))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+			backoff = min(backoff*2, maxUpdateBackoff)
+		}
+
+		var v T
+		etag, err := GetJSON(ctx, client, key, &v)
+		if isPreconditionFailed(err) {
+			// The object is changed between the info and the read.
+			continue
+		}
+		exist := err == nil
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		if err := update(&v, exist); err != nil {
+			return nil, err
+		}
+		o := &WriteOptions{IfMatch: etag}
+		if !exist {
+			o = &WriteOptions{IfNoneMatch: "*"}
+		}
+		_, err = PutJSON(ctx, client, key, &v, o)
+		if err == nil {
+			return &v, nil
+		}
+		if !isPreconditionFailed(err) {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestValues(t *testing.T) {
+	ctx := context.Background()
+	mem := newMemClient()
+	type state struct {
+		Name  string
+		Count int
+	}
+
+	result, err := PutJSON(ctx, mem, "state.json", &state{Name: "gc", Count: 1}, &WriteOptions{IfNoneMatch: "*"})
+	if err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	var got state
+	etag, err := GetJSON(ctx, mem, "state.json", &got)
+	if err != nil || etag != result.ETag || got != (state{Name: "gc", Count: 1}) {
+		t.Fatalf("invalid value %+v of %v: %v", got, etag, err)
+	}
+	if _, err := PutJSON(ctx, mem, "state.json", &state{}, &WriteOptions{IfNoneMatch: "*"}); !isPreconditionFailed(err) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	if _, err := PutJSON(ctx, mem, "state.json", &state{Count: 2}, &WriteOptions{IfMatch: etag}); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	if _, err := PutJSON(ctx, mem, "state.json", &state{Count: 3}, &WriteOptions{IfMatch: etag}); !isPreconditionFailed(err) {
+		t.Fatalf("expect precondition failed: %v", err)
+	}
+	if _, err := GetJSON(ctx, mem, "missing", &got); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect not found: %v", err)
+	}
+
+	if _, err := PutValue(ctx, mem, "state.gob", &state{Name: "gob"}, GobCodec, nil); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	if _, err := GetValue(ctx, mem, "state.gob", &got, GobCodec); err != nil || got.Name != "gob" {
+		t.Fatalf("invalid value %+v: %v", got, err)
+	}
+	if _, err := PutValue(ctx, mem, "state.pb", wrapperspb.String("proto"), ProtoCodec, nil); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	var message wrapperspb.StringValue
+	if _, err := GetValue(ctx, mem, "state.pb", &message, ProtoCodec); err != nil || message.Value != "proto" {
+		t.Fatalf("invalid value %v: %v", &message, err)
+	}
+	if _, err := PutValue(ctx, mem, "state.pb", &state{}, ProtoCodec, nil); err == nil {
+		t.Fatalf("invalid proto message is encoded")
+	}
+
+	// The concurrent updates aren't lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := UpdateJSON(ctx, mem, "counter.json", func(v *state, exist bool) error {
+				v.Count++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("failed to update: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := GetJSON(ctx, mem, "counter.json", &got); err != nil || got.Count != 10 {
+		t.Fatalf("invalid counter %+v: %v", got, err)
+	}
+
+	// The object changed between the info and the read is retried.
+	changed := false
+	mem.fail = func(op, key string) error {
+		if op == "Read" && !changed {
+			changed = true
+			return fmt.Errorf("%w: %v", ErrPreconditionFailed, key)
+		}
+		return nil
+	}
+	if v, err := UpdateJSON(ctx, mem, "counter.json", func(v *state, exist bool) error {
+		v.Count++
+		return nil
+	}); err != nil || v.Count != 11 {
+		t.Fatalf("invalid update %+v: %v", v, err)
+	}
+}
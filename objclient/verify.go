@@ -0,0 +1,208 @@
+package objclient
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+// VerifyOptions are the options of NewVerifyingClient.
+type VerifyOptions struct {
+	// Attempts is the number of times the object is checked after a write,
+	// defaults to 5.
+	Attempts int
+	// Backoff is the delay before the second check, which is doubled for
+	// the next ones. It defaults to 100 milliseconds.
+	Backoff time.Duration
+	// Checksum compares the MD5 of the written data with the ETag of the
+	// object, or with the object read back if the ETag isn't the MD5, e.g.
+	// of multipart uploads and encrypted objects.
+	Checksum bool
+	// OnVerified is called with the info of each object verified, as the
+	// proof of the write.
+	OnVerified func(key string, info *ObjectInfo)
+}
+
+type verifyingClient struct {
+	inner Client
+	opts  VerifyOptions
+}
+
+// NewVerifyingClient returns a client whose writes get the info of the
+// object before returning, and retry until it has the written size and
+// ETag, for backends whose reads after writes may be stale. Writes fail
+// with an error wrapping ErrNotVerified if the object isn't verified in
+// the attempts. The options can be nil.
+func NewVerifyingClient(inner Client, opts *VerifyOptions) Client {
+	o := VerifyOptions{Attempts: 5, Backoff: 100 * time.Millisecond}
+	if opts != nil {
+		if opts.Attempts > 0 {
+			o.Attempts = opts.Attempts
+		}
+		if opts.Backoff > 0 {
+			o.Backoff = opts.Backoff
+		}
+		o.Checksum = opts.Checksum
+		o.OnVerified = opts.OnVerified
+	}
+	client := &verifyingClient{inner: inner, opts: o}
+	if _, ok := AsPageLister(inner); ok {
+		return &verifyingPageClient{client}
+	}
+	return client
+}
+
+// verifyingPageClient is the verifyingClient of a PageLister.
+type verifyingPageClient struct {
+	*verifyingClient
+}
+
+func (client *verifyingPageClient) ListPages(ctx context.Context, prefix, startAfter string, fn func(items []ObjectItem) bool) error {
+	return client.inner.(PageLister).ListPages(ctx, prefix, startAfter, fn)
+}
+
+// hashingReader counts and hashes the data read. The remaining size is
+// reported by Len if it's known, so the inner client can still detect it.
+type hashingReader struct {
+	r      io.Reader
+	hash   hash.Hash
+	size   int64
+	remain int64
+}
+
+func (reader *hashingReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.hash.Write(data[:n])
+	reader.size += int64(n)
+	reader.remain -= int64(n)
+	return n, err
+}
+
+func (reader *hashingReader) Len() int {
+	return int(max(reader.remain, 0))
+}
+
+func (client *verifyingClient) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return client.inner.Read(ctx, key)
+}
+
+func (client *verifyingClient) ReadWithOptions(ctx context.Context, key string, o *ReadOptions) (io.ReadCloser, error) {
+	return client.inner.ReadWithOptions(ctx, key, o)
+}
+
+func (client *verifyingClient) Write(ctx context.Context, key string, r io.Reader, o *WriteOptions) error {
+	_, err := client.WriteWithResult(ctx, key, r, o)
+	return err
+}
+
+func (client *verifyingClient) WriteWithResult(ctx context.Context, key string, r io.Reader, o *WriteOptions) (*WriteResult, error) {
+	reader := &hashingReader{r: r, hash: md5.New()}
+	var w io.Reader = reader
+	if size, ok := writeSize(r, o); ok {
+		reader.remain = size
+	} else {
+		// Len of 0 would be taken as the size.
+		w = struct{ io.Reader }{reader}
+	}
+
+	result, err := client.inner.WriteWithResult(ctx, key, w, o)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.verify(ctx, key, reader.size, result.ETag, hex.EncodeToString(reader.hash.Sum(nil)))
+	if err != nil {
+		return nil, err
+	}
+	if result.ETag == "" {
+		result.ETag = info.ETag
+	}
+	if client.opts.OnVerified != nil {
+		client.opts.OnVerified(key, info)
+	}
+	return result, nil
+}
+
+// verify gets the info of key until it has size and etag, which is skipped
+// if it's empty. The checksum is compared if it's enabled.
+func (client *verifyingClient) verify(ctx context.Context, key string, size int64, etag, sum string) (*ObjectInfo, error) {
+	backoff := client.opts.Backoff
+	var reason error
+	for i := 0; i < client.opts.Attempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		info, err := client.inner.Info(ctx, key)
+		switch {
+		case err != nil:
+			reason = err
+		case info.Size != size:
+			reason = fmt.Errorf("size is %v instead of %v", info.Size, size)
+		case etag != "" && info.ETag != "" && !strings.EqualFold(info.ETag, etag):
+			reason = fmt.Errorf("etag is %v instead of %v", info.ETag, etag)
+		case client.opts.Checksum && !strings.EqualFold(info.ETag, sum):
+			reason = client.compareData(ctx, key, sum)
+		default:
+			reason = nil
+		}
+		if reason == nil {
+			return info, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("failed to verify write of %v: %w: %w", key, ErrNotVerified, reason)
+}
+
+// compareData reads key back and compares its MD5 with sum.
+func (client *verifyingClient) compareData(ctx context.Context, key, sum string) error {
+	r, err := client.inner.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sum {
+		return fmt.Errorf("md5 is %v instead of %v", got, sum)
+	}
+	return nil
+}
+
+func (client *verifyingClient) Exist(ctx context.Context, key string) (bool, error) {
+	return client.inner.Exist(ctx, key)
+}
+
+func (client *verifyingClient) Remove(ctx context.Context, keys ...string) error {
+	return client.inner.Remove(ctx, keys...)
+}
+
+func (client *verifyingClient) List(ctx context.Context, prefix string) ([]ObjectItem, error) {
+	return client.inner.List(ctx, prefix)
+}
+
+func (client *verifyingClient) Info(ctx context.Context, key string) (*ObjectInfo, error) {
+	return client.inner.Info(ctx, key)
+}
+
+func (client *verifyingClient) Copy(ctx context.Context, src, dst string) error {
+	return client.inner.Copy(ctx, src, dst)
+}
+
+func (client *verifyingClient) Close() error {
+	return client.inner.Close()
+}
@@ -0,0 +1,52 @@
+package objclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyingClient(t *testing.T) {
+	mem := newMemClient()
+	// The object isn't visible to the first two checks.
+	infos := 0
+	mem.fail = func(op, key string) error {
+		if op == "Info" {
+			if infos++; infos <= 2 {
+				return ErrNotFound
+			}
+		}
+		return nil
+	}
+
+	var verified *ObjectInfo
+	client := NewVerifyingClient(mem, &VerifyOptions{
+		Backoff:    time.Millisecond,
+		Checksum:   true,
+		OnVerified: func(key string, info *ObjectInfo) { verified = info },
+	})
+	result, err := client.WriteWithResult(context.Background(), "key", strings.NewReader("data"), nil)
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if infos != 3 || verified == nil || verified.Size != 4 || verified.ETag != result.ETag {
+		t.Fatalf("invalid verification: %v checks, %v", infos, verified)
+	}
+
+	// The object never gets the written data.
+	infos = 0
+	mem.fail = func(op, key string) error {
+		if op == "Info" {
+			infos++
+			mem.objects[key] = memObject{data: []byte("stale")}
+		}
+		return nil
+	}
+	client = NewVerifyingClient(mem, &VerifyOptions{Attempts: 3, Backoff: time.Millisecond})
+	err = client.Write(context.Background(), "key", strings.NewReader("data"), nil)
+	if !errors.Is(err, ErrNotVerified) || infos != 3 {
+		t.Fatalf("unverified write isn't reported: %v checks, %v", infos, err)
+	}
+}
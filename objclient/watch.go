@@ -0,0 +1,181 @@
+package objclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// WatchOptions are the options of Watch.
+type WatchOptions struct {
+	// Notifications delivers the events between the listings if it's set,
+	// e.g. an EventBusClient, or an S3Client of MinIO. The listings still
+	// find the changes whose events are missed.
+	Notifications Notifications
+	// EmitExisting delivers ObjectCreated events of the objects of the
+	// first listing, which is only the baseline of the changes otherwise.
+	EmitExisting bool
+}
+
+// watcher keeps the objects of a prefix watched.
+type watcher struct {
+	client ReadOnlyClient
+	prefix string
+	items  map[string]ObjectItem
+	ch     chan Event
+}
+
+// Watch delivers the events of the objects of prefix created, updated and
+// removed, which are found by comparing the listings by interval, 1 minute
+// if it's not positive, until ctx is done, then the returned channel is
+// closed. The failed listings are delivered as events of Err and retried by
+// the next interval. The options can be nil.
+func Watch(ctx context.Context, client ReadOnlyClient, prefix string, interval time.Duration, opts *WatchOptions) (<-chan Event, error) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	var o WatchOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	// The events are subscribed before the first listing, so the changes
+	// after it aren't missed.
+	ctx, cancel := context.WithCancel(ctx)
+	var events <-chan Event
+	if o.Notifications != nil {
+		ch, err := o.Notifications.Subscribe(ctx, prefix)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to subscribe %v: %w", prefix, err)
+		}
+		events = ch
+	}
+
+	items, err := client.List(ctx, prefix)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to list %v: %w", prefix, err)
+	}
+	w := &watcher{client: client, prefix: prefix, items: make(map[string]ObjectItem, len(items)), ch: make(chan Event)}
+	var initial []Event
+	for _, item := range items {
+		w.items[item.Key] = item
+		if o.EmitExisting {
+			initial = append(initial, itemEvent(EventObjectCreated, item))
+		}
+	}
+	go func() {
+		defer cancel()
+		w.run(ctx, interval, events, initial)
+	}()
+	return w.ch, nil
+}
+
+func itemEvent(t EventType, item ObjectItem) Event {
+	return Event{Type: t, Key: item.Key, Size: item.Size, ETag: item.ETag, Time: item.LastModified}
+}
+
+func (w *watcher) run(ctx context.Context, interval time.Duration, events <-chan Event, initial []Event) {
+	defer close(w.ch)
+	for _, event := range initial {
+		if !sendEvent(ctx, w.ch, event) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.poll(ctx) {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if !w.apply(ctx, event) {
+				return
+			}
+		}
+	}
+}
+
+// poll lists the prefix, and delivers the changes since the objects known.
+// It returns false if ctx is done.
+func (w *watcher) poll(ctx context.Context) bool {
+	items, err := w.client.List(ctx, w.prefix)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		return sendEvent(ctx, w.ch, Event{Err: fmt.Errorf("failed to list %v: %w", w.prefix, err)})
+	}
+
+	listed := make(map[string]bool, len(items))
+	for _, item := range items {
+		listed[item.Key] = true
+		if !w.update(ctx, item) {
+			return false
+		}
+	}
+	var removed []string
+	for key := range w.items {
+		if !listed[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		delete(w.items, key)
+		if !sendEvent(ctx, w.ch, Event{Type: EventObjectRemoved, Key: key, Time: time.Now()}) {
+			return false
+		}
+	}
+	return true
+}
+
+// update replaces the object of item, and delivers its event if it's new or
+// changed.
+func (w *watcher) update(ctx context.Context, item ObjectItem) bool {
+	old, ok := w.items[item.Key]
+	w.items[item.Key] = item
+	switch {
+	case !ok:
+		return sendEvent(ctx, w.ch, itemEvent(EventObjectCreated, item))
+	case itemChanged(old, item):
+		return sendEvent(ctx, w.ch, itemEvent(EventObjectUpdated, item))
+	}
+	return true
+}
+
+// apply delivers the change of the event of notifications, unless it's
+// known by the listings. The object is read by Info, since the events may
+// not carry its size or ETag, e.g. the ones of copies. It's left to the next
+// listing if Info fails.
+func (w *watcher) apply(ctx context.Context, event Event) bool {
+	if event.Err != nil {
+		return sendEvent(ctx, w.ch, event)
+	}
+	if !matchEvent(event, w.prefix, nil) {
+		return true
+	}
+	if event.Type == EventObjectRemoved {
+		if _, ok := w.items[event.Key]; !ok {
+			return true
+		}
+		delete(w.items, event.Key)
+		return sendEvent(ctx, w.ch, Event{Type: EventObjectRemoved, Key: event.Key, Time: event.Time})
+	}
+	info, err := w.client.Info(ctx, event.Key)
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	return w.update(ctx, ObjectItem{Key: event.Key, Size: info.Size, ETag: info.ETag, LastModified: info.LastModified})
+}
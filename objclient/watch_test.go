@@ -0,0 +1,89 @@
+package objclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("missing event")
+		return Event{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem := newMemClient()
+	mem.put("exports/old", "old")
+	mem.put("other", "other")
+	events, err := Watch(ctx, mem, "exports/", 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+
+	mem.put("exports/new", "new")
+	if event := nextEvent(t, events); event.Type != EventObjectCreated || event.Key != "exports/new" || event.Size != 3 {
+		t.Fatalf("invalid event %+v", event)
+	}
+	mem.put("exports/old", "updated")
+	if event := nextEvent(t, events); event.Type != EventObjectUpdated || event.Key != "exports/old" {
+		t.Fatalf("invalid event %+v", event)
+	}
+	mem.Remove(ctx, "exports/new")
+	if event := nextEvent(t, events); event.Type != EventObjectRemoved || event.Key != "exports/new" {
+		t.Fatalf("invalid event %+v", event)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchNotifications(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem := newMemClient()
+	mem.put("exports/old", "old")
+	bus := NewEventBusClient(mem)
+	events, err := Watch(ctx, bus, "exports/", time.Hour, &WatchOptions{Notifications: bus, EmitExisting: true})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	if event := nextEvent(t, events); event.Type != EventObjectCreated || event.Key != "exports/old" {
+		t.Fatalf("invalid existing event %+v", event)
+	}
+
+	// The events are delivered without listings.
+	if err := bus.Write(ctx, "exports/new", strings.NewReader("new"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if event := nextEvent(t, events); event.Type != EventObjectCreated || event.Key != "exports/new" {
+		t.Fatalf("invalid event %+v", event)
+	}
+	if err := bus.Write(ctx, "exports/new", strings.NewReader("newer"), nil); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if event := nextEvent(t, events); event.Type != EventObjectUpdated || event.Size != 5 {
+		t.Fatalf("invalid event %+v", event)
+	}
+	// The copies are reported with the sizes of the objects.
+	if err := bus.Copy(ctx, "exports/new", "exports/copy"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if event := nextEvent(t, events); event.Type != EventObjectCreated || event.Key != "exports/copy" || event.Size != 5 {
+		t.Fatalf("invalid copied event %+v", event)
+	}
+	if err := bus.Remove(ctx, "exports/old", "missing"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if event := nextEvent(t, events); event.Type != EventObjectRemoved || event.Key != "exports/old" {
+		t.Fatalf("invalid event %+v", event)
+	}
+}
@@ -0,0 +1,30 @@
+package objclient
+
+// OpClass groups the operations of a client by their kind of requests, for
+// wrappers applying different policies to them.
+type OpClass int
+
+const (
+	// OpRead includes Read, Exist and Info.
+	OpRead OpClass = iota
+	// OpWrite includes Write and Copy.
+	OpWrite
+	OpList
+	OpDelete
+)
+
+var opClasses = []OpClass{OpRead, OpWrite, OpList, OpDelete}
+
+func (class OpClass) String() string {
+	switch class {
+	case OpRead:
+		return "read"
+	case OpWrite:
+		return "write"
+	case OpList:
+		return "list"
+	case OpDelete:
+		return "delete"
+	}
+	return "unknown"
+}